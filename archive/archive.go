@@ -0,0 +1,380 @@
+// Package archive exports a whole site to a portable zip archive, and imports
+// it back into an empty or existing instance.
+//
+// An archive holds a manifest.json describing it, a site.json with the content,
+// and the media files under media/. Content refers to other records by slug,
+// email or media path rather than by ID, so it can be merged into another database.
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+	"github.com/captain-corp/captain/system"
+)
+
+// FormatVersion is the version of the archive layout. Archives of a newer
+// format are refused by Import.
+const FormatVersion = 1
+
+const (
+	manifestFile = "manifest.json"
+	siteFile     = "site.json"
+	mediaDir     = "media/"
+)
+
+// Manifest describes an archive
+type Manifest struct {
+	FormatVersion     int       `json:"formatVersion"`
+	CaptainVersion    string    `json:"captainVersion"`
+	CreatedAt         time.Time `json:"createdAt"`
+	IncludesPasswords bool      `json:"includesPasswords"`
+}
+
+// Site is the content of an archive
+type Site struct {
+	Settings  *Settings  `json:"settings"`
+	Users     []User     `json:"users"`
+	Tags      []Tag      `json:"tags"`
+	Posts     []Post     `json:"posts"`
+	Pages     []Page     `json:"pages"`
+	MenuItems []MenuItem `json:"menuItems"`
+	Media     []Media    `json:"media"`
+}
+
+type Settings struct {
+	Title        string `json:"title"`
+	Subtitle     string `json:"subtitle"`
+	ChromaStyle  string `json:"chromaStyle"`
+	Theme        string `json:"theme"`
+	PostsPerPage int    `json:"postsPerPage"`
+	Logo         string `json:"logo,omitempty"` // media path
+	UseFavicon   bool   `json:"useFavicon"`
+	RobotsTxt    string `json:"robotsTxt"`
+}
+
+type User struct {
+	Email     string    `json:"email"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Credentials, only exported on request
+	Password    string `json:"password,omitempty"` // bcrypt hash
+	TOTPSecret  string `json:"totpSecret,omitempty"`
+	TOTPEnabled bool   `json:"totpEnabled,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type Post struct {
+	Title                     string    `json:"title"`
+	Slug                      string    `json:"slug"`
+	Content                   string    `json:"content"`
+	Excerpt                   *string   `json:"excerpt,omitempty"`
+	PublishedAt               time.Time `json:"publishedAt"`
+	PublishedAtUTC            time.Time `json:"publishedAtUtc"`
+	PublishedAtTimezone       string    `json:"publishedAtTimezone"`
+	PublishedAtTimeZoneOffset int       `json:"publishedAtTimezoneOffset"`
+	Visible                   bool      `json:"visible"`
+	Tags                      []string  `json:"tags"`
+	Author                    string    `json:"author,omitempty"` // user email
+	CreatedAt                 time.Time `json:"createdAt"`
+	UpdatedAt                 time.Time `json:"updatedAt"`
+}
+
+type Page struct {
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Content     string    `json:"content"`
+	ContentType string    `json:"contentType"`
+	Visible     bool      `json:"visible"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type MenuItem struct {
+	Label    string  `json:"label"`
+	URL      *string `json:"url,omitempty"`
+	Page     *string `json:"page,omitempty"` // page slug
+	Position int     `json:"position"`
+}
+
+type Media struct {
+	Name        string     `json:"name"`
+	Path        string     `json:"path"`
+	MimeType    string     `json:"mimeType"`
+	Size        int64      `json:"size"`
+	Description string     `json:"description"`
+	AltText     string     `json:"altText,omitempty"`
+	Caption     string     `json:"caption,omitempty"`
+	Folder      string     `json:"folder,omitempty"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	TakenAt     *time.Time `json:"takenAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ExportOptions controls what Export includes
+type ExportOptions struct {
+	// IncludePasswords exports the password hashes and two-factor secrets of the users
+	IncludePasswords bool
+}
+
+// ExportReport lists what Export wrote to the archive
+type ExportReport struct {
+	Posts        int
+	Pages        int
+	Users        int
+	Media        int
+	MissingMedia []string // media whose file could not be read, left out of the archive
+}
+
+// Export writes the site to w as a zip archive. The media files are read
+// from the storage provider.
+func Export(w io.Writer, repos *repository.Repositories, store storage.Provider, opts ExportOptions) (*ExportReport, error) {
+	site, err := loadSite(repos, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ExportReport{}
+	zw := zip.NewWriter(w)
+
+	// Media files are written first, so those that cannot be read are left out of site.json
+	media := site.Media[:0]
+	written := make(map[string]bool)
+	for _, m := range site.Media {
+		// Media with the same content share their file
+		if written[m.Path] {
+			media = append(media, m)
+			continue
+		}
+		if err := writeMediaFile(zw, store, m.Path); err != nil {
+			var missing *missingMediaError
+			if errors.As(err, &missing) {
+				report.MissingMedia = append(report.MissingMedia, m.Path)
+				continue
+			}
+			return nil, err
+		}
+		written[m.Path] = true
+		media = append(media, m)
+	}
+	site.Media = media
+
+	manifest := Manifest{
+		FormatVersion:     FormatVersion,
+		CaptainVersion:    system.Version,
+		CreatedAt:         time.Now().UTC(),
+		IncludesPasswords: opts.IncludePasswords,
+	}
+	if err := writeJSON(zw, manifestFile, manifest); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(zw, siteFile, site); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	report.Posts = len(site.Posts)
+	report.Pages = len(site.Pages)
+	report.Users = len(site.Users)
+	report.Media = len(site.Media)
+
+	return report, nil
+}
+
+// missingMediaError is returned when a media file cannot be read from the storage
+type missingMediaError struct {
+	path string
+	err  error
+}
+
+func (e *missingMediaError) Error() string {
+	return fmt.Sprintf("failed to read media %s: %v", e.path, e.err)
+}
+
+func writeMediaFile(zw *zip.Writer, store storage.Provider, path string) error {
+	file, err := store.Get(path)
+	if err != nil {
+		return &missingMediaError{path: path, err: err}
+	}
+	defer file.Close()
+
+	entry, err := zw.Create(mediaDir + path)
+	if err != nil {
+		return fmt.Errorf("failed to write media %s: %w", path, err)
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("failed to write media %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	entry, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// loadSite reads the content of the site from the database
+func loadSite(repos *repository.Repositories, opts ExportOptions) (*Site, error) {
+	site := &Site{
+		Users:     []User{},
+		Tags:      []Tag{},
+		Posts:     []Post{},
+		Pages:     []Page{},
+		MenuItems: []MenuItem{},
+		Media:     []Media{},
+	}
+
+	settings, err := repos.Settings.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	site.Settings = &Settings{
+		Title:        settings.Title,
+		Subtitle:     settings.Subtitle,
+		ChromaStyle:  settings.ChromaStyle,
+		Theme:        settings.Theme,
+		PostsPerPage: settings.PostsPerPage,
+		UseFavicon:   settings.UseFavicon,
+		RobotsTxt:    settings.RobotsTxt,
+	}
+	if settings.LogoID != nil {
+		if logo, err := repos.Media.FindByID(*settings.LogoID); err == nil {
+			site.Settings.Logo = logo.Path
+		}
+	}
+
+	users, err := repos.Users.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	for _, user := range users {
+		u := User{
+			Email:     user.Email,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+		}
+		if opts.IncludePasswords {
+			u.Password = user.Password
+			u.TOTPSecret = user.TOTPSecret
+			u.TOTPEnabled = user.TOTPEnabled
+		}
+		site.Users = append(site.Users, u)
+	}
+
+	tags, err := repos.Tags.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	for _, tag := range tags {
+		site.Tags = append(site.Tags, Tag{Name: tag.Name, Slug: tag.Slug})
+	}
+
+	posts, err := repos.Posts.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load posts: %w", err)
+	}
+	for _, post := range posts {
+		p := Post{
+			Title:                     post.Title,
+			Slug:                      post.Slug,
+			Content:                   post.Content,
+			Excerpt:                   post.Excerpt,
+			PublishedAt:               post.PublishedAt,
+			PublishedAtUTC:            post.PublishedAtUTC,
+			PublishedAtTimezone:       post.PublishedAtTimezone,
+			PublishedAtTimeZoneOffset: post.PublishedAtTimeZoneOffset,
+			Visible:                   post.Visible,
+			Tags:                      []string{},
+			CreatedAt:                 post.CreatedAt,
+			UpdatedAt:                 post.UpdatedAt,
+		}
+		for _, tag := range post.Tags {
+			p.Tags = append(p.Tags, tag.Name)
+		}
+		if post.Author != nil {
+			p.Author = post.Author.Email
+		}
+		site.Posts = append(site.Posts, p)
+	}
+
+	pages, err := repos.Pages.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pages: %w", err)
+	}
+	for _, page := range pages {
+		site.Pages = append(site.Pages, Page{
+			Title:       page.Title,
+			Slug:        page.Slug,
+			Content:     page.Content,
+			ContentType: page.ContentType,
+			Visible:     page.Visible,
+			CreatedAt:   page.CreatedAt,
+			UpdatedAt:   page.UpdatedAt,
+		})
+	}
+
+	menuItems, err := repos.MenuItems.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load menu items: %w", err)
+	}
+	for _, item := range menuItems {
+		m := MenuItem{Label: item.Label, URL: item.URL, Position: item.Position}
+		if item.Page != nil {
+			m.Page = &item.Page.Slug
+		}
+		site.MenuItems = append(site.MenuItems, m)
+	}
+
+	media, err := repos.Media.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load media: %w", err)
+	}
+	for _, m := range media {
+		site.Media = append(site.Media, Media{
+			Name:        m.Name,
+			Path:        m.Path,
+			MimeType:    m.MimeType,
+			Size:        m.Size,
+			Description: m.Description,
+			AltText:     m.AltText,
+			Caption:     m.Caption,
+			Folder:      m.Folder,
+			Width:       m.Width,
+			Height:      m.Height,
+			TakenAt:     m.TakenAt,
+			CreatedAt:   m.CreatedAt,
+		})
+	}
+
+	return site, nil
+}
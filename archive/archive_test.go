@@ -0,0 +1,197 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSite struct {
+	repos *repository.Repositories
+	store storage.Provider
+}
+
+func newTestSite(t *testing.T) *testSite {
+	store, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	return &testSite{repos: repository.NewRepositories(db.SetupTestDB()), store: store}
+}
+
+// populate creates a small site with a post linking to an image
+func (s *testSite) populate(t *testing.T) {
+	author := &models.User{Email: "author@example.com", FirstName: "Ada", LastName: "Lovelace", Role: models.RoleAuthor, Password: "hash"}
+	require.NoError(t, s.repos.Users.Create(author))
+
+	path, err := s.store.Save("images/logo.png", strings.NewReader("png data"))
+	require.NoError(t, err)
+	logo := &models.Media{Name: "logo.png", Path: path, Size: 8, AltText: "Captain logo", Folder: "brand"}
+	require.NoError(t, s.repos.Media.Create(logo))
+
+	publishedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	post := &models.Post{
+		Title:          "Hello",
+		Slug:           "hello",
+		Content:        "![logo](/media/images/logo.png)",
+		PublishedAt:    publishedAt,
+		PublishedAtUTC: publishedAt,
+		Visible:        true,
+		AuthorID:       author.ID,
+	}
+	require.NoError(t, s.repos.Posts.Create(post))
+	require.NoError(t, s.repos.Posts.AssociateTags(post, []string{"Go", "News"}))
+
+	page := &models.Page{Title: "About", Slug: "about", Content: "About us", ContentType: "markdown", Visible: true}
+	require.NoError(t, s.repos.Pages.Create(page))
+	require.NoError(t, s.repos.MenuItems.Create(&models.MenuItem{Label: "About", PageID: &page.ID, Position: 1}))
+
+	settings, err := s.repos.Settings.Get()
+	require.NoError(t, err)
+	settings.Title = "Exported"
+	settings.LogoID = &logo.ID
+	require.NoError(t, s.repos.Settings.Update(settings))
+}
+
+func (s *testSite) export(t *testing.T, opts ExportOptions) *bytes.Reader {
+	var buf bytes.Buffer
+	_, err := Export(&buf, s.repos, s.store, opts)
+	require.NoError(t, err)
+	return bytes.NewReader(buf.Bytes())
+}
+
+func (s *testSite) importArchive(t *testing.T, archive *bytes.Reader, strategy Strategy) *ImportReport {
+	report, err := Import(archive, archive.Size(), s.repos, s.store, ImportOptions{Strategy: strategy})
+	require.NoError(t, err)
+	return report
+}
+
+func TestExportImport(t *testing.T) {
+	source := newTestSite(t)
+	source.populate(t)
+	archive := source.export(t, ExportOptions{})
+
+	target := newTestSite(t)
+	require.NoError(t, target.repos.Users.Create(&models.User{Email: "admin@example.com", Role: models.RoleAdmin}))
+
+	report := target.importArchive(t, archive, StrategySkip)
+	assert.Equal(t, 1, report.Posts.Created)
+	assert.Equal(t, 1, report.Pages.Created)
+	assert.Equal(t, 1, report.Media.Created)
+	assert.Equal(t, 1, report.Users.Created)
+	assert.Equal(t, 1, report.MenuItems)
+	assert.True(t, report.Settings)
+	assert.Equal(t, []string{"author@example.com"}, report.UsersWithoutPassword)
+
+	post, err := target.repos.Posts.FindBySlug("hello")
+	require.NoError(t, err)
+	assert.Equal(t, "author@example.com", post.Author.Email)
+	assert.Len(t, post.Tags, 2)
+	assert.True(t, post.PublishedAtUTC.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+
+	author, err := target.repos.Users.FindByEmail("author@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, author.Password, "password hashes are only exported on request")
+
+	file, err := target.store.Get("images/logo.png")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "png data", string(data))
+
+	logo, err := target.repos.Media.FindByPath("images/logo.png")
+	require.NoError(t, err)
+	assert.Equal(t, "Captain logo", logo.AltText)
+	assert.Equal(t, "brand", logo.Folder)
+
+	settings, err := target.repos.Settings.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "Exported", settings.Title)
+	require.NotNil(t, settings.LogoID)
+
+	menu, err := target.repos.MenuItems.FindAll()
+	require.NoError(t, err)
+	require.Len(t, menu, 1)
+	require.NotNil(t, menu[0].Page)
+	assert.Equal(t, "about", menu[0].Page.Slug)
+}
+
+func TestImportStrategies(t *testing.T) {
+	source := newTestSite(t)
+	source.populate(t)
+	archive := source.export(t, ExportOptions{IncludePasswords: true})
+
+	t.Run("skip", func(t *testing.T) {
+		report := source.importArchive(t, archive, StrategySkip)
+		assert.Equal(t, 1, report.Posts.Skipped)
+		assert.Equal(t, 1, report.Pages.Skipped)
+		assert.Equal(t, 1, report.Media.Skipped)
+		assert.Equal(t, 1, report.Users.Skipped)
+		assert.False(t, report.Settings)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		report := source.importArchive(t, archive, StrategyRename)
+		assert.Equal(t, 1, report.Posts.Renamed)
+		assert.Equal(t, 1, report.Pages.Renamed)
+		assert.Equal(t, 1, report.Media.Renamed)
+
+		post, err := source.repos.Posts.FindBySlug("hello-2")
+		require.NoError(t, err)
+		assert.Equal(t, "![logo](/media/images/logo-2.png)", post.Content)
+
+		_, err = source.repos.Pages.FindBySlug("about-2")
+		assert.NoError(t, err)
+		_, err = source.repos.Media.FindByPath("images/logo-2.png")
+		assert.NoError(t, err)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		post, err := source.repos.Posts.FindBySlug("hello")
+		require.NoError(t, err)
+		post.Title = "Changed"
+		require.NoError(t, source.repos.Posts.Update(post))
+
+		report := source.importArchive(t, archive, StrategyOverwrite)
+		assert.Equal(t, 1, report.Posts.Updated)
+		assert.True(t, report.Settings)
+
+		post, err = source.repos.Posts.FindBySlug("hello")
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", post.Title)
+		assert.Len(t, post.Tags, 2)
+
+		author, err := source.repos.Users.FindByEmail("author@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "hash", author.Password)
+	})
+}
+
+func TestImportRejectsInvalidArchives(t *testing.T) {
+	site := newTestSite(t)
+
+	_, err := Import(strings.NewReader("not a zip"), 9, site.repos, site.store, ImportOptions{})
+	assert.Error(t, err)
+
+	assert.False(t, validMediaPath("../config.yaml"))
+	assert.False(t, validMediaPath("/etc/passwd"))
+	assert.False(t, validMediaPath("images/../../secret"))
+	assert.True(t, validMediaPath("images/logo.png"))
+}
+
+func TestParseStrategy(t *testing.T) {
+	strategy, err := ParseStrategy("rename")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategyRename, strategy)
+
+	_, err = ParseStrategy("merge")
+	assert.Error(t, err)
+}
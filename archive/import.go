@@ -0,0 +1,705 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"gorm.io/gorm"
+)
+
+// Strategy decides what happens to an imported post, page or media file whose
+// slug or path already exists
+type Strategy string
+
+const (
+	// StrategySkip keeps the existing record and ignores the imported one
+	StrategySkip Strategy = "skip"
+	// StrategyOverwrite replaces the existing record with the imported one
+	StrategyOverwrite Strategy = "overwrite"
+	// StrategyRename imports the record under a new slug or path
+	StrategyRename Strategy = "rename"
+)
+
+// Strategies lists the available conflict strategies
+var Strategies = []Strategy{StrategySkip, StrategyOverwrite, StrategyRename}
+
+// ParseStrategy returns the strategy with the given name
+func ParseStrategy(name string) (Strategy, error) {
+	for _, strategy := range Strategies {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid conflict strategy %q, expected skip, overwrite or rename", name)
+}
+
+// ImportOptions controls how Import merges the archive into the site
+type ImportOptions struct {
+	Strategy Strategy
+	// DefaultAuthor is the author of the imported posts whose author is unknown.
+	// Defaults to the first admin.
+	DefaultAuthor *models.User
+}
+
+// ImportCounts counts the imported records of a kind
+type ImportCounts struct {
+	Created int
+	Updated int
+	Renamed int
+	Skipped int
+}
+
+func (c ImportCounts) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d renamed, %d skipped", c.Created, c.Updated, c.Renamed, c.Skipped)
+}
+
+// ImportReport summarizes what Import changed
+type ImportReport struct {
+	Posts     ImportCounts
+	Pages     ImportCounts
+	Media     ImportCounts
+	Users     ImportCounts
+	Tags      ImportCounts
+	MenuItems int
+	Settings  bool
+
+	// UsersWithoutPassword lists the created users who must be given a password to log in
+	UsersWithoutPassword []string
+}
+
+func (r *ImportReport) String() string {
+	lines := []string{
+		"Posts: " + r.Posts.String(),
+		"Pages: " + r.Pages.String(),
+		"Media: " + r.Media.String(),
+		"Users: " + r.Users.String(),
+		"Tags: " + r.Tags.String(),
+		fmt.Sprintf("Menu items: %d imported", r.MenuItems),
+	}
+	if r.Settings {
+		lines = append(lines, "Settings: imported")
+	} else {
+		lines = append(lines, "Settings: kept")
+	}
+	if len(r.UsersWithoutPassword) > 0 {
+		lines = append(lines, "Users without password: "+strings.Join(r.UsersWithoutPassword, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// importer holds the state of an import
+type importer struct {
+	repos  *repository.Repositories
+	store  storage.Provider
+	opts   ImportOptions
+	files  map[string]*zip.File
+	report *ImportReport
+
+	mediaPaths map[string]string        // archive media path to imported path, when they differ
+	mediaFiles map[string]*models.Media // archive media path to the first media imported with its file
+	pageIDs    map[string]uint          // archive page slug to imported page ID
+	userIDs    map[string]uint          // email to user ID
+}
+
+// Import restores an archive written by Export. Tags and users are merged by
+// name and email, and conflicting posts, pages and media are resolved with the
+// strategy of the options. The settings and menu are only replaced when the
+// site has no posts and pages yet, or with the overwrite strategy.
+//
+// Import is not atomic: the database records and media files are written one
+// after the other. Importing the same archive again with the skip strategy
+// completes an interrupted import.
+func Import(r io.ReaderAt, size int64, repos *repository.Repositories, store storage.Provider, opts ImportOptions) (*ImportReport, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = StrategySkip
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	imp := &importer{
+		repos:      repos,
+		store:      store,
+		opts:       opts,
+		files:      make(map[string]*zip.File, len(zr.File)),
+		report:     &ImportReport{},
+		mediaPaths: make(map[string]string),
+		mediaFiles: make(map[string]*models.Media),
+		pageIDs:    make(map[string]uint),
+		userIDs:    make(map[string]uint),
+	}
+	for _, file := range zr.File {
+		imp.files[file.Name] = file
+	}
+
+	var manifest Manifest
+	if err := imp.readJSON(manifestFile, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format %d, this version of Captain reads up to format %d", manifest.FormatVersion, FormatVersion)
+	}
+
+	var site Site
+	if err := imp.readJSON(siteFile, &site); err != nil {
+		return nil, err
+	}
+
+	// An empty site takes the settings and menu of the archive
+	posts, err := repos.Posts.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := repos.Pages.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	replaceSite := opts.Strategy == StrategyOverwrite || (len(posts) == 0 && len(pages) == 0)
+
+	steps := []func(*Site) error{
+		imp.importMedia,
+		imp.importUsers,
+		imp.importTags,
+		imp.importPosts,
+		imp.importPages,
+	}
+	if replaceSite {
+		steps = append(steps, imp.importMenu, imp.importSettings)
+	}
+	for _, step := range steps {
+		if err := step(&site); err != nil {
+			return imp.report, err
+		}
+	}
+
+	return imp.report, nil
+}
+
+func (imp *importer) readJSON(name string, v interface{}) error {
+	file, ok := imp.files[name]
+	if !ok {
+		return fmt.Errorf("invalid archive: %s is missing", name)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("invalid archive: failed to parse %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// validMediaPath returns false for paths escaping the storage directory
+func validMediaPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && !strings.HasPrefix(p, `\`) &&
+		path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+func (imp *importer) importMedia(site *Site) error {
+	for _, m := range site.Media {
+		if !validMediaPath(m.Path) {
+			return fmt.Errorf("invalid archive: invalid media path %q", m.Path)
+		}
+
+		file, ok := imp.files[mediaDir+m.Path]
+		if !ok {
+			return fmt.Errorf("invalid archive: the file of media %s is missing", m.Path)
+		}
+
+		// Media with the same content share the file imported with the first of them
+		if first, ok := imp.mediaFiles[m.Path]; ok {
+			media := &models.Media{Path: first.Path, Hash: first.Hash}
+			media.CreatedAt = m.CreatedAt
+			m.apply(media)
+			if err := imp.repos.Media.Create(media); err != nil {
+				return fmt.Errorf("failed to import media %s: %w", m.Path, err)
+			}
+			imp.report.Media.Created++
+			continue
+		}
+
+		existing, err := imp.repos.Media.FindByPath(m.Path)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		media := &models.Media{}
+		filename := m.Path
+		if existing != nil {
+			switch imp.opts.Strategy {
+			case StrategySkip:
+				imp.report.Media.Skipped++
+				continue
+			case StrategyOverwrite:
+				media = existing
+			case StrategyRename:
+				if filename, err = imp.uniqueMediaPath(m.Path); err != nil {
+					return err
+				}
+			}
+		}
+
+		savedPath, hash, err := imp.saveMediaFile(file, filename)
+		if err != nil {
+			return err
+		}
+		if savedPath != m.Path {
+			imp.mediaPaths[m.Path] = savedPath
+		}
+
+		// The resized variants of the previous file are outdated
+		if media.ID != 0 {
+			if err := imp.deleteMediaVariants(media); err != nil {
+				return err
+			}
+		}
+
+		// Providers may store the file under another name, the previous one is then orphaned
+		if media.ID != 0 && media.Path != savedPath {
+			if err := mediastore.Release(imp.repos, imp.store, media); err != nil {
+				return err
+			}
+		}
+
+		media.Path = savedPath
+		m.apply(media)
+		media.Hash = hash
+
+		switch {
+		case media.ID != 0:
+			err = imp.repos.Media.Update(media)
+			imp.report.Media.Updated++
+		default:
+			media.CreatedAt = m.CreatedAt
+			err = imp.repos.Media.Create(media)
+			if existing != nil {
+				imp.report.Media.Renamed++
+			} else {
+				imp.report.Media.Created++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import media %s: %w", m.Path, err)
+		}
+		imp.mediaFiles[m.Path] = media
+	}
+
+	return nil
+}
+
+// apply copies the metadata of an archived media to a media
+func (m *Media) apply(media *models.Media) {
+	media.Name = m.Name
+	media.MimeType = m.MimeType
+	media.Size = m.Size
+	media.Description = m.Description
+	media.AltText = m.AltText
+	media.Caption = m.Caption
+	media.Folder = m.Folder
+	media.Width = m.Width
+	media.Height = m.Height
+	media.TakenAt = m.TakenAt
+}
+
+// deleteMediaVariants deletes the resized variants of a media
+func (imp *importer) deleteMediaVariants(media *models.Media) error {
+	variants, err := imp.repos.MediaVariants.FindByMedia(media.ID)
+	if err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		if err := imp.store.Delete(variant.Path); err != nil {
+			return err
+		}
+		if err := imp.repos.MediaVariants.Delete(variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveMediaFile copies a media file of the archive to the storage, and returns
+// its path and the hash of its content. Media keep the path of the archive, as
+// the content links to them.
+func (imp *importer) saveMediaFile(file *zip.File, filename string) (string, string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read media %s: %w", filename, err)
+	}
+	defer reader.Close()
+
+	// S3 uploads need a seekable body, so the file is spooled rather than
+	// held in memory
+	spooled, err := os.CreateTemp("", "captain-import-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		spooled.Close()
+		os.Remove(spooled.Name())
+	}()
+
+	hash, _, err := mediastore.Hash(io.TeeReader(reader, spooled))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read media %s: %w", filename, err)
+	}
+	if _, err := spooled.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	savedPath, err := imp.store.Save(filename, spooled)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save media %s: %w", filename, err)
+	}
+
+	return savedPath, hash, nil
+}
+
+func (imp *importer) uniqueMediaPath(p string) (string, error) {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := imp.repos.Media.FindByPath(candidate); errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// rewriteMediaLinks updates the links to the media imported under another path
+func (imp *importer) rewriteMediaLinks(content string) string {
+	for oldPath, newPath := range imp.mediaPaths {
+		content = strings.ReplaceAll(content, "/media/"+oldPath, "/media/"+newPath)
+	}
+	return content
+}
+
+func (imp *importer) importUsers(site *Site) error {
+	for _, u := range site.Users {
+		if u.Email == "" {
+			continue
+		}
+		if !models.IsValidRole(u.Role) {
+			return fmt.Errorf("invalid archive: user %s has an invalid role %q", u.Email, u.Role)
+		}
+
+		user, err := imp.repos.Users.FindByEmail(u.Email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if user != nil {
+			imp.userIDs[u.Email] = user.ID
+			if imp.opts.Strategy != StrategyOverwrite {
+				imp.report.Users.Skipped++
+				continue
+			}
+
+			user.FirstName = u.FirstName
+			user.LastName = u.LastName
+			user.Role = u.Role
+			if u.Password != "" {
+				user.Password = u.Password
+				user.TOTPSecret = u.TOTPSecret
+				user.TOTPEnabled = u.TOTPEnabled
+			}
+			if err := imp.repos.Users.Update(user); err != nil {
+				return fmt.Errorf("failed to import user %s: %w", u.Email, err)
+			}
+			imp.report.Users.Updated++
+			continue
+		}
+
+		user = &models.User{
+			Email:       u.Email,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+			Role:        u.Role,
+			Password:    u.Password,
+			TOTPSecret:  u.TOTPSecret,
+			TOTPEnabled: u.TOTPEnabled,
+		}
+		user.CreatedAt = u.CreatedAt
+		if err := imp.repos.Users.Create(user); err != nil {
+			return fmt.Errorf("failed to import user %s: %w", u.Email, err)
+		}
+		imp.userIDs[u.Email] = user.ID
+		imp.report.Users.Created++
+		if u.Password == "" {
+			imp.report.UsersWithoutPassword = append(imp.report.UsersWithoutPassword, u.Email)
+		}
+	}
+
+	return nil
+}
+
+func (imp *importer) importTags(site *Site) error {
+	for _, t := range site.Tags {
+		if _, err := imp.repos.Tags.FindBySlug(t.Slug); err == nil {
+			imp.report.Tags.Skipped++
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if _, err := imp.repos.Tags.FindByName(t.Name); err == nil {
+			imp.report.Tags.Skipped++
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := imp.repos.Tags.Create(&models.Tag{Name: t.Name, Slug: t.Slug}); err != nil {
+			return fmt.Errorf("failed to import tag %s: %w", t.Name, err)
+		}
+		imp.report.Tags.Created++
+	}
+
+	return nil
+}
+
+// defaultAuthorID returns the author of the posts whose author is unknown
+func (imp *importer) defaultAuthorID() (uint, error) {
+	if imp.opts.DefaultAuthor != nil {
+		return imp.opts.DefaultAuthor.ID, nil
+	}
+
+	users, err := imp.repos.Users.FindAll()
+	if err != nil {
+		return 0, err
+	}
+	for _, user := range users {
+		if user.Role == models.RoleAdmin {
+			imp.opts.DefaultAuthor = user
+			return user.ID, nil
+		}
+	}
+
+	return 0, errors.New("no admin to attribute the posts of unknown authors to, create a user first")
+}
+
+func (imp *importer) importPosts(site *Site) error {
+	for _, p := range site.Posts {
+		existing, err := imp.repos.Posts.FindBySlug(p.Slug)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		post := &models.Post{}
+		slug := p.Slug
+		if existing != nil {
+			switch imp.opts.Strategy {
+			case StrategySkip:
+				imp.report.Posts.Skipped++
+				continue
+			case StrategyOverwrite:
+				post = existing
+				post.Author = nil
+			case StrategyRename:
+				if slug, err = uniqueSlug(p.Slug, func(s string) error {
+					_, err := imp.repos.Posts.FindBySlug(s)
+					return err
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		authorID, ok := imp.userIDs[p.Author]
+		if !ok {
+			if authorID, err = imp.defaultAuthorID(); err != nil {
+				return err
+			}
+		}
+
+		excerpt := p.Excerpt
+		if excerpt != nil {
+			rewritten := imp.rewriteMediaLinks(*excerpt)
+			excerpt = &rewritten
+		}
+
+		post.Title = p.Title
+		post.Slug = slug
+		post.Content = imp.rewriteMediaLinks(p.Content)
+		post.Excerpt = excerpt
+		post.PublishedAt = p.PublishedAt
+		post.PublishedAtUTC = p.PublishedAtUTC
+		post.PublishedAtTimezone = p.PublishedAtTimezone
+		post.PublishedAtTimeZoneOffset = p.PublishedAtTimeZoneOffset
+		post.Visible = p.Visible
+		post.AuthorID = authorID
+		post.CreatedAt = p.CreatedAt
+		post.UpdatedAt = p.UpdatedAt
+		post.Tags = nil
+
+		if post.ID != 0 {
+			err = imp.repos.Posts.Update(post)
+			imp.report.Posts.Updated++
+		} else {
+			err = imp.repos.Posts.Create(post)
+			if existing != nil {
+				imp.report.Posts.Renamed++
+			} else {
+				imp.report.Posts.Created++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import post %s: %w", p.Slug, err)
+		}
+
+		if err := imp.repos.Posts.AssociateTags(post, p.Tags); err != nil {
+			return fmt.Errorf("failed to tag post %s: %w", p.Slug, err)
+		}
+	}
+
+	return nil
+}
+
+func (imp *importer) importPages(site *Site) error {
+	for _, p := range site.Pages {
+		existing, err := imp.repos.Pages.FindBySlug(p.Slug)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		page := &models.Page{}
+		slug := p.Slug
+		if existing != nil {
+			switch imp.opts.Strategy {
+			case StrategySkip:
+				imp.pageIDs[p.Slug] = existing.ID
+				imp.report.Pages.Skipped++
+				continue
+			case StrategyOverwrite:
+				page = existing
+			case StrategyRename:
+				if slug, err = uniqueSlug(p.Slug, func(s string) error {
+					_, err := imp.repos.Pages.FindBySlug(s)
+					return err
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		page.Title = p.Title
+		page.Slug = slug
+		page.Content = imp.rewriteMediaLinks(p.Content)
+		page.ContentType = p.ContentType
+		page.Visible = p.Visible
+		page.CreatedAt = p.CreatedAt
+		page.UpdatedAt = p.UpdatedAt
+
+		if page.ID != 0 {
+			err = imp.repos.Pages.Update(page)
+			imp.report.Pages.Updated++
+		} else {
+			err = imp.repos.Pages.Create(page)
+			if existing != nil {
+				imp.report.Pages.Renamed++
+			} else {
+				imp.report.Pages.Created++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import page %s: %w", p.Slug, err)
+		}
+
+		imp.pageIDs[p.Slug] = page.ID
+	}
+
+	return nil
+}
+
+// uniqueSlug returns the first of slug-2, slug-3... for which find returns gorm.ErrRecordNotFound
+func uniqueSlug(slug string, find func(string) error) (string, error) {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", slug, i)
+		if err := find(candidate); errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (imp *importer) importMenu(site *Site) error {
+	if err := imp.repos.MenuItems.DeleteAll(); err != nil {
+		return fmt.Errorf("failed to replace the menu: %w", err)
+	}
+
+	for _, m := range site.MenuItems {
+		item := &models.MenuItem{Label: m.Label, URL: m.URL, Position: m.Position}
+		if m.Page != nil {
+			pageID, ok := imp.pageIDs[*m.Page]
+			if !ok {
+				continue
+			}
+			item.PageID = &pageID
+		}
+
+		if err := imp.repos.MenuItems.Create(item); err != nil {
+			return fmt.Errorf("failed to import menu item %s: %w", m.Label, err)
+		}
+		imp.report.MenuItems++
+	}
+
+	return nil
+}
+
+func (imp *importer) importSettings(site *Site) error {
+	if site.Settings == nil {
+		return nil
+	}
+
+	settings, err := imp.repos.Settings.Get()
+	if err != nil {
+		return err
+	}
+
+	settings.Title = site.Settings.Title
+	settings.Subtitle = site.Settings.Subtitle
+	settings.ChromaStyle = site.Settings.ChromaStyle
+	settings.Theme = site.Settings.Theme
+	settings.PostsPerPage = site.Settings.PostsPerPage
+	settings.UseFavicon = site.Settings.UseFavicon
+	settings.RobotsTxt = site.Settings.RobotsTxt
+	settings.LogoID = nil
+
+	if site.Settings.Logo != "" {
+		logoPath := site.Settings.Logo
+		if newPath, ok := imp.mediaPaths[logoPath]; ok {
+			logoPath = newPath
+		}
+		if logo, err := imp.repos.Media.FindByPath(logoPath); err == nil {
+			settings.LogoID = &logo.ID
+		}
+	}
+
+	if err := imp.repos.Settings.Update(settings); err != nil {
+		return fmt.Errorf("failed to import settings: %w", err)
+	}
+	imp.report.Settings = true
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/captain-corp/captain/archive"
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+// openSite loads the config, database and storage used by the export and import commands
+func openSite() (*repository.Repositories, storage.Provider) {
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	if err := cfg.ValidateStorageConfig(); err != nil {
+		log.Fatalf("Storage configuration error: %v", err)
+	}
+
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	return repository.NewRepositories(database), store
+}
+
+func ExportSite(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	withPasswords, _ := cmd.Flags().GetBool("with-passwords")
+	if output == "" {
+		output = fmt.Sprintf("captain-export-%s.zip", time.Now().Format("20060102-150405"))
+	}
+
+	repos, store := openSite()
+
+	file, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", output, err)
+	}
+
+	report, err := archive.Export(file, repos, store, archive.ExportOptions{IncludePasswords: withPasswords})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	for _, path := range report.MissingMedia {
+		log.Warnf("Media %s left out of the archive: its file could not be read", path)
+	}
+	fmt.Printf("Exported %d posts, %d pages, %d users and %d media files to %s\n", report.Posts, report.Pages, report.Users, report.Media, output)
+	if withPasswords {
+		fmt.Println("The archive contains password hashes and two-factor secrets, keep it safe")
+	}
+}
+
+func ImportSite(cmd *cobra.Command, args []string) {
+	onConflict, _ := cmd.Flags().GetString("on-conflict")
+	strategy, err := archive.ParseStrategy(onConflict)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Failed to read archive: %v", err)
+	}
+
+	repos, store := openSite()
+
+	report, err := archive.Import(file, info.Size(), repos, store, archive.ImportOptions{Strategy: strategy})
+	if report != nil {
+		fmt.Println(report)
+	}
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	if len(report.UsersWithoutPassword) > 0 {
+		fmt.Println("Users without password cannot log in until an admin sets their password")
+	}
+}
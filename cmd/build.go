@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/server"
+	"github.com/captain-corp/captain/staticsite"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+// BuildSite returns the command writing the site as static files, rendered
+// with the templates of embeddedFS
+func BuildSite(embeddedFS embed.FS) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		outDir, _ := cmd.Flags().GetString("out")
+		baseURL, _ := cmd.Flags().GetString("base-url")
+		links, _ := cmd.Flags().GetString("links")
+
+		linkMode, err := staticsite.ParseLinkMode(links)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cfg, err := config.InitConfig()
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		if baseURL == "" && cfg.Site.Domain != "" {
+			scheme := "http"
+			if cfg.Site.SecureCookie {
+				scheme = "https"
+			}
+			baseURL = scheme + "://" + strings.TrimPrefix(cfg.Site.Domain, ".")
+		}
+		if baseURL == "" {
+			log.Fatal("No base URL, set site.domain in the config or use --base-url")
+		}
+		// Pages are rendered with the URLs of the build, rewritten to the base URL
+		cfg.Site.Domain = ""
+
+		database, err := db.New(cfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+
+		srv, err := server.New(database, cfg, embeddedFS)
+		if err != nil {
+			log.Fatalf("Failed to initialize server: %v", err)
+		}
+
+		report, err := staticsite.Build(srv.App(), srv.StaticFS(), repository.NewRepositories(database), srv.Storage(), staticsite.Options{
+			OutDir:  outDir,
+			BaseURL: baseURL,
+			Links:   linkMode,
+		})
+		if report != nil {
+			fmt.Println(report)
+		}
+		if err != nil {
+			log.Fatalf("Build failed: %v", err)
+		}
+		fmt.Printf("Site written to %s\n", outDir)
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/db"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// connectDB connects to the configured database without migrating it
+func connectDB() *gorm.DB {
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+
+	return database
+}
+
+// printMigrationRuns prints the migrations run, and their SQL statements for dry runs
+func printMigrationRuns(runs []db.MigrationRun, action string, dryRun bool) {
+	for _, run := range runs {
+		if !dryRun {
+			fmt.Printf("%s migration %d (%s)\n", action, run.Migration.Version, run.Migration.Name)
+			continue
+		}
+
+		fmt.Printf("-- %d %s\n", run.Migration.Version, run.Migration.Name)
+		for _, statement := range run.Statements {
+			fmt.Printf("%s;\n", statement)
+		}
+		fmt.Println()
+	}
+}
+
+func MigrateDB(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	database := connectDB()
+
+	runs, err := db.Migrate(database, dryRun)
+	printMigrationRuns(runs, "Applied", dryRun)
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("The database schema is up to date")
+	} else if dryRun && len(runs) > 1 {
+		fmt.Println("-- Each migration is planned against the current schema, without the changes of the previous ones")
+	}
+}
+
+func RollbackDB(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	steps, _ := cmd.Flags().GetInt("steps")
+	if steps < 1 {
+		log.Fatalf("Invalid number of steps %d, expected at least 1", steps)
+	}
+
+	database := connectDB()
+
+	runs, err := db.Rollback(database, steps, dryRun)
+	printMigrationRuns(runs, "Rolled back", dryRun)
+	if err != nil {
+		log.Fatalf("Rollback failed: %v", err)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No migration to roll back")
+	}
+}
+
+func ShowDBStatus(cmd *cobra.Command, args []string) {
+	database := connectDB()
+
+	statuses, err := db.Status(database)
+	if err != nil {
+		log.Fatalf("Failed to read the migration status: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		if status.Unknown {
+			state += " (unknown to this version of Captain)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	w.Flush()
+
+	if err := db.CheckSchemaVersion(database); err != nil {
+		log.Warn(err)
+	}
+}
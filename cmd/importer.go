@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/captain-corp/captain/archive"
+	"github.com/captain-corp/captain/importer"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+func ImportWordPress(cmd *cobra.Command, args []string) {
+	timezone, _ := cmd.Flags().GetString("timezone")
+	importBlog(cmd, args[0], func(file *os.File) (*importer.Blog, error) {
+		return importer.ParseWordPress(file, timezone)
+	})
+}
+
+func ImportGhost(cmd *cobra.Command, args []string) {
+	siteURL, _ := cmd.Flags().GetString("url")
+	importBlog(cmd, args[0], func(file *os.File) (*importer.Blog, error) {
+		return importer.ParseGhost(file, siteURL)
+	})
+}
+
+// importBlog parses the export file of another blog platform and imports it
+func importBlog(cmd *cobra.Command, filename string, parse func(*os.File) (*importer.Blog, error)) {
+	onConflict, _ := cmd.Flags().GetString("on-conflict")
+	uploads, _ := cmd.Flags().GetString("uploads")
+	keepHTML, _ := cmd.Flags().GetBool("keep-html")
+
+	strategy, err := archive.ParseStrategy(onConflict)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Failed to open export: %v", err)
+	}
+	blog, err := parse(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repos, store := openSite()
+
+	report, err := importer.Import(blog, repos, store, importer.Options{
+		Strategy:   strategy,
+		UploadsDir: uploads,
+		KeepHTML:   keepHTML,
+	})
+	if report != nil {
+		fmt.Println(report)
+	}
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	if len(report.UsersWithoutPassword) > 0 {
+		fmt.Println("Users without password cannot log in until an admin sets their password")
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/captain-corp/captain/mediacheck"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+func CheckMedia(cmd *cobra.Command, args []string) {
+	names, _ := cmd.Flags().GetStringSlice("fix")
+	fixes, err := mediacheck.ParseFixes(names)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repos, store := openSite()
+
+	report, err := mediacheck.Check(repos, store, mediacheck.Options{Fixes: fixes})
+	if err != nil {
+		log.Fatalf("Check failed: %v", err)
+	}
+	fmt.Println(report)
+
+	// Like fsck, differences left unrepaired are reported by the exit status
+	if len(report.Failed) > 0 || !report.OK() {
+		os.Exit(1)
+	}
+}
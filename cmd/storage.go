@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/mediamigrate"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+func MigrateStorage(cmd *cobra.Command, args []string) {
+	fromName, _ := cmd.Flags().GetString("from")
+	toName, _ := cmd.Flags().GetString("to")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if fromName == "" {
+		fromName = cfg.Storage.Provider
+	}
+	if fromName == "" {
+		fromName = "local"
+	}
+	if fromName == toName {
+		log.Fatalf("Files are already stored on %s", toName)
+	}
+
+	database, err := db.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	from := openProvider(cfg, fromName)
+	to := openProvider(cfg, toName)
+
+	report, err := mediamigrate.Migrate(repository.NewRepositories(database), from, to, mediamigrate.Options{
+		DryRun: dryRun,
+		Progress: func(done, total int, file *mediamigrate.File) {
+			fmt.Printf("[%d/%d] %s %s (%d bytes)\n", done, total, file.Status, file.Path, file.Size)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	fmt.Println(report)
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+	if !dryRun && cfg.Storage.Provider != toName {
+		fmt.Printf("Set storage.provider to %q and restart Captain, then run the migration again to copy the files uploaded meanwhile\n", toName)
+	}
+}
+
+// openProvider creates a storage provider of the config, whether it is the
+// selected one or not
+func openProvider(cfg *config.Config, name string) storage.Provider {
+	if err := cfg.ValidateStorageProvider(name); err != nil {
+		log.Fatalf("Storage configuration error: %v", err)
+	}
+	provider, err := storage.NewProvider(cfg, name)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	return provider
+}
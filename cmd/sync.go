@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/contentsync"
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/repository"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/cobra"
+)
+
+func SyncContent(cmd *cobra.Command, args []string) {
+	dir, _ := cmd.Flags().GetString("dir")
+	export, _ := cmd.Flags().GetBool("export")
+
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if dir == "" {
+		dir = cfg.Content.Dir
+	}
+	if dir == "" {
+		log.Fatal("No content directory, set content.dir in the config or use --dir")
+	}
+
+	database, err := db.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	syncer := contentsync.New(dir, repository.NewRepositories(database))
+
+	var report *contentsync.Report
+	if export {
+		report, err = syncer.Export()
+	} else {
+		report, err = syncer.Import()
+	}
+	if report != nil {
+		fmt.Println(report)
+	}
+	if err != nil {
+		log.Fatalf("Sync failed: %v", err)
+	}
+	if len(report.Failed) > 0 {
+		log.Fatalf("%d files could not be synced", len(report.Failed))
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 
 	"github.com/captain-corp/captain/config"
@@ -66,6 +67,11 @@ func readPassword(prompt string) ([]byte, error) {
 }
 
 func CreateUser(cmd *cobra.Command, args []string) {
+	role, _ := cmd.Flags().GetString("role")
+	if !models.IsValidRole(role) {
+		log.Fatalf("Invalid role %q, expected one of: %s", role, strings.Join(models.Roles, ", "))
+	}
+
 	cfg, err := config.InitConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
@@ -94,6 +100,7 @@ func CreateUser(cmd *cobra.Command, args []string) {
 		LastName:  lastName,
 		Email:     email,
 		Password:  hashedPassword,
+		Role:      role,
 	}
 
 	if err := repos.Users.Create(user); err != nil {
@@ -167,3 +174,42 @@ func UpdateUserPassword(cmd *cobra.Command, args []string) {
 
 	log.Info("Password updated successfully")
 }
+
+func ResetUserTwoFactor(cmd *cobra.Command, args []string) {
+	cfg, err := config.InitConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	database, err := db.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	repos := repository.NewRepositories(database)
+
+	email := getValidInput("Email: ", utils.ValidateEmail)
+
+	user, err := repos.Users.FindByEmail(email)
+	if err != nil {
+		log.Warn("User not found")
+		return
+	}
+
+	if !user.TOTPEnabled && user.TOTPSecret == "" {
+		log.Info("Two-factor authentication is not enabled for this user")
+		return
+	}
+
+	user.ResetTwoFactor()
+	if err := repos.Users.Update(user); err != nil {
+		log.Errorf("Failed to reset two-factor authentication: %v\n", err)
+		return
+	}
+
+	if err := repos.RecoveryCodes.DeleteByUser(user.ID); err != nil {
+		log.Errorf("Failed to delete recovery codes: %v\n", err)
+		return
+	}
+
+	log.Info("Two-factor authentication reset successfully")
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/imaging"
 
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/spf13/viper"
@@ -52,8 +56,10 @@ var (
 
 type Config struct {
 	Server struct {
-		Host string `mapstructure:"host"`
-		Port int    `mapstructure:"port"`
+		Host        string `mapstructure:"host"`
+		Port        int    `mapstructure:"port"`
+		ProxyHeader string `mapstructure:"proxy_header"` // header holding the client IP, when behind a reverse proxy
+		BodyLimit   int    `mapstructure:"body_limit"`   // maximum request body size in megabytes, limiting uploads
 	}
 	Site struct {
 		SecureCookie bool   `mapstructure:"secure_cookie"`
@@ -61,11 +67,14 @@ type Config struct {
 		Theme        string `mapstructure:"theme"`
 	} `mapstructure:"site"`
 	DB struct {
-		Path     string `mapstructure:"path"`
-		LogLevel string `mapstructure:"log_level"`
+		Driver      string `mapstructure:"driver"` // "sqlite", "postgres" or "mysql"
+		Path        string `mapstructure:"path"`   // SQLite database file
+		DSN         string `mapstructure:"dsn"`    // PostgreSQL or MySQL connection string
+		LogLevel    string `mapstructure:"log_level"`
+		AutoMigrate bool   `mapstructure:"auto_migrate"` // apply pending migrations on startup
 	}
 	Storage struct {
-		Provider string `mapstructure:"provider"` // "local" or "s3"
+		Provider string `mapstructure:"provider"` // "local", "s3", "azure", "gcs" or "webdav"
 		S3       struct {
 			Bucket    string `mapstructure:"bucket"`
 			Region    string `mapstructure:"region"`
@@ -73,22 +82,106 @@ type Config struct {
 			AccessKey string `mapstructure:"access_key"`
 			SecretKey string `mapstructure:"secret_key"`
 		}
+		Azure struct {
+			AccountName string `mapstructure:"account_name"`
+			AccountKey  string `mapstructure:"account_key"`
+			Container   string `mapstructure:"container"`
+			Endpoint    string `mapstructure:"endpoint"` // service URL, https://<account_name>.blob.core.windows.net/ by default
+		}
+		GCS struct {
+			Bucket          string `mapstructure:"bucket"`
+			API             string `mapstructure:"api"`              // "json" or "xml", the S3-compatible API
+			CredentialsFile string `mapstructure:"credentials_file"` // service account key of the JSON API, the default credentials when empty
+			Endpoint        string `mapstructure:"endpoint"`         // to use an emulator
+			AccessKey       string `mapstructure:"access_key"`       // HMAC key of the XML API
+			SecretKey       string `mapstructure:"secret_key"`
+		}
+		WebDAV struct {
+			URL      string `mapstructure:"url"` // of the directory holding the files
+			Username string `mapstructure:"username"`
+			Password string `mapstructure:"password"`
+		}
 		LocalPath string `mapstructure:"local_path"` // Path for local storage
+		Serve     struct {
+			Mode      string        `mapstructure:"mode"`       // "proxy", "redirect" to presigned URLs of the provider, or "cdn"
+			URLExpiry time.Duration `mapstructure:"url_expiry"` // how long the presigned URLs are valid
+			CDNURL    string        `mapstructure:"cdn_url"`    // base URL of the CDN serving the files of the provider
+		}
+		Cache struct {
+			Dir         string `mapstructure:"dir"`           // where files of remote providers are cached, the system temporary directory by default
+			MaxSize     int    `mapstructure:"max_size"`      // size of the cache in megabytes, 0 disables it
+			MaxFileSize int    `mapstructure:"max_file_size"` // size in megabytes of the largest file cached
+		}
 	}
+	Media struct {
+		ImageSizes     []int `mapstructure:"image_sizes"`     // widths and heights images can be resized to, also used in srcsets
+		ImageQualities []int `mapstructure:"image_qualities"` // qualities that can be requested besides the default
+		ImageQuality   int   `mapstructure:"image_quality"`   // quality of the resized images
+		Pregenerate    bool  `mapstructure:"pregenerate"`     // generate the srcset variants on upload
+
+		UploadDir     string        `mapstructure:"upload_dir"`      // where uploads sent in chunks are assembled, the system temporary directory by default
+		ChunkSize     int           `mapstructure:"chunk_size"`      // size in megabytes of the chunks of uploads, below server.body_limit
+		MaxUploadSize int           `mapstructure:"max_upload_size"` // maximum size in megabytes of the uploads sent in chunks or straight to the storage
+		UploadExpiry  time.Duration `mapstructure:"upload_expiry"`   // how long unfinished uploads can be resumed
+	} `mapstructure:"media"`
+	Content struct {
+		Dir       string `mapstructure:"dir"`        // directory of Markdown files synced with the posts and pages
+		Watch     bool   `mapstructure:"watch"`      // sync the directory while the server runs
+		WriteBack bool   `mapstructure:"write_back"` // also write the changes made in the admin to the files
+	} `mapstructure:"content"`
+	Security struct {
+		Login struct {
+			MaxAccountFailures int           `mapstructure:"max_account_failures"`
+			MaxIPFailures      int           `mapstructure:"max_ip_failures"`
+			Window             time.Duration `mapstructure:"window"`
+			BackoffBase        time.Duration `mapstructure:"backoff_base"`
+			Lockout            time.Duration `mapstructure:"lockout"`
+		} `mapstructure:"login"`
+	} `mapstructure:"security"`
 	Debug bool `mapstructure:"debug"`
 }
 
 func InitConfig() (*Config, error) {
 	viper.SetDefault("server.host", "localhost")
 	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.proxy_header", "")
+	viper.SetDefault("server.body_limit", 64)
 	viper.SetDefault("site.secure_cookie", false)
 	viper.SetDefault("site.domain", "")
 	viper.SetDefault("site.theme", "")
+	viper.SetDefault("db.driver", "sqlite")
 	viper.SetDefault("db.path", "blog.db")
+	viper.SetDefault("db.dsn", "")
 	viper.SetDefault("db.log_level", "warn")
+	viper.SetDefault("db.auto_migrate", true)
 	viper.SetDefault("storage.provider", "local")
 	viper.SetDefault("storage.local_path", "./storage")
 
+	// Media serving
+	viper.SetDefault("storage.serve.mode", "proxy")
+	viper.SetDefault("storage.serve.url_expiry", "1h")
+	viper.SetDefault("storage.serve.cdn_url", "")
+	viper.SetDefault("storage.cache.dir", "")
+	viper.SetDefault("storage.cache.max_size", 0)
+	viper.SetDefault("storage.cache.max_file_size", 64)
+
+	// Image variants
+	viper.SetDefault("media.image_sizes", []int{320, 640, 960, 1280, 1920})
+	viper.SetDefault("media.image_qualities", []int{50, 90})
+	viper.SetDefault("media.image_quality", 80)
+	viper.SetDefault("media.pregenerate", true)
+
+	// Large uploads
+	viper.SetDefault("media.upload_dir", "")
+	viper.SetDefault("media.chunk_size", 8)
+	viper.SetDefault("media.max_upload_size", 5120)
+	viper.SetDefault("media.upload_expiry", "24h")
+
+	// Content directory
+	viper.SetDefault("content.dir", "")
+	viper.SetDefault("content.watch", false)
+	viper.SetDefault("content.write_back", false)
+
 	// S3
 	viper.SetDefault("storage.s3.bucket", "")
 	viper.SetDefault("storage.s3.region", "")
@@ -96,6 +189,32 @@ func InitConfig() (*Config, error) {
 	viper.SetDefault("storage.s3.access_key", "")
 	viper.SetDefault("storage.s3.secret_key", "")
 
+	// Azure Blob Storage
+	viper.SetDefault("storage.azure.account_name", "")
+	viper.SetDefault("storage.azure.account_key", "")
+	viper.SetDefault("storage.azure.container", "")
+	viper.SetDefault("storage.azure.endpoint", "")
+
+	// Google Cloud Storage
+	viper.SetDefault("storage.gcs.bucket", "")
+	viper.SetDefault("storage.gcs.api", "json")
+	viper.SetDefault("storage.gcs.credentials_file", "")
+	viper.SetDefault("storage.gcs.endpoint", "")
+	viper.SetDefault("storage.gcs.access_key", "")
+	viper.SetDefault("storage.gcs.secret_key", "")
+
+	// WebDAV
+	viper.SetDefault("storage.webdav.url", "")
+	viper.SetDefault("storage.webdav.username", "")
+	viper.SetDefault("storage.webdav.password", "")
+
+	// Login throttling
+	viper.SetDefault("security.login.max_account_failures", 5)
+	viper.SetDefault("security.login.max_ip_failures", 20)
+	viper.SetDefault("security.login.window", "15m")
+	viper.SetDefault("security.login.backoff_base", "1s")
+	viper.SetDefault("security.login.lockout", "15m")
+
 	// Debug
 	viper.SetDefault("debug", false)
 
@@ -133,6 +252,15 @@ func InitConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+// ImageLimits returns the image variants clients can request
+func (c *Config) ImageLimits() imaging.Limits {
+	return imaging.Limits{
+		Sizes:     c.Media.ImageSizes,
+		Qualities: c.Media.ImageQualities,
+		Quality:   c.Media.ImageQuality,
+	}
+}
+
 // GetGormLogLevel returns the gorm logger level based on the config
 func (c *Config) GetGormLogLevel() logger.LogLevel {
 	if c.Debug {
@@ -153,6 +281,14 @@ func (c *Config) GetGormLogLevel() logger.LogLevel {
 	}
 }
 
+// GetDBDataSource returns the SQLite database file, or the connection string of other drivers
+func (c *Config) GetDBDataSource() string {
+	if c.DB.Driver == "" || c.DB.Driver == "sqlite" {
+		return c.DB.Path
+	}
+	return c.DB.DSN
+}
+
 // ValidateS3Config validates S3 configuration if S3 provider is selected
 func (c *Config) ValidateS3Config() error {
 	if c.Storage.Provider != "s3" {
@@ -181,6 +317,143 @@ func (c *Config) ValidateS3Config() error {
 	return nil
 }
 
+// ValidateAzureConfig validates Azure configuration if Azure provider is selected
+func (c *Config) ValidateAzureConfig() error {
+	if c.Storage.Provider != "azure" {
+		return nil
+	}
+
+	var missingFields []string
+
+	if c.Storage.Azure.AccountName == "" {
+		missingFields = append(missingFields, "storage.azure.account_name")
+	}
+	if c.Storage.Azure.AccountKey == "" {
+		missingFields = append(missingFields, "storage.azure.account_key")
+	}
+	if c.Storage.Azure.Container == "" {
+		missingFields = append(missingFields, "storage.azure.container")
+	}
+
+	if len(missingFields) > 0 {
+		return fmt.Errorf("missing required Azure configuration fields: %v", missingFields)
+	}
+
+	return nil
+}
+
+// ValidateGCSConfig validates Google Cloud Storage configuration if GCS
+// provider is selected. The JSON API falls back to the default credentials,
+// the XML API needs an HMAC key.
+func (c *Config) ValidateGCSConfig() error {
+	if c.Storage.Provider != "gcs" {
+		return nil
+	}
+
+	var missingFields []string
+
+	if c.Storage.GCS.Bucket == "" {
+		missingFields = append(missingFields, "storage.gcs.bucket")
+	}
+	switch c.Storage.GCS.API {
+	case "", "json":
+	case "xml":
+		if c.Storage.GCS.AccessKey == "" {
+			missingFields = append(missingFields, "storage.gcs.access_key")
+		}
+		if c.Storage.GCS.SecretKey == "" {
+			missingFields = append(missingFields, "storage.gcs.secret_key")
+		}
+	default:
+		return fmt.Errorf("invalid storage.gcs.api %q, use json or xml", c.Storage.GCS.API)
+	}
+
+	if len(missingFields) > 0 {
+		return fmt.Errorf("missing required GCS configuration fields: %v", missingFields)
+	}
+
+	return nil
+}
+
+// ValidateWebDAVConfig validates WebDAV configuration if WebDAV provider is
+// selected
+func (c *Config) ValidateWebDAVConfig() error {
+	if c.Storage.Provider != "webdav" {
+		return nil
+	}
+
+	if c.Storage.WebDAV.URL == "" {
+		return fmt.Errorf("missing required WebDAV configuration fields: [storage.webdav.url]")
+	}
+	if u, err := url.Parse(c.Storage.WebDAV.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid storage.webdav.url %q, use an http or https URL", c.Storage.WebDAV.URL)
+	}
+
+	return nil
+}
+
+// ValidateStorageConfig validates the configuration of the selected storage
+// provider, and how its files are served
+func (c *Config) ValidateStorageConfig() error {
+	if err := c.validateProvider(); err != nil {
+		return err
+	}
+	return c.ValidateServeConfig()
+}
+
+// ValidateStorageProvider validates the configuration of a storage provider,
+// selected or not
+func (c *Config) ValidateStorageProvider(name string) error {
+	other := *c
+	other.Storage.Provider = name
+	return other.validateProvider()
+}
+
+// validateProvider validates the configuration of the selected storage
+// provider
+func (c *Config) validateProvider() error {
+	switch c.Storage.Provider {
+	case "", "local", "s3", "azure", "gcs", "webdav":
+	default:
+		return fmt.Errorf("unknown storage provider %q, use local, s3, azure, gcs or webdav", c.Storage.Provider)
+	}
+
+	for _, validate := range []func() error{c.ValidateS3Config, c.ValidateAzureConfig, c.ValidateGCSConfig, c.ValidateWebDAVConfig} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateServeConfig validates how media files are served. Presigned URLs
+// are signed by S3, Azure and GCS, for up to 7 days.
+func (c *Config) ValidateServeConfig() error {
+	serve := c.Storage.Serve
+	switch serve.Mode {
+	case "", "proxy":
+	case "redirect":
+		switch c.Storage.Provider {
+		case "s3", "azure", "gcs":
+		default:
+			return fmt.Errorf("storage.serve.mode redirect needs the s3, azure or gcs storage provider")
+		}
+		if serve.URLExpiry <= 0 || serve.URLExpiry > 7*24*time.Hour {
+			return fmt.Errorf("invalid storage.serve.url_expiry %s, use a duration up to 168h", serve.URLExpiry)
+		}
+	case "cdn":
+		if serve.CDNURL == "" {
+			return fmt.Errorf("missing required CDN configuration fields: [storage.serve.cdn_url]")
+		}
+		if u, err := url.Parse(serve.CDNURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid storage.serve.cdn_url %q, use an http or https URL", serve.CDNURL)
+		}
+	default:
+		return fmt.Errorf("unknown storage.serve.mode %q, use proxy, redirect or cdn", serve.Mode)
+	}
+	return nil
+}
+
 // GetChromaStyles returns the list of available syntax highlighting themes
 func GetChromaStyles() []string {
 	return chromaStyles
@@ -0,0 +1,246 @@
+package contentsync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSyncer(t *testing.T) (*Syncer, *repository.Repositories, string) {
+	dir := t.TempDir()
+	repos := repository.NewRepositories(db.SetupTestDB())
+	require.NoError(t, repos.Users.Create(&models.User{Email: "admin@example.com", Role: models.RoleAdmin}))
+	return New(dir, repos), repos, dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+const helloPost = `---
+title: Hello
+tags: [Go, News]
+published_at: 2024-05-01 10:00
+timezone: Europe/Paris
+visible: true
+excerpt: A greeting
+---
+
+Hello **world**
+`
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseDocument([]byte(helloPost))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", doc.Title)
+	assert.Equal(t, []string{"Go", "News"}, doc.Tags)
+	assert.Equal(t, "Hello **world**", doc.Body)
+	require.NotNil(t, doc.Visible)
+	assert.True(t, *doc.Visible)
+
+	publishedAt, timezone, err := doc.PublishTime()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Paris", timezone)
+	assert.True(t, publishedAt.Equal(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)))
+
+	// YAML timestamps are kept as written
+	doc, err = ParseDocument([]byte("---\ntitle: T\npublished_at: 2024-05-01T10:00:00+02:00\n---\nBody"))
+	require.NoError(t, err)
+	publishedAt, _, err = doc.PublishTime()
+	require.NoError(t, err)
+	assert.True(t, publishedAt.Equal(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)))
+
+	data, err := doc.Marshal()
+	require.NoError(t, err)
+	roundTrip, err := ParseDocument(data)
+	require.NoError(t, err)
+	assert.Equal(t, doc, roundTrip)
+
+	for _, invalid := range []string{"no front matter", "---\ntitle: T\n", "---\nslug: x\n---\n", "---\ntitle: [\n---\n"} {
+		_, err := ParseDocument([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestImport(t *testing.T) {
+	syncer, repos, dir := newTestSyncer(t)
+	writeFile(t, filepath.Join(dir, "posts", "hello.md"), helloPost)
+	writeFile(t, filepath.Join(dir, "pages", "about-us.md"), "---\ntitle: About\nslug: about\ncontent_type: html\n---\n<p>About</p>\n")
+	writeFile(t, filepath.Join(dir, "posts", "broken.md"), "---\ntitle: Broken\nauthor: nobody@example.com\n---\n")
+
+	report, err := syncer.Import()
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	require.Len(t, report.Failed, 1)
+	assert.Contains(t, report.Failed[0], "unknown author nobody@example.com")
+
+	post, err := repos.Posts.FindBySlug("hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello **world**", post.Content)
+	assert.Equal(t, "admin@example.com", post.Author.Email)
+	assert.Equal(t, "Europe/Paris", post.PublishedAtTimezone)
+	assert.Equal(t, 120, post.PublishedAtTimeZoneOffset)
+	assert.True(t, post.PublishedAtUTC.Equal(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)))
+	assert.True(t, post.Visible)
+	assert.Len(t, post.Tags, 2)
+
+	page, err := repos.Pages.FindBySlug("about")
+	require.NoError(t, err)
+	assert.Equal(t, "html", page.ContentType)
+	assert.False(t, page.Visible)
+
+	// Syncing again changes nothing
+	os.Remove(filepath.Join(dir, "posts", "broken.md"))
+	report, err = syncer.Import()
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
+
+	writeFile(t, filepath.Join(dir, "posts", "hello.md"), strings.Replace(helloPost, "tags: [Go, News]", "tags: [Go]", 1))
+	report, err = syncer.Import()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+
+	post, err = repos.Posts.FindBySlug("hello")
+	require.NoError(t, err)
+	assert.Len(t, post.Tags, 1)
+	revisions, err := repos.PostRevisions.FindByPost(post.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestImportRejectsDuplicateSlugs(t *testing.T) {
+	syncer, _, dir := newTestSyncer(t)
+	writeFile(t, filepath.Join(dir, "posts", "a.md"), "---\ntitle: A\nslug: same\n---\n")
+	writeFile(t, filepath.Join(dir, "posts", "b.md"), "---\ntitle: B\nslug: same\n---\n")
+
+	report, err := syncer.Import()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	require.Len(t, report.Failed, 1)
+	assert.Contains(t, report.Failed[0], "slug same is already used by a.md")
+}
+
+func TestExport(t *testing.T) {
+	syncer, repos, dir := newTestSyncer(t)
+	admin, err := repos.Users.FindByEmail("admin@example.com")
+	require.NoError(t, err)
+
+	publishedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("", 2*3600))
+	post := &models.Post{
+		Title:                     "From the admin",
+		Slug:                      "from-admin",
+		Content:                   "Written in the web editor",
+		PublishedAt:               publishedAt,
+		PublishedAtUTC:            publishedAt.UTC(),
+		PublishedAtTimezone:       "Europe/Paris",
+		PublishedAtTimeZoneOffset: 120,
+		Visible:                   true,
+		AuthorID:                  admin.ID,
+	}
+	require.NoError(t, repos.Posts.Create(post))
+	require.NoError(t, repos.Posts.AssociateTags(post, []string{"Go"}))
+	require.NoError(t, repos.Pages.Create(&models.Page{Title: "About", Slug: "about", Content: "About", ContentType: "markdown"}))
+
+	// A hand-written file matching its post is kept as written
+	handWritten := "---\ntitle: From the admin\npublished_at: 2024-05-01T08:00:00Z\ntimezone: Europe/Paris\nvisible: true\ntags: [go]\n---\nWritten in the web editor"
+	writeFile(t, filepath.Join(dir, "posts", "renamed.md"), strings.Replace(handWritten, "title:", "slug: from-admin\ntitle:", 1))
+
+	report, err := syncer.Export()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Written)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.NoFileExists(t, filepath.Join(dir, "posts", "from-admin.md"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "pages", "about.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "---\ntitle: About\nslug: about\nvisible: false\ncontent_type: markdown\n---\n\nAbout\n", string(data))
+
+	post.Content = "Edited in the web editor"
+	require.NoError(t, repos.Posts.Update(post))
+	report, err = syncer.Export()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Written)
+
+	doc, slug, err := readDocument(filepath.Join(dir, "posts", "renamed.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "from-admin", slug)
+	assert.Equal(t, "Edited in the web editor", doc.Body)
+	assert.Equal(t, "2024-05-01 10:00:00", doc.PublishedAt)
+	assert.Equal(t, "admin@example.com", doc.Author)
+
+	// The written files import without changes
+	report, err = syncer.Import()
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Unchanged)
+}
+
+func TestWatch(t *testing.T) {
+	syncer, repos, dir := newTestSyncer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- syncer.Watch(ctx, true, 50*time.Millisecond) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	// Wait for the watcher to create the directories
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "pages"))
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, filepath.Join(dir, "posts", "hello.md"), helloPost)
+	require.Eventually(t, func() bool {
+		_, err := repos.Posts.FindBySlug("hello")
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, repos.Pages.Create(&models.Page{Title: "New page", Slug: "new-page", Content: "Content", ContentType: "markdown"}))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "pages", "new-page.md"))
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestExportSince_Deleted(t *testing.T) {
+	syncer, repos, dir := newTestSyncer(t)
+	writeFile(t, filepath.Join(dir, "posts", "hello.md"), helloPost)
+	writeFile(t, filepath.Join(dir, "pages", "about-us.md"), "---\ntitle: About\nslug: about\n---\nAbout\n")
+	_, err := syncer.Import()
+	require.NoError(t, err)
+
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	post, err := repos.Posts.FindBySlug("hello")
+	require.NoError(t, err)
+	require.NoError(t, repos.Posts.Delete(post))
+	page, err := repos.Pages.FindBySlug("about")
+	require.NoError(t, err)
+	require.NoError(t, repos.Pages.Delete(page))
+
+	report, err := syncer.ExportSince(since)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Deleted)
+	assert.NoFileExists(t, filepath.Join(dir, "posts", "hello.md"))
+	assert.NoFileExists(t, filepath.Join(dir, "pages", "about-us.md"))
+
+	// The next sync does not create them again
+	report, err = syncer.Import()
+	require.NoError(t, err)
+	assert.Zero(t, report.Created)
+	assert.Empty(t, report.Failed)
+}
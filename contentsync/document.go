@@ -0,0 +1,115 @@
+package contentsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// publishedAtFormats are the accepted formats of published_at. Dates without
+// offset are in the timezone of the document.
+var publishedAtFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// FrontMatter is the YAML header of a content file
+type FrontMatter struct {
+	Title       string   `yaml:"title"`
+	Slug        string   `yaml:"slug,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	PublishedAt string   `yaml:"published_at,omitempty"`
+	Timezone    string   `yaml:"timezone,omitempty"`
+	Visible     *bool    `yaml:"visible,omitempty"`
+	Excerpt     string   `yaml:"excerpt,omitempty"`
+	Author      string   `yaml:"author,omitempty"`       // email of the author, posts only
+	ContentType string   `yaml:"content_type,omitempty"` // markdown or html, pages only
+}
+
+// Document is a post or page stored as a Markdown file with YAML front matter
+type Document struct {
+	FrontMatter
+	Body string
+}
+
+// ParseDocument parses a content file. It must start with front matter
+// delimited by --- lines.
+func ParseDocument(data []byte) (*Document, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, errors.New("missing front matter")
+	}
+	header, body, found := strings.Cut(text[len("---\n"):], "\n---\n")
+	if !found {
+		if header, found = strings.CutSuffix(text[len("---\n"):], "\n---"); !found {
+			return nil, errors.New("unterminated front matter")
+		}
+	}
+
+	doc := &Document{Body: strings.TrimSpace(body)}
+	if err := yaml.Unmarshal([]byte(header), &doc.FrontMatter); err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+	if strings.TrimSpace(doc.Title) == "" {
+		return nil, errors.New("missing title")
+	}
+
+	return doc, nil
+}
+
+// Marshal renders the document as a content file
+func (d *Document) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&d.FrontMatter); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("---\n\n")
+	buf.WriteString(d.Body)
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// PublishTime returns the publish time of the document in its timezone, with
+// the timezone name, or a zero time when it has none
+func (d *Document) PublishTime() (time.Time, string, error) {
+	timezone := d.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid timezone %q", d.Timezone)
+	}
+
+	if d.PublishedAt == "" {
+		return time.Time{}, timezone, nil
+	}
+	if t, err := time.Parse(time.RFC3339, d.PublishedAt); err == nil {
+		return t.In(loc), timezone, nil
+	}
+	for _, layout := range publishedAtFormats {
+		if t, err := time.ParseInLocation(layout, d.PublishedAt, loc); err == nil {
+			return t, timezone, nil
+		}
+	}
+
+	return time.Time{}, "", fmt.Errorf("invalid published_at %q", d.PublishedAt)
+}
@@ -0,0 +1,256 @@
+package contentsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+)
+
+// Export writes the posts and pages to the content files. Files already
+// matching their post or page are left untouched, so exporting does not undo
+// the formatting of hand-written files.
+func (s *Syncer) Export() (*Report, error) {
+	posts, err := s.repos.Posts.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := s.repos.Pages.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.export(posts, pages)
+}
+
+// ExportSince writes the posts and pages updated after since to the content
+// files, and deletes the files of the ones deleted after since, so they are
+// not created again by the next import
+func (s *Syncer) ExportSince(since time.Time) (*Report, error) {
+	posts, err := s.repos.Posts.FindUpdatedSince(since)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := s.repos.Pages.FindUpdatedSince(since)
+	if err != nil {
+		return nil, err
+	}
+	deletedPosts, err := s.repos.Posts.FindDeletedSince(since)
+	if err != nil {
+		return nil, err
+	}
+	deletedPages, err := s.repos.Pages.FindDeletedSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.export(posts, pages)
+	if err != nil {
+		return report, err
+	}
+
+	deleted := map[string][]string{}
+	for _, post := range deletedPosts {
+		deleted[postsDir] = append(deleted[postsDir], post.Slug)
+	}
+	for _, page := range deletedPages {
+		deleted[pagesDir] = append(deleted[pagesDir], page.Slug)
+	}
+	return report, s.remove(report, deleted)
+}
+
+func (s *Syncer) export(posts []*models.Post, pages []*models.Page) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{}
+	if len(posts) == 0 && len(pages) == 0 {
+		return report, nil
+	}
+
+	if len(posts) > 0 {
+		files, err := s.index(postsDir)
+		if err != nil {
+			return report, err
+		}
+		for _, post := range posts {
+			path, ok := files[post.Slug]
+			if !ok {
+				path = filepath.Join(s.dir, postsDir, post.Slug+".md")
+			}
+			if s.postFileMatches(path, post) {
+				report.Unchanged++
+				continue
+			}
+			if err := writeDocument(path, postDocument(post)); err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			report.Written++
+		}
+	}
+
+	if len(pages) > 0 {
+		files, err := s.index(pagesDir)
+		if err != nil {
+			return report, err
+		}
+		for _, page := range pages {
+			path, ok := files[page.Slug]
+			if !ok {
+				path = filepath.Join(s.dir, pagesDir, page.Slug+".md")
+			}
+			if pageFileMatches(path, page) {
+				report.Unchanged++
+				continue
+			}
+			if err := writeDocument(path, pageDocument(page)); err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			report.Written++
+		}
+	}
+
+	return report, nil
+}
+
+// remove deletes the content files of slugs, by kind
+func (s *Syncer) remove(report *Report, slugs map[string][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for kind, slugs := range slugs {
+		files, err := s.index(kind)
+		if err != nil {
+			return err
+		}
+		for _, slug := range slugs {
+			path, ok := files[slug]
+			if !ok {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			report.Deleted++
+		}
+	}
+	return nil
+}
+
+// index returns the content files of a kind by slug, as a file can be named
+// differently from the slug of its front matter
+func (s *Syncer) index(kind string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, kind, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]string)
+	for _, path := range files {
+		if _, slug, err := readDocument(path); err == nil {
+			index[slug] = path
+		}
+	}
+	return index, nil
+}
+
+// postFileMatches returns whether importing the file would leave the post unchanged
+func (s *Syncer) postFileMatches(path string, post *models.Post) bool {
+	doc, slug, err := readDocument(path)
+	if err != nil {
+		return false
+	}
+	fromFile, err := s.postFromDocument(post, slug, doc)
+	return err == nil && !postChanged(post, fromFile, doc.Tags)
+}
+
+// pageFileMatches returns whether importing the file would leave the page unchanged
+func pageFileMatches(path string, page *models.Page) bool {
+	doc, slug, err := readDocument(path)
+	if err != nil {
+		return false
+	}
+	fromFile, err := pageFromDocument(page, slug, doc)
+	return err == nil && !pageChanged(page, fromFile)
+}
+
+func postDocument(post *models.Post) *Document {
+	visible := post.Visible
+	doc := &Document{
+		FrontMatter: FrontMatter{
+			Title:    post.Title,
+			Slug:     post.Slug,
+			Tags:     models.PostTagNames(post),
+			Timezone: post.PublishedAtTimezone,
+			Visible:  &visible,
+		},
+		Body: post.Content,
+	}
+
+	if loc, err := time.LoadLocation(post.PublishedAtTimezone); err == nil && post.PublishedAtTimezone != "" {
+		doc.PublishedAt = post.PublishedAtUTC.In(loc).Format(localTimeFormat)
+	} else {
+		doc.Timezone = ""
+		doc.PublishedAt = post.PublishedAtUTC.UTC().Format(time.RFC3339)
+	}
+	if post.Excerpt != nil {
+		doc.Excerpt = *post.Excerpt
+	}
+	if post.Author != nil {
+		doc.Author = post.Author.Email
+	}
+
+	return doc
+}
+
+func pageDocument(page *models.Page) *Document {
+	visible := page.Visible
+	return &Document{
+		FrontMatter: FrontMatter{
+			Title:       page.Title,
+			Slug:        page.Slug,
+			Visible:     &visible,
+			ContentType: page.ContentType,
+		},
+		Body: page.Content,
+	}
+}
+
+// writeDocument writes a content file through a temporary file, so the
+// watcher never reads it half written
+func writeDocument(path string, doc *Document) error {
+	data, err := doc.Marshal()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".captain-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,438 @@
+// Package contentsync keeps a directory of Markdown files with YAML front
+// matter in sync with the posts and pages of the site, so they can be written
+// in an editor and reviewed in pull requests. Posts are stored in
+// posts/<slug>.md and pages in pages/<slug>.md.
+package contentsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	postsDir = "posts"
+	pagesDir = "pages"
+
+	// localTimeFormat is the format of the published_at of the written files,
+	// which are in the timezone of the document
+	localTimeFormat = "2006-01-02 15:04:05"
+)
+
+// Report summarizes a sync
+type Report struct {
+	Created   int      // posts and pages created from files
+	Updated   int      // posts and pages updated from files
+	Unchanged int      // files matching their post or page
+	Written   int      // files written from the database
+	Deleted   int      // files deleted with their post or page
+	Failed    []string // files that could not be synced, with the reason
+}
+
+func (r *Report) String() string {
+	summary := fmt.Sprintf("%d created, %d updated, %d unchanged, %d files written", r.Created, r.Updated, r.Unchanged, r.Written)
+	if r.Deleted > 0 {
+		summary += fmt.Sprintf(", %d files deleted", r.Deleted)
+	}
+	for _, failure := range r.Failed {
+		summary += "\n  " + failure
+	}
+	return summary
+}
+
+type result int
+
+const (
+	unchanged result = iota
+	created
+	updated
+)
+
+func (r *Report) add(res result) {
+	switch res {
+	case created:
+		r.Created++
+	case updated:
+		r.Updated++
+	default:
+		r.Unchanged++
+	}
+}
+
+// Syncer syncs a content directory with the database
+type Syncer struct {
+	dir   string
+	repos *repository.Repositories
+
+	// mu serializes the syncs of the watcher and the commands
+	mu            sync.Mutex
+	defaultAuthor *models.User
+}
+
+// New creates a syncer of the content directory dir
+func New(dir string, repos *repository.Repositories) *Syncer {
+	return &Syncer{dir: dir, repos: repos}
+}
+
+// Import creates or updates the posts and pages of the content files. Posts and
+// pages without a file are left untouched.
+func (s *Syncer) Import() (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{}
+	for _, kind := range []string{postsDir, pagesDir} {
+		files, err := filepath.Glob(filepath.Join(s.dir, kind, "*.md"))
+		if err != nil {
+			return report, err
+		}
+
+		slugs := make(map[string]string)
+		for _, path := range files {
+			doc, slug, err := readDocument(path)
+			if err == nil && slugs[slug] != "" {
+				err = fmt.Errorf("slug %s is already used by %s", slug, filepath.Base(slugs[slug]))
+			}
+			if err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			slugs[slug] = path
+
+			res, err := s.importDocument(kind, slug, doc)
+			if err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			report.add(res)
+		}
+	}
+
+	return report, nil
+}
+
+// ImportFile creates or updates the post or page of a content file
+func (s *Syncer) ImportFile(path string) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &Report{}
+	kind := filepath.Base(filepath.Dir(path))
+	if kind != postsDir && kind != pagesDir {
+		return report, fmt.Errorf("%s is not in the posts or pages directory", path)
+	}
+
+	doc, slug, err := readDocument(path)
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", path, err)
+	}
+
+	res, err := s.importDocument(kind, slug, doc)
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", path, err)
+	}
+	report.add(res)
+
+	return report, nil
+}
+
+// readDocument reads a content file and returns its slug, taken from the
+// front matter or else the file name
+func readDocument(path string) (*Document, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	slug := doc.Slug
+	if slug == "" {
+		slug = strings.TrimSuffix(filepath.Base(path), ".md")
+	}
+	return doc, utils.Slugify(slug), nil
+}
+
+func (s *Syncer) importDocument(kind, slug string, doc *Document) (result, error) {
+	if kind == pagesDir {
+		return s.importPage(slug, doc)
+	}
+	return s.importPost(slug, doc)
+}
+
+func (s *Syncer) importPost(slug string, doc *Document) (result, error) {
+	existing, err := s.repos.Posts.FindBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		existing = nil
+	} else if err != nil {
+		return unchanged, err
+	}
+
+	post, err := s.postFromDocument(existing, slug, doc)
+	if err != nil {
+		return unchanged, err
+	}
+	if existing != nil && !postChanged(existing, post, doc.Tags) {
+		return unchanged, nil
+	}
+
+	// The associations are saved separately, and would override AuthorID
+	post.Author = nil
+	post.Tags = nil
+
+	res := created
+	if existing == nil {
+		err = s.repos.Posts.Create(post)
+	} else {
+		res = updated
+		if err = s.ensurePostBaselineRevision(existing); err == nil {
+			err = s.repos.Posts.Update(post)
+		}
+	}
+	if err != nil {
+		return unchanged, err
+	}
+
+	if err := s.repos.Posts.AssociateTags(post, doc.Tags); err != nil {
+		return unchanged, err
+	}
+	if err := s.repos.PostRevisions.Create(models.NewPostRevision(post, doc.Tags, post.AuthorID)); err != nil {
+		return unchanged, err
+	}
+
+	return res, nil
+}
+
+// postFromDocument returns the post described by a document. The fields the
+// document leaves out keep the value of the existing post, when there is one.
+func (s *Syncer) postFromDocument(existing *models.Post, slug string, doc *Document) (*models.Post, error) {
+	publishedAt, timezone, err := doc.PublishTime()
+	if err != nil {
+		return nil, err
+	}
+
+	post := &models.Post{}
+	if existing != nil {
+		copied := *existing
+		post = &copied
+	}
+
+	post.Title = doc.Title
+	post.Slug = slug
+	post.Content = doc.Body
+	post.Excerpt = nil
+	if doc.Excerpt != "" {
+		excerpt := doc.Excerpt
+		post.Excerpt = &excerpt
+	}
+
+	switch {
+	case doc.Visible != nil:
+		post.Visible = *doc.Visible
+	case existing == nil:
+		// Posts are drafts until published explicitly
+		post.Visible = false
+	}
+
+	switch {
+	case doc.Author != "":
+		author, err := s.repos.Users.FindByEmail(doc.Author)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("unknown author %s", doc.Author)
+		} else if err != nil {
+			return nil, err
+		}
+		post.AuthorID = author.ID
+	case existing == nil:
+		author, err := s.firstAdmin()
+		if err != nil {
+			return nil, err
+		}
+		post.AuthorID = author.ID
+	}
+
+	if publishedAt.IsZero() {
+		if existing != nil {
+			return post, nil
+		}
+		// The timezone was validated by PublishTime
+		loc, _ := time.LoadLocation(timezone)
+		publishedAt = time.Now().In(loc)
+	}
+	post.PublishedAt = publishedAt
+	post.PublishedAtUTC = publishedAt.UTC()
+	post.PublishedAtTimezone = timezone
+	_, offset := publishedAt.Zone()
+	post.PublishedAtTimeZoneOffset = offset / 60
+
+	return post, nil
+}
+
+// postChanged returns whether a post differs from its saved version
+func postChanged(saved, post *models.Post, tags []string) bool {
+	return saved.Title != post.Title ||
+		saved.Slug != post.Slug ||
+		strings.TrimSpace(saved.Content) != strings.TrimSpace(post.Content) ||
+		excerpt(saved) != excerpt(post) ||
+		saved.Visible != post.Visible ||
+		saved.AuthorID != post.AuthorID ||
+		!saved.PublishedAtUTC.Equal(post.PublishedAtUTC) ||
+		saved.PublishedAtTimezone != post.PublishedAtTimezone ||
+		!sameTags(models.PostTagNames(saved), tags)
+}
+
+func excerpt(post *models.Post) string {
+	if post.Excerpt == nil {
+		return ""
+	}
+	return strings.TrimSpace(*post.Excerpt)
+}
+
+// sameTags compares tags as AssociateTags matches them, by slug
+func sameTags(a, b []string) bool {
+	slugs := func(tags []string) []string {
+		var result []string
+		for _, tag := range tags {
+			if strings.TrimSpace(tag) != "" {
+				result = append(result, utils.Slugify(tag))
+			}
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	sa, sb := slugs(a), slugs(b)
+	if len(sa) != len(sb) {
+		return false
+	}
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ensurePostBaselineRevision records the state of a post saved before
+// revisions existed, so that updating it from its file does not lose it
+func (s *Syncer) ensurePostBaselineRevision(post *models.Post) error {
+	count, err := s.repos.PostRevisions.CountByPost(post.ID)
+	if err != nil || count > 0 {
+		return err
+	}
+	return s.repos.PostRevisions.Create(models.NewPostRevision(post, models.PostTagNames(post), post.AuthorID))
+}
+
+func (s *Syncer) firstAdmin() (*models.User, error) {
+	if s.defaultAuthor != nil {
+		return s.defaultAuthor, nil
+	}
+
+	users, err := s.repos.Users.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.Role == models.RoleAdmin {
+			s.defaultAuthor = user
+			return user, nil
+		}
+	}
+	return nil, errors.New("no admin to attribute the post to, set its author")
+}
+
+func (s *Syncer) importPage(slug string, doc *Document) (result, error) {
+	existing, err := s.repos.Pages.FindBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		existing = nil
+	} else if err != nil {
+		return unchanged, err
+	}
+
+	page, err := pageFromDocument(existing, slug, doc)
+	if err != nil {
+		return unchanged, err
+	}
+	if existing != nil && !pageChanged(existing, page) {
+		return unchanged, nil
+	}
+
+	res := created
+	if existing == nil {
+		err = s.repos.Pages.Create(page)
+	} else {
+		res = updated
+		if err = s.ensurePageBaselineRevision(existing); err == nil {
+			err = s.repos.Pages.Update(page)
+		}
+	}
+	if err != nil {
+		return unchanged, err
+	}
+
+	if err := s.repos.PageRevisions.Create(models.NewPageRevision(page, 0)); err != nil {
+		return unchanged, err
+	}
+
+	return res, nil
+}
+
+// pageFromDocument returns the page described by a document. The fields the
+// document leaves out keep the value of the existing page, when there is one.
+func pageFromDocument(existing *models.Page, slug string, doc *Document) (*models.Page, error) {
+	page := &models.Page{ContentType: "markdown"}
+	if existing != nil {
+		copied := *existing
+		page = &copied
+	}
+
+	page.Title = doc.Title
+	page.Slug = slug
+	page.Content = doc.Body
+	if doc.Visible != nil {
+		page.Visible = *doc.Visible
+	}
+
+	switch doc.ContentType {
+	case "":
+	case "markdown", "html":
+		page.ContentType = doc.ContentType
+	default:
+		return nil, fmt.Errorf("invalid content_type %q, expected markdown or html", doc.ContentType)
+	}
+
+	return page, nil
+}
+
+// pageChanged returns whether a page differs from its saved version
+func pageChanged(saved, page *models.Page) bool {
+	return saved.Title != page.Title ||
+		saved.Slug != page.Slug ||
+		strings.TrimSpace(saved.Content) != strings.TrimSpace(page.Content) ||
+		saved.Visible != page.Visible ||
+		saved.ContentType != page.ContentType
+}
+
+// ensurePageBaselineRevision records the state of a page saved before
+// revisions existed, so that updating it from its file does not lose it
+func (s *Syncer) ensurePageBaselineRevision(page *models.Page) error {
+	count, err := s.repos.PageRevisions.CountByPage(page.ID)
+	if err != nil || count > 0 {
+		return err
+	}
+	return s.repos.PageRevisions.Create(models.NewPageRevision(page, 0))
+}
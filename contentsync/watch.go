@@ -0,0 +1,105 @@
+package contentsync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// settleDelay is how long a file must stay unchanged before it is imported, as
+// editors often save a file in several writes
+const settleDelay = 300 * time.Millisecond
+
+// Watch imports the content files as they are created or changed, until ctx is
+// done. With writeBack, the posts and pages changed in the database, from the
+// admin or the API, are also written to their files every interval, and the
+// files of the deleted ones are deleted. Deleting a file does not delete its
+// post or page.
+func (s *Syncer) Watch(ctx context.Context, writeBack bool, interval time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	for _, kind := range []string{postsDir, pagesDir} {
+		dir := filepath.Join(s.dir, kind)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	settle := time.NewTimer(settleDelay)
+	settle.Stop()
+	changed := make(map[string]bool)
+
+	var poll <-chan time.Time
+	if writeBack {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	since := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if strings.HasSuffix(event.Name, ".md") && event.Has(fsnotify.Create|fsnotify.Write) {
+				changed[event.Name] = true
+				settle.Reset(settleDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("Content watcher error: %v", err)
+
+		case <-settle.C:
+			for path := range changed {
+				report, err := s.ImportFile(path)
+				switch {
+				case err != nil:
+					log.Warnf("Failed to sync %s: %v", path, err)
+				case report.Created > 0:
+					log.Infof("Created %s", path)
+				case report.Updated > 0:
+					log.Infof("Updated %s", path)
+				}
+			}
+			clear(changed)
+
+		case <-poll:
+			start := time.Now()
+			report, err := s.ExportSince(since)
+			if err != nil {
+				log.Warnf("Failed to write content files: %v", err)
+				continue
+			}
+			since = start
+			for _, failure := range report.Failed {
+				log.Warnf("Failed to write %s", failure)
+			}
+			if report.Written > 0 {
+				log.Infof("Wrote %d content files", report.Written)
+			}
+			if report.Deleted > 0 {
+				log.Infof("Deleted %d content files", report.Deleted)
+			}
+		}
+	}
+}
@@ -7,23 +7,51 @@ import (
 	"github.com/captain-corp/captain/models"
 
 	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func New(cfg *config.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(cfg.DB.Path), &gorm.Config{
-		Logger: logger.Default.LogMode(cfg.GetGormLogLevel()),
-	})
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
 
+// New connects to the configured database and brings its schema up to date.
+// With db.auto_migrate disabled, it fails when migrations are pending instead.
+func New(cfg *config.Config) (*gorm.DB, error) {
+	db, err := Connect(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := ExecuteMigrations(db); err != nil {
+	if err := CheckSchemaVersion(db); err != nil {
 		return nil, err
 	}
 
+	if cfg.DB.AutoMigrate {
+		runs, err := Migrate(db, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			log.Infof("Applied migration %d (%s)", run.Migration.Version, run.Migration.Name)
+		}
+	} else {
+		pending, err := PendingMigrations(db)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			return nil, fmt.Errorf("%d database migration(s) pending, run \"captain db migrate\" first", len(pending))
+		}
+	}
+
 	// Initialize default settings if they don't exist
 	var settings models.Settings
 	if err := db.FirstOrCreate(&settings, models.Settings{
@@ -39,14 +67,89 @@ func New(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Connect connects to the configured database, without migrating it
+func Connect(cfg *config.Config) (*gorm.DB, error) {
+	return Open(cfg.DB.Driver, cfg.GetDBDataSource(), &gorm.Config{
+		Logger:         logger.Default.LogMode(cfg.GetGormLogLevel()),
+		TranslateError: true,
+	})
+}
+
+// Open connects to the database with the given driver. The data source is the
+// database file for SQLite, and a connection string for PostgreSQL and MySQL.
+func Open(driver, dataSource string, gormConfig *gorm.Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+
+	switch driver {
+	case DriverSQLite, "":
+		dialector = sqlite.Open(dataSource)
+	case DriverPostgres:
+		if dataSource == "" {
+			return nil, fmt.Errorf("db.dsn is required with the %s driver", driver)
+		}
+		dialector = postgres.Open(dataSource)
+	case DriverMySQL:
+		if dataSource == "" {
+			return nil, fmt.Errorf("db.dsn is required with the %s driver", driver)
+		}
+		mysqlConfig, err := mysqldriver.ParseDSN(dataSource)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MySQL DSN: %w", err)
+		}
+		// Dates are stored in DATETIME columns, which must be scanned as time.Time
+		mysqlConfig.ParseTime = true
+		dialector = mysql.Open(mysqlConfig.FormatDSN())
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, expected %s, %s or %s", driver, DriverSQLite, DriverPostgres, DriverMySQL)
+	}
+
+	// Foreign keys were never enforced by SQLite, and the data relies on it: posts
+	// imported without an author, revisions of deleted users...
+	gormConfig.DisableForeignKeyConstraintWhenMigrating = true
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the %s database: %w", dialector.Name(), err)
+	}
+
+	return db, nil
+}
+
+// ExecuteMigrations applies the pending schema migrations
 func ExecuteMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Post{},
-		&models.Tag{},
-		&models.User{},
-		&models.Page{},
-		&models.MenuItem{},
-		&models.Settings{},
-		&models.Media{},
-	)
+	_, err := Migrate(db, false)
+	return err
+}
+
+// RebuildSearchIndex clears the search index and re-indexes all posts and pages
+func RebuildSearchIndex(db *gorm.DB) error {
+	// MySQL only concatenates strings with CONCAT, which SQLite lacks. The kinds are
+	// inlined, as PostgreSQL cannot infer the type of parameters in the selected columns.
+	postContent := "COALESCE(excerpt, '') || ? || content"
+	if db.Dialector.Name() == DriverMySQL {
+		postContent = "CONCAT(COALESCE(excerpt, ''), ?, content)"
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM search_index").Error; err != nil {
+			return err
+		}
+
+		if tx.Migrator().HasTable(&models.Post{}) {
+			if err := tx.Exec(`INSERT INTO search_index (title, content, kind, ref_id)
+				SELECT title, `+postContent+`, '`+models.SearchKindPost+`', id FROM posts WHERE deleted_at IS NULL`,
+				"\n\n").Error; err != nil {
+				return fmt.Errorf("failed to index posts: %w", err)
+			}
+		}
+
+		if tx.Migrator().HasTable(&models.Page{}) {
+			if err := tx.Exec(`INSERT INTO search_index (title, content, kind, ref_id)
+				SELECT title, content, '` + models.SearchKindPage + `', id FROM pages WHERE deleted_at IS NULL`).Error; err != nil {
+				return fmt.Errorf("failed to index pages: %w", err)
+			}
+		}
+
+		return nil
+	})
 }
@@ -0,0 +1,317 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer version of
+// Captain, whose migrations this binary does not know.
+var ErrSchemaTooNew = errors.New("the database schema is newer than this version of Captain")
+
+// Migration is a versioned change of the database schema. Released migrations
+// must never change: they declare their own copy of the tables they create, so
+// they keep producing the same schema when the models evolve.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a migration applied to the database
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus is a known or applied migration, and when it was applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil when pending
+	Unknown   bool       // applied by a newer version of Captain
+}
+
+// MigrationRun is a migration applied or rolled back. Statements holds the SQL
+// the migration would run, and is only set by dry runs.
+type MigrationRun struct {
+	Migration  Migration
+	Statements []string
+}
+
+// LatestVersion returns the version of the last migration known by this binary
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// appliedMigrations returns the migrations applied to the database, by version
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	applied := make(map[int]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// CheckSchemaVersion returns ErrSchemaTooNew when the database has migrations
+// applied that this binary does not know
+func CheckSchemaVersion(db *gorm.DB) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for version := range applied {
+		if version > LatestVersion() {
+			return fmt.Errorf("%w: the database is at version %d, this binary only knows up to version %d", ErrSchemaTooNew, version, LatestVersion())
+		}
+	}
+
+	return nil
+}
+
+// Status returns the known migrations, followed by the unknown ones applied to the database
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	var unknown []MigrationStatus
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		unknown = append(unknown, MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+
+	return append(statuses, unknown...), nil
+}
+
+// PendingMigrations returns the known migrations not applied to the database yet
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order, each one in a transaction.
+// With dryRun, the database is left untouched and the returned runs hold the
+// SQL statements the migrations would execute.
+func Migrate(db *gorm.DB, dryRun bool) ([]MigrationRun, error) {
+	if err := CheckSchemaVersion(db); err != nil {
+		return nil, err
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) > 0 && !dryRun {
+		if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("failed to create the schema_migrations table: %w", err)
+		}
+	}
+
+	var runs []MigrationRun
+	for _, migration := range pending {
+		run := MigrationRun{Migration: migration}
+
+		if dryRun {
+			if run.Statements, err = dryRunMigration(db, migration.Up); err != nil {
+				return runs, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			runs = append(runs, run)
+			continue
+		}
+
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return runs, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// Rollback reverts the last steps applied migrations, most recent first.
+// Like Migrate, dryRun only returns the SQL statements.
+func Rollback(db *gorm.DB, steps int, dryRun bool) ([]MigrationRun, error) {
+	if err := CheckSchemaVersion(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []MigrationRun
+	for i := len(migrations) - 1; i >= 0 && len(runs) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		run := MigrationRun{Migration: migration}
+
+		if dryRun {
+			if run.Statements, err = dryRunMigration(db, migration.Down); err != nil {
+				return runs, fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			runs = append(runs, run)
+			continue
+		}
+
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return runs, fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// inTransaction runs fn in a transaction, except on MySQL which commits schema
+// changes implicitly, so a failed migration could not be rolled back anyway
+func inTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if db.Dialector.Name() == DriverMySQL {
+		return fn(db)
+	}
+	return db.Transaction(fn)
+}
+
+// dryRunMigration runs fn against a connection that reads the schema but only
+// records the statements changing it
+func dryRunMigration(db *gorm.DB, fn func(tx *gorm.DB) error) ([]string, error) {
+	// The context makes the session clone the statement, and not swap the pool of db
+	tx := db.Session(&gorm.Session{NewDB: true, DisableNestedTransaction: true, Context: context.Background()})
+	pool := &dryRunPool{ConnPool: tx.Statement.ConnPool, dialector: db.Dialector}
+	tx.Statement.ConnPool = pool
+
+	if err := fn(tx); err != nil {
+		return pool.statements, err
+	}
+
+	return pool.statements, nil
+}
+
+// dryRunPool is a connection pool running the queries that read the database,
+// and recording the other statements instead of running them. It acts as a
+// transaction, so the migrations can open transactions during a dry run.
+type dryRunPool struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	statements []string
+}
+
+// emptyQuery replaces the recorded statements that return rows
+const emptyQuery = "SELECT 1 FROM (SELECT 1) AS t WHERE 1 = 0"
+
+func (p *dryRunPool) record(query string, args ...interface{}) {
+	p.statements = append(p.statements, p.dialector.Explain(strings.TrimSpace(query), args...))
+}
+
+func (p *dryRunPool) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	p.record(query, args...)
+	return driver.RowsAffected(0), nil
+}
+
+func (p *dryRunPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if isReadQuery(query) {
+		return p.ConnPool.QueryContext(ctx, query, args...)
+	}
+	p.record(query, args...)
+	return p.ConnPool.QueryContext(ctx, emptyQuery)
+}
+
+func (p *dryRunPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if isReadQuery(query) {
+		return p.ConnPool.QueryRowContext(ctx, query, args...)
+	}
+	p.record(query, args...)
+	return p.ConnPool.QueryRowContext(ctx, emptyQuery)
+}
+
+func (p *dryRunPool) BeginTx(_ context.Context, _ *sql.TxOptions) (gorm.ConnPool, error) {
+	return p, nil
+}
+
+func (p *dryRunPool) Commit() error {
+	return nil
+}
+
+func (p *dryRunPool) Rollback() error {
+	return nil
+}
+
+// isReadQuery reports whether the query only reads the database or its schema
+func isReadQuery(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	for _, prefix := range []string{"SELECT", "WITH", "PRAGMA", "SHOW", "DESCRIBE", "EXPLAIN"} {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+	return false
+}
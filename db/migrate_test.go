@@ -0,0 +1,118 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	db := OpenTestDB()
+
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+
+	runs, err := Migrate(db, false)
+	require.NoError(t, err)
+	assert.Len(t, runs, len(migrations))
+	assert.True(t, db.Migrator().HasTable("posts"))
+	assert.True(t, db.Migrator().HasTable("search_index"))
+	assert.True(t, db.Migrator().HasTable("media_variants"))
+	assert.True(t, db.Migrator().HasColumn("media", "width"))
+	assert.True(t, db.Migrator().HasColumn("media", "folder"))
+	assert.True(t, db.Migrator().HasTable("media_uploads"))
+	assert.True(t, db.Migrator().HasColumn("media", "hash"))
+	assert.True(t, db.Migrator().HasTable("fiber_storage"))
+
+	// Media with the same content share their file
+	for range 2 {
+		require.NoError(t, db.Exec("INSERT INTO media (name, path, mime_type, size, hash) VALUES ('a.png', 'a.png', 'image/png', 1, 'h')").Error)
+	}
+
+	// Nothing left to apply
+	runs, err = Migrate(db, false)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt)
+		assert.False(t, status.Unknown)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	db := OpenTestDB()
+
+	runs, err := Migrate(db, true)
+	require.NoError(t, err)
+	require.Len(t, runs, len(migrations))
+
+	statements := strings.Join(runs[0].Statements, "\n")
+	assert.Contains(t, statements, "CREATE TABLE")
+	assert.Contains(t, statements, "posts")
+
+	// The database is left untouched
+	assert.False(t, db.Migrator().HasTable("posts"))
+	assert.False(t, db.Migrator().HasTable(&SchemaMigration{}))
+
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+}
+
+func TestRollback(t *testing.T) {
+	db := OpenTestDB()
+
+	_, err := Migrate(db, false)
+	require.NoError(t, err)
+
+	runs, err := Rollback(db, 1, true)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Contains(t, strings.Join(runs[0].Statements, "\n"), "DROP TABLE")
+	assert.True(t, db.Migrator().HasTable("posts"))
+
+	runs, err = Rollback(db, len(migrations), false)
+	require.NoError(t, err)
+	assert.Len(t, runs, len(migrations))
+	assert.Equal(t, 1, runs[len(runs)-1].Migration.Version)
+	assert.False(t, db.Migrator().HasTable("posts"))
+	assert.False(t, db.Migrator().HasTable("search_index"))
+
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+
+	// The schema can be migrated again
+	_, err = Migrate(db, false)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("posts"))
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	db := SetupTestDB()
+
+	future := SchemaMigration{Version: LatestVersion() + 1, Name: "from the future", AppliedAt: time.Now()}
+	require.NoError(t, db.Create(&future).Error)
+
+	assert.ErrorIs(t, CheckSchemaVersion(db), ErrSchemaTooNew)
+
+	_, err := Migrate(db, false)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	_, err = Rollback(db, 1, false)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	last := statuses[len(statuses)-1]
+	assert.Equal(t, future.Version, last.Version)
+	assert.True(t, last.Unknown)
+}
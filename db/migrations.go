@@ -0,0 +1,439 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrations lists the schema migrations, in order. Append new migrations at
+// the end with the next version number, and never edit a released one.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up:      initialSchemaUp,
+		Down:    initialSchemaDown,
+	},
+	{
+		Version: 2,
+		Name:    "image dimensions and variants",
+		Up:      mediaVariantsUp,
+		Down:    mediaVariantsDown,
+	},
+	{
+		Version: 3,
+		Name:    "media alt text, captions, folders and capture dates",
+		Up:      mediaMetadataUp,
+		Down:    mediaMetadataDown,
+	},
+	{
+		Version: 4,
+		Name:    "media uploads in progress",
+		Up:      mediaUploadsUp,
+		Down:    mediaUploadsDown,
+	},
+	{
+		Version: 5,
+		Name:    "media content hashes",
+		Up:      mediaHashesUp,
+		Down:    mediaHashesDown,
+	},
+	{
+		Version: 6,
+		Name:    "sessions",
+		Up:      sessionsUp,
+		Down:    sessionsDown,
+	},
+}
+
+// initialSchemaTables are the tables created by the initial schema, in the order they are dropped
+var initialSchemaTables = []string{
+	"search_index",
+	"login_throttles",
+	"login_attempts",
+	"recovery_codes",
+	"api_tokens",
+	"page_revisions",
+	"post_revisions",
+	"media",
+	"settings",
+	"menu_items",
+	"pages",
+	"users",
+	"post_tags",
+	"tags",
+	"posts",
+}
+
+// initialSchemaUp creates the schema of the databases migrated with AutoMigrate,
+// before versioned migrations. It only creates what is missing, so those databases
+// are brought under version control as they are.
+func initialSchemaUp(tx *gorm.DB) error {
+	type Tag struct {
+		gorm.Model
+		Name string `gorm:"size:255;uniqueIndex;not null"`
+		Slug string `gorm:"size:255;uniqueIndex;not null"`
+	}
+
+	type Post struct {
+		gorm.Model
+		Title                     string    `gorm:"not null"`
+		Slug                      string    `gorm:"size:255;uniqueIndex;not null"`
+		Content                   string    `gorm:"not null"`
+		PublishedAt               time.Time `gorm:"not null"`
+		PublishedAtUTC            time.Time `gorm:"not null"`
+		PublishedAtTimezone       string    `gorm:"not null;default:'UTC'"`
+		PublishedAtTimeZoneOffset int       `gorm:"not null;default:0"`
+		Visible                   bool      `gorm:"not null"`
+		Excerpt                   *string   `gorm:"type:text"`
+		Tags                      []Tag     `gorm:"many2many:post_tags;"`
+		AuthorID                  uint      `gorm:"not null"`
+	}
+
+	type User struct {
+		gorm.Model
+		FirstName    string
+		LastName     string
+		Email        string `gorm:"size:255;uniqueIndex"`
+		Password     string
+		Role         string `gorm:"not null;default:'admin'"`
+		TOTPSecret   string
+		TOTPEnabled  bool  `gorm:"not null;default:false"`
+		TOTPLastStep int64 `gorm:"not null;default:0"`
+	}
+
+	type Page struct {
+		gorm.Model
+		Title       string `gorm:"not null"`
+		Slug        string `gorm:"size:255;uniqueIndex;not null"`
+		Content     string `gorm:"not null"`
+		ContentType string `gorm:"not null;default:'markdown' "`
+		Visible     bool   `gorm:"not null"`
+	}
+
+	type MenuItem struct {
+		gorm.Model
+		Label    string  `gorm:"not null"`
+		URL      *string `gorm:"null"`
+		PageID   *uint   `gorm:"null"`
+		Position int     `gorm:"not null;default:0"`
+	}
+
+	type Settings struct {
+		gorm.Model
+		Title         string `gorm:"not null"`
+		Subtitle      string `gorm:"not null"`
+		ChromaStyle   string `gorm:"not null"`
+		Theme         string `gorm:"not null"`
+		PostsPerPage  int    `gorm:"not null"`
+		LogoID        *uint
+		UseFavicon    bool   `gorm:"not null;default:false"`
+		RobotsTxt     string `gorm:"type:text"`
+		PreviewSecret string
+	}
+
+	type Media struct {
+		gorm.Model
+		Name        string `gorm:"not null"`
+		Path        string `gorm:"size:255;not null;unique"`
+		MimeType    string `gorm:"not null"`
+		Size        int64  `gorm:"not null"`
+		Description string `gorm:"type:text"`
+	}
+
+	type PostRevision struct {
+		gorm.Model
+		PostID   uint   `gorm:"index;not null"`
+		Title    string `gorm:"not null"`
+		Content  string `gorm:"not null"`
+		Excerpt  string `gorm:"type:text"`
+		Tags     string `gorm:"type:text"`
+		AuthorID uint
+	}
+
+	type PageRevision struct {
+		gorm.Model
+		PageID      uint   `gorm:"index;not null"`
+		Title       string `gorm:"not null"`
+		Content     string `gorm:"not null"`
+		ContentType string `gorm:"not null;default:'markdown'"`
+		AuthorID    uint
+	}
+
+	type APIToken struct {
+		gorm.Model
+		UserID     uint   `gorm:"index;not null"`
+		Name       string `gorm:"not null"`
+		Prefix     string `gorm:"not null"`
+		TokenHash  string `gorm:"size:255;uniqueIndex;not null"`
+		Scopes     string `gorm:"type:text"`
+		LastUsedAt *time.Time
+	}
+
+	type RecoveryCode struct {
+		gorm.Model
+		UserID   uint   `gorm:"index;not null"`
+		CodeHash string `gorm:"not null"`
+		UsedAt   *time.Time
+	}
+
+	type LoginAttempt struct {
+		gorm.Model
+		Email     string `gorm:"index"`
+		UserID    *uint  `gorm:"index"`
+		IP        string `gorm:"index"`
+		UserAgent string
+		Success   bool   `gorm:"not null;default:false"`
+		Result    string `gorm:"not null"`
+	}
+
+	type LoginThrottle struct {
+		gorm.Model
+		Key           string `gorm:"column:throttle_key;size:255;uniqueIndex;not null"`
+		Failures      int    `gorm:"not null;default:0"`
+		LastFailureAt time.Time
+		BlockedUntil  time.Time `gorm:"index"`
+	}
+
+	if err := tx.AutoMigrate(
+		&Post{},
+		&Tag{},
+		&User{},
+		&Page{},
+		&MenuItem{},
+		&Settings{},
+		&Media{},
+		&PostRevision{},
+		&PageRevision{},
+		&APIToken{},
+		&RecoveryCode{},
+		&LoginAttempt{},
+		&LoginThrottle{},
+	); err != nil {
+		return err
+	}
+
+	return initialSearchIndexUp(tx)
+}
+
+// initialSearchIndexUp creates the search index and fills it from the posts and
+// pages of databases created before migrations existed
+func initialSearchIndexUp(tx *gorm.DB) error {
+	// PostgreSQL and MySQL have no FTS5, SQLite uses a virtual table with the same columns
+	type SearchIndex struct {
+		ID      uint   `gorm:"primaryKey"`
+		Title   string `gorm:"type:text"`
+		Content string `gorm:"type:text"`
+		Kind    string `gorm:"size:16;index:idx_search_index_ref"`
+		RefID   uint   `gorm:"index:idx_search_index_ref"`
+	}
+
+	if tx.Migrator().HasTable("search_index") {
+		return nil
+	}
+
+	if tx.Dialector.Name() == DriverSQLite {
+		if err := tx.Exec("CREATE VIRTUAL TABLE search_index USING fts5(title, content, kind UNINDEXED, ref_id UNINDEXED, tokenize = 'porter unicode61')").Error; err != nil {
+			return fmt.Errorf("failed to create search index: %w", err)
+		}
+	} else if err := tx.Table("search_index").Migrator().CreateTable(&SearchIndex{}); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	postContent := "COALESCE(excerpt, '') || ? || content"
+	if tx.Dialector.Name() == DriverMySQL {
+		postContent = "CONCAT(COALESCE(excerpt, ''), ?, content)"
+	}
+
+	if err := tx.Exec(`INSERT INTO search_index (title, content, kind, ref_id)
+		SELECT title, `+postContent+`, 'post', id FROM posts WHERE deleted_at IS NULL`, "\n\n").Error; err != nil {
+		return fmt.Errorf("failed to index posts: %w", err)
+	}
+	if err := tx.Exec(`INSERT INTO search_index (title, content, kind, ref_id)
+		SELECT title, content, 'page', id FROM pages WHERE deleted_at IS NULL`).Error; err != nil {
+		return fmt.Errorf("failed to index pages: %w", err)
+	}
+	return nil
+}
+
+func initialSchemaDown(tx *gorm.DB) error {
+	// Migrator.DropTable needs a dedicated connection on MySQL, which dry runs lack
+	for _, table := range initialSchemaTables {
+		if err := tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: table}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mediaVariantsUp(tx *gorm.DB) error {
+	type Media struct {
+		gorm.Model
+		Width  int `gorm:"not null;default:0"`
+		Height int `gorm:"not null;default:0"`
+	}
+
+	type MediaVariant struct {
+		gorm.Model
+		MediaID  uint   `gorm:"not null;uniqueIndex:idx_media_variants_key"`
+		Key      string `gorm:"column:variant_key;size:64;not null;uniqueIndex:idx_media_variants_key"`
+		Path     string `gorm:"size:255;not null"`
+		MimeType string `gorm:"not null"`
+		Size     int64  `gorm:"not null"`
+		Width    int    `gorm:"not null"`
+		Height   int    `gorm:"not null"`
+	}
+
+	return tx.AutoMigrate(&Media{}, &MediaVariant{})
+}
+
+func mediaVariantsDown(tx *gorm.DB) error {
+	if err := tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: "media_variants"}).Error; err != nil {
+		return err
+	}
+	for _, column := range []string{"width", "height"} {
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "media"}, clause.Column{Name: column}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mediaMetadataUp(tx *gorm.DB) error {
+	type Media struct {
+		gorm.Model
+		AltText string `gorm:"type:text"`
+		Caption string `gorm:"type:text"`
+		Folder  string `gorm:"size:255;not null;default:'';index"`
+		TakenAt *time.Time
+	}
+
+	return tx.AutoMigrate(&Media{})
+}
+
+func mediaMetadataDown(tx *gorm.DB) error {
+	type Media struct {
+		gorm.Model
+		Folder string `gorm:"index"`
+	}
+
+	// Indexed columns cannot be dropped
+	if tx.Migrator().HasIndex(&Media{}, "idx_media_folder") {
+		if err := tx.Migrator().DropIndex(&Media{}, "idx_media_folder"); err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"alt_text", "caption", "folder", "taken_at"} {
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "media"}, clause.Column{Name: column}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mediaUploadsUp(tx *gorm.DB) error {
+	type MediaUpload struct {
+		gorm.Model
+		UserID    uint      `gorm:"not null;index"`
+		Filename  string    `gorm:"size:255;not null"`
+		MimeType  string    `gorm:"size:255;not null"`
+		Size      int64     `gorm:"not null"`
+		Received  int64     `gorm:"not null;default:0"`
+		Direct    bool      `gorm:"not null;default:false"`
+		Path      string    `gorm:"size:255;not null;default:''"`
+		ExpiresAt time.Time `gorm:"not null;index"`
+	}
+
+	return tx.AutoMigrate(&MediaUpload{})
+}
+
+func mediaUploadsDown(tx *gorm.DB) error {
+	return tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: "media_uploads"}).Error
+}
+
+// mediaPathUniques are the names of the unique constraint of the path of media:
+// the one of GORM, and the defaults of PostgreSQL and MySQL for databases
+// created by older versions of GORM
+var mediaPathUniques = []string{"uni_media_path", "media_path_key", "path"}
+
+func mediaHashesUp(tx *gorm.DB) error {
+	type Media struct {
+		gorm.Model
+		Path   string `gorm:"size:255;not null;index"`
+		Folder string `gorm:"index"`
+		Hash   string `gorm:"size:64;not null;default:'';index"`
+	}
+
+	// Media with the same content share their file
+	for _, name := range mediaPathUniques {
+		// MySQL keeps unique constraints as indexes
+		if tx.Migrator().HasIndex(&Media{}, name) {
+			if err := tx.Migrator().DropIndex(&Media{}, name); err != nil {
+				return err
+			}
+		} else if tx.Migrator().HasConstraint(&Media{}, name) {
+			if err := tx.Migrator().DropConstraint(&Media{}, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !tx.Migrator().HasColumn(&Media{}, "Hash") {
+		if err := tx.Migrator().AddColumn(&Media{}, "Hash"); err != nil {
+			return err
+		}
+	}
+	// SQLite drops the indexes of the table when dropping its constraint
+	for _, index := range []string{"idx_media_deleted_at", "idx_media_folder", "idx_media_path", "idx_media_hash"} {
+		if !tx.Migrator().HasIndex(&Media{}, index) {
+			if err := tx.Migrator().CreateIndex(&Media{}, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mediaHashesDown(tx *gorm.DB) error {
+	type Media struct {
+		gorm.Model
+		Path string `gorm:"index"`
+		Hash string `gorm:"index"`
+	}
+
+	for _, index := range []string{"idx_media_path", "idx_media_hash"} {
+		if tx.Migrator().HasIndex(&Media{}, index) {
+			if err := tx.Migrator().DropIndex(&Media{}, index); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "media"}, clause.Column{Name: "hash"}).Error; err != nil {
+		return err
+	}
+	// Fails while several media share a file
+	return tx.Exec("CREATE UNIQUE INDEX ? ON ? (?)", clause.Column{Name: "uni_media_path"}, clause.Table{Name: "media"}, clause.Column{Name: "path"}).Error
+}
+
+// sessionsUp creates the table of the sessions kept in the database. With SQLite,
+// the session storage may have created it already, with the same columns.
+func sessionsUp(tx *gorm.DB) error {
+	type Session struct {
+		Key    string `gorm:"column:k;primaryKey;size:64"`
+		Value  []byte `gorm:"column:v;not null"`
+		Expiry int64  `gorm:"column:e;not null;default:0;index:idx_fiber_storage_e"`
+	}
+
+	sessions := tx.Table("fiber_storage")
+	if sessions.Migrator().HasTable("fiber_storage") {
+		return nil
+	}
+	return sessions.Migrator().CreateTable(&Session{})
+}
+
+func sessionsDown(tx *gorm.DB) error {
+	return tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: "fiber_storage"}).Error
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sessionGCInterval is how often expired sessions are deleted
+const sessionGCInterval = 10 * time.Second
+
+// sessionEntry is a session of the fiber_storage table, with the same columns
+// as the SQLite session storage
+type sessionEntry struct {
+	Key    string `gorm:"column:k;primaryKey;size:64"`
+	Value  []byte `gorm:"column:v;not null"`
+	Expiry int64  `gorm:"column:e;not null;default:0;index"`
+}
+
+func (sessionEntry) TableName() string {
+	return "fiber_storage"
+}
+
+// SessionStorage is a fiber.Storage keeping the sessions in the database, used
+// with the PostgreSQL and MySQL drivers so sessions are shared between replicas
+type SessionStorage struct {
+	db   *gorm.DB
+	done chan struct{}
+}
+
+// NewSessionStorage starts deleting expired sessions. The sessions table is
+// created by the migrations.
+func NewSessionStorage(db *gorm.DB) (*SessionStorage, error) {
+	s := &SessionStorage{db: db, done: make(chan struct{})}
+	go s.gcTicker()
+
+	return s, nil
+}
+
+// Get returns the value of a key, or nil if it does not exist or expired
+func (s *SessionStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	var entry sessionEntry
+	err := s.db.Where("k = ?", key).Take(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Expiry != 0 && entry.Expiry <= time.Now().Unix() {
+		return nil, nil
+	}
+
+	return entry.Value, nil
+}
+
+// Set stores the value of a key, expiring after exp unless it is 0
+func (s *SessionStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	entry := sessionEntry{Key: key, Value: val}
+	if exp != 0 {
+		entry.Expiry = time.Now().Add(exp).Unix()
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "k"}},
+		DoUpdates: clause.AssignmentColumns([]string{"v", "e"}),
+	}).Create(&entry).Error
+}
+
+// Delete deletes a key
+func (s *SessionStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.db.Where("k = ?", key).Delete(&sessionEntry{}).Error
+}
+
+// Reset deletes all the keys, including unexpired ones
+func (s *SessionStorage) Reset() error {
+	return s.db.Where("1 = 1").Delete(&sessionEntry{}).Error
+}
+
+// Close stops deleting expired sessions. The database connection is left open.
+func (s *SessionStorage) Close() error {
+	close(s.done)
+	return nil
+}
+
+// gcTicker periodically deletes the expired sessions
+func (s *SessionStorage) gcTicker() {
+	ticker := time.NewTicker(sessionGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case t := <-ticker.C:
+			s.db.Where("e <= ? AND e != 0", t.Unix()).Delete(&sessionEntry{})
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionStorage(t *testing.T) {
+	storage, err := NewSessionStorage(SetupTestDB())
+	require.NoError(t, err)
+	defer storage.Close()
+
+	value, err := storage.Get("missing")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, storage.Set("session", []byte("first"), time.Hour))
+	require.NoError(t, storage.Set("session", []byte("second"), time.Hour))
+	value, err = storage.Get("session")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), value)
+
+	// Expired keys are not returned
+	require.NoError(t, storage.Set("expired", []byte("value"), -time.Second))
+	value, err = storage.Get("expired")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, storage.Delete("session"))
+	value, err = storage.Get("session")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, storage.Set("other", []byte("value"), 0))
+	require.NoError(t, storage.Reset())
+	value, err = storage.Get("other")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
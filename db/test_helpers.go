@@ -2,23 +2,59 @@ package db
 
 import (
 	"fmt"
+	"os"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// SetupTestDB returns a migrated in-memory SQLite database. Tests run against
+// PostgreSQL or MySQL when the CAPTAIN_TEST_DB_DRIVER and CAPTAIN_TEST_DB_DSN
+// environment variables are set, after dropping all the tables of that database.
 func SetupTestDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent),
+	db := OpenTestDB()
+
+	// Migrate the schema
+	if err := ExecuteMigrations(db); err != nil {
+		panic(fmt.Sprintf("failed to migrate: %v", err))
+	}
+
+	return db
+}
+
+// OpenTestDB returns the empty test database, without migrating it
+func OpenTestDB() *gorm.DB {
+	driver := os.Getenv("CAPTAIN_TEST_DB_DRIVER")
+	dataSource := ":memory:"
+	if driver != "" && driver != DriverSQLite {
+		dataSource = os.Getenv("CAPTAIN_TEST_DB_DSN")
+	}
+
+	db, err := Open(driver, dataSource, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
 	})
 	if err != nil {
-		panic("failed to connect database")
+		panic(fmt.Sprintf("failed to connect database: %v", err))
 	}
 
-	// Migrate the schema
-	if err := ExecuteMigrations(db); err != nil {
-		panic(fmt.Sprintf("failed to auto-migrate: %v", err))
+	if dataSource == ":memory:" {
+		// Each connection to an in-memory database opens a new, empty database
+		sqlDB, err := db.DB()
+		if err != nil {
+			panic(fmt.Sprintf("failed to get the connection pool: %v", err))
+		}
+		sqlDB.SetMaxOpenConns(1)
+	} else {
+		tables, err := db.Migrator().GetTables()
+		if err != nil {
+			panic(fmt.Sprintf("failed to list tables: %v", err))
+		}
+		for _, table := range tables {
+			if err := db.Migrator().DropTable(table); err != nil {
+				panic(fmt.Sprintf("failed to drop table %s: %v", table, err))
+			}
+		}
 	}
 
 	return db
@@ -1,5 +1,32 @@
+// csrfFetch works like fetch, sending the CSRF token of the session required
+// by every admin request that is not a GET
+function csrfFetch(url, options = {}) {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    const headers = new Headers(options.headers || {});
+
+    if (meta) {
+        headers.set('X-CSRF-Token', meta.content);
+    }
+
+    return fetch(url, { ...options, headers });
+}
+
+// responseJSON parses the JSON body of a response. Other bodies, like the login
+// page served once the session expired, are turned into an error message.
+async function responseJSON(resp) {
+    const type = resp.headers.get('Content-Type') || '';
+    if (resp.status === 204 || !type.includes('application/json')) {
+        return resp.ok ? {} : { error: `The request failed (${resp.status} ${resp.statusText})` };
+    }
+    try {
+        return await resp.json();
+    } catch (e) {
+        return { error: 'The server sent an invalid response' };
+    }
+}
+
 function deleteTag(id) {
-    fetch(`/admin/tags/${id}`, {
+    csrfFetch(`/admin/tags/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -12,7 +39,7 @@ function deleteTag(id) {
 }
 
 function deletePost(id) {
-    fetch(`/admin/posts/${id}`, {
+    csrfFetch(`/admin/posts/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -25,7 +52,7 @@ function deletePost(id) {
 }
 
 function deletePage(id) {
-    fetch(`/admin/pages/${id}`, {
+    csrfFetch(`/admin/pages/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -38,7 +65,7 @@ function deletePage(id) {
 }
 
 function deleteMenuItem(id) {
-    fetch(`/admin/menus/${id}`, {
+    csrfFetch(`/admin/menus/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -51,7 +78,7 @@ function deleteMenuItem(id) {
 }
 
 function deleteMedia(id) {
-    fetch(`/admin/media/${id}`, {
+    csrfFetch(`/admin/media/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -64,7 +91,7 @@ function deleteMedia(id) {
 }
 
 function deleteUser(id) {
-    fetch(`/admin/users/${id}`, {
+    csrfFetch(`/admin/users/${id}`, {
         method: 'DELETE',
     }).then((response) => response.json())
         .then((data) => {
@@ -171,7 +198,7 @@ function moveItem(id, direction) {
         return; // Don't move if button is disabled
     }
 
-    fetch(`/admin/menus/${id}/move/${direction}`, {
+    csrfFetch(`/admin/menus/${id}/move/${direction}`, {
         method: 'POST',
     }).then(response => {
         if (response.ok) {
@@ -214,6 +241,130 @@ function initializeMenuToggle() {
     }
 }
 
+// uploadJSON sends a JSON request to the media uploads API, throwing its error
+async function uploadJSON(url, method, body) {
+    const resp = await csrfFetch(url, {
+        method,
+        headers: { 'Content-Type': 'application/json' },
+        body: body && JSON.stringify(body),
+    });
+    const json = await responseJSON(resp);
+    if (!resp.ok) {
+        throw new Error(json.error || resp.statusText);
+    }
+    return json;
+}
+
+// putDirect sends a file straight to the storage provider, reporting the
+// progress of the transfer
+function putDirect(upload, file, onProgress) {
+    return new Promise((resolve, reject) => {
+        const xhr = new XMLHttpRequest();
+        xhr.open('PUT', upload.url);
+        Object.entries(upload.headers || {}).forEach(([name, value]) => xhr.setRequestHeader(name, value));
+        xhr.upload.addEventListener('progress', (event) => onProgress(event.loaded));
+        xhr.addEventListener('load', () => {
+            if (xhr.status >= 200 && xhr.status < 300) {
+                resolve();
+            } else {
+                reject(new Error(`The storage rejected the file (${xhr.status})`));
+            }
+        });
+        xhr.addEventListener('error', () => reject(new Error('The file could not be sent to the storage')));
+        xhr.send(file);
+    });
+}
+
+// putChunks sends a file in chunks from the data already received, so uploads
+// interrupted by a network error resume where they stopped
+async function putChunks(upload, file, onProgress) {
+    let received = upload.received;
+    while (received < file.size) {
+        const chunk = file.slice(received, received + upload.chunkSize);
+        const resp = await csrfFetch(`/admin/api/media/uploads/${upload.id}`, {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/octet-stream',
+                'Upload-Offset': String(received),
+            },
+            body: chunk,
+        });
+        const json = await resp.json();
+        if (resp.status === 409) {
+            // Another tab sent some of the chunks
+            received = json.received;
+            continue;
+        }
+        if (!resp.ok) {
+            throw new Error(json.error || resp.statusText);
+        }
+        received = json.received;
+        onProgress(received);
+    }
+}
+
+// initializeMediaUpload sends the file of the upload form through the media
+// uploads API, which is not limited by the size of request bodies. The form is
+// submitted as is without JavaScript.
+function initializeMediaUpload() {
+    const form = document.querySelector('form[data-media-upload]');
+    if (!form) {
+        return;
+    }
+    const progress = form.querySelector('progress');
+    const status = form.querySelector('.upload-status');
+    const button = form.querySelector('button[type="submit"]');
+
+    form.addEventListener('submit', async (event) => {
+        event.preventDefault();
+        const file = form.elements.file.files[0];
+        if (!file) {
+            return;
+        }
+        const key = `media-upload:${file.name}:${file.size}:${file.lastModified}`;
+        const onProgress = (sent) => {
+            progress.value = sent;
+            status.textContent = `${Math.floor(sent * 100 / file.size)}%`;
+        };
+
+        button.disabled = true;
+        progress.max = file.size;
+        progress.hidden = false;
+
+        try {
+            let upload = null;
+            const previous = localStorage.getItem(key);
+            if (previous) {
+                upload = await uploadJSON(`/admin/api/media/uploads/${previous}`, 'GET').catch(() => null);
+            }
+            if (!upload) {
+                upload = await uploadJSON('/admin/api/media/uploads', 'POST', { filename: file.name, size: file.size });
+            }
+
+            if (upload.direct) {
+                await putDirect(upload, file, onProgress);
+            } else {
+                localStorage.setItem(key, upload.id);
+                onProgress(upload.received);
+                await putChunks(upload, file, onProgress);
+            }
+
+            status.textContent = 'Processing…';
+            const json = await uploadJSON(`/admin/api/media/uploads/${upload.id}/complete`, 'POST', {
+                description: form.elements.description.value,
+                altText: form.elements.altText.value,
+                caption: form.elements.caption.value,
+                folder: form.elements.folder.value,
+            });
+            localStorage.removeItem(key);
+            window.location.href = json.redirect;
+        } catch (err) {
+            status.textContent = `Upload failed: ${err.message}. Submit the form again to resume.`;
+            button.disabled = false;
+        }
+    });
+}
+
 !(function (win, doc) {
     function openMediaModal(cb) {
         doc.getElementById('mediaModal').style.display = 'block';
@@ -239,7 +390,7 @@ function initializeMenuToggle() {
                     if (item.MimeType.startsWith('image/')) {
                         div.innerHTML = `
                             <div class="media-preview">
-                                <img src="/media/${item.Path}" alt="${item.Name}">
+                                <img src="/media/${item.Path}" alt="${item.AltText || item.Name}">
                             </div>
                             <div class="media-info">
                                 <h3>${item.Name}</h3>
@@ -269,10 +420,11 @@ function initializeMenuToggle() {
         let tag;
 
         if (media.MimeType.startsWith('image/')) {
-            // For images
+            // For images, described by their alt text
+            const alt = media.AltText || media.Name;
             tag = format === 'markdown'
-                ? `![${media.Name}](/media/${media.Path})`
-                : `<img src="/media/${media.Path}" alt="${media.Name}">`;
+                ? `![${alt}](/media/${media.Path})`
+                : `<img src="/media/${media.Path}" alt="${alt}">`;
         } else {
             // For other files
             tag = format === 'markdown'
@@ -351,14 +503,14 @@ function openLogoMediaSelector() {
             }
             done('saving');
 
-            const resp = await fetch(url, {
+            const resp = await csrfFetch(url, {
                 method,
                 headers: {
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify(data),
             });
-            const json = await resp.json();
+            const json = await responseJSON(resp);
 
             if (resp.ok) {
                 if (json.redirect) {
@@ -383,16 +535,16 @@ function openLogoMediaSelector() {
             }
             done('saving');
 
-            const resp = await fetch(url, {
+            const resp = await csrfFetch(url, {
                 method,
                 headers: {
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify(data),
             });
+            const json = await responseJSON(resp);
 
             if (resp.ok) {
-                const json = await resp.json();
                 if (json.redirect) {
                     done('saved');
                     window.location.href = json.redirect;
@@ -412,4 +564,5 @@ document.addEventListener('DOMContentLoaded', () => {
     initializeMenuItemForm();
     initializeMenuItems();
     initializeMenuToggle();
+    initializeMediaUpload();
 });
@@ -3,6 +3,8 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
 	"github.com/captain-corp/captain/storage"
 
@@ -11,18 +13,27 @@ import (
 
 // AdminHandlers contains handlers for admin routes
 type AdminHandlers struct {
-	repos   *repository.Repositories
+	*BaseHandlers
 	storage storage.Provider
 }
 
 // NewAdminHandlers creates a new AdminHandlers instance
-func NewAdminHandlers(repos *repository.Repositories, storage storage.Provider) *AdminHandlers {
+func NewAdminHandlers(repos *repository.Repositories, cfg *config.Config, storage storage.Provider) *AdminHandlers {
 	return &AdminHandlers{
-		repos:   repos,
-		storage: storage,
+		BaseHandlers: NewBaseHandlers(repos, cfg),
+		storage:      storage,
 	}
 }
 
+// currentUser returns the logged in user, set by the LoadUserData middleware
+func currentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals("user").(*models.User)
+	if user == nil {
+		return &models.User{}
+	}
+	return user
+}
+
 // Index handles the GET /admin route
 func (h *AdminHandlers) Index(c *fiber.Ctx) error {
 	posts, err := h.repos.Posts.FindAll()
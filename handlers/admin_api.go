@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/middleware"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 )
 
 // tagResponse struct for API responses
@@ -103,6 +105,11 @@ func (h *AdminHandlers) ApiCreatePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid publish date"})
 	}
 
+	// Users who cannot publish may only save drafts
+	if !user.Can(models.PermissionPublishPosts) {
+		post.Visible = false
+	}
+
 	newPost := &models.Post{
 		Title:                     post.Title,
 		Slug:                      post.Slug,
@@ -133,6 +140,11 @@ func (h *AdminHandlers) ApiCreatePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to associate tags"})
 	}
 
+	if err := h.repos.PostRevisions.Create(models.NewPostRevision(newPost, post.Tags, user.ID)); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	flash.Success(c, "Post created successfully")
 
 	return c.JSON(fiber.Map{"message": "Post created successfully", "redirect": "/admin/posts"})
@@ -157,6 +169,24 @@ func (h *AdminHandlers) ApiUpdatePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	// Get the logged in user
+	exists := c.Locals("user")
+	if exists == nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "User session not found",
+		})
+	}
+	user := exists.(*models.User)
+
+	if !user.CanEditPost(postToUpdate) {
+		return middleware.Forbidden(c)
+	}
+
+	// Users who cannot publish may only save drafts
+	if !user.Can(models.PermissionPublishPosts) {
+		post.Visible = false
+	}
+
 	publishedAt, err := parseTime(post.PublishedAt, post.Timezone)
 	if err != nil {
 		// TODO: Log error
@@ -164,6 +194,11 @@ func (h *AdminHandlers) ApiUpdatePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid publish date"})
 	}
 
+	if err := ensurePostBaselineRevision(h.repos, postToUpdate); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	postToUpdate.Title = post.Title
 	postToUpdate.Slug = post.Slug
 	postToUpdate.Content = post.Content
@@ -191,6 +226,11 @@ func (h *AdminHandlers) ApiUpdatePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to associate tags"})
 	}
 
+	if err := h.repos.PostRevisions.Create(models.NewPostRevision(postToUpdate, post.Tags, user.ID)); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	flash.Success(c, "Post updated successfully")
 
 	return c.JSON(fiber.Map{"message": "Post updated successfully", "redirect": "/admin/posts"})
@@ -204,6 +244,15 @@ func (h *AdminHandlers) ApiCreatePage(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	// Get the logged in user
+	exists := c.Locals("user")
+	if exists == nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "User session not found",
+		})
+	}
+	user := exists.(*models.User)
+
 	newPage := &models.Page{
 		Title:       page.Title,
 		Slug:        page.Slug,
@@ -223,6 +272,11 @@ func (h *AdminHandlers) ApiCreatePage(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create page"})
 	}
 
+	if err := h.repos.PageRevisions.Create(models.NewPageRevision(newPage, user.ID)); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	flash.Success(c, "Page created successfully")
 
 	return c.JSON(fiber.Map{"message": "Page created successfully", "redirect": "/admin/pages"})
@@ -246,6 +300,20 @@ func (h *AdminHandlers) ApiUpdatePage(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Page not found"})
 	}
 
+	// Get the logged in user
+	exists := c.Locals("user")
+	if exists == nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "User session not found",
+		})
+	}
+	user := exists.(*models.User)
+
+	if err := ensurePageBaselineRevision(h.repos, pageToUpdate); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	pageToUpdate.Title = page.Title
 	pageToUpdate.Slug = page.Slug
 	pageToUpdate.Content = page.Content
@@ -263,6 +331,11 @@ func (h *AdminHandlers) ApiUpdatePage(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update page"})
 	}
 
+	if err := h.repos.PageRevisions.Create(models.NewPageRevision(pageToUpdate, user.ID)); err != nil {
+		log.Warnf("Failed to save revision: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save revision"})
+	}
+
 	flash.Success(c, "Page updated successfully")
 
 	return c.JSON(fiber.Map{"message": "Page updated successfully", "redirect": "/admin/pages"})
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/captain-corp/captain/archive"
+	"github.com/captain-corp/captain/flash"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// ExportSite handles the GET /admin/settings/export route, streaming the site archive
+func (h *AdminHandlers) ExportSite(c *fiber.Ctx) error {
+	opts := archive.ExportOptions{IncludePasswords: c.Query("passwords") == "on"}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment(fmt.Sprintf("captain-export-%s.zip", time.Now().Format("20060102-150405")))
+
+	// The response is already sent when the export fails, errors can only be logged
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		report, err := archive.Export(w, h.repos, h.storage, opts)
+		if err != nil {
+			log.Errorf("Site export failed: %v", err)
+			return
+		}
+		for _, path := range report.MissingMedia {
+			log.Warnf("Media %s left out of the export: its file could not be read", path)
+		}
+		if err := w.Flush(); err != nil {
+			log.Errorf("Site export failed: %v", err)
+		}
+	})
+
+	return nil
+}
+
+// ImportSite handles the POST /admin/settings/import route
+func (h *AdminHandlers) ImportSite(c *fiber.Ctx) error {
+	strategy, err := archive.ParseStrategy(c.FormValue("strategy"))
+	if err != nil {
+		flash.Error(c, "Invalid conflict strategy")
+		return c.Redirect("/admin/settings")
+	}
+
+	fileHeader, err := c.FormFile("archive")
+	if err != nil {
+		flash.Error(c, "Please choose an archive to import")
+		return c.Redirect("/admin/settings")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		flash.Error(c, "Failed to read the archive")
+		return c.Redirect("/admin/settings")
+	}
+	defer file.Close()
+
+	report, err := archive.Import(file, fileHeader.Size, h.repos, h.storage, archive.ImportOptions{
+		Strategy:      strategy,
+		DefaultAuthor: currentUser(c),
+	})
+	if err != nil {
+		flash.Error(c, fmt.Sprintf("Import failed: %v", err))
+		return c.Redirect("/admin/settings")
+	}
+
+	flash.Success(c, fmt.Sprintf("Site imported. Posts: %s. Pages: %s. Media: %s. Users: %s.",
+		report.Posts, report.Pages, report.Media, report.Users))
+	if len(report.UsersWithoutPassword) > 0 {
+		flash.Warning(c, fmt.Sprintf("%d imported users have no password and cannot log in until you set one", len(report.UsersWithoutPassword)))
+	}
+
+	return c.Redirect("/admin/settings")
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/system"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListLoginActivity handles the GET /admin/logins route
+func (h *AdminHandlers) ListLoginActivity(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	attempts, total, err := h.repos.Logins.FindAttemptsPaginated(page, system.LoginActivityPerPage)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	blocked, err := h.repos.Logins.FindBlockedThrottles(time.Now())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_logins", fiber.Map{
+		"title":       "Login Activity",
+		"attempts":    attempts,
+		"blocked":     blocked,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(system.LoginActivityPerPage))),
+	})
+}
+
+// UnlockLogin handles the POST /admin/logins/unlock route
+func (h *AdminHandlers) UnlockLogin(c *fiber.Ctx) error {
+	key := strings.TrimSpace(c.FormValue("key"))
+	if key == "" {
+		flash.Error(c, "Missing key to unlock")
+		return c.Redirect("/admin/logins")
+	}
+
+	if err := h.repos.Logins.DeleteThrottle(key); err != nil {
+		flash.Error(c, "Failed to unlock "+key)
+		return c.Redirect("/admin/logins")
+	}
+
+	flash.Success(c, "Unlocked "+key)
+	return c.Redirect("/admin/logins")
+}
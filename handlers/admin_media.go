@@ -1,35 +1,66 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/mediacheck"
+	"github.com/captain-corp/captain/mediastore"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
 	"github.com/captain-corp/captain/storage"
 	"github.com/captain-corp/captain/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // AdminMediaHandlers handles admin media routes
 type AdminMediaHandlers struct {
+	repos     *repository.Repositories
 	storage   storage.Provider
 	mediaRepo models.MediaRepository
+	variants  *ImageVariants
+	uploads   *MediaUploads
 }
 
 // NewAdminMediaHandlers creates a new AdminMediaHandlers instance
-func NewAdminMediaHandlers(repos *repository.Repositories, storage storage.Provider) *AdminMediaHandlers {
+func NewAdminMediaHandlers(repos *repository.Repositories, storage storage.Provider, variants *ImageVariants, uploads *MediaUploads) *AdminMediaHandlers {
 	return &AdminMediaHandlers{
+		repos:     repos,
 		storage:   storage,
 		mediaRepo: repos.Media,
+		variants:  variants,
+		uploads:   uploads,
 	}
 }
 
-// ListMedia displays the list of media files
+// mediaTypes are the media types the media library can be filtered by
+var mediaTypes = []string{models.MediaTypeImage, models.MediaTypeVideo, models.MediaTypeAudio, models.MediaTypeDocument}
+
+// ListMedia displays the list of media files, filtered by the q, folder and
+// type query parameters
 func (h *AdminMediaHandlers) ListMedia(c *fiber.Ctx) error {
-	media, err := h.mediaRepo.FindAll()
+	filter := models.MediaFilter{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Folder: models.NormalizeFolder(c.Query("folder")),
+		Type:   c.Query("type"),
+	}
+
+	media, err := h.mediaRepo.Search(filter)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	folders, err := h.mediaRepo.FindFolders()
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
 			"error": err.Error(),
@@ -37,15 +68,28 @@ func (h *AdminMediaHandlers) ListMedia(c *fiber.Ctx) error {
 	}
 
 	return c.Render("admin_media_list", fiber.Map{
-		"title": "Media Library",
-		"media": media,
+		"title":      "Media Library",
+		"media":      media,
+		"filter":     filter,
+		"filtered":   filter != models.MediaFilter{},
+		"folders":    folders,
+		"mediaTypes": mediaTypes,
 	})
 }
 
 // ShowUploadMedia displays the upload media form
 func (h *AdminMediaHandlers) ShowUploadMedia(c *fiber.Ctx) error {
+	folders, err := h.mediaRepo.FindFolders()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	return c.Render("admin_media_upload", fiber.Map{
-		"title": "Upload Media",
+		"title":   "Upload Media",
+		"folder":  models.NormalizeFolder(c.Query("folder")),
+		"folders": folders,
 	})
 }
 
@@ -53,6 +97,7 @@ func (h *AdminMediaHandlers) ShowUploadMedia(c *fiber.Ctx) error {
 func (h *AdminMediaHandlers) UploadMedia(c *fiber.Ctx) error {
 	file, err := c.FormFile("file")
 	description := c.FormValue("description")
+	folder := c.FormValue("folder")
 
 	if err != nil {
 		flash.Error(c, "No file uploaded")
@@ -69,8 +114,11 @@ func (h *AdminMediaHandlers) UploadMedia(c *fiber.Ctx) error {
 		})
 	}
 
-	// Save file using storage provider
-	filename, err := h.storage.Save(file.Filename, multipartFile)
+	defer multipartFile.Close()
+
+	// Save file using storage provider, identical files are stored once
+	metadata, reader := inspectUpload(multipartFile)
+	stored, err := mediastore.Save(h.repos, h.storage, file.Filename, reader)
 	if err != nil {
 		flash.Error(c, fmt.Sprintf("Failed to save file: %v", err))
 		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
@@ -81,31 +129,111 @@ func (h *AdminMediaHandlers) UploadMedia(c *fiber.Ctx) error {
 	// Create media record
 	media := &models.Media{
 		Name:        file.Filename,
-		Path:        filename,
-		Size:        file.Size,
+		Path:        stored.Path,
+		Size:        stored.Size,
+		Hash:        stored.Hash,
 		Description: description,
+		AltText:     strings.TrimSpace(c.FormValue("altText")),
+		Caption:     strings.TrimSpace(c.FormValue("caption")),
+		Folder:      folder,
+		Width:       metadata.Width,
+		Height:      metadata.Height,
+		TakenAt:     metadata.TakenAt,
 	}
 
-	err = h.mediaRepo.Create(media)
+	// The file is deleted if the database insert fails
+	err = mediastore.Create(h.repos, h.storage, media, stored)
 	if err != nil {
-		// Clean up file if database insert fails
-		if err := h.storage.Delete(filename); err != nil {
-			flash.Error(c, fmt.Sprintf("Failed to delete file: %v", err))
-			return c.Status(http.StatusInternalServerError).Render("admin_media_upload", fiber.Map{
-				"title": "Media library",
-			})
-		}
-
 		flash.Error(c, fmt.Sprintf("Failed to save media record: %v", err))
 		return c.Status(http.StatusInternalServerError).Render("admin_media_upload", fiber.Map{
 			"title": "Media library",
 		})
 	}
 
+	// The variants of shared files are the ones of the first media
+	if !stored.Shared {
+		go h.variants.Pregenerate(media)
+	}
+
 	flash.Success(c, "Media uploaded successfully")
+	if media.Folder != "" {
+		return c.Redirect("/admin/media?folder=" + url.QueryEscape(media.Folder))
+	}
 	return c.Redirect("/admin/media")
 }
 
+// ShowEditMedia displays the form editing the metadata of a media, and the
+// posts and pages using it
+func (h *AdminMediaHandlers) ShowEditMedia(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	media, err := h.mediaRepo.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	return h.renderEditMedia(c, http.StatusOK, media)
+}
+
+// UpdateMedia handles the edit media form
+func (h *AdminMediaHandlers) UpdateMedia(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	media, err := h.mediaRepo.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	if name := strings.TrimSpace(c.FormValue("name")); name != "" {
+		media.Name = name
+	}
+	media.AltText = strings.TrimSpace(c.FormValue("altText"))
+	media.Caption = strings.TrimSpace(c.FormValue("caption"))
+	media.Description = c.FormValue("description")
+	media.Folder = c.FormValue("folder")
+
+	if err := h.mediaRepo.Update(media); err != nil {
+		flash.Error(c, "Failed to update media")
+		return h.renderEditMedia(c, http.StatusInternalServerError, media)
+	}
+
+	flash.Success(c, "Media updated successfully")
+	return c.Redirect(fmt.Sprintf("/admin/media/%d/edit", media.ID))
+}
+
+func (h *AdminMediaHandlers) renderEditMedia(c *fiber.Ctx, status int, media *models.Media) error {
+	usage, err := h.mediaRepo.FindUsage(media)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	folders, err := h.mediaRepo.FindFolders()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(status).Render("admin_edit_media", fiber.Map{
+		"title":   "Edit Media",
+		"media":   media,
+		"usage":   usage,
+		"folders": folders,
+	})
+}
+
 // DeleteMedia handles media deletion
 func (h *AdminMediaHandlers) DeleteMedia(c *fiber.Ctx) error {
 	id, err := utils.ParseUint(c.Params("id"))
@@ -126,17 +254,16 @@ func (h *AdminMediaHandlers) DeleteMedia(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete file using storage provider
-	if err := h.storage.Delete(media.Path); err != nil {
-		flash.Error(c, "Failed to delete media file")
+	// Delete the variants and the file using storage provider
+	if err := h.variants.DeleteAll(media); err != nil {
+		flash.Error(c, "Failed to delete the resized images")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error":    "Failed to delete media file",
+			"error":    "Failed to delete the resized images",
 			"redirect": "/admin/media",
 		})
 	}
-
-	// Delete media record
-	if err := h.mediaRepo.Delete(media); err != nil {
+	// Delete media record, and its file unless other media share it
+	if err := mediastore.Delete(h.repos, h.storage, media); err != nil {
 		flash.Error(c, "Failed to delete media record")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error":    "Failed to delete media record",
@@ -166,8 +293,181 @@ func (h *AdminMediaHandlers) ConfirmDeleteMedia(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
 	}
 
+	// Deleting a media in use breaks the posts and pages linking to it
+	usage, err := h.mediaRepo.FindUsage(media)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	return c.Render("admin_confirm_delete_media", fiber.Map{
 		"title": "Confirm Media deletion",
 		"media": media,
+		"usage": usage,
+	})
+}
+
+// CheckMedia displays the differences between the media and the files of the
+// storage provider, repaired by the media fsck command
+func (h *AdminMediaHandlers) CheckMedia(c *fiber.Ctx) error {
+	report, err := mediacheck.Check(h.repos, h.storage, mediacheck.Options{})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_media_check", fiber.Map{
+		"title":  "Media Check",
+		"report": report,
 	})
 }
+
+// uploadResponse is the state of an upload returned to the browser
+type uploadResponse struct {
+	ID        uint              `json:"id"`
+	Direct    bool              `json:"direct"`
+	URL       string            `json:"url,omitempty"`     // where direct uploads are sent
+	Headers   map[string]string `json:"headers,omitempty"` // to send with direct uploads
+	ChunkSize int64             `json:"chunkSize"`
+	Received  int64             `json:"received"`
+	Size      int64             `json:"size"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+func (h *AdminMediaHandlers) uploadResponse(upload *models.MediaUpload, direct *storage.DirectUpload) uploadResponse {
+	response := uploadResponse{
+		ID:        upload.ID,
+		Direct:    upload.Direct,
+		ChunkSize: h.uploads.ChunkSize(),
+		Received:  upload.Received,
+		Size:      upload.Size,
+		ExpiresAt: upload.ExpiresAt,
+	}
+	if direct != nil {
+		response.URL = direct.URL
+		response.Headers = direct.Headers
+	}
+	return response
+}
+
+// findUpload returns the upload of the id parameter, started by the current user
+func (h *AdminMediaHandlers) findUpload(c *fiber.Ctx) (*models.MediaUpload, error) {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	upload, err := h.repos.MediaUploads.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID != currentUser(c).ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return upload, nil
+}
+
+// ApiStartUpload starts the upload of a large media, sent straight to the
+// storage provider or in chunks
+func (h *AdminMediaHandlers) ApiStartUpload(c *fiber.Ctx) error {
+	var request struct {
+		Filename string `json:"filename"`
+		Size     int64  `json:"size"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	upload, direct, err := h.uploads.Start(currentUser(c), request.Filename, request.Size)
+	if errors.Is(err, errUploadSize) {
+		return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "File too large"})
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(http.StatusCreated).JSON(h.uploadResponse(upload, direct))
+}
+
+// ApiGetUpload returns the state of an upload, to resume it
+func (h *AdminMediaHandlers) ApiGetUpload(c *fiber.Ctx) error {
+	upload, err := h.findUpload(c)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Upload not found"})
+	}
+
+	return c.JSON(h.uploadResponse(upload, nil))
+}
+
+// ApiUploadChunk receives a chunk of an upload, starting at the offset of the
+// Upload-Offset header
+func (h *AdminMediaHandlers) ApiUploadChunk(c *fiber.Ctx) error {
+	upload, err := h.findUpload(c)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Upload not found"})
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Upload-Offset header"})
+	}
+
+	err = h.uploads.WriteChunk(upload, offset, c.Body())
+	if errors.Is(err, errChunkOffset) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error(), "received": upload.Received})
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(h.uploadResponse(upload, nil))
+}
+
+// ApiCompleteUpload creates the media of a fully received upload
+func (h *AdminMediaHandlers) ApiCompleteUpload(c *fiber.Ctx) error {
+	upload, err := h.findUpload(c)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Upload not found"})
+	}
+	var request struct {
+		Description string `json:"description"`
+		AltText     string `json:"altText"`
+		Caption     string `json:"caption"`
+		Folder      string `json:"folder"`
+	}
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	media := &models.Media{
+		Description: request.Description,
+		AltText:     strings.TrimSpace(request.AltText),
+		Caption:     strings.TrimSpace(request.Caption),
+		Folder:      request.Folder,
+	}
+	err = h.uploads.Complete(upload, media)
+	if errors.Is(err, errUploadIncomplete) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Upload incomplete", "received": upload.Received})
+	}
+	if err != nil {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	flash.Success(c, "Media uploaded successfully")
+	redirect := "/admin/media"
+	if media.Folder != "" {
+		redirect += "?folder=" + url.QueryEscape(media.Folder)
+	}
+	return c.Status(http.StatusCreated).JSON(fiber.Map{"id": media.ID, "redirect": redirect})
+}
+
+// ApiAbortUpload cancels an upload and deletes the data received
+func (h *AdminMediaHandlers) ApiAbortUpload(c *fiber.Ctx) error {
+	upload, err := h.findUpload(c)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Upload not found"})
+	}
+
+	h.uploads.Abort(upload)
+	return c.SendStatus(http.StatusNoContent)
+}
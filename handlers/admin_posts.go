@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/middleware"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/utils"
 
@@ -81,6 +82,10 @@ func (h *AdminHandlers) ConfirmDeletePost(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
 	}
 
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
 	return c.Render("admin_confirm_delete_post", fiber.Map{
 		"title": "Confirm Post deletion",
 		"post":  post,
@@ -107,6 +112,10 @@ func (h *AdminHandlers) DeletePost(c *fiber.Ctx) error {
 		})
 	}
 
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
 	// Delete post
 	if err := h.repos.Posts.Delete(post); err != nil {
 		flash.Error(c, "Failed to delete post")
@@ -147,6 +156,10 @@ func (h *AdminHandlers) ShowEditPost(c *fiber.Ctx) error {
 		})
 	}
 
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
 	return c.Render("admin_edit_post", fiber.Map{
 		"title": "Edit Post",
 		"post":  post,
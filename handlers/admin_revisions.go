@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// revisionDiff is the line-level diff of a single field between two revisions
+type revisionDiff struct {
+	Label string
+	Lines []utils.DiffLine
+}
+
+// ListPostRevisions handles the GET /admin/posts/:id/revisions route
+func (h *AdminHandlers) ListPostRevisions(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid post ID")
+		return c.Redirect("/admin/posts")
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
+	revisions, err := h.repos.PostRevisions.FindByPost(post.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	data := fiber.Map{
+		"title":     fmt.Sprintf("Revisions of '%s'", post.Title),
+		"kind":      "posts",
+		"itemID":    post.ID,
+		"itemTitle": post.Title,
+		"revisions": revisions,
+	}
+
+	ids := make([]uint, len(revisions))
+	for i, revision := range revisions {
+		ids[i] = revision.ID
+	}
+
+	if from, to, ok := selectRevisionPair(c, ids); ok {
+		a, b := revisions[from], revisions[to]
+		data["from"] = a
+		data["to"] = b
+		data["diffs"] = changedDiffs([]revisionDiff{
+			{Label: "Title", Lines: utils.DiffLines(a.Title, b.Title)},
+			{Label: "Excerpt", Lines: utils.DiffLines(a.Excerpt, b.Excerpt)},
+			{Label: "Tags", Lines: utils.DiffLines(strings.Join(a.TagNames(), "\n"), strings.Join(b.TagNames(), "\n"))},
+			{Label: "Content", Lines: utils.DiffLines(a.Content, b.Content)},
+		})
+	}
+
+	return c.Render("admin_revisions", data)
+}
+
+// RestorePostRevision handles the POST /admin/posts/:id/revisions/:revision/restore route
+func (h *AdminHandlers) RestorePostRevision(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid post ID")
+		return c.Redirect("/admin/posts")
+	}
+
+	revisionsURL := fmt.Sprintf("/admin/posts/%d/revisions", id)
+
+	revisionID, err := utils.ParseUint(c.Params("revision"))
+	if err != nil {
+		flash.Error(c, "Invalid revision ID")
+		return c.Redirect(revisionsURL)
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
+	revision, err := h.repos.PostRevisions.FindByID(revisionID)
+	if err != nil || revision.PostID != post.ID {
+		flash.Error(c, "Revision not found")
+		return c.Redirect(revisionsURL)
+	}
+
+	// The author of the post is kept, revisions only record who saved them
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Excerpt = nil
+	if revision.Excerpt != "" {
+		post.Excerpt = &revision.Excerpt
+	}
+
+	if err := h.repos.Posts.Update(post); err != nil {
+		flash.Error(c, "Failed to restore revision")
+		return c.Redirect(revisionsURL)
+	}
+
+	tags := revision.TagNames()
+	if err := h.repos.Posts.AssociateTags(post, tags); err != nil {
+		flash.Error(c, "Failed to restore revision tags")
+		return c.Redirect(revisionsURL)
+	}
+
+	if err := h.repos.PostRevisions.Create(models.NewPostRevision(post, tags, user.ID)); err != nil {
+		flash.Error(c, "Revision restored but the new revision could not be saved")
+		return c.Redirect(revisionsURL)
+	}
+
+	flash.Success(c, "Revision restored successfully")
+	return c.Redirect(revisionsURL)
+}
+
+// ListPageRevisions handles the GET /admin/pages/:id/revisions route
+func (h *AdminHandlers) ListPageRevisions(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid page ID")
+		return c.Redirect("/admin/pages")
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	revisions, err := h.repos.PageRevisions.FindByPage(page.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	data := fiber.Map{
+		"title":     fmt.Sprintf("Revisions of '%s'", page.Title),
+		"kind":      "pages",
+		"itemID":    page.ID,
+		"itemTitle": page.Title,
+		"revisions": revisions,
+	}
+
+	ids := make([]uint, len(revisions))
+	for i, revision := range revisions {
+		ids[i] = revision.ID
+	}
+
+	if from, to, ok := selectRevisionPair(c, ids); ok {
+		a, b := revisions[from], revisions[to]
+		data["from"] = a
+		data["to"] = b
+		data["diffs"] = changedDiffs([]revisionDiff{
+			{Label: "Title", Lines: utils.DiffLines(a.Title, b.Title)},
+			{Label: "Content type", Lines: utils.DiffLines(a.ContentType, b.ContentType)},
+			{Label: "Content", Lines: utils.DiffLines(a.Content, b.Content)},
+		})
+	}
+
+	return c.Render("admin_revisions", data)
+}
+
+// RestorePageRevision handles the POST /admin/pages/:id/revisions/:revision/restore route
+func (h *AdminHandlers) RestorePageRevision(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid page ID")
+		return c.Redirect("/admin/pages")
+	}
+
+	revisionsURL := fmt.Sprintf("/admin/pages/%d/revisions", id)
+
+	revisionID, err := utils.ParseUint(c.Params("revision"))
+	if err != nil {
+		flash.Error(c, "Invalid revision ID")
+		return c.Redirect(revisionsURL)
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
+	}
+
+	revision, err := h.repos.PageRevisions.FindByID(revisionID)
+	if err != nil || revision.PageID != page.ID {
+		flash.Error(c, "Revision not found")
+		return c.Redirect(revisionsURL)
+	}
+
+	page.Title = revision.Title
+	page.Content = revision.Content
+	page.ContentType = revision.ContentType
+
+	if err := h.repos.Pages.Update(page); err != nil {
+		flash.Error(c, "Failed to restore revision")
+		return c.Redirect(revisionsURL)
+	}
+
+	if err := h.repos.PageRevisions.Create(models.NewPageRevision(page, user.ID)); err != nil {
+		flash.Error(c, "Revision restored but the new revision could not be saved")
+		return c.Redirect(revisionsURL)
+	}
+
+	flash.Success(c, "Revision restored successfully")
+	return c.Redirect(revisionsURL)
+}
+
+// ensurePostBaselineRevision records the current state of a post saved before
+// revisions existed, so that the first update does not lose it
+func ensurePostBaselineRevision(repos *repository.Repositories, post *models.Post) error {
+	count, err := repos.PostRevisions.CountByPost(post.ID)
+	if err != nil || count > 0 {
+		return err
+	}
+	return repos.PostRevisions.Create(models.NewPostRevision(post, models.PostTagNames(post), post.AuthorID))
+}
+
+// ensurePageBaselineRevision records the current state of a page saved before
+// revisions existed, so that the first update does not lose it
+func ensurePageBaselineRevision(repos *repository.Repositories, page *models.Page) error {
+	count, err := repos.PageRevisions.CountByPage(page.ID)
+	if err != nil || count > 0 {
+		return err
+	}
+	return repos.PageRevisions.Create(models.NewPageRevision(page, 0))
+}
+
+// selectRevisionPair returns the indexes of the revisions to compare, taken from
+// the from and to query parameters. It defaults to the two most recent revisions.
+func selectRevisionPair(c *fiber.Ctx, ids []uint) (int, int, bool) {
+	if len(ids) == 0 {
+		return 0, 0, false
+	}
+
+	from, to := 0, 0
+	if len(ids) > 1 {
+		from = 1
+	}
+
+	for i, id := range ids {
+		if c.Query("from") == fmt.Sprint(id) {
+			from = i
+		}
+		if c.Query("to") == fmt.Sprint(id) {
+			to = i
+		}
+	}
+
+	return from, to, true
+}
+
+// changedDiffs filters out the fields that did not change
+func changedDiffs(diffs []revisionDiff) []revisionDiff {
+	changed := make([]revisionDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		if utils.DiffChanged(diff.Lines) {
+			changed = append(changed, diff)
+		}
+	}
+	return changed
+}
@@ -50,6 +50,7 @@ func (h *AdminHandlers) UpdateSettings(c *fiber.Ctx) error {
 	postsPerPage := c.FormValue("posts_per_page")
 	logoID := c.FormValue("logo_id")
 	useFavicon := c.FormValue("use_favicon") == "on"
+	form.RobotsTxt = c.FormValue("robots_txt")
 
 	// Validate required fields
 	if form.Title == "" {
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiTokenForm struct {
+	Name   string   `form:"name"`
+	Scopes []string `form:"scopes"`
+}
+
+// ListAPITokens handles the GET /admin/tokens route
+func (h *AdminHandlers) ListAPITokens(c *fiber.Ctx) error {
+	tokens, err := h.repos.APITokens.FindByUser(currentUser(c).ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_tokens", fiber.Map{
+		"title":  "API Tokens",
+		"tokens": tokens,
+	})
+}
+
+// ShowCreateAPIToken handles the GET /admin/tokens/create route
+func (h *AdminHandlers) ShowCreateAPIToken(c *fiber.Ctx) error {
+	return c.Render("admin_create_token", fiber.Map{
+		"title":  "API Tokens",
+		"scopes": models.APIScopes,
+	})
+}
+
+// CreateAPIToken handles the POST /admin/tokens/create route.
+// The plain token is only displayed once, right after creation.
+func (h *AdminHandlers) CreateAPIToken(c *fiber.Ctx) error {
+	form := new(apiTokenForm)
+	if err := c.BodyParser(form); err != nil {
+		return c.Status(http.StatusBadRequest).Render("admin_create_token", fiber.Map{
+			"title":  "API Tokens",
+			"scopes": models.APIScopes,
+			"error":  "Invalid form data",
+		})
+	}
+
+	name := strings.TrimSpace(form.Name)
+	renderError := func(message string) error {
+		return c.Status(http.StatusBadRequest).Render("admin_create_token", fiber.Map{
+			"title":  "API Tokens",
+			"scopes": models.APIScopes,
+			"name":   name,
+			"error":  message,
+		})
+	}
+
+	if name == "" {
+		return renderError("Name is required")
+	}
+
+	if len(form.Scopes) == 0 {
+		return renderError("At least one scope is required")
+	}
+
+	for _, scope := range form.Scopes {
+		if !models.IsValidAPIScope(scope) {
+			return renderError("Invalid scope: " + scope)
+		}
+	}
+
+	plainToken, err := utils.GenerateAPIToken()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	token := &models.APIToken{
+		UserID:    currentUser(c).ID,
+		Name:      name,
+		Prefix:    plainToken[:len(utils.APITokenPrefix)+6],
+		TokenHash: utils.HashAPIToken(plainToken),
+		Scopes:    strings.Join(form.Scopes, ","),
+	}
+
+	if err := h.repos.APITokens.Create(token); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_create_token", fiber.Map{
+		"title":      "API Tokens",
+		"token":      token,
+		"plainToken": plainToken,
+	})
+}
+
+// RevokeAPIToken handles the POST /admin/tokens/:id/revoke route
+func (h *AdminHandlers) RevokeAPIToken(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid token ID")
+		return c.Redirect("/admin/tokens")
+	}
+
+	// Users can only revoke their own tokens
+	token, err := h.repos.APITokens.FindByID(id)
+	if err != nil || token.UserID != currentUser(c).ID {
+		flash.Error(c, "Token not found")
+		return c.Redirect("/admin/tokens")
+	}
+
+	if err := h.repos.APITokens.Delete(token); err != nil {
+		flash.Error(c, "Failed to revoke token")
+		return c.Redirect("/admin/tokens")
+	}
+
+	flash.Success(c, "Token revoked successfully")
+	return c.Redirect("/admin/tokens")
+}
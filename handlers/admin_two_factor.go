@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/skip2/go-qrcode"
+)
+
+// ShowTwoFactor handles the GET /admin/account/2fa route
+func (h *AdminHandlers) ShowTwoFactor(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	remaining, err := h.repos.RecoveryCodes.CountUnused(user.ID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_two_factor", fiber.Map{
+		"title":              "Two-Factor Authentication",
+		"remainingCodes":     remaining,
+		"recoveryCodesCount": system.RecoveryCodesCount,
+	})
+}
+
+// SetupTwoFactor handles the POST /admin/account/2fa/setup route.
+// A new secret is stored but only enabled once a valid code is entered.
+func (h *AdminHandlers) SetupTwoFactor(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	if user.TOTPEnabled {
+		flash.Error(c, "Two-factor authentication is already enabled")
+		return c.Redirect("/admin/account/2fa")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := h.repos.Users.Update(user); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.renderTwoFactorSetup(c, user, "")
+}
+
+// renderTwoFactorSetup renders the enrollment step with the QR code of the user's secret
+func (h *AdminHandlers) renderTwoFactorSetup(c *fiber.Ctx, user *models.User, errorMessage string) error {
+	issuer := system.DefaultTitle
+	if settings, ok := c.Locals("settings").(*models.Settings); ok && settings.Title != "" {
+		issuer = settings.Title
+	}
+
+	png, err := qrcode.Encode(utils.TOTPProvisioningURI(issuer, user.Email, user.TOTPSecret), qrcode.Medium, 256)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	status := http.StatusOK
+	if errorMessage != "" {
+		status = http.StatusBadRequest
+	}
+
+	return c.Status(status).Render("admin_two_factor", fiber.Map{
+		"title":  "Two-Factor Authentication",
+		"setup":  true,
+		"secret": user.TOTPSecret,
+		"qrCode": template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		"error":  errorMessage,
+	})
+}
+
+// EnableTwoFactor handles the POST /admin/account/2fa/enable route
+func (h *AdminHandlers) EnableTwoFactor(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		return c.Redirect("/admin/account/2fa")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, c.FormValue("code"), time.Now(), user.TOTPLastStep)
+	if !ok {
+		return h.renderTwoFactorSetup(c, user, "Invalid code, check the time of your device and try again")
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	if err := h.repos.Users.Update(user); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.renderRecoveryCodes(c, user, "Two-factor authentication enabled")
+}
+
+// RegenerateRecoveryCodes handles the POST /admin/account/2fa/recovery-codes route
+func (h *AdminHandlers) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	if !user.TOTPEnabled {
+		return c.Redirect("/admin/account/2fa")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, c.FormValue("code"), time.Now(), user.TOTPLastStep)
+	if !ok {
+		flash.Error(c, "Invalid code")
+		return c.Redirect("/admin/account/2fa")
+	}
+
+	user.TOTPLastStep = step
+	if err := h.repos.Users.Update(user); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.renderRecoveryCodes(c, user, "New recovery codes generated, the previous ones no longer work")
+}
+
+// renderRecoveryCodes replaces the recovery codes of the user and displays them once
+func (h *AdminHandlers) renderRecoveryCodes(c *fiber.Ctx, user *models.User, message string) error {
+	codes, err := utils.GenerateRecoveryCodes(system.RecoveryCodesCount)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+
+	if err := h.repos.RecoveryCodes.ReplaceForUser(user.ID, hashes); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Render("admin_two_factor", fiber.Map{
+		"title":         "Two-Factor Authentication",
+		"recoveryCodes": codes,
+		"message":       message,
+	})
+}
+
+// DisableTwoFactor handles the POST /admin/account/2fa/disable route.
+// The current password is required.
+func (h *AdminHandlers) DisableTwoFactor(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	if !utils.CheckPasswordHash(c.FormValue("password"), user.Password) {
+		flash.Error(c, "Invalid password")
+		return c.Redirect("/admin/account/2fa")
+	}
+
+	if err := h.resetTwoFactor(user); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	flash.Success(c, "Two-factor authentication disabled")
+	return c.Redirect("/admin/account/2fa")
+}
+
+// ResetUserTwoFactor handles the POST /admin/users/:id/reset-2fa route, for
+// users who lost both their authenticator and their recovery codes
+func (h *AdminHandlers) ResetUserTwoFactor(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid user ID")
+		return c.Redirect("/admin/users")
+	}
+
+	user, err := h.repos.Users.FindByID(id)
+	if err != nil {
+		flash.Error(c, "User not found")
+		return c.Redirect("/admin/users")
+	}
+
+	if err := h.resetTwoFactor(user); err != nil {
+		flash.Error(c, "Failed to reset two-factor authentication")
+		return c.Redirect("/admin/users")
+	}
+
+	flash.Success(c, fmt.Sprintf("Two-factor authentication reset for %s", user.Email))
+	return c.Redirect("/admin/users")
+}
+
+func (h *AdminHandlers) resetTwoFactor(user *models.User) error {
+	user.ResetTwoFactor()
+	if err := h.repos.Users.Update(user); err != nil {
+		return err
+	}
+	return h.repos.RecoveryCodes.DeleteByUser(user.ID)
+}
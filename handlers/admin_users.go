@@ -30,7 +30,7 @@ func (h *AdminHandlers) ListUsers(c *fiber.Ctx) error {
 func (h *AdminHandlers) ShowCreateUser(c *fiber.Ctx) error {
 	return c.Render("admin_create_user", fiber.Map{
 		"title": "Create User",
-		"user":  &models.User{},
+		"user":  &models.User{Role: models.RoleAuthor},
 	})
 }
 
@@ -40,6 +40,7 @@ func (h *AdminHandlers) CreateUser(c *fiber.Ctx) error {
 	lastName := c.FormValue("lastName")
 	email := c.FormValue("email")
 	password := c.FormValue("password")
+	role := c.FormValue("role")
 
 	// Validate input
 	if err := utils.ValidateFirstName(firstName); err != nil {
@@ -70,6 +71,13 @@ func (h *AdminHandlers) CreateUser(c *fiber.Ctx) error {
 			"user":  &models.User{},
 		})
 	}
+	if !models.IsValidRole(role) {
+		flash.Error(c, "Invalid role")
+		return c.Status(http.StatusBadRequest).Render("admin_create_user", fiber.Map{
+			"title": "Users",
+			"user":  &models.User{},
+		})
+	}
 
 	// Check if email already exists
 	count, err := h.repos.Users.CountByEmail(email)
@@ -105,6 +113,7 @@ func (h *AdminHandlers) CreateUser(c *fiber.Ctx) error {
 		LastName:  lastName,
 		Email:     email,
 		Password:  string(hashedPassword),
+		Role:      role,
 	}
 
 	if err := h.repos.Users.Create(user); err != nil {
@@ -154,6 +163,7 @@ func (h *AdminHandlers) UpdateUser(c *fiber.Ctx) error {
 		flash.Error(c, "User not found")
 		return c.Status(http.StatusNotFound).Render("admin_404", fiber.Map{})
 	}
+	previousRole := user.Role
 
 	if err := c.BodyParser(&user); err != nil {
 		flash.Error(c, "Invalid form data")
@@ -185,6 +195,24 @@ func (h *AdminHandlers) UpdateUser(c *fiber.Ctx) error {
 			"user":  user,
 		})
 	}
+	if !models.IsValidRole(user.Role) {
+		flash.Error(c, "Invalid role")
+		return c.Status(http.StatusBadRequest).Render("admin_edit_user", fiber.Map{
+			"title": "Users",
+			"user":  user,
+		})
+	}
+
+	// Make sure the site always keeps an admin
+	if previousRole == models.RoleAdmin && user.Role != models.RoleAdmin {
+		if admins, err := h.repos.Users.CountByRole(models.RoleAdmin); err != nil || admins <= 1 {
+			flash.Error(c, "Cannot change the role of the last admin")
+			return c.Status(http.StatusBadRequest).Render("admin_edit_user", fiber.Map{
+				"title": "Users",
+				"user":  user,
+			})
+		}
+	}
 
 	// Check if email already exists for other users
 	count, err := h.repos.Users.CountByEmail(user.Email)
@@ -279,6 +307,17 @@ func (h *AdminHandlers) DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Make sure the site always keeps an admin
+	if user.Role == models.RoleAdmin {
+		if admins, err := h.repos.Users.CountByRole(models.RoleAdmin); err != nil || admins <= 1 {
+			flash.Error(c, "Cannot delete the last admin")
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error":    "Cannot delete the last admin",
+				"redirect": "/admin/users",
+			})
+		}
+	}
+
 	// Delete user
 	if err := h.repos.Users.Delete(user); err != nil {
 		flash.Error(c, "Failed to delete user")
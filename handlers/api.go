@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"io/fs"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIHandlers handles the /api/v1 REST API routes
+type APIHandlers struct {
+	repos    *repository.Repositories
+	storage  storage.Provider
+	variants *ImageVariants
+	openAPI  []byte
+}
+
+// NewAPIHandlers creates a new APIHandlers instance
+func NewAPIHandlers(repos *repository.Repositories, storage storage.Provider, variants *ImageVariants, embeddedFS fs.FS) (*APIHandlers, error) {
+	openAPI, err := fs.ReadFile(embeddedFS, "embedded/api/openapi.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIHandlers{
+		repos:    repos,
+		storage:  storage,
+		variants: variants,
+		openAPI:  openAPI,
+	}, nil
+}
+
+type apiMeta struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"perPage"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"totalPages"`
+}
+
+// GetOpenAPI handles the GET /api/v1/openapi.json route
+func (h *APIHandlers) GetOpenAPI(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Send(h.openAPI)
+}
+
+// NotFound answers unknown /api/v1 routes
+func (h *APIHandlers) NotFound(c *fiber.Ctx) error {
+	return middleware.APIError(c, http.StatusNotFound, "Route not found")
+}
+
+// apiID parses the :id route parameter
+func apiID(c *fiber.Ctx) (uint, bool) {
+	id, err := utils.ParseUint(c.Params("id"))
+	return id, err == nil && id > 0
+}
+
+// apiPagination reads the page and perPage query parameters
+func apiPagination(c *fiber.Ctx) (int, int) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(c.Query("perPage", strconv.Itoa(system.APIDefaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = system.APIDefaultPerPage
+	}
+	if perPage > system.APIMaxPerPage {
+		perPage = system.APIMaxPerPage
+	}
+
+	return page, perPage
+}
+
+// sendAPIList sends a page of items along with the pagination metadata
+func sendAPIList(c *fiber.Ctx, data interface{}, page, perPage int, total int64) error {
+	return c.JSON(fiber.Map{
+		"data": data,
+		"meta": apiMeta{
+			Page:       page,
+			PerPage:    perPage,
+			Total:      total,
+			TotalPages: int(math.Ceil(float64(total) / float64(perPage))),
+		},
+	})
+}
+
+// sendAPIItem sends a single item
+func sendAPIItem(c *fiber.Ctx, status int, data interface{}) error {
+	return c.Status(status).JSON(fiber.Map{"data": data})
+}
+
+// paginateSlice returns the requested page of items
+func paginateSlice[T any](items []T, page, perPage int) []T {
+	start := (page - 1) * perPage
+	if start >= len(items) {
+		return []T{}
+	}
+	end := min(start+perPage, len(items))
+	return items[start:end]
+}
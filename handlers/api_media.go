@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiMedia struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Path        string     `json:"path"`
+	URL         string     `json:"url"`
+	MimeType    string     `json:"mimeType"`
+	Size        int64      `json:"size"`
+	Hash        string     `json:"hash"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	Description string     `json:"description"`
+	AltText     string     `json:"altText"`
+	Caption     string     `json:"caption"`
+	Folder      string     `json:"folder"`
+	TakenAt     *time.Time `json:"takenAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+type mediaRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AltText     string `json:"altText"`
+	Caption     string `json:"caption"`
+	Folder      string `json:"folder"`
+}
+
+// apiMediaReference is a post or page linking to a media
+type apiMediaReference struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+type apiMediaUsage struct {
+	Posts []apiMediaReference `json:"posts"`
+	Pages []apiMediaReference `json:"pages"`
+	Logo  bool                `json:"logo"`
+}
+
+func newAPIMediaUsage(usage *models.MediaUsage) apiMediaUsage {
+	out := apiMediaUsage{Posts: []apiMediaReference{}, Pages: []apiMediaReference{}, Logo: usage.Logo}
+	for _, post := range usage.Posts {
+		out.Posts = append(out.Posts, apiMediaReference{ID: post.ID, Title: post.Title, Slug: post.Slug})
+	}
+	for _, page := range usage.Pages {
+		out.Pages = append(out.Pages, apiMediaReference{ID: page.ID, Title: page.Title, Slug: page.Slug})
+	}
+	return out
+}
+
+func newAPIMedia(media *models.Media) apiMedia {
+	return apiMedia{
+		ID:          media.ID,
+		Name:        media.Name,
+		Path:        media.Path,
+		URL:         "/media/" + media.Path,
+		MimeType:    media.MimeType,
+		Size:        media.Size,
+		Hash:        media.Hash,
+		Width:       media.Width,
+		Height:      media.Height,
+		Description: media.Description,
+		AltText:     media.AltText,
+		Caption:     media.Caption,
+		Folder:      media.Folder,
+		TakenAt:     media.TakenAt,
+		CreatedAt:   media.CreatedAt,
+		UpdatedAt:   media.UpdatedAt,
+	}
+}
+
+// ListMedia handles the GET /api/v1/media route, filtered by the q, folder
+// and type query parameters
+func (h *APIHandlers) ListMedia(c *fiber.Ctx) error {
+	page, perPage := apiPagination(c)
+
+	media, err := h.repos.Media.Search(models.MediaFilter{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Folder: c.Query("folder"),
+		Type:   c.Query("type"),
+	})
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load media")
+	}
+
+	data := make([]apiMedia, 0, perPage)
+	for _, m := range paginateSlice(media, page, perPage) {
+		data = append(data, newAPIMedia(m))
+	}
+
+	return sendAPIList(c, data, page, perPage, int64(len(media)))
+}
+
+// GetMedia handles the GET /api/v1/media/:id route
+func (h *APIHandlers) GetMedia(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid media ID")
+	}
+
+	media, err := h.repos.Media.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Media not found")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIMedia(media))
+}
+
+// UploadMedia handles the multipart POST /api/v1/media route
+func (h *APIHandlers) UploadMedia(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "No file uploaded")
+	}
+
+	multipartFile, err := file.Open()
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to open file")
+	}
+	defer multipartFile.Close()
+
+	metadata, reader := inspectUpload(multipartFile)
+	stored, err := mediastore.Save(h.repos, h.storage, file.Filename, reader)
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save file")
+	}
+
+	media := &models.Media{
+		Name:        file.Filename,
+		Path:        stored.Path,
+		Size:        stored.Size,
+		Hash:        stored.Hash,
+		Description: c.FormValue("description"),
+		AltText:     strings.TrimSpace(c.FormValue("altText")),
+		Caption:     strings.TrimSpace(c.FormValue("caption")),
+		Folder:      c.FormValue("folder"),
+		Width:       metadata.Width,
+		Height:      metadata.Height,
+		TakenAt:     metadata.TakenAt,
+	}
+
+	// The file is deleted if the database insert fails
+	if err := mediastore.Create(h.repos, h.storage, media, stored); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save media record")
+	}
+
+	if !stored.Shared {
+		go h.variants.Pregenerate(media)
+	}
+
+	return sendAPIItem(c, http.StatusCreated, newAPIMedia(media))
+}
+
+// GetMediaUsage handles the GET /api/v1/media/:id/usage route
+func (h *APIHandlers) GetMediaUsage(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid media ID")
+	}
+
+	media, err := h.repos.Media.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Media not found")
+	}
+
+	usage, err := h.repos.Media.FindUsage(media)
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to find the media usage")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIMediaUsage(usage))
+}
+
+// UpdateMedia handles the PUT /api/v1/media/:id route
+func (h *APIHandlers) UpdateMedia(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid media ID")
+	}
+
+	media, err := h.repos.Media.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Media not found")
+	}
+
+	input := new(mediaRequest)
+	if err := c.BodyParser(input); err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if input.Name != "" {
+		media.Name = input.Name
+	}
+	media.Description = input.Description
+	media.AltText = strings.TrimSpace(input.AltText)
+	media.Caption = strings.TrimSpace(input.Caption)
+	media.Folder = input.Folder
+
+	if err := h.repos.Media.Update(media); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update media")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIMedia(media))
+}
+
+// DeleteMedia handles the DELETE /api/v1/media/:id route
+func (h *APIHandlers) DeleteMedia(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid media ID")
+	}
+
+	media, err := h.repos.Media.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Media not found")
+	}
+
+	// Media in use are only deleted on purpose, as their links would break
+	if !c.QueryBool("force") {
+		usage, err := h.repos.Media.FindUsage(media)
+		if err != nil {
+			return middleware.APIError(c, http.StatusInternalServerError, "Failed to find the media usage")
+		}
+		if usage.InUse() {
+			return middleware.APIError(c, http.StatusConflict, "Media in use, delete it with force=true to break the links to it")
+		}
+	}
+
+	if err := h.variants.DeleteAll(media); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete the resized images")
+	}
+	if err := mediastore.Delete(h.repos, h.storage, media); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete media record")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiMenuItem struct {
+	ID        uint      `json:"id"`
+	Label     string    `json:"label"`
+	URL       *string   `json:"url"`
+	PageID    *uint     `json:"pageId"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type menuItemRequest struct {
+	Label  string  `json:"label"`
+	URL    *string `json:"url"`
+	PageID *uint   `json:"pageId"`
+}
+
+func newAPIMenuItem(item *models.MenuItem) apiMenuItem {
+	return apiMenuItem{
+		ID:        item.ID,
+		Label:     item.Label,
+		URL:       item.URL,
+		PageID:    item.PageID,
+		Position:  item.Position,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+// parseAPIMenuItemRequest reads and validates a menu item request body
+func (h *APIHandlers) parseAPIMenuItemRequest(c *fiber.Ctx) (*menuItemRequest, int, string) {
+	input := new(menuItemRequest)
+	if err := c.BodyParser(input); err != nil {
+		return nil, http.StatusBadRequest, "Invalid request body"
+	}
+
+	if input.URL != nil && *input.URL == "" {
+		input.URL = nil
+	}
+
+	if strings.TrimSpace(input.Label) == "" || (input.URL == nil && input.PageID == nil) {
+		return nil, http.StatusBadRequest, "Label and either URL or page ID are required"
+	}
+
+	// A page reference takes precedence over the URL, like in the admin
+	if input.PageID != nil {
+		if _, err := h.repos.Pages.FindByID(*input.PageID); err != nil {
+			return nil, http.StatusBadRequest, "Page not found"
+		}
+		input.URL = nil
+	}
+
+	return input, 0, ""
+}
+
+// ListMenuItems handles the GET /api/v1/menus route
+func (h *APIHandlers) ListMenuItems(c *fiber.Ctx) error {
+	page, perPage := apiPagination(c)
+
+	items, err := h.repos.MenuItems.FindAll()
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load menu items")
+	}
+
+	data := make([]apiMenuItem, 0, perPage)
+	for _, item := range paginateSlice(items, page, perPage) {
+		data = append(data, newAPIMenuItem(item))
+	}
+
+	return sendAPIList(c, data, page, perPage, int64(len(items)))
+}
+
+// GetMenuItem handles the GET /api/v1/menus/:id route
+func (h *APIHandlers) GetMenuItem(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid menu item ID")
+	}
+
+	item, err := h.repos.MenuItems.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Menu item not found")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIMenuItem(item))
+}
+
+// CreateMenuItem handles the POST /api/v1/menus route
+func (h *APIHandlers) CreateMenuItem(c *fiber.Ctx) error {
+	input, status, message := h.parseAPIMenuItemRequest(c)
+	if input == nil {
+		return middleware.APIError(c, status, message)
+	}
+
+	item := &models.MenuItem{
+		Label:    input.Label,
+		URL:      input.URL,
+		PageID:   input.PageID,
+		Position: h.repos.MenuItems.GetNextPosition(),
+	}
+
+	if err := h.repos.MenuItems.Create(item); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to create menu item")
+	}
+
+	return sendAPIItem(c, http.StatusCreated, newAPIMenuItem(item))
+}
+
+// UpdateMenuItem handles the PUT /api/v1/menus/:id route
+func (h *APIHandlers) UpdateMenuItem(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid menu item ID")
+	}
+
+	item, err := h.repos.MenuItems.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Menu item not found")
+	}
+
+	input, status, message := h.parseAPIMenuItemRequest(c)
+	if input == nil {
+		return middleware.APIError(c, status, message)
+	}
+
+	item.Label = input.Label
+	item.URL = input.URL
+	item.PageID = input.PageID
+	item.Page = nil
+
+	if err := h.repos.MenuItems.Update(item); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update menu item")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIMenuItem(item))
+}
+
+// DeleteMenuItem handles the DELETE /api/v1/menus/:id route
+func (h *APIHandlers) DeleteMenuItem(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid menu item ID")
+	}
+
+	item, err := h.repos.MenuItems.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Menu item not found")
+	}
+
+	if err := h.repos.MenuItems.Delete(item); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete menu item")
+	}
+
+	// Update positions of remaining items
+	if err := h.repos.MenuItems.UpdatePositions(item.Position); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update menu positions")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiPage struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Content     string    `json:"content"`
+	ContentType string    `json:"contentType"`
+	Visible     bool      `json:"visible"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func newAPIPage(page *models.Page) apiPage {
+	return apiPage{
+		ID:          page.ID,
+		Title:       page.Title,
+		Slug:        page.Slug,
+		Content:     page.Content,
+		ContentType: page.ContentType,
+		Visible:     page.Visible,
+		CreatedAt:   page.CreatedAt,
+		UpdatedAt:   page.UpdatedAt,
+	}
+}
+
+// parseAPIPageRequest reads and validates a page request body
+func parseAPIPageRequest(c *fiber.Ctx) (*pageRequest, string) {
+	input := new(pageRequest)
+	if err := c.BodyParser(input); err != nil {
+		return nil, "Invalid request body"
+	}
+
+	if strings.TrimSpace(input.Title) == "" || strings.TrimSpace(input.Slug) == "" {
+		return nil, "Title and slug are required"
+	}
+
+	if input.ContentType == "" {
+		input.ContentType = "markdown"
+	}
+	if input.ContentType != "markdown" && input.ContentType != "html" {
+		return nil, "Content type must be markdown or html"
+	}
+
+	return input, ""
+}
+
+// ListPages handles the GET /api/v1/pages route
+func (h *APIHandlers) ListPages(c *fiber.Ctx) error {
+	page, perPage := apiPagination(c)
+
+	pages, err := h.repos.Pages.FindAll()
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load pages")
+	}
+
+	data := make([]apiPage, 0, perPage)
+	for _, p := range paginateSlice(pages, page, perPage) {
+		data = append(data, newAPIPage(p))
+	}
+
+	return sendAPIList(c, data, page, perPage, int64(len(pages)))
+}
+
+// GetPage handles the GET /api/v1/pages/:id route
+func (h *APIHandlers) GetPage(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid page ID")
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Page not found")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIPage(page))
+}
+
+// CreatePage handles the POST /api/v1/pages route
+func (h *APIHandlers) CreatePage(c *fiber.Ctx) error {
+	input, message := parseAPIPageRequest(c)
+	if input == nil {
+		return middleware.APIError(c, http.StatusBadRequest, message)
+	}
+
+	page := &models.Page{
+		Title:       input.Title,
+		Slug:        input.Slug,
+		Content:     input.Content,
+		ContentType: input.ContentType,
+		Visible:     input.Visible,
+	}
+
+	if err := h.repos.Pages.Create(page); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Page with the same slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to create page")
+	}
+
+	if err := h.repos.PageRevisions.Create(models.NewPageRevision(page, currentUser(c).ID)); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save revision")
+	}
+
+	return sendAPIItem(c, http.StatusCreated, newAPIPage(page))
+}
+
+// UpdatePage handles the PUT /api/v1/pages/:id route
+func (h *APIHandlers) UpdatePage(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid page ID")
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Page not found")
+	}
+
+	input, message := parseAPIPageRequest(c)
+	if input == nil {
+		return middleware.APIError(c, http.StatusBadRequest, message)
+	}
+
+	if err := ensurePageBaselineRevision(h.repos, page); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save revision")
+	}
+
+	page.Title = input.Title
+	page.Slug = input.Slug
+	page.Content = input.Content
+	page.ContentType = input.ContentType
+	page.Visible = input.Visible
+
+	if err := h.repos.Pages.Update(page); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Page with the same slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update page")
+	}
+
+	if err := h.repos.PageRevisions.Create(models.NewPageRevision(page, currentUser(c).ID)); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save revision")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIPage(page))
+}
+
+// DeletePage handles the DELETE /api/v1/pages/:id route
+func (h *APIHandlers) DeletePage(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid page ID")
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Page not found")
+	}
+
+	var count int64
+	if err := h.repos.Pages.CountRelatedMenuItems(page.ID, &count); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to check page usage")
+	}
+	if count > 0 {
+		return middleware.APIError(c, http.StatusConflict, "Cannot delete page that is linked from the menu")
+	}
+
+	if err := h.repos.Pages.Delete(page); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete page")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiPost struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Content     string    `json:"content"`
+	Excerpt     string    `json:"excerpt"`
+	Visible     bool      `json:"visible"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Timezone    string    `json:"timezone"`
+	Tags        []string  `json:"tags"`
+	AuthorID    uint      `json:"authorId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func newAPIPost(post *models.Post) apiPost {
+	result := apiPost{
+		ID:          post.ID,
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Content:     post.Content,
+		Visible:     post.Visible,
+		PublishedAt: post.PublishedAt,
+		Timezone:    post.PublishedAtTimezone,
+		Tags:        models.PostTagNames(post),
+		AuthorID:    post.AuthorID,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+	}
+	if post.Excerpt != nil {
+		result.Excerpt = *post.Excerpt
+	}
+	return result
+}
+
+// ListPosts handles the GET /api/v1/posts route
+func (h *APIHandlers) ListPosts(c *fiber.Ctx) error {
+	page, perPage := apiPagination(c)
+
+	posts, total, err := h.repos.Posts.FindAllPaginated(page, perPage)
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load posts")
+	}
+
+	data := make([]apiPost, 0, len(posts))
+	for i := range posts {
+		data = append(data, newAPIPost(&posts[i]))
+	}
+
+	return sendAPIList(c, data, page, perPage, total)
+}
+
+// GetPost handles the GET /api/v1/posts/:id route
+func (h *APIHandlers) GetPost(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid post ID")
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Post not found")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPIPost(post))
+}
+
+// CreatePost handles the POST /api/v1/posts route
+func (h *APIHandlers) CreatePost(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	input := new(postRequest)
+	if err := c.BodyParser(input); err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if strings.TrimSpace(input.Title) == "" || strings.TrimSpace(input.Slug) == "" {
+		return middleware.APIError(c, http.StatusBadRequest, "Title and slug are required")
+	}
+
+	// Users who cannot publish may only save drafts
+	if !user.Can(models.PermissionPublishPosts) {
+		input.Visible = false
+	}
+
+	publishedAt, err := parseTime(input.PublishedAt, input.Timezone)
+	if err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid publish date")
+	}
+
+	post := &models.Post{
+		Title:                     input.Title,
+		Slug:                      input.Slug,
+		Content:                   input.Content,
+		Excerpt:                   &input.Excerpt,
+		Visible:                   input.Visible,
+		PublishedAt:               publishedAt.Raw,
+		PublishedAtTimezone:       publishedAt.Timezone,
+		PublishedAtUTC:            publishedAt.UTC,
+		PublishedAtTimeZoneOffset: publishedAt.TimezoneOffset,
+		AuthorID:                  user.ID,
+	}
+
+	if err := h.repos.Posts.Create(post); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Post with the same slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to create post")
+	}
+
+	return h.savePostTagsAndRevision(c, post, input.Tags, http.StatusCreated)
+}
+
+// UpdatePost handles the PUT /api/v1/posts/:id route
+func (h *APIHandlers) UpdatePost(c *fiber.Ctx) error {
+	user := currentUser(c)
+
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid post ID")
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Post not found")
+	}
+
+	if !user.CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
+	input := new(postRequest)
+	if err := c.BodyParser(input); err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if strings.TrimSpace(input.Title) == "" || strings.TrimSpace(input.Slug) == "" {
+		return middleware.APIError(c, http.StatusBadRequest, "Title and slug are required")
+	}
+
+	if !user.Can(models.PermissionPublishPosts) {
+		input.Visible = false
+	}
+
+	publishedAt, err := parseTime(input.PublishedAt, input.Timezone)
+	if err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid publish date")
+	}
+
+	if err := ensurePostBaselineRevision(h.repos, post); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save revision")
+	}
+
+	post.Title = input.Title
+	post.Slug = input.Slug
+	post.Content = input.Content
+	post.Excerpt = &input.Excerpt
+	post.Visible = input.Visible
+	post.PublishedAt = publishedAt.Raw
+	post.PublishedAtTimezone = publishedAt.Timezone
+	post.PublishedAtUTC = publishedAt.UTC
+	post.PublishedAtTimeZoneOffset = publishedAt.TimezoneOffset
+
+	if err := h.repos.Posts.Update(post); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Post with the same slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update post")
+	}
+
+	return h.savePostTagsAndRevision(c, post, input.Tags, http.StatusOK)
+}
+
+// DeletePost handles the DELETE /api/v1/posts/:id route
+func (h *APIHandlers) DeletePost(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid post ID")
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Post not found")
+	}
+
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
+	if err := h.repos.Posts.Delete(post); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete post")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// savePostTagsAndRevision associates the tags with a saved post, records a
+// revision and sends the up to date post
+func (h *APIHandlers) savePostTagsAndRevision(c *fiber.Ctx, post *models.Post, tags []string, status int) error {
+	if err := h.repos.Posts.AssociateTags(post, tags); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to associate tags")
+	}
+
+	if err := h.repos.PostRevisions.Create(models.NewPostRevision(post, tags, currentUser(c).ID)); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to save revision")
+	}
+
+	saved, err := h.repos.Posts.FindByID(post.ID)
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load post")
+	}
+
+	return sendAPIItem(c, status, newAPIPost(saved))
+}
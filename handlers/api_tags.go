@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type apiTag struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type tagRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func newAPITag(tag *models.Tag) apiTag {
+	return apiTag{
+		ID:        tag.ID,
+		Name:      tag.Name,
+		Slug:      tag.Slug,
+		CreatedAt: tag.CreatedAt,
+		UpdatedAt: tag.UpdatedAt,
+	}
+}
+
+// ListTags handles the GET /api/v1/tags route
+func (h *APIHandlers) ListTags(c *fiber.Ctx) error {
+	page, perPage := apiPagination(c)
+
+	tags, err := h.repos.Tags.FindAll()
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to load tags")
+	}
+
+	data := make([]apiTag, 0, perPage)
+	for _, tag := range paginateSlice(tags, page, perPage) {
+		data = append(data, newAPITag(tag))
+	}
+
+	return sendAPIList(c, data, page, perPage, int64(len(tags)))
+}
+
+// GetTag handles the GET /api/v1/tags/:id route
+func (h *APIHandlers) GetTag(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	tag, err := h.repos.Tags.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Tag not found")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPITag(tag))
+}
+
+// CreateTag handles the POST /api/v1/tags route
+func (h *APIHandlers) CreateTag(c *fiber.Ctx) error {
+	input := new(tagRequest)
+	if err := c.BodyParser(input); err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if strings.TrimSpace(input.Name) == "" {
+		return middleware.APIError(c, http.StatusBadRequest, "Name is required")
+	}
+
+	tag := &models.Tag{Name: input.Name, Slug: input.Slug}
+
+	if err := h.repos.Tags.Create(tag); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Tag with the same name or slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to create tag")
+	}
+
+	return sendAPIItem(c, http.StatusCreated, newAPITag(tag))
+}
+
+// UpdateTag handles the PUT /api/v1/tags/:id route
+func (h *APIHandlers) UpdateTag(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	tag, err := h.repos.Tags.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Tag not found")
+	}
+
+	input := new(tagRequest)
+	if err := c.BodyParser(input); err != nil {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if strings.TrimSpace(input.Name) == "" {
+		return middleware.APIError(c, http.StatusBadRequest, "Name is required")
+	}
+
+	tag.Name = input.Name
+	tag.Slug = input.Slug
+
+	if err := h.repos.Tags.Update(tag); err != nil {
+		if utils.IsConstraintError(err) {
+			return middleware.APIError(c, http.StatusConflict, "Tag with the same name or slug already exists")
+		}
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to update tag")
+	}
+
+	return sendAPIItem(c, http.StatusOK, newAPITag(tag))
+}
+
+// DeleteTag handles the DELETE /api/v1/tags/:id route
+func (h *APIHandlers) DeleteTag(c *fiber.Ctx) error {
+	id, ok := apiID(c)
+	if !ok {
+		return middleware.APIError(c, http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	tag, err := h.repos.Tags.FindByID(id)
+	if err != nil {
+		return middleware.APIError(c, http.StatusNotFound, "Tag not found")
+	}
+
+	count, err := h.repos.Posts.CountByTag(tag.ID)
+	if err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to check tag usage")
+	}
+	if count > 0 {
+		return middleware.APIError(c, http.StatusConflict, "Cannot delete tag that is still in use")
+	}
+
+	if err := h.repos.Tags.Delete(tag); err != nil {
+		return middleware.APIError(c, http.StatusInternalServerError, "Failed to delete tag")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
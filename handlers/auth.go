@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/middleware"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/system"
 	"github.com/captain-corp/captain/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -50,11 +53,24 @@ func (h *AuthHandlers) PostLogin(c *fiber.Ctx) error {
 		})
 	}
 
+	// Slow down and lock out clients and accounts with too many failed logins
+	throttleKeys := h.loginThrottleKeys(c, email)
+	if retryAfter := h.loginRetryAfter(throttleKeys); retryAfter > 0 {
+		h.recordLoginAttempt(c, email, nil, models.LoginResultThrottled)
+		return c.Status(http.StatusTooManyRequests).Render("login", fiber.Map{
+			"error": throttledMessage(c, retryAfter),
+			"email": email,
+			"next":  next,
+		})
+	}
+
 	// Find user by email
 	user, err := h.repos.Users.FindByEmail(email)
 	if err != nil {
 		// Timing attack prevention
 		utils.CheckPasswordHash(password, "")
+		h.recordLoginFailure(throttleKeys)
+		h.recordLoginAttempt(c, email, nil, models.LoginResultInvalidCredentials)
 		return c.Status(http.StatusUnauthorized).Render("login", fiber.Map{
 			"error": "Invalid credentials",
 			"email": email,
@@ -64,6 +80,8 @@ func (h *AuthHandlers) PostLogin(c *fiber.Ctx) error {
 
 	// Check password
 	if !utils.CheckPasswordHash(password, user.Password) {
+		h.recordLoginFailure(throttleKeys)
+		h.recordLoginAttempt(c, email, user, models.LoginResultInvalidCredentials)
 		return c.Status(http.StatusUnauthorized).Render("login", fiber.Map{
 			"error": "Invalid credentials",
 			"email": email,
@@ -81,10 +99,26 @@ func (h *AuthHandlers) PostLogin(c *fiber.Ctx) error {
 		})
 	}
 
-	sess.Set("loggedIn", true)
-	sess.Set("userID", user.ID)
+	// Users with two-factor authentication must enter a code before being logged in
+	if user.TOTPEnabled {
+		sess.Set("twoFactorUserID", user.ID)
+		sess.Set("twoFactorNext", next)
+		sess.Set("twoFactorStartedAt", time.Now().Unix())
+		sess.Set("twoFactorAttempts", 0)
+
+		if err := sess.Save(); err != nil {
+			return c.Status(http.StatusInternalServerError).Render("login", fiber.Map{
+				"error": "Failed to save session",
+				"email": email,
+				"next":  next,
+			})
+		}
 
-	if err := sess.Save(); err != nil {
+		h.recordLoginAttempt(c, email, user, models.LoginResultPasswordAccepted)
+		return c.Redirect("/login/2fa")
+	}
+
+	if err := startSession(sess, user); err != nil {
 		return c.Status(http.StatusInternalServerError).Render("login", fiber.Map{
 			"error": "Failed to save session",
 			"email": email,
@@ -92,6 +126,136 @@ func (h *AuthHandlers) PostLogin(c *fiber.Ctx) error {
 		})
 	}
 
+	h.resetAccountThrottle(email)
+	h.recordLoginAttempt(c, email, user, models.LoginResultSuccess)
+	return c.Redirect(next)
+}
+
+// startSession logs the user in, under a new session ID
+func startSession(sess *session.Session, user *models.User) error {
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+
+	sess.Delete("twoFactorUserID")
+	sess.Delete("twoFactorNext")
+	sess.Delete("twoFactorStartedAt")
+	sess.Delete("twoFactorAttempts")
+	sess.Delete(middleware.CSRFSessionKey) // a new token is issued to the logged in session
+	sess.Set("loggedIn", true)
+	sess.Set("userID", user.ID)
+
+	return sess.Save()
+}
+
+// pendingTwoFactorUser returns the user who passed the password step of the
+// login, or nil if there is none or it expired
+func (h *AuthHandlers) pendingTwoFactorUser(sess *session.Session) *models.User {
+	userID, _ := sess.Get("twoFactorUserID").(uint)
+	startedAt, _ := sess.Get("twoFactorStartedAt").(int64)
+	if userID == 0 || time.Since(time.Unix(startedAt, 0)) > system.TwoFactorLoginTTL {
+		return nil
+	}
+
+	user, err := h.repos.Users.FindByID(userID)
+	if err != nil || !user.TOTPEnabled {
+		return nil
+	}
+	return user
+}
+
+// ShowTwoFactorLogin handles the GET /login/2fa route
+func (h *AuthHandlers) ShowTwoFactorLogin(c *fiber.Ctx) error {
+	sess, err := h.sessionStore.Get(c)
+	if err != nil || h.pendingTwoFactorUser(sess) == nil {
+		return c.Redirect("/login")
+	}
+
+	return c.Render("login_2fa", fiber.Map{})
+}
+
+// PostTwoFactorLogin handles the POST /login/2fa route. It accepts either a
+// TOTP code or an unused recovery code.
+func (h *AuthHandlers) PostTwoFactorLogin(c *fiber.Ctx) error {
+	sess, err := h.sessionStore.Get(c)
+	if err != nil {
+		return c.Redirect("/login")
+	}
+
+	user := h.pendingTwoFactorUser(sess)
+	if user == nil {
+		return c.Redirect("/login")
+	}
+
+	throttleKeys := h.loginThrottleKeys(c, user.Email)
+	if retryAfter := h.loginRetryAfter(throttleKeys); retryAfter > 0 {
+		h.recordLoginAttempt(c, user.Email, user, models.LoginResultThrottled)
+		return c.Status(http.StatusTooManyRequests).Render("login_2fa", fiber.Map{
+			"error": throttledMessage(c, retryAfter),
+		})
+	}
+
+	code := c.FormValue("code")
+	valid := false
+
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+		if err := h.repos.Users.Update(user); err != nil {
+			return c.Status(http.StatusInternalServerError).Render("login_2fa", fiber.Map{
+				"error": "Failed to save login",
+			})
+		}
+		valid = true
+	} else if len(code) > utils.TOTPDigits {
+		if valid, err = h.repos.RecoveryCodes.Use(user.ID, utils.HashRecoveryCode(code)); err != nil {
+			return c.Status(http.StatusInternalServerError).Render("login_2fa", fiber.Map{
+				"error": "Failed to check recovery code",
+			})
+		}
+	}
+
+	if !valid {
+		h.recordLoginFailure(throttleKeys)
+		h.recordLoginAttempt(c, user.Email, user, models.LoginResultInvalidCode)
+
+		attempts, _ := sess.Get("twoFactorAttempts").(int)
+		attempts++
+
+		// Too many wrong codes: the password must be entered again
+		if attempts >= system.TwoFactorMaxAttempts {
+			sess.Delete("twoFactorUserID")
+			_ = sess.Save()
+			return c.Status(http.StatusUnauthorized).Render("login", fiber.Map{
+				"error": "Too many invalid codes, please log in again",
+				"email": user.Email,
+			})
+		}
+
+		sess.Set("twoFactorAttempts", attempts)
+		if err := sess.Save(); err != nil {
+			return c.Status(http.StatusInternalServerError).Render("login_2fa", fiber.Map{
+				"error": "Failed to save session",
+			})
+		}
+
+		return c.Status(http.StatusUnauthorized).Render("login_2fa", fiber.Map{
+			"error": "Invalid code",
+		})
+	}
+
+	next, _ := sess.Get("twoFactorNext").(string)
+	if next == "" {
+		next = "/admin"
+	}
+
+	if err := startSession(sess, user); err != nil {
+		return c.Status(http.StatusInternalServerError).Render("login_2fa", fiber.Map{
+			"error": "Failed to save session",
+		})
+	}
+
+	h.resetAccountThrottle(user.Email)
+	h.recordLoginAttempt(c, user.Email, user, models.LoginResultSuccess)
 	return c.Redirect(next)
 }
 
@@ -131,18 +295,26 @@ func (h *AuthHandlers) HandleSetup(c *fiber.Ctx) error {
 		firstName := c.FormValue("firstName")
 		lastName := c.FormValue("lastName")
 
+		// Setup is only throttled by client IP, as there is no account yet
+		throttleKeys := h.loginThrottleKeys(c, "")
+		if retryAfter := h.loginRetryAfter(throttleKeys); retryAfter > 0 {
+			return c.Status(http.StatusTooManyRequests).Render("setup", fiber.Map{"Error": throttledMessage(c, retryAfter)})
+		}
+
 		// Validate input
+		var validationErr string
 		if err := utils.ValidateEmail(email); err != nil {
-			return c.Status(http.StatusBadRequest).Render("setup", fiber.Map{"Error": "Invalid email address"})
-		}
-		if err := utils.ValidatePassword(password); err != nil {
-			return c.Status(http.StatusBadRequest).Render("setup", fiber.Map{"Error": "Password must be at least 8 characters"})
-		}
-		if err := utils.ValidateFirstName(firstName); err != nil {
-			return c.Status(http.StatusBadRequest).Render("setup", fiber.Map{"Error": err.Error()})
+			validationErr = "Invalid email address"
+		} else if err := utils.ValidatePassword(password); err != nil {
+			validationErr = "Password must be at least 8 characters"
+		} else if err := utils.ValidateFirstName(firstName); err != nil {
+			validationErr = err.Error()
+		} else if err := utils.ValidateLastName(lastName); err != nil {
+			validationErr = err.Error()
 		}
-		if err := utils.ValidateLastName(lastName); err != nil {
-			return c.Status(http.StatusBadRequest).Render("setup", fiber.Map{"Error": err.Error()})
+		if validationErr != "" {
+			h.recordLoginFailure(throttleKeys)
+			return c.Status(http.StatusBadRequest).Render("setup", fiber.Map{"Error": validationErr})
 		}
 
 		// Hash password
@@ -157,6 +329,7 @@ func (h *AuthHandlers) HandleSetup(c *fiber.Ctx) error {
 			Password:  hashedPassword,
 			FirstName: firstName,
 			LastName:  lastName,
+			Role:      models.RoleAdmin,
 		}
 
 		if err := h.repos.Users.Create(user); err != nil {
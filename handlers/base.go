@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/captain-corp/captain/config"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
@@ -37,3 +39,18 @@ func (h *BaseHandlers) addCommonData(c *fiber.Ctx, data fiber.Map) fiber.Map {
 
 	return data
 }
+
+// siteURL returns the absolute base URL of the site, without trailing slash.
+// The configured site domain takes precedence over the request host.
+func (h *BaseHandlers) siteURL(c *fiber.Ctx) string {
+	if h.config == nil || h.config.Site.Domain == "" {
+		return c.BaseURL()
+	}
+
+	scheme := c.Protocol()
+	if h.config.Site.SecureCookie {
+		scheme = "https"
+	}
+
+	return scheme + "://" + strings.TrimPrefix(h.config.Site.Domain, ".")
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/system"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FeedHandlers handles RSS and Atom syndication routes
+type FeedHandlers struct {
+	*BaseHandlers
+}
+
+// NewFeedHandlers creates a new feed handlers instance
+func NewFeedHandlers(repos *repository.Repositories, cfg *config.Config) *FeedHandlers {
+	return &FeedHandlers{
+		BaseHandlers: NewBaseHandlers(repos, cfg),
+	}
+}
+
+// feedGenerator names Captain in the feeds, along with its version
+const feedGenerator = "Captain"
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName   xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string        `xml:"title"`
+	Subtitle  string        `xml:"subtitle,omitempty"`
+	ID        string        `xml:"id"`
+	Updated   string        `xml:"updated"`
+	Links     []atomLink    `xml:"link"`
+	Generator atomGenerator `xml:"generator"`
+	Entries   []atomEntry   `xml:"entry"`
+}
+
+type atomGenerator struct {
+	Version string `xml:"version,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GetRSSFeed handles the GET /feed.xml route
+func (h *FeedHandlers) GetRSSFeed(c *fiber.Ctx) error {
+	posts, _, err := h.repos.Posts.FindVisiblePaginated(1, system.FeedItemsLimit)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating feed")
+	}
+
+	settings := c.Locals("settings").(*models.Settings)
+	return h.sendRSS(c, settings.Title, settings.Subtitle, "/", "/feed.xml", posts)
+}
+
+// GetAtomFeed handles the GET /atom.xml route
+func (h *FeedHandlers) GetAtomFeed(c *fiber.Ctx) error {
+	posts, _, err := h.repos.Posts.FindVisiblePaginated(1, system.FeedItemsLimit)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating feed")
+	}
+
+	settings := c.Locals("settings").(*models.Settings)
+	return h.sendAtom(c, settings.Title, settings.Subtitle, "/", "/atom.xml", posts)
+}
+
+// GetTagFeed handles the GET /tags/:slug/feed.xml route
+func (h *FeedHandlers) GetTagFeed(c *fiber.Ctx) error {
+	tag, err := h.repos.Tags.FindBySlug(c.Params("slug"))
+	if err != nil {
+		return c.Status(http.StatusNotFound).SendString("Tag not found")
+	}
+
+	posts, _, err := h.repos.Posts.FindVisibleByTag(tag.ID, 1, system.FeedItemsLimit)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating feed")
+	}
+
+	settings := c.Locals("settings").(*models.Settings)
+	title := fmt.Sprintf("%s - Posts tagged with %s", settings.Title, tag.Name)
+	return h.sendRSS(c, title, settings.Subtitle, "/tags/"+tag.Slug, "/tags/"+tag.Slug+"/feed.xml", posts)
+}
+
+func (h *FeedHandlers) sendRSS(c *fiber.Ctx, title, description, link, self string, posts []models.Post) error {
+	lastModified := feedLastModified(posts)
+	if notModified(c, feedETag(self, posts), lastModified) {
+		return nil
+	}
+
+	baseURL := h.siteURL(c)
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        baseURL + link,
+			Description: description,
+			AtomLink:    atomLink{Href: baseURL + self, Rel: "self", Type: "application/rss+xml"},
+			Generator:   feedGenerator + " v" + system.Version,
+		},
+	}
+	if !lastModified.IsZero() {
+		feed.Channel.LastBuildDate = lastModified.Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		url := baseURL + "/posts/" + post.Slug
+		item := rssItem{
+			Title:       post.Title,
+			Link:        url,
+			GUID:        rssGUID{IsPermaLink: true, Value: url},
+			PubDate:     postPublishedAt(post).Format(time.RFC1123Z),
+			Description: renderMarkdown(post.Content, nil),
+		}
+		if post.Author != nil {
+			item.Author = fmt.Sprintf("%s (%s %s)", post.Author.Email, post.Author.FirstName, post.Author.LastName)
+		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return sendXML(c, "application/rss+xml; charset=utf-8", feed)
+}
+
+func (h *FeedHandlers) sendAtom(c *fiber.Ctx, title, subtitle, link, self string, posts []models.Post) error {
+	lastModified := feedLastModified(posts)
+	if notModified(c, feedETag(self, posts), lastModified) {
+		return nil
+	}
+
+	// Atom feeds need an update time, even without posts
+	updated := lastModified
+	if updated.IsZero() {
+		updated = time.Now()
+		if settings, ok := c.Locals("settings").(*models.Settings); ok && !settings.UpdatedAt.IsZero() {
+			updated = settings.UpdatedAt
+		}
+	}
+
+	baseURL := h.siteURL(c)
+	feed := atomFeed{
+		Title:    title,
+		Subtitle: subtitle,
+		ID:       baseURL + link,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: baseURL + link, Rel: "alternate", Type: "text/html"},
+			{Href: baseURL + self, Rel: "self", Type: "application/atom+xml"},
+		},
+		Generator: atomGenerator{Version: system.Version, Value: feedGenerator},
+	}
+
+	for _, post := range posts {
+		url := baseURL + "/posts/" + post.Slug
+		entry := atomEntry{
+			Title:     post.Title,
+			ID:        url,
+			Link:      atomLink{Href: url, Rel: "alternate", Type: "text/html"},
+			Published: postPublishedAt(post).Format(time.RFC3339),
+			Updated:   post.UpdatedAt.UTC().Format(time.RFC3339),
+			Content:   atomText{Type: "html", Value: renderMarkdown(post.Content, nil)},
+		}
+		if post.Author != nil {
+			entry.Author = &atomAuthor{Name: post.Author.FirstName + " " + post.Author.LastName}
+		}
+		if post.Excerpt != nil && *post.Excerpt != "" {
+			entry.Summary = &atomText{Type: "html", Value: renderMarkdown(*post.Excerpt, nil)}
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag.Name})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return sendXML(c, "application/atom+xml; charset=utf-8", feed)
+}
+
+// feedLastModified returns the most recent update time among the given posts
+func feedLastModified(posts []models.Post) time.Time {
+	var lastModified time.Time
+	for _, post := range posts {
+		if post.UpdatedAt.After(lastModified) {
+			lastModified = post.UpdatedAt
+		}
+		if published := postPublishedAt(post); published.After(lastModified) {
+			lastModified = published
+		}
+	}
+	return lastModified.UTC().Truncate(time.Second)
+}
+
+// postPublishedAt returns the UTC publication date of a post, falling back to
+// the raw publication date for posts created without a UTC value
+func postPublishedAt(post models.Post) time.Time {
+	if post.PublishedAtUTC.IsZero() {
+		return post.PublishedAt.UTC()
+	}
+	return post.PublishedAtUTC
+}
+
+// feedETag generates an ETag based on the feed path and the posts it contains
+func feedETag(path string, posts []models.Post) string {
+	hash := md5.New()
+	hash.Write([]byte(path))
+	for _, post := range posts {
+		fmt.Fprintf(hash, "%d-%d;", post.ID, post.UpdatedAt.UnixNano())
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil))
+}
+
+// notModified sets the caching headers and answers 304 when the client copy is fresh
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	c.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if match := c.Get("If-None-Match"); match != "" {
+		if match == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if since := c.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(since); err == nil && !lastModified.After(t) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+func sendXML(c *fiber.Ctx, contentType string, v interface{}) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating feed")
+	}
+
+	c.Set("Content-Type", contentType)
+	return c.Send(append([]byte(xml.Header), out...))
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/imaging"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2/log"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// imageHeaderSize is how much of an image is read to find its dimensions and
+// metadata, enough for the largest EXIF segment after the JFIF one
+const imageHeaderSize = 128 * 1024
+
+// ImageVariants generates the resized variants of the images of the media
+// library, and keeps them in the storage provider
+type ImageVariants struct {
+	repos       *repository.Repositories
+	storage     storage.Provider
+	limits      imaging.Limits
+	pregenerate bool
+
+	// group generates each variant once when it is requested concurrently
+	group singleflight.Group
+}
+
+// NewImageVariants creates the image variants of the storage provider
+func NewImageVariants(repos *repository.Repositories, storage storage.Provider, cfg *config.Config) *ImageVariants {
+	return &ImageVariants{
+		repos:       repos,
+		storage:     storage,
+		limits:      cfg.ImageLimits(),
+		pregenerate: cfg.Media.Pregenerate,
+	}
+}
+
+// Open returns a variant of an image, generated and stored on its first request
+func (v *ImageVariants) Open(media *models.Media, opts imaging.Options) (*models.MediaVariant, io.ReadCloser, error) {
+	var file io.ReadCloser
+	variant, data, err := v.find(media, opts, func(variant *models.MediaVariant) (err error) {
+		file, err = v.storage.Get(variant.Path)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == nil {
+		file = io.NopCloser(bytes.NewReader(data))
+	}
+	return variant, file, nil
+}
+
+// Locate returns a variant of an image, generated and stored on its first
+// request, without reading it, for the variants served by the storage provider
+func (v *ImageVariants) Locate(media *models.Media, opts imaging.Options) (*models.MediaVariant, error) {
+	variant, _, err := v.find(media, opts, func(variant *models.MediaVariant) error {
+		_, err := v.storage.Stat(variant.Path)
+		return err
+	})
+	return variant, err
+}
+
+// find returns a stored variant of an image, whose file is checked by check,
+// or the variant generated with its data
+func (v *ImageVariants) find(media *models.Media, opts imaging.Options, check func(*models.MediaVariant) error) (*models.MediaVariant, []byte, error) {
+	variant, err := v.repos.MediaVariants.FindByKey(media.ID, opts.Key())
+	if err == nil {
+		err := check(variant)
+		if err == nil {
+			return variant, nil, nil
+		}
+		// The stored variant is gone, generate it again
+		log.Warnf("Failed to open variant %s of %s: %v", variant.Key, media.Path, err)
+		if err := v.repos.MediaVariants.Delete(variant); err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	type generated struct {
+		variant *models.MediaVariant
+		data    []byte
+	}
+	result, err, _ := v.group.Do(fmt.Sprintf("%d/%s", media.ID, opts.Key()), func() (interface{}, error) {
+		variant, data, err := v.generate(media, opts)
+		return generated{variant, data}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	g := result.(generated)
+	return g.variant, g.data, nil
+}
+
+// generate resizes an image and stores the variant
+func (v *ImageVariants) generate(media *models.Media, opts imaging.Options) (*models.MediaVariant, []byte, error) {
+	file, err := v.storage.Get(media.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	original, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if media.Width == 0 {
+		if width, height, err := imaging.Dimensions(original); err == nil {
+			v.recordDimensions(media, width, height)
+		}
+	}
+
+	img, err := imaging.Transform(original, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resize %s: %w", media.Path, err)
+	}
+
+	filename, err := v.storage.Save(variantFilename(media.Path, opts.Key()), bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	variant := &models.MediaVariant{
+		MediaID:  media.ID,
+		Key:      opts.Key(),
+		Path:     filename,
+		MimeType: opts.MimeType(),
+		Size:     int64(len(img.Data)),
+		Width:    img.Width,
+		Height:   img.Height,
+	}
+	if err := v.repos.MediaVariants.Create(variant); err != nil {
+		// Another server may have stored the same variant meanwhile
+		if existing, findErr := v.repos.MediaVariants.FindByKey(media.ID, variant.Key); findErr == nil {
+			if existing.Path != filename {
+				_ = v.storage.Delete(filename)
+			}
+			return existing, img.Data, nil
+		}
+		_ = v.storage.Delete(filename)
+		return nil, nil, err
+	}
+
+	return variant, img.Data, nil
+}
+
+// Pregenerate generates the variants of an image listed in its srcset, so the
+// first visitors do not wait for them
+func (v *ImageVariants) Pregenerate(media *models.Media) {
+	if !v.pregenerate || !imaging.Resizable(media.MimeType) || media.Width == 0 {
+		return
+	}
+
+	for _, width := range v.limits.Sizes {
+		if width >= media.Width {
+			continue
+		}
+		_, file, err := v.Open(media, v.limits.Width(width, media.MimeType))
+		if err != nil {
+			log.Warnf("Failed to generate the %dpx variant of %s: %v", width, media.Path, err)
+			continue
+		}
+		file.Close()
+	}
+}
+
+// DeleteAll deletes the variants of a media
+func (v *ImageVariants) DeleteAll(media *models.Media) error {
+	variants, err := v.repos.MediaVariants.FindByMedia(media.ID)
+	if err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		if err := v.storage.Delete(variant.Path); err != nil {
+			return err
+		}
+		if err := v.repos.MediaVariants.Delete(variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Widths returns the widths of the variants listed in srcsets
+func (v *ImageVariants) Widths() []int {
+	return v.limits.Sizes
+}
+
+// recordDimensions saves the dimensions of an image found while serving it
+func (v *ImageVariants) recordDimensions(media *models.Media, width, height int) {
+	media.Width, media.Height = width, height
+	if err := v.repos.Media.UpdateDimensions(media); err != nil {
+		log.Warnf("Failed to save the dimensions of %s: %v", media.Path, err)
+	}
+}
+
+// peekDimensions returns the dimensions of an image from the start of a
+// reader, and a reader of the whole content. The dimensions are 0 for other
+// files.
+func peekDimensions(r io.Reader) (int, int, io.Reader) {
+	buffered := bufio.NewReaderSize(r, imageHeaderSize)
+	header, _ := buffered.Peek(imageHeaderSize)
+	width, height, err := imaging.Dimensions(header)
+	if err != nil {
+		return 0, 0, buffered
+	}
+	return width, height, buffered
+}
+
+// inspectUpload returns the metadata of an uploaded image, and a reader of its
+// content without the GPS position of the photo. The metadata are empty for
+// other files. The images whose metadata may follow their image data are read
+// in memory, a failed read failing the reader.
+func inspectUpload(r io.Reader) (imaging.Metadata, io.Reader) {
+	buffered := bufio.NewReaderSize(r, imageHeaderSize)
+	header, _ := buffered.Peek(imageHeaderSize)
+	metadata, _ := imaging.ReadMetadata(header)
+
+	if imaging.StripNeedsWholeFile(header) {
+		data, err := io.ReadAll(buffered)
+		if err != nil {
+			return metadata, failedReader{err}
+		}
+		imaging.StripGPS(data)
+		return metadata, bytes.NewReader(data)
+	}
+
+	stripped := bytes.Clone(header)
+	if !imaging.StripGPS(stripped) {
+		return metadata, buffered
+	}
+	if _, err := buffered.Discard(len(stripped)); err != nil {
+		return metadata, failedReader{err}
+	}
+	return metadata, io.MultiReader(bytes.NewReader(stripped), buffered)
+}
+
+// failedReader returns the error of a read that failed
+type failedReader struct {
+	err error
+}
+
+func (r failedReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// variantFilename returns the name a variant is stored under, next to the
+// variants of the same image
+func variantFilename(mediaPath, key string) string {
+	return path.Join(models.MediaVariantsDir, strings.TrimSuffix(mediaPath, path.Ext(mediaPath))+"-"+key)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// loginThrottleKey is a throttled login key along with its policy
+type loginThrottleKey struct {
+	key    string
+	policy models.LoginPolicy
+}
+
+// loginThrottleKeys returns the keys counting the failed logins of the client
+// IP and, when known, of the account
+func (h *AuthHandlers) loginThrottleKeys(c *fiber.Ctx, email string) []loginThrottleKey {
+	login := h.config.Security.Login
+	policy := models.LoginPolicy{
+		Window:      login.Window,
+		BackoffBase: login.BackoffBase,
+		Lockout:     login.Lockout,
+	}
+
+	ipPolicy := policy
+	ipPolicy.MaxFailures = login.MaxIPFailures
+	keys := []loginThrottleKey{{key: "ip:" + c.IP(), policy: ipPolicy}}
+
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		accountPolicy := policy
+		accountPolicy.MaxFailures = login.MaxAccountFailures
+		keys = append(keys, loginThrottleKey{key: "account:" + email, policy: accountPolicy})
+	}
+
+	return keys
+}
+
+// loginRetryAfter returns how long the client must wait before trying to log in again
+func (h *AuthHandlers) loginRetryAfter(keys []loginThrottleKey) time.Duration {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	for _, k := range keys {
+		throttle, err := h.repos.Logins.FindThrottle(k.key)
+		if err != nil {
+			log.Errorf("Failed to load login throttle %s: %v", k.key, err)
+			continue
+		}
+		retryAfter = max(retryAfter, throttle.RetryAfter(now))
+	}
+
+	return retryAfter
+}
+
+// recordLoginFailure counts a failed login against each key
+func (h *AuthHandlers) recordLoginFailure(keys []loginThrottleKey) {
+	now := time.Now()
+
+	for _, k := range keys {
+		throttle, err := h.repos.Logins.FindThrottle(k.key)
+		if err != nil {
+			log.Errorf("Failed to load login throttle %s: %v", k.key, err)
+			continue
+		}
+
+		throttle.RecordFailure(now, k.policy)
+		if err := h.repos.Logins.SaveThrottle(throttle); err != nil {
+			log.Errorf("Failed to save login throttle %s: %v", k.key, err)
+		}
+	}
+}
+
+// resetAccountThrottle forgets the failed logins of an account after a successful login.
+// The failures of the IP are kept, as credential stuffing succeeds from time to time.
+func (h *AuthHandlers) resetAccountThrottle(email string) {
+	key := "account:" + strings.ToLower(strings.TrimSpace(email))
+	if err := h.repos.Logins.DeleteThrottle(key); err != nil {
+		log.Errorf("Failed to reset login throttle %s: %v", key, err)
+	}
+}
+
+// recordLoginAttempt adds an entry to the login audit log
+func (h *AuthHandlers) recordLoginAttempt(c *fiber.Ctx, email string, user *models.User, result string) {
+	attempt := &models.LoginAttempt{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Success:   result == models.LoginResultSuccess,
+		Result:    result,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+
+	if err := h.repos.Logins.RecordAttempt(attempt); err != nil {
+		log.Errorf("Failed to record login attempt: %v", err)
+	}
+}
+
+// throttledMessage sets the Retry-After header and returns the error displayed to throttled clients
+func throttledMessage(c *fiber.Ctx, retryAfter time.Duration) string {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+
+	wait := "1 second"
+	if seconds > 90 {
+		wait = fmt.Sprintf("%d minutes", int(math.Ceil(float64(seconds)/60)))
+	} else if seconds > 1 {
+		wait = fmt.Sprintf("%d seconds", seconds)
+	}
+
+	return "Too many failed attempts, please try again in " + wait
+}
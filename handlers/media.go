@@ -2,17 +2,25 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/captain-corp/captain/imaging"
+	"github.com/captain-corp/captain/mediastore"
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
 	"github.com/captain-corp/captain/storage"
 	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nfnt/resize"
@@ -20,8 +28,10 @@ import (
 	"gorm.io/gorm"
 )
 
-// ServeMedia serves media files from the configured storage provider
-func ServeMedia(repositories *repository.Repositories, storageProvider storage.Provider) fiber.Handler {
+// ServeMedia serves media files from the configured storage provider, or
+// redirects to them in the redirect and cdn serving modes. Images are resized
+// and converted by the w, h, fit, q and fmt query parameters.
+func ServeMedia(repositories *repository.Repositories, storageProvider storage.Provider, variants *ImageVariants, redirects *MediaRedirects) fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
 		// Get path and trim leading slash if present
@@ -42,8 +52,22 @@ func ServeMedia(repositories *repository.Repositories, storageProvider storage.P
 			return c.Status(http.StatusInternalServerError).SendString("Error retrieving media")
 		}
 
-		// Generate ETag based on last modified time and size
-		etag := fmt.Sprintf(`"%x-%x"`, media.UpdatedAt.Unix(), media.Size)
+		// Other media, like SVGs and animated GIFs, are served as they are
+		if imaging.Resizable(media.MimeType) {
+			opts, transform, err := variants.limits.Parse(func(key string) string { return c.Query(key) }, media.MimeType)
+			if err != nil {
+				return c.Status(http.StatusBadRequest).SendString(err.Error())
+			}
+			if transform {
+				return serveImageVariant(c, variants, redirects, media, opts)
+			}
+		}
+
+		if redirected, err := redirects.Redirect(c, media.Path, media.MimeType); redirected {
+			return err
+		}
+
+		etag := mediaETag(media, "")
 
 		// Check If-None-Match header
 		if match := c.Get("If-None-Match"); match != "" {
@@ -52,6 +76,24 @@ func ServeMedia(repositories *repository.Repositories, storageProvider storage.P
 			}
 		}
 
+		c.Set("ETag", etag)
+		c.Set("Last-Modified", media.UpdatedAt.Format(http.TimeFormat))
+		c.Set("Accept-Ranges", "bytes")
+		c.Set("Cache-Control", "public, max-age=31536000")
+
+		// Partial requests, to seek in videos and resume downloads. Ranges
+		// are ignored when the file changed since the client got its start.
+		if header := c.Get(fiber.HeaderRange); header != "" && media.Size > 0 && ifRange(c, etag, media.UpdatedAt) {
+			ranges, err := utils.ParseRange(header, media.Size)
+			if errors.Is(err, utils.ErrRangeUnsatisfiable) {
+				c.Set("Content-Range", fmt.Sprintf("bytes */%d", media.Size))
+				return c.Status(http.StatusRequestedRangeNotSatisfiable).SendString("Range not satisfiable")
+			}
+			if err == nil {
+				return serveMediaRanges(c, storageProvider, media, ranges)
+			}
+		}
+
 		// Get file from storage provider
 		file, err := storageProvider.Get(path)
 		if err != nil {
@@ -59,17 +101,23 @@ func ServeMedia(repositories *repository.Repositories, storageProvider storage.P
 		}
 		defer file.Close()
 
+		// Images uploaded before their dimensions were recorded get them on
+		// their first request, for their srcset
+		var body io.Reader = file
+		if media.Width == 0 && imaging.Resizable(media.MimeType) {
+			var width, height int
+			if width, height, body = peekDimensions(file); width > 0 {
+				variants.recordDimensions(media, width, height)
+			}
+		}
+
 		// Set content type header
 		c.Set("Content-Type", media.MimeType)
 		c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", path))
-		c.Set("ETag", etag)
-		c.Set("Last-Modified", media.UpdatedAt.Format(http.TimeFormat))
 		c.Set("Content-Length", fmt.Sprintf("%d", media.Size))
-		c.Set("Accept-Ranges", "bytes")
-		c.Set("Cache-Control", "public, max-age=31536000")
 
 		// Stream the file to the response
-		if _, err := io.Copy(c.Response().BodyWriter(), file); err != nil {
+		if _, err := io.Copy(c.Response().BodyWriter(), body); err != nil {
 			return c.Status(http.StatusInternalServerError).SendString("Error streaming media file")
 		}
 
@@ -77,11 +125,140 @@ func ServeMedia(repositories *repository.Repositories, storageProvider storage.P
 	}
 }
 
+// mediaETag returns the strong ETag of the content of a media, or of one of its
+// variants, from the hash of its content. Media stored before hashing get one
+// from their modification time and size.
+func mediaETag(media *models.Media, variant string) string {
+	tag := media.Hash
+	if tag == "" {
+		tag = fmt.Sprintf("%x-%x", media.UpdatedAt.Unix(), media.Size)
+	}
+	if variant != "" {
+		tag += "-" + variant
+	}
+	return `"` + tag + `"`
+}
+
+// ifRange returns whether the ranges of a request apply, from its If-Range
+// header holding the ETag or the modification date the client has
+func ifRange(c *fiber.Ctx, etag string, modified time.Time) bool {
+	header := c.Get(fiber.HeaderIfRange)
+	if header == "" {
+		return true
+	}
+	if strings.HasPrefix(header, `"`) {
+		return header == etag
+	}
+	date, err := http.ParseTime(header)
+	return err == nil && date.Equal(modified.Truncate(time.Second))
+}
+
+// serveMediaRanges sends ranges of a media file, read from the storage
+// provider without the rest of the file. Several ranges are sent as a
+// multipart/byteranges response.
+func serveMediaRanges(c *fiber.Ctx, storageProvider storage.Provider, media *models.Media, ranges []utils.ByteRange) error {
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", media.Path))
+	c.Status(http.StatusPartialContent)
+
+	if len(ranges) == 1 {
+		file, err := storageProvider.GetRange(media.Path, ranges[0].Start, ranges[0].Length)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).SendString("Error retrieving media file")
+		}
+		defer file.Close()
+
+		c.Set("Content-Type", media.MimeType)
+		c.Set("Content-Range", ranges[0].ContentRange(media.Size))
+		c.Set("Content-Length", fmt.Sprintf("%d", ranges[0].Length))
+		if _, err := io.Copy(c.Response().BodyWriter(), file); err != nil {
+			return c.Status(http.StatusInternalServerError).SendString("Error streaming media file")
+		}
+		return nil
+	}
+
+	parts := multipart.NewWriter(c.Response().BodyWriter())
+	for _, r := range ranges {
+		if err := copyMediaRange(parts, storageProvider, media, r); err != nil {
+			c.Response().ResetBody()
+			return c.Status(http.StatusInternalServerError).SendString("Error streaming media file")
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return err
+	}
+	c.Set("Content-Type", "multipart/byteranges; boundary="+parts.Boundary())
+	return nil
+}
+
+// copyMediaRange writes a range of a media file as a part of a
+// multipart/byteranges response
+func copyMediaRange(parts *multipart.Writer, storageProvider storage.Provider, media *models.Media, r utils.ByteRange) error {
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":  {media.MimeType},
+		"Content-Range": {r.ContentRange(media.Size)},
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := storageProvider.GetRange(media.Path, r.Start, r.Length)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(part, file)
+	return err
+}
+
+// serveImageVariant serves a resized or converted image
+func serveImageVariant(c *fiber.Ctx, variants *ImageVariants, redirects *MediaRedirects, media *models.Media, opts imaging.Options) error {
+	etag := mediaETag(media, opts.Key())
+	if match := c.Get("If-None-Match"); match != "" && match == etag {
+		return c.Status(http.StatusNotModified).SendString("")
+	}
+
+	if redirects.Active() {
+		variant, err := variants.Locate(media, opts)
+		if err != nil {
+			return variantError(c, err)
+		}
+		if redirected, err := redirects.Redirect(c, variant.Path, variant.MimeType); redirected {
+			return err
+		}
+	}
+
+	variant, file, err := variants.Open(media, opts)
+	if err != nil {
+		return variantError(c, err)
+	}
+	defer file.Close()
+
+	filename := strings.TrimSuffix(filepath.Base(media.Path), filepath.Ext(media.Path)) + "." + opts.Format
+	c.Set("Content-Type", variant.MimeType)
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", filename))
+	c.Set("ETag", etag)
+	c.Set("Last-Modified", media.UpdatedAt.Format(http.TimeFormat))
+	c.Set("Content-Length", fmt.Sprintf("%d", variant.Size))
+	c.Set("Cache-Control", "public, max-age=31536000")
+
+	if _, err := io.Copy(c.Response().BodyWriter(), file); err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error streaming media file")
+	}
+
+	return nil
+}
+
+// variantError responds to a request of a variant that cannot be generated
+func variantError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return c.Status(http.StatusUnprocessableEntity).SendString("Image too large to resize")
+	}
+	return c.Status(http.StatusInternalServerError).SendString("Error resizing media")
+}
+
 // GenerateFavicons generates favicon files from a media file
 func GenerateFavicons(repositories *repository.Repositories, media *models.Media, storage storage.Provider) error {
-	var filename string
-	var icons []*models.Media
-
 	err := media.FetchFile(storage)
 	if err != nil {
 		return fmt.Errorf("failed to fetch logo file: %w", err)
@@ -94,40 +271,30 @@ func GenerateFavicons(repositories *repository.Repositories, media *models.Media
 	}
 
 	// Generate favicon.ico (32x32)
-	filename, err = uploadResizedImage(origImg, system.FaviconSize, storage, system.FaviconFilename)
+	favicon, err := uploadResizedImage(origImg, system.FaviconSize, storage, system.FaviconFilename, "image/x-icon")
 	if err != nil {
 		return fmt.Errorf("failed to generate favicon.ico: %w", err)
 	}
-	icons = append(icons, &models.Media{
-		Name:     system.FaviconFilename,
-		Path:     filename,
-		Size:     0,
-		MimeType: "image/x-icon",
-	})
 
 	// Generate apple-touch-icon.png (180x180)
-	filename, err = uploadResizedImage(origImg, system.AppleTouchIconSize, storage, system.AppleTouchIconFilename)
+	appleTouchIcon, err := uploadResizedImage(origImg, system.AppleTouchIconSize, storage, system.AppleTouchIconFilename, "image/png")
 	if err != nil {
 		return fmt.Errorf("failed to generate apple-touch-icon.png: %w", err)
 	}
-	icons = append(icons, &models.Media{
-		Name:     system.AppleTouchIconFilename,
-		Path:     filename,
-		Size:     0,
-		MimeType: "image/png",
-	})
 
 	// Generate icon.png (300x300)
-	filename, err = uploadResizedImage(origImg, system.IconSize, storage, system.FaviconPngFilename)
+	icon, err := uploadResizedImage(origImg, system.IconSize, storage, system.FaviconPngFilename, "image/png")
 	if err != nil {
 		return fmt.Errorf("failed to generate favicon.png: %w", err)
 	}
-	icons = append(icons, &models.Media{
-		Name:     system.FaviconPngFilename,
-		Path:     filename,
-		Size:     0,
-		MimeType: "image/png",
-	})
+
+	// Icons generated from a previous logo are replaced
+	icons := []*models.Media{favicon, appleTouchIcon, icon}
+	for _, icon := range icons {
+		if existing, err := repositories.Media.FindByFilename(icon.Name); err == nil {
+			icon.ID, icon.CreatedAt = existing.ID, existing.CreatedAt
+		}
+	}
 
 	// Save icons to the database
 	err = repositories.Media.SaveAll(icons)
@@ -138,15 +305,30 @@ func GenerateFavicons(repositories *repository.Repositories, media *models.Media
 	return nil
 }
 
-func uploadResizedImage(img image.Image, width int, storage storage.Provider, filename string) (string, error) {
+// uploadResizedImage saves a square PNG of an image, and returns its media.
+// Icons are served under their name, so their files are never shared.
+func uploadResizedImage(img image.Image, width int, storage storage.Provider, filename, mimeType string) (*models.Media, error) {
 	x, y := width, width
 
 	resized := resize.Resize(uint(x), uint(y), img, resize.Lanczos3)
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, resized); err != nil {
-		return "", err
+		return nil, err
+	}
+	hash, size, err := mediastore.Hash(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
 	}
 
-	filename, err := storage.Save(filename, bytes.NewReader(buf.Bytes()))
-	return filename, err
+	path, err := storage.Save(filename, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	return &models.Media{
+		Name:     filename,
+		Path:     path,
+		Size:     size,
+		MimeType: mimeType,
+		Hash:     hash,
+	}, nil
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// cdnRedirectMaxAge is how long browsers and caches keep the redirects to the
+// CDN, short enough to follow a change of its URL
+const cdnRedirectMaxAge = 24 * time.Hour
+
+// MediaRedirects sends browsers to presigned URLs of the storage provider or
+// to a CDN for the files of the media, so their content does not go through
+// Captain
+type MediaRedirects struct {
+	signer storage.URLSigner
+	expiry time.Duration
+	cdnURL string
+}
+
+// NewMediaRedirects creates the redirects of the serving mode of the storage
+// provider, none in proxy mode
+func NewMediaRedirects(provider storage.Provider, cfg *config.Config) *MediaRedirects {
+	redirects := &MediaRedirects{expiry: cfg.Storage.Serve.URLExpiry}
+	switch cfg.Storage.Serve.Mode {
+	case "redirect":
+		redirects.signer, _ = storage.AsURLSigner(provider)
+	case "cdn":
+		redirects.cdnURL = strings.TrimSuffix(cfg.Storage.Serve.CDNURL, "/")
+	}
+	return redirects
+}
+
+// Active returns whether files are redirected
+func (r *MediaRedirects) Active() bool {
+	return r != nil && (r.signer != nil || r.cdnURL != "")
+}
+
+// Redirect redirects a request to the URL of a file of the storage provider.
+// It returns false when Captain serves the file, as when the provider cannot
+// sign its URL.
+func (r *MediaRedirects) Redirect(c *fiber.Ctx, path, mimeType string) (bool, error) {
+	if !r.Active() {
+		return false, nil
+	}
+
+	escaped := (&url.URL{Path: path}).EscapedPath()
+	if r.cdnURL != "" {
+		c.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cdnRedirectMaxAge.Seconds())))
+		return true, c.Redirect(r.cdnURL+"/"+escaped, http.StatusFound)
+	}
+
+	signedURL, err := r.signer.PresignGet(path, mimeType, r.expiry)
+	if errors.Is(err, storage.ErrNoPresignedURL) {
+		log.Debugf("Serving %s through Captain: %v", path, err)
+		return false, nil
+	}
+	if err != nil {
+		log.Warnf("Serving %s through Captain: %v", path, err)
+		return false, nil
+	}
+	// Cached redirects lead to URLs valid for at least half their expiry
+	c.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(r.expiry.Seconds()/2)))
+	return true, c.Redirect(signedURL, http.StatusFound)
+}
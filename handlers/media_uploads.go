@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/imaging"
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+var (
+	// errUploadSize is returned for uploads larger than media.max_upload_size
+	errUploadSize = errors.New("file too large")
+	// errChunkOffset is returned for chunks not following the ones received
+	errChunkOffset = errors.New("chunk does not start at the end of the received data")
+	// errUploadIncomplete is returned when completing an upload missing data
+	errUploadIncomplete = errors.New("upload incomplete")
+)
+
+// MediaUploads receives large media, sent by browsers straight to the storage
+// provider when it supports it, and in chunks through Captain otherwise, so
+// uploads are not limited by the size of request bodies and resume where they
+// stopped
+type MediaUploads struct {
+	repos     *repository.Repositories
+	storage   storage.Provider
+	variants  *ImageVariants
+	dir       string
+	chunkSize int64
+	maxSize   int64
+	expiry    time.Duration
+
+	// mu serializes the chunks written to the upload directory
+	mu sync.Mutex
+}
+
+// NewMediaUploads creates the uploads of the storage provider
+func NewMediaUploads(repos *repository.Repositories, storage storage.Provider, variants *ImageVariants, cfg *config.Config) *MediaUploads {
+	dir := cfg.Media.UploadDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "captain-uploads")
+	}
+	// Chunks are request bodies
+	chunkSize := min(cfg.Media.ChunkSize, cfg.Server.BodyLimit)
+
+	return &MediaUploads{
+		repos:     repos,
+		storage:   storage,
+		variants:  variants,
+		dir:       dir,
+		chunkSize: int64(chunkSize) * 1024 * 1024,
+		maxSize:   int64(cfg.Media.MaxUploadSize) * 1024 * 1024,
+		expiry:    cfg.Media.UploadExpiry,
+	}
+}
+
+// Start creates the upload of a file of size bytes. For direct uploads, it
+// also returns where the browser sends the file.
+func (u *MediaUploads) Start(user *models.User, filename string, size int64) (*models.MediaUpload, *storage.DirectUpload, error) {
+	u.Cleanup()
+
+	filename = filepath.Base(filepath.Clean("/" + filename))
+	if filename == "/" || filename == "." {
+		return nil, nil, fmt.Errorf("invalid file name")
+	}
+	if size <= 0 {
+		return nil, nil, fmt.Errorf("invalid file size")
+	}
+	if size > u.maxSize {
+		return nil, nil, errUploadSize
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	upload := &models.MediaUpload{
+		UserID:    user.ID,
+		Filename:  filename,
+		MimeType:  mimeType,
+		Size:      size,
+		ExpiresAt: time.Now().Add(u.expiry),
+	}
+
+	var direct *storage.DirectUpload
+	if uploader, ok := storage.AsDirectUploader(u.storage); ok {
+		var err error
+		direct, err = uploader.PresignUpload(filename, mimeType, size, u.expiry)
+		switch {
+		case errors.Is(err, storage.ErrNoDirectUpload):
+			// Sent in chunks instead
+			log.Debugf("Uploading %s through Captain: %v", filename, err)
+			direct = nil
+		case err != nil:
+			return nil, nil, err
+		default:
+			upload.Direct = true
+			upload.Path = direct.Path
+		}
+	}
+
+	if err := u.repos.MediaUploads.Create(upload); err != nil {
+		return nil, nil, err
+	}
+	return upload, direct, nil
+}
+
+// WriteChunk appends a chunk of the file of an upload sent through Captain,
+// from offset
+func (u *MediaUploads) WriteChunk(upload *models.MediaUpload, offset int64, chunk []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if upload.Direct {
+		return fmt.Errorf("the file is sent to the storage provider")
+	}
+	if offset != upload.Received {
+		return errChunkOffset
+	}
+	if len(chunk) == 0 || int64(len(chunk)) > u.chunkSize {
+		return fmt.Errorf("chunks hold 1 to %d bytes", u.chunkSize)
+	}
+	if offset+int64(len(chunk)) > upload.Size {
+		return errUploadSize
+	}
+
+	if err := os.MkdirAll(u.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create the upload directory: %w", err)
+	}
+	file, err := os.OpenFile(u.partPath(upload), os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// Drop the end of a chunk whose write failed
+	err = file.Truncate(offset)
+	if err == nil {
+		_, err = file.WriteAt(chunk, offset)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	upload.Received += int64(len(chunk))
+	upload.ExpiresAt = time.Now().Add(u.expiry)
+	return u.repos.MediaUploads.Update(upload)
+}
+
+// Complete verifies the file of an upload and creates its media, taking its
+// MIME type from the name of the file. Uploads whose content does not match
+// their type are rejected, and the ones of a content already stored share the
+// file of its media.
+func (u *MediaUploads) Complete(upload *models.MediaUpload, media *models.Media) error {
+	var stored *mediastore.File
+	var err error
+	if upload.Direct {
+		stored, err = u.completeDirect(upload, media)
+	} else {
+		stored, err = u.completeChunked(upload, media)
+	}
+	if errors.Is(err, errUploadIncomplete) {
+		return err
+	}
+	if err != nil {
+		// The file is not kept, uploading it again starts over
+		u.Abort(upload)
+		return err
+	}
+
+	media.Name = upload.Filename
+	media.MimeType = upload.MimeType
+	media.Path = stored.Path
+	media.Size = stored.Size
+	media.Hash = stored.Hash
+	if err := mediastore.Create(u.repos, u.storage, media, stored); err != nil {
+		return err
+	}
+	if err := u.repos.MediaUploads.Delete(upload); err != nil {
+		log.Warnf("Failed to delete upload %d: %v", upload.ID, err)
+	}
+
+	// The variants of shared files are the ones of the first media
+	if !stored.Shared {
+		go u.variants.Pregenerate(media)
+	}
+	return nil
+}
+
+// completeDirect verifies a file uploaded to the storage provider, and stores
+// it again without its GPS position when it is a photo
+func (u *MediaUploads) completeDirect(upload *models.MediaUpload, media *models.Media) (*mediastore.File, error) {
+	object, err := u.storage.Stat(upload.Path)
+	if err != nil {
+		return nil, errUploadIncomplete
+	}
+	if object.Size != upload.Size {
+		return nil, fmt.Errorf("the file has %d bytes instead of %d", object.Size, upload.Size)
+	}
+
+	file, err := u.storage.GetRange(upload.Path, 0, min(imageHeaderSize, upload.Size))
+	if err != nil {
+		return nil, err
+	}
+	header, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMimeType(header, upload.MimeType); err != nil {
+		return nil, err
+	}
+
+	// The metadata are empty for other files
+	metadata, _ := imaging.ReadMetadata(header)
+	media.Width, media.Height, media.TakenAt = metadata.Width, metadata.Height, metadata.TakenAt
+	if !imaging.StripNeedsWholeFile(header) && !imaging.StripGPS(bytes.Clone(header)) {
+		return mediastore.Adopt(u.repos, u.storage, upload.Path)
+	}
+
+	file, err = u.storage.Get(upload.Path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !imaging.StripGPS(data) {
+		return mediastore.Adopt(u.repos, u.storage, upload.Path)
+	}
+	stored, err := mediastore.Save(u.repos, u.storage, upload.Filename, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.storage.Delete(upload.Path); err != nil {
+		log.Warnf("Failed to delete %s, stored again without its GPS position: %v", upload.Path, err)
+	}
+	return stored, nil
+}
+
+// completeChunked verifies a file uploaded in chunks, and moves it to the
+// storage provider
+func (u *MediaUploads) completeChunked(upload *models.MediaUpload, media *models.Media) (*mediastore.File, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if upload.Received != upload.Size {
+		return nil, errUploadIncomplete
+	}
+	file, err := os.Open(u.partPath(upload))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || info.Size() != upload.Size {
+		return nil, fmt.Errorf("the received file does not have %d bytes", upload.Size)
+	}
+
+	sniffed := make([]byte, min(utils.SniffSize, upload.Size))
+	if _, err := io.ReadFull(file, sniffed); err != nil {
+		return nil, err
+	}
+	if err := verifyMimeType(sniffed, upload.MimeType); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	metadata, reader := inspectUpload(file)
+	stored, err := mediastore.Save(u.repos, u.storage, upload.Filename, reader)
+	if err != nil {
+		return nil, err
+	}
+	media.Width, media.Height, media.TakenAt = metadata.Width, metadata.Height, metadata.TakenAt
+
+	if err := os.Remove(u.partPath(upload)); err != nil {
+		log.Warnf("Failed to delete the chunks of upload %d: %v", upload.ID, err)
+	}
+	return stored, nil
+}
+
+// Abort deletes an upload and the data received
+func (u *MediaUploads) Abort(upload *models.MediaUpload) {
+	if upload.Direct {
+		if err := u.storage.Delete(upload.Path); err != nil {
+			log.Warnf("Failed to delete the file of upload %d: %v", upload.ID, err)
+		}
+	} else if err := os.Remove(u.partPath(upload)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to delete the chunks of upload %d: %v", upload.ID, err)
+	}
+	if err := u.repos.MediaUploads.Delete(upload); err != nil {
+		log.Warnf("Failed to delete upload %d: %v", upload.ID, err)
+	}
+}
+
+// Cleanup aborts the expired uploads
+func (u *MediaUploads) Cleanup() {
+	uploads, err := u.repos.MediaUploads.FindExpired(time.Now())
+	if err != nil {
+		log.Warnf("Failed to find the expired uploads: %v", err)
+		return
+	}
+	for _, upload := range uploads {
+		u.Abort(upload)
+	}
+}
+
+// ChunkSize returns the size of the chunks of uploads sent through Captain
+func (u *MediaUploads) ChunkSize() int64 {
+	return u.chunkSize
+}
+
+// partPath returns the file the chunks of an upload are written to
+func (u *MediaUploads) partPath(upload *models.MediaUpload) string {
+	return filepath.Join(u.dir, fmt.Sprintf("%d.part", upload.ID))
+}
+
+// verifyMimeType checks the start of a file against the MIME type of its name.
+// Types of the same family, like audio/wave and audio/x-wav, are accepted.
+func verifyMimeType(data []byte, mimeType string) error {
+	sniffed := utils.SniffMimeType(data)
+	if sniffed == "" {
+		return nil
+	}
+	family, _, _ := strings.Cut(utils.BaseMimeType(mimeType), "/")
+	if sniffedFamily, _, _ := strings.Cut(sniffed, "/"); sniffedFamily != family {
+		return fmt.Errorf("the file is %s, not %s", sniffed, mimeType)
+	}
+	return nil
+}
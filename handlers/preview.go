@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	previewStatusDraft     = "draft"
+	previewStatusScheduled = "scheduled"
+)
+
+// previewSubject identifies the content a preview token was signed for
+func previewSubject(kind string, id uint) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// canPreview returns true if the request may see unpublished content: logged-in
+// users always can, anonymous visitors need a valid signed preview link.
+// Previews are kept out of caches and search engines.
+func canPreview(c *fiber.Ctx, subject string) bool {
+	allowed := c.Locals("user") != nil
+	if !allowed {
+		if settings, ok := c.Locals("settings").(*models.Settings); ok {
+			allowed = utils.VerifyPreviewToken(settings.PreviewSecret, subject, c.Query("preview"), time.Now())
+		}
+	}
+
+	if allowed {
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+		c.Set("X-Robots-Tag", "noindex")
+	}
+
+	return allowed
+}
+
+// previewLink returns an absolute signed preview link to path, on the site URL
+// of the feeds and sitemap
+func (h *AdminHandlers) previewLink(c *fiber.Ctx, path, subject string) (string, time.Time, error) {
+	settings, ok := c.Locals("settings").(*models.Settings)
+	if !ok || settings.PreviewSecret == "" {
+		return "", time.Time{}, fmt.Errorf("preview links are not available")
+	}
+
+	expiresAt := time.Now().Add(system.PreviewLinkTTL)
+	token := utils.SignPreviewToken(settings.PreviewSecret, subject, expiresAt)
+
+	return h.siteURL(c) + path + "?preview=" + url.QueryEscape(token), expiresAt, nil
+}
+
+// CreatePostPreviewLink handles the POST /admin/posts/:id/preview-link route
+func (h *AdminHandlers) CreatePostPreviewLink(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid post ID")
+		return c.Redirect("/admin/posts")
+	}
+
+	post, err := h.repos.Posts.FindByID(id)
+	if err != nil {
+		flash.Error(c, "Post not found")
+		return c.Redirect("/admin/posts")
+	}
+
+	if !currentUser(c).CanEditPost(post) {
+		return middleware.Forbidden(c)
+	}
+
+	editURL := fmt.Sprintf("/admin/posts/%d/edit", post.ID)
+
+	link, expiresAt, err := h.previewLink(c, "/posts/"+post.Slug, previewSubject("post", post.ID))
+	if err != nil {
+		flash.Error(c, err.Error())
+		return c.Redirect(editURL)
+	}
+
+	flash.Success(c, fmt.Sprintf("Preview link valid until %s: %s", expiresAt.Format("January 2, 2006 15:04"), link))
+	return c.Redirect(editURL)
+}
+
+// CreatePagePreviewLink handles the POST /admin/pages/:id/preview-link route
+func (h *AdminHandlers) CreatePagePreviewLink(c *fiber.Ctx) error {
+	id, err := utils.ParseUint(c.Params("id"))
+	if err != nil {
+		flash.Error(c, "Invalid page ID")
+		return c.Redirect("/admin/pages")
+	}
+
+	page, err := h.repos.Pages.FindByID(id)
+	if err != nil {
+		flash.Error(c, "Page not found")
+		return c.Redirect("/admin/pages")
+	}
+
+	editURL := fmt.Sprintf("/admin/pages/%d/edit", page.ID)
+
+	link, expiresAt, err := h.previewLink(c, "/pages/"+page.Slug, previewSubject("page", page.ID))
+	if err != nil {
+		flash.Error(c, err.Error())
+		return c.Redirect(editURL)
+	}
+
+	flash.Success(c, fmt.Sprintf("Preview link valid until %s: %s", expiresAt.Format("January 2, 2006 15:04"), link))
+	return c.Redirect(editURL)
+}
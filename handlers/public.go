@@ -6,6 +6,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -44,12 +45,26 @@ func (h *PublicHandlers) GetPostBySlug(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).Render("404", fiber.Map{})
 	}
 
+	// Drafts and scheduled posts are only shown to logged-in users and preview links
+	previewStatus := ""
+	if !post.IsPublished() {
+		if !canPreview(c, previewSubject("post", post.ID)) {
+			return c.Status(http.StatusNotFound).Render("404", fiber.Map{})
+		}
+
+		previewStatus = previewStatusDraft
+		if post.IsScheduled() {
+			previewStatus = previewStatusScheduled
+		}
+	}
+
 	// Render markdown content
-	post.Content = renderMarkdown(post.Content)
+	post.Content = renderMarkdown(post.Content, h.responsiveImages())
 
 	return c.Render("post", fiber.Map{
-		"title": post.Title,
-		"post":  post,
+		"title":         post.Title,
+		"post":          post,
+		"previewStatus": previewStatus,
 	})
 }
 
@@ -155,14 +170,24 @@ func (h *PublicHandlers) GetPageBySlug(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).Render("404", fiber.Map{})
 	}
 
+	// Hidden pages are only shown to logged-in users and preview links
+	previewStatus := ""
+	if !page.Visible {
+		if !canPreview(c, previewSubject("page", page.ID)) {
+			return c.Status(http.StatusNotFound).Render("404", fiber.Map{})
+		}
+		previewStatus = previewStatusDraft
+	}
+
 	// Render content based on type
 	if page.ContentType == "markdown" {
-		page.Content = renderMarkdown(page.Content)
+		page.Content = renderMarkdown(page.Content, h.responsiveImages())
 	}
 
 	return c.Render("page", fiber.Map{
-		"title": page.Title,
-		"page":  page,
+		"title":         page.Title,
+		"page":          page,
+		"previewStatus": previewStatus,
 	})
 }
 
@@ -189,14 +214,44 @@ func processPostsContent(posts []models.Post) {
 		posts[i].Excerpt = &content
 		// Render markdown for excerpt
 		if posts[i].Excerpt != nil {
-			rendered := renderMarkdown(*posts[i].Excerpt)
+			rendered := renderMarkdown(*posts[i].Excerpt, nil)
 			posts[i].Excerpt = &rendered
 		}
 	}
 }
 
-// renderMarkdown converts markdown content to HTML
-func renderMarkdown(content string) string {
+// responsiveImages returns the helper adding a srcset to the images of the
+// media library in markdown content
+func (h *PublicHandlers) responsiveImages() *responsiveImages {
+	return &responsiveImages{media: h.repos.Media, widths: h.config.Media.ImageSizes}
+}
+
+// responsiveImages renders the images of the media library with a srcset, so
+// browsers download the variant sized for the screen
+type responsiveImages struct {
+	media  models.MediaRepository
+	widths []int
+}
+
+// find returns the media an image of markdown content points to, if any
+func (r *responsiveImages) find(destination string) *models.Media {
+	if r == nil || !strings.HasPrefix(destination, "/media/") || strings.Contains(destination, "?") {
+		return nil
+	}
+	mediaPath, err := url.PathUnescape(strings.TrimPrefix(destination, "/media/"))
+	if err != nil {
+		return nil
+	}
+	media, err := r.media.FindByPath(mediaPath)
+	if err != nil || media.SrcSet(r.widths) == "" {
+		return nil
+	}
+	return media
+}
+
+// renderMarkdown converts markdown content to HTML. The images of the media
+// library get a srcset when images is not nil.
+func renderMarkdown(content string, images *responsiveImages) string {
 	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
 	p := parser.NewWithExtensions(extensions)
 	doc := p.Parse([]byte(content))
@@ -204,13 +259,63 @@ func renderMarkdown(content string) string {
 	htmlFlags := mdhtml.CommonFlags | mdhtml.HrefTargetBlank
 	opts := mdhtml.RendererOptions{
 		Flags:          htmlFlags,
-		RenderNodeHook: renderHook,
+		RenderNodeHook: newRenderHook(images),
 	}
 	renderer := mdhtml.NewRenderer(opts)
 
 	return string(markdown.Render(doc, renderer))
 }
 
+// newRenderHook returns the hook highlighting code blocks and rendering the
+// images of the media library with a srcset
+func newRenderHook(images *responsiveImages) mdhtml.RenderNodeFunc {
+	// The images rendered by the hook, whose exit is skipped as well
+	handled := map[*ast.Image]bool{}
+
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		image, ok := node.(*ast.Image)
+		if !ok {
+			return renderHook(w, node, entering)
+		}
+		if !entering {
+			return ast.GoToNext, handled[image]
+		}
+
+		media := images.find(string(image.Destination))
+		if media == nil {
+			return ast.GoToNext, false
+		}
+		handled[image] = true
+
+		var alt strings.Builder
+		ast.WalkFunc(image, func(node ast.Node, entering bool) ast.WalkStatus {
+			if text, ok := node.(*ast.Text); ok && entering {
+				alt.Write(text.Literal)
+			}
+			return ast.GoToNext
+		})
+
+		// Images without a description in the content get their alt text
+		if alt.Len() == 0 {
+			alt.WriteString(media.AltText)
+		}
+
+		var tag bytes.Buffer
+		tag.WriteString(`<img src="`)
+		mdhtml.EscapeHTML(&tag, image.Destination)
+		fmt.Fprintf(&tag, `" srcset="%s" sizes="%s" width="%d" height="%d" alt="`,
+			media.SrcSet(images.widths), media.Sizes(), media.Width, media.Height)
+		mdhtml.EscapeHTML(&tag, []byte(alt.String()))
+		if len(image.Title) > 0 {
+			tag.WriteString(`" title="`)
+			mdhtml.EscapeHTML(&tag, image.Title)
+		}
+		tag.WriteString(`" loading="lazy">`)
+		_, _ = w.Write(tag.Bytes())
+		return ast.SkipChildren, true
+	}
+}
+
 func renderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
 	if code, ok := node.(*ast.CodeBlock); ok && entering {
 		language := string(code.Info)
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"io/fs"
+
 	"github.com/captain-corp/captain/config"
 	"github.com/captain-corp/captain/flash"
+	"github.com/captain-corp/captain/middleware"
+	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/repository"
 	"github.com/captain-corp/captain/storage"
 
@@ -13,6 +17,8 @@ import (
 // RegisterPublicRoutes registers all public routes
 func RegisterPublicRoutes(repos *repository.Repositories, cfg *config.Config) *fiber.App {
 	publicHandlers := NewPublicHandlers(repos, cfg)
+	feedHandlers := NewFeedHandlers(repos, cfg)
+	sitemapHandlers := NewSitemapHandlers(repos, cfg)
 	app := fiber.New()
 
 	// Public routes
@@ -20,20 +26,94 @@ func RegisterPublicRoutes(repos *repository.Repositories, cfg *config.Config) *f
 	app.Get("/posts/:slug", publicHandlers.GetPostBySlug)
 	app.Get("/pages/:slug", publicHandlers.GetPageBySlug)
 	app.Get("/tags/:slug", publicHandlers.ListPostsByTag)
+	app.Get("/search", publicHandlers.Search)
+
+	// Feeds
+	app.Get("/feed.xml", feedHandlers.GetRSSFeed)
+	app.Get("/atom.xml", feedHandlers.GetAtomFeed)
+	app.Get("/tags/:slug/feed.xml", feedHandlers.GetTagFeed)
+
+	// Sitemaps
+	app.Get("/robots.txt", sitemapHandlers.GetRobotsTxt)
+	app.Get("/sitemap.xml", sitemapHandlers.GetSitemap)
+	app.Get("/sitemap-pages-:page.xml", sitemapHandlers.GetPagesSitemap)
+	app.Get("/sitemap-posts-:page.xml", sitemapHandlers.GetPostsSitemap)
 
 	return app
 }
 
 // RegisterDynamicRoutes registers all dynamic routes
-func RegisterDynamicRoutes(repos *repository.Repositories, storageProvider storage.Provider) *fiber.App {
+func RegisterDynamicRoutes(repos *repository.Repositories, storageProvider storage.Provider, variants *ImageVariants, redirects *MediaRedirects) *fiber.App {
 	app := fiber.New()
 
 	app.Get("/chroma.css", GetChromaCSS)
-	app.Get("/*", ServeMedia(repos, storageProvider))
+	app.Get("/*", ServeMedia(repos, storageProvider, variants, redirects))
 
 	return app
 }
 
+// RegisterAPIRoutes registers all /api/v1 REST API routes
+func RegisterAPIRoutes(repos *repository.Repositories, storage storage.Provider, variants *ImageVariants, embeddedFS fs.FS) (*fiber.App, error) {
+	apiHandlers, err := NewAPIHandlers(repos, storage, variants, embeddedFS)
+	if err != nil {
+		return nil, err
+	}
+
+	canEditPosts := middleware.RequirePermission(models.PermissionEditOwnPosts)
+	canManagePages := middleware.RequirePermission(models.PermissionManagePages)
+	canManageTags := middleware.RequirePermission(models.PermissionManageTags)
+	canUploadMedia := middleware.RequirePermission(models.PermissionUploadMedia)
+	canManageMedia := middleware.RequirePermission(models.PermissionManageMedia)
+	canManageMenus := middleware.RequirePermission(models.PermissionManageMenus)
+
+	app := fiber.New()
+
+	// The API description is public
+	app.Get("/api/v1/openapi.json", apiHandlers.GetOpenAPI)
+
+	api := app.Group("/api/v1", middleware.APIAuth(repos))
+
+	// Posts
+	api.Get("/posts", middleware.RequireScope(models.ScopePostsRead), canEditPosts, apiHandlers.ListPosts)
+	api.Get("/posts/:id", middleware.RequireScope(models.ScopePostsRead), canEditPosts, apiHandlers.GetPost)
+	api.Post("/posts", middleware.RequireScope(models.ScopePostsWrite), canEditPosts, apiHandlers.CreatePost)
+	api.Put("/posts/:id", middleware.RequireScope(models.ScopePostsWrite), canEditPosts, apiHandlers.UpdatePost)
+	api.Delete("/posts/:id", middleware.RequireScope(models.ScopePostsWrite), canEditPosts, apiHandlers.DeletePost)
+
+	// Pages
+	api.Get("/pages", middleware.RequireScope(models.ScopePagesRead), canManagePages, apiHandlers.ListPages)
+	api.Get("/pages/:id", middleware.RequireScope(models.ScopePagesRead), canManagePages, apiHandlers.GetPage)
+	api.Post("/pages", middleware.RequireScope(models.ScopePagesWrite), canManagePages, apiHandlers.CreatePage)
+	api.Put("/pages/:id", middleware.RequireScope(models.ScopePagesWrite), canManagePages, apiHandlers.UpdatePage)
+	api.Delete("/pages/:id", middleware.RequireScope(models.ScopePagesWrite), canManagePages, apiHandlers.DeletePage)
+
+	// Tags
+	api.Get("/tags", middleware.RequireScope(models.ScopeTagsRead), canEditPosts, apiHandlers.ListTags)
+	api.Get("/tags/:id", middleware.RequireScope(models.ScopeTagsRead), canEditPosts, apiHandlers.GetTag)
+	api.Post("/tags", middleware.RequireScope(models.ScopeTagsWrite), canManageTags, apiHandlers.CreateTag)
+	api.Put("/tags/:id", middleware.RequireScope(models.ScopeTagsWrite), canManageTags, apiHandlers.UpdateTag)
+	api.Delete("/tags/:id", middleware.RequireScope(models.ScopeTagsWrite), canManageTags, apiHandlers.DeleteTag)
+
+	// Media
+	api.Get("/media", middleware.RequireScope(models.ScopeMediaRead), canUploadMedia, apiHandlers.ListMedia)
+	api.Get("/media/:id", middleware.RequireScope(models.ScopeMediaRead), canUploadMedia, apiHandlers.GetMedia)
+	api.Get("/media/:id/usage", middleware.RequireScope(models.ScopeMediaRead), canUploadMedia, apiHandlers.GetMediaUsage)
+	api.Post("/media", middleware.RequireScope(models.ScopeMediaWrite), canUploadMedia, apiHandlers.UploadMedia)
+	api.Put("/media/:id", middleware.RequireScope(models.ScopeMediaWrite), canUploadMedia, apiHandlers.UpdateMedia)
+	api.Delete("/media/:id", middleware.RequireScope(models.ScopeMediaWrite), canManageMedia, apiHandlers.DeleteMedia)
+
+	// Menus
+	api.Get("/menus", middleware.RequireScope(models.ScopeMenusRead), canManageMenus, apiHandlers.ListMenuItems)
+	api.Get("/menus/:id", middleware.RequireScope(models.ScopeMenusRead), canManageMenus, apiHandlers.GetMenuItem)
+	api.Post("/menus", middleware.RequireScope(models.ScopeMenusWrite), canManageMenus, apiHandlers.CreateMenuItem)
+	api.Put("/menus/:id", middleware.RequireScope(models.ScopeMenusWrite), canManageMenus, apiHandlers.UpdateMenuItem)
+	api.Delete("/menus/:id", middleware.RequireScope(models.ScopeMenusWrite), canManageMenus, apiHandlers.DeleteMenuItem)
+
+	api.All("/*", apiHandlers.NotFound)
+
+	return app, nil
+}
+
 // RegisterAuthRoutes registers all authentication routes
 func RegisterAuthRoutes(repos *repository.Repositories, cfg *config.Config, sessionStore *session.Store) *fiber.App {
 	app := fiber.New()
@@ -45,6 +125,8 @@ func RegisterAuthRoutes(repos *repository.Repositories, cfg *config.Config, sess
 	// Login routes
 	app.Get("/login", authHandlers.ShowLogin)
 	app.Post("/login", authHandlers.PostLogin)
+	app.Get("/login/2fa", authHandlers.ShowTwoFactorLogin)
+	app.Post("/login/2fa", authHandlers.PostTwoFactorLogin)
 
 	// Logout route
 	app.Get("/logout", authHandlers.Logout)
@@ -53,86 +135,130 @@ func RegisterAuthRoutes(repos *repository.Repositories, cfg *config.Config, sess
 }
 
 // RegisterAdminRoutes registers all admin routes
-func RegisterAdminRoutes(repos *repository.Repositories, storage storage.Provider, sessionStore *session.Store) *fiber.App {
+func RegisterAdminRoutes(repos *repository.Repositories, cfg *config.Config, storage storage.Provider, variants *ImageVariants, uploads *MediaUploads, sessionStore *session.Store) *fiber.App {
 
 	flash.Setup(sessionStore)
-	adminHandlers := NewAdminHandlers(repos, storage)
-	adminMediaHandlers := NewAdminMediaHandlers(repos, storage)
+	adminHandlers := NewAdminHandlers(repos, cfg, storage)
+	adminMediaHandlers := NewAdminMediaHandlers(repos, storage, variants, uploads)
+
+	canEditPosts := middleware.RequirePermission(models.PermissionEditOwnPosts)
+	canManagePages := middleware.RequirePermission(models.PermissionManagePages)
+	canManageTags := middleware.RequirePermission(models.PermissionManageTags)
+	canUploadMedia := middleware.RequirePermission(models.PermissionUploadMedia)
+	canManageMedia := middleware.RequirePermission(models.PermissionManageMedia)
+	canManageMenus := middleware.RequirePermission(models.PermissionManageMenus)
+	canManageUsers := middleware.RequirePermission(models.PermissionManageUsers)
+	canManageSettings := middleware.RequirePermission(models.PermissionManageSettings)
 
 	app := fiber.New()
 	admin := app.Group("/admin")
 
 	// Dashboard
-	admin.Get("/", adminHandlers.Index)
+	admin.Get("/", canEditPosts, adminHandlers.Index)
+	admin.Get("/search", canEditPosts, adminHandlers.Search)
 
 	// Posts
-	admin.Get("/posts", adminHandlers.ListPosts)
-	admin.Get("/posts/create", adminHandlers.ShowCreatePost)
-	admin.Get("/posts/:id/edit", adminHandlers.ShowEditPost)
-	admin.Get("/posts/:id/delete", adminHandlers.ConfirmDeletePost)
-	admin.Delete("/posts/:id", adminHandlers.DeletePost)
+	admin.Get("/posts", canEditPosts, adminHandlers.ListPosts)
+	admin.Get("/posts/create", canEditPosts, adminHandlers.ShowCreatePost)
+	admin.Get("/posts/:id/edit", canEditPosts, adminHandlers.ShowEditPost)
+	admin.Get("/posts/:id/delete", canEditPosts, adminHandlers.ConfirmDeletePost)
+	admin.Delete("/posts/:id", canEditPosts, adminHandlers.DeletePost)
+	admin.Get("/posts/:id/revisions", canEditPosts, adminHandlers.ListPostRevisions)
+	admin.Post("/posts/:id/preview-link", canEditPosts, adminHandlers.CreatePostPreviewLink)
+	admin.Post("/posts/:id/revisions/:revision/restore", canEditPosts, adminHandlers.RestorePostRevision)
 
 	// Pages
-	admin.Get("/pages", adminHandlers.ListPages)
-	admin.Get("/pages/create", adminHandlers.ShowCreatePage)
-	admin.Get("/pages/:id/edit", adminHandlers.EditPage)
-	admin.Get("/pages/:id/delete", adminHandlers.ConfirmDeletePage)
-	admin.Delete("/pages/:id", adminHandlers.DeletePage)
+	admin.Get("/pages", canManagePages, adminHandlers.ListPages)
+	admin.Get("/pages/create", canManagePages, adminHandlers.ShowCreatePage)
+	admin.Get("/pages/:id/edit", canManagePages, adminHandlers.EditPage)
+	admin.Get("/pages/:id/delete", canManagePages, adminHandlers.ConfirmDeletePage)
+	admin.Delete("/pages/:id", canManagePages, adminHandlers.DeletePage)
+	admin.Get("/pages/:id/revisions", canManagePages, adminHandlers.ListPageRevisions)
+	admin.Post("/pages/:id/preview-link", canManagePages, adminHandlers.CreatePagePreviewLink)
+	admin.Post("/pages/:id/revisions/:revision/restore", canManagePages, adminHandlers.RestorePageRevision)
 
 	// Tags
-	admin.Get("/tags", adminHandlers.ListTags)
-	admin.Get("/tags/create", adminHandlers.ShowCreateTag)
-	admin.Post("/tags/create", adminHandlers.CreateTag)
-	admin.Get("/tags/:id/edit", adminHandlers.ShowEditTag)
-	admin.Post("/tags/:id/edit", adminHandlers.UpdateTag)
-	admin.Get("/tags/:id/posts", adminHandlers.ListPostsByTag)
-	admin.Get("/tags/:id/delete", adminHandlers.ConfirmDeleteTag)
-	admin.Delete("/tags/:id", adminHandlers.DeleteTag)
+	admin.Get("/tags", canManageTags, adminHandlers.ListTags)
+	admin.Get("/tags/create", canManageTags, adminHandlers.ShowCreateTag)
+	admin.Post("/tags/create", canManageTags, adminHandlers.CreateTag)
+	admin.Get("/tags/:id/edit", canManageTags, adminHandlers.ShowEditTag)
+	admin.Post("/tags/:id/edit", canManageTags, adminHandlers.UpdateTag)
+	admin.Get("/tags/:id/posts", canManageTags, adminHandlers.ListPostsByTag)
+	admin.Get("/tags/:id/delete", canManageTags, adminHandlers.ConfirmDeleteTag)
+	admin.Delete("/tags/:id", canManageTags, adminHandlers.DeleteTag)
 
 	// Users
-	admin.Get("/users", adminHandlers.ListUsers)
-	admin.Get("/users/create", adminHandlers.ShowCreateUser)
-	admin.Post("/users/create", adminHandlers.CreateUser)
-	admin.Get("/users/:id/edit", adminHandlers.ShowEditUser)
-	admin.Post("/users/:id/edit", adminHandlers.UpdateUser)
-	admin.Get("/users/:id/delete", adminHandlers.ConfirmDeleteUser)
-	admin.Delete("/users/:id", adminHandlers.DeleteUser)
+	admin.Get("/users", canManageUsers, adminHandlers.ListUsers)
+	admin.Get("/users/create", canManageUsers, adminHandlers.ShowCreateUser)
+	admin.Post("/users/create", canManageUsers, adminHandlers.CreateUser)
+	admin.Get("/users/:id/edit", canManageUsers, adminHandlers.ShowEditUser)
+	admin.Post("/users/:id/edit", canManageUsers, adminHandlers.UpdateUser)
+	admin.Get("/users/:id/delete", canManageUsers, adminHandlers.ConfirmDeleteUser)
+	admin.Delete("/users/:id", canManageUsers, adminHandlers.DeleteUser)
+	admin.Post("/users/:id/reset-2fa", canManageUsers, adminHandlers.ResetUserTwoFactor)
+	admin.Get("/logins", canManageUsers, adminHandlers.ListLoginActivity)
+	admin.Post("/logins/unlock", canManageUsers, adminHandlers.UnlockLogin)
 
 	// Menus
-	admin.Get("/menus", adminHandlers.ListMenuItems)
-	admin.Get("/menus/create", adminHandlers.ShowCreateMenuItem)
-	admin.Post("/menus/create", adminHandlers.CreateMenuItem)
-	admin.Get("/menus/:id/edit", adminHandlers.EditMenuItem)
-	admin.Post("/menus/:id", adminHandlers.UpdateMenuItem)
-	admin.Post("/menus/:id/move/:direction", adminHandlers.MoveMenuItem)
-	admin.Get("/menus/:id/delete", adminHandlers.ConfirmDeleteMenuItem)
-	admin.Delete("/menus/:id", adminHandlers.DeleteMenuItem)
+	admin.Get("/menus", canManageMenus, adminHandlers.ListMenuItems)
+	admin.Get("/menus/create", canManageMenus, adminHandlers.ShowCreateMenuItem)
+	admin.Post("/menus/create", canManageMenus, adminHandlers.CreateMenuItem)
+	admin.Get("/menus/:id/edit", canManageMenus, adminHandlers.EditMenuItem)
+	admin.Post("/menus/:id", canManageMenus, adminHandlers.UpdateMenuItem)
+	admin.Post("/menus/:id/move/:direction", canManageMenus, adminHandlers.MoveMenuItem)
+	admin.Get("/menus/:id/delete", canManageMenus, adminHandlers.ConfirmDeleteMenuItem)
+	admin.Delete("/menus/:id", canManageMenus, adminHandlers.DeleteMenuItem)
 
 	// Media
-	admin.Get("/media", adminMediaHandlers.ListMedia)
-	admin.Get("/media/upload", adminMediaHandlers.ShowUploadMedia)
-	admin.Post("/media/upload", adminMediaHandlers.UploadMedia)
-	admin.Get("/media/:id/delete", adminMediaHandlers.ConfirmDeleteMedia)
-	admin.Delete("/media/:id", adminMediaHandlers.DeleteMedia)
+	admin.Get("/media", canUploadMedia, adminMediaHandlers.ListMedia)
+	admin.Get("/media/upload", canUploadMedia, adminMediaHandlers.ShowUploadMedia)
+	admin.Post("/media/upload", canUploadMedia, adminMediaHandlers.UploadMedia)
+	admin.Get("/media/check", canManageMedia, adminMediaHandlers.CheckMedia)
+	admin.Get("/media/:id/edit", canUploadMedia, adminMediaHandlers.ShowEditMedia)
+	admin.Post("/media/:id/edit", canUploadMedia, adminMediaHandlers.UpdateMedia)
+	admin.Get("/media/:id/delete", canManageMedia, adminMediaHandlers.ConfirmDeleteMedia)
+	admin.Delete("/media/:id", canManageMedia, adminMediaHandlers.DeleteMedia)
+
+	// Two-factor authentication of the current user
+	admin.Get("/account/2fa", canEditPosts, adminHandlers.ShowTwoFactor)
+	admin.Post("/account/2fa/setup", canEditPosts, adminHandlers.SetupTwoFactor)
+	admin.Post("/account/2fa/enable", canEditPosts, adminHandlers.EnableTwoFactor)
+	admin.Post("/account/2fa/recovery-codes", canEditPosts, adminHandlers.RegenerateRecoveryCodes)
+	admin.Post("/account/2fa/disable", canEditPosts, adminHandlers.DisableTwoFactor)
+
+	// API tokens
+	admin.Get("/tokens", canEditPosts, adminHandlers.ListAPITokens)
+	admin.Get("/tokens/create", canEditPosts, adminHandlers.ShowCreateAPIToken)
+	admin.Post("/tokens/create", canEditPosts, adminHandlers.CreateAPIToken)
+	admin.Post("/tokens/:id/revoke", canEditPosts, adminHandlers.RevokeAPIToken)
 
 	// Settings
-	admin.Get("/settings", adminHandlers.ShowSettings)
-	admin.Post("/settings", adminHandlers.UpdateSettings)
+	admin.Get("/settings", canManageSettings, adminHandlers.ShowSettings)
+	admin.Post("/settings", canManageSettings, adminHandlers.UpdateSettings)
+	admin.Get("/settings/export", canManageSettings, adminHandlers.ExportSite)
+	admin.Post("/settings/import", canManageSettings, adminHandlers.ImportSite)
 
 	admin.Use("/", flash.Middleware())
 
 	// API routes
 	api := admin.Group("/api")
-	api.Get("/tags", adminHandlers.ApiGetTags)
-	api.Get("/media", adminMediaHandlers.ApiGetMediaList)
+	api.Get("/tags", canEditPosts, adminHandlers.ApiGetTags)
+	api.Get("/media", canEditPosts, adminMediaHandlers.ApiGetMediaList)
+
+	// Media uploads API routes
+	api.Post("/media/uploads", canUploadMedia, adminMediaHandlers.ApiStartUpload)
+	api.Get("/media/uploads/:id", canUploadMedia, adminMediaHandlers.ApiGetUpload)
+	api.Put("/media/uploads/:id", canUploadMedia, adminMediaHandlers.ApiUploadChunk)
+	api.Post("/media/uploads/:id/complete", canUploadMedia, adminMediaHandlers.ApiCompleteUpload)
+	api.Delete("/media/uploads/:id", canUploadMedia, adminMediaHandlers.ApiAbortUpload)
 
 	// Posts API routes
-	api.Post("/posts", adminHandlers.ApiCreatePost)
-	api.Put("/posts/:id", adminHandlers.ApiUpdatePost)
+	api.Post("/posts", canEditPosts, adminHandlers.ApiCreatePost)
+	api.Put("/posts/:id", canEditPosts, adminHandlers.ApiUpdatePost)
 
 	// Pages API routes
-	api.Post("/pages", adminHandlers.ApiCreatePage)
-	api.Put("/pages/:id", adminHandlers.ApiUpdatePage)
+	api.Post("/pages", canManagePages, adminHandlers.ApiCreatePage)
+	api.Put("/pages/:id", canManagePages, adminHandlers.ApiUpdatePage)
 
 	return app
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"html"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// snippetHighlighter replaces the markers returned by the search index with <mark> tags
+var snippetHighlighter = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// Search handles the GET /search route
+func (h *PublicHandlers) Search(c *fiber.Ctx) error {
+	settings := c.Locals("settings").(*models.Settings)
+	query := strings.TrimSpace(c.Query("q"))
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Logged-in users can find hidden and scheduled content
+	user := c.Locals("user")
+
+	results, total, err := h.repos.Search.Search(query, user != nil, page, settings.PostsPerPage)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	highlightSearchResults(results)
+
+	return c.Render("search", h.addCommonData(c, fiber.Map{
+		"title":       "Search",
+		"query":       query,
+		"results":     results,
+		"total":       total,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(settings.PostsPerPage))),
+		"user":        user,
+		"settings":    settings,
+	}))
+}
+
+// Search handles the GET /admin/search route
+func (h *AdminHandlers) Search(c *fiber.Ctx) error {
+	settings := c.Locals("settings").(*models.Settings)
+	query := strings.TrimSpace(c.Query("q"))
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	results, total, err := h.repos.Search.Search(query, true, page, settings.PostsPerPage)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).Render("admin_500", fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	highlightSearchResults(results)
+
+	return c.Render("admin_search", fiber.Map{
+		"title":       "Search",
+		"query":       query,
+		"results":     results,
+		"total":       total,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(settings.PostsPerPage))),
+	})
+}
+
+// highlightSearchResults escapes the search snippets and highlights the matching terms
+func highlightSearchResults(results []models.SearchResult) {
+	for i := range results {
+		results[i].TitleSnippet = snippetHighlighter.Replace(html.EscapeString(results[i].TitleSnippet))
+		results[i].Snippet = snippetHighlighter.Replace(html.EscapeString(results[i].Snippet))
+	}
+}
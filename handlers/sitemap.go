@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/system"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapHandlers handles sitemap and robots.txt routes
+type SitemapHandlers struct {
+	*BaseHandlers
+}
+
+// NewSitemapHandlers creates a new sitemap handlers instance
+func NewSitemapHandlers(repos *repository.Repositories, cfg *config.Config) *SitemapHandlers {
+	return &SitemapHandlers{
+		BaseHandlers: NewBaseHandlers(repos, cfg),
+	}
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	XMLNS    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// GetSitemap handles the GET /sitemap.xml route. When the number of URLs of
+// the pages, tags and visible posts exceeds the sitemap limit, a sitemap index
+// is returned instead.
+func (h *SitemapHandlers) GetSitemap(c *fiber.Ctx) error {
+	posts, total, err := h.repos.Posts.FindVisiblePaginated(1, system.SitemapMaxURLs)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating sitemap")
+	}
+
+	baseURL := h.siteURL(c)
+	urls, err := h.contentURLs(baseURL)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating sitemap")
+	}
+
+	if int64(len(urls))+total > system.SitemapMaxURLs {
+		index := sitemapIndex{XMLNS: sitemapNS}
+		for i := 1; i <= sitemapCount(int64(len(urls))); i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapEntry{
+				Loc: fmt.Sprintf("%s/sitemap-pages-%d.xml", baseURL, i),
+			})
+		}
+		for i := 1; i <= sitemapCount(total); i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapEntry{
+				Loc: fmt.Sprintf("%s/sitemap-posts-%d.xml", baseURL, i),
+			})
+		}
+		return sendXML(c, "application/xml; charset=utf-8", index)
+	}
+
+	urls = append(urls, postURLs(baseURL, posts)...)
+
+	return sendXML(c, "application/xml; charset=utf-8", sitemapURLSet{XMLNS: sitemapNS, URLs: urls})
+}
+
+// GetPagesSitemap handles the GET /sitemap-pages-:page.xml route
+func (h *SitemapHandlers) GetPagesSitemap(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Params("page"))
+	if err != nil || page < 1 {
+		return c.Status(http.StatusNotFound).SendString("Sitemap not found")
+	}
+
+	urls, err := h.contentURLs(h.siteURL(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating sitemap")
+	}
+
+	start := (page - 1) * system.SitemapMaxURLs
+	if start >= len(urls) {
+		return c.Status(http.StatusNotFound).SendString("Sitemap not found")
+	}
+	urls = urls[start:min(start+system.SitemapMaxURLs, len(urls))]
+
+	return sendXML(c, "application/xml; charset=utf-8", sitemapURLSet{XMLNS: sitemapNS, URLs: urls})
+}
+
+// GetPostsSitemap handles the GET /sitemap-posts-:page.xml route
+func (h *SitemapHandlers) GetPostsSitemap(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Params("page"))
+	if err != nil || page < 1 {
+		return c.Status(http.StatusNotFound).SendString("Sitemap not found")
+	}
+
+	posts, _, err := h.repos.Posts.FindVisiblePaginated(page, system.SitemapMaxURLs)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Error generating sitemap")
+	}
+
+	if len(posts) == 0 {
+		return c.Status(http.StatusNotFound).SendString("Sitemap not found")
+	}
+
+	return sendXML(c, "application/xml; charset=utf-8", sitemapURLSet{
+		XMLNS: sitemapNS,
+		URLs:  postURLs(h.siteURL(c), posts),
+	})
+}
+
+// GetRobotsTxt handles the GET /robots.txt route
+func (h *SitemapHandlers) GetRobotsTxt(c *fiber.Ctx) error {
+	settings := c.Locals("settings").(*models.Settings)
+
+	robots := settings.RobotsTxt
+	if strings.TrimSpace(robots) == "" {
+		robots = system.DefaultRobotsTxt
+	}
+
+	if !strings.Contains(strings.ToLower(robots), "sitemap:") {
+		robots = strings.TrimRight(robots, "\r\n") + "\n\nSitemap: " + h.siteURL(c) + "/sitemap.xml\n"
+	}
+
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	return c.SendString(robots)
+}
+
+// contentURLs returns the sitemap entries for the home page, visible pages and
+// the archives of the tags of visible posts
+func (h *SitemapHandlers) contentURLs(baseURL string) ([]sitemapURL, error) {
+	urls := []sitemapURL{{Loc: baseURL + "/"}}
+
+	pages, err := h.repos.Pages.FindAllVisible()
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		urls = append(urls, sitemapURL{
+			Loc:     baseURL + "/pages/" + page.Slug,
+			LastMod: sitemapDate(page.UpdatedAt),
+		})
+	}
+
+	tags, err := h.repos.Tags.FindVisible()
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		urls = append(urls, sitemapURL{
+			Loc:     baseURL + "/tags/" + tag.Slug,
+			LastMod: sitemapDate(tag.UpdatedAt),
+		})
+	}
+
+	return urls, nil
+}
+
+// sitemapCount returns the number of sitemaps holding a number of URLs
+func sitemapCount(urls int64) int {
+	return int(math.Ceil(float64(urls) / float64(system.SitemapMaxURLs)))
+}
+
+func postURLs(baseURL string, posts []models.Post) []sitemapURL {
+	urls := make([]sitemapURL, 0, len(posts))
+	for _, post := range posts {
+		urls = append(urls, sitemapURL{
+			Loc:     baseURL + "/posts/" + post.Slug,
+			LastMod: sitemapDate(post.UpdatedAt),
+		})
+	}
+	return urls
+}
+
+func sitemapDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,250 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// Tags of the EXIF metadata read or stripped from photos
+const (
+	exifOrientationTag       = 0x0112
+	exifIFDTag               = 0x8769 // offset of the EXIF IFD
+	exifGPSTag               = 0x8825 // offset of the GPS IFD
+	exifDateTimeOriginalTag  = 0x9003
+	exifDateTimeDigitizedTag = 0x9004
+	exifOffsetTimeOrigTag    = 0x9011
+)
+
+// exifTypeSizes are the sizes of the values of the TIFF field types
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// jpegExif returns the TIFF data of the EXIF segment of a JPEG image, sharing
+// the memory of data, or nil when it has none
+func jpegExif(data []byte) []byte {
+	return jpegSegment(data, "Exif\x00\x00")
+}
+
+// jpegSegment returns the content of the first APP1 segment of a JPEG image
+// starting with a prefix, after the prefix, or nil when it has none
+func jpegSegment(data []byte, prefix string) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		// Start of the image data, no metadata follows
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte(prefix)) {
+			return segment[len(prefix):]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// tiff reads the IFDs of TIFF data, as found in EXIF segments
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffEntry is a field of an IFD, at an offset of the TIFF data
+type tiffEntry struct {
+	offset int
+	tag    uint16
+	typ    uint16
+	count  int
+}
+
+func newTIFF(data []byte) *tiff {
+	if len(data) < 8 {
+		return nil
+	}
+	switch string(data[:2]) {
+	case "II":
+		return &tiff{data: data, order: binary.LittleEndian}
+	case "MM":
+		return &tiff{data: data, order: binary.BigEndian}
+	}
+	return nil
+}
+
+// firstIFD returns the offset of the first IFD, holding the main tags
+func (t *tiff) firstIFD() int {
+	return int(t.order.Uint32(t.data[4:]))
+}
+
+// entries returns the fields of the IFD at an offset
+func (t *tiff) entries(ifd int) []tiffEntry {
+	if ifd < 8 || ifd+2 > len(t.data) {
+		return nil
+	}
+	var entries []tiffEntry
+	count := int(t.order.Uint16(t.data[ifd:]))
+	for i := 0; i < count; i++ {
+		offset := ifd + 2 + i*12
+		if offset+12 > len(t.data) {
+			break
+		}
+		entries = append(entries, tiffEntry{
+			offset: offset,
+			tag:    t.order.Uint16(t.data[offset:]),
+			typ:    t.order.Uint16(t.data[offset+2:]),
+			count:  int(t.order.Uint32(t.data[offset+4:])),
+		})
+	}
+	return entries
+}
+
+// find returns the field of a tag in the IFD at an offset
+func (t *tiff) find(ifd int, tag uint16) (tiffEntry, bool) {
+	for _, e := range t.entries(ifd) {
+		if e.tag == tag {
+			return e, true
+		}
+	}
+	return tiffEntry{}, false
+}
+
+// value returns the bytes of the value of a field, stored in the field when
+// they fit in 4 bytes and at an offset of the TIFF data otherwise
+func (t *tiff) value(e tiffEntry) []byte {
+	size := exifTypeSizes[e.typ] * e.count
+	if size <= 0 || e.count > len(t.data) {
+		return nil
+	}
+	if size <= 4 {
+		return t.data[e.offset+8 : e.offset+8+size]
+	}
+	offset := int(t.order.Uint32(t.data[e.offset+8:]))
+	if offset < 0 || offset+size > len(t.data) {
+		return nil
+	}
+	return t.data[offset : offset+size]
+}
+
+// uint returns the value of a SHORT or LONG field
+func (t *tiff) uint(e tiffEntry) int {
+	switch e.typ {
+	case 3:
+		return int(t.order.Uint16(t.data[e.offset+8:]))
+	case 4:
+		return int(t.order.Uint32(t.data[e.offset+8:]))
+	}
+	return 0
+}
+
+// string returns the value of an ASCII field
+func (t *tiff) string(e tiffEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(t.value(e)), "\x00 ")
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG image, from 1 for an
+// upright image to 8, or 0 when it has none. Phones store their photos as the
+// sensor saw them and record how to turn them in this tag.
+func jpegOrientation(data []byte) int {
+	t := newTIFF(jpegExif(data))
+	if t == nil {
+		return 0
+	}
+	e, ok := t.find(t.firstIFD(), exifOrientationTag)
+	if !ok {
+		return 0
+	}
+	if orientation := t.uint(e); orientation >= 1 && orientation <= 8 {
+		return orientation
+	}
+	return 0
+}
+
+// jpegTakenAt returns the date a JPEG photo was taken, in the time zone of the
+// camera when it recorded it and in UTC otherwise
+func jpegTakenAt(data []byte) (time.Time, bool) {
+	t := newTIFF(jpegExif(data))
+	if t == nil {
+		return time.Time{}, false
+	}
+	e, ok := t.find(t.firstIFD(), exifIFDTag)
+	if !ok {
+		return time.Time{}, false
+	}
+	exifIFD := t.uint(e)
+
+	var date string
+	for _, tag := range []uint16{exifDateTimeOriginalTag, exifDateTimeDigitizedTag} {
+		if e, ok := t.find(exifIFD, tag); ok {
+			if date = t.string(e); date != "" {
+				break
+			}
+		}
+	}
+	if date == "" {
+		return time.Time{}, false
+	}
+
+	if e, ok := t.find(exifIFD, exifOffsetTimeOrigTag); ok {
+		if taken, err := time.Parse("2006:01:02 15:04:05-07:00", date+t.string(e)); err == nil {
+			return taken, true
+		}
+	}
+	taken, err := time.Parse("2006:01:02 15:04:05", date)
+	return taken, err == nil
+}
+
+// stripGPS erases the GPS position of TIFF data in place, keeping its size. It
+// returns false when the data have no position.
+func (t *tiff) stripGPS() bool {
+	e, ok := t.find(t.firstIFD(), exifGPSTag)
+	if !ok {
+		return false
+	}
+	gpsIFD := t.uint(e)
+	entries := t.entries(gpsIFD)
+	if len(entries) == 0 {
+		return false
+	}
+
+	for _, e := range entries {
+		clear(t.value(e))
+		clear(t.data[e.offset : e.offset+12])
+	}
+	// An empty IFD is left for the readers following the offset
+	t.order.PutUint16(t.data[gpsIFD:], 0)
+	return true
+}
+
+// Metadata describes an image from the start of its file
+type Metadata struct {
+	Width   int        // as displayed, after the EXIF orientation
+	Height  int        // as displayed, after the EXIF orientation
+	TakenAt *time.Time // capture date of photos, when recorded
+}
+
+// ReadMetadata returns the metadata of an image from the start of its file
+func ReadMetadata(header []byte) (Metadata, error) {
+	width, height, err := Dimensions(header)
+	if err != nil {
+		return Metadata{}, err
+	}
+	metadata := Metadata{Width: width, Height: height}
+	if taken, ok := jpegTakenAt(header); ok {
+		metadata.TakenAt = &taken
+	}
+	return metadata, nil
+}
@@ -0,0 +1,392 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"regexp"
+	"slices"
+)
+
+// Containers of the images whose GPS position is stripped
+const (
+	containerJPEG = "jpeg"
+	containerPNG  = "png"
+	containerWebP = "webp"
+	containerHEIF = "heif"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// heifBrands are the major brands of HEIF files, including HEIC photos and AVIF images
+var heifBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1", "avif", "avis"}
+
+// xmpNamespace starts the APP1 segments of JPEG images holding an XMP packet
+const xmpNamespace = "http://ns.adobe.com/xap/1.0/\x00"
+
+// xmpGPS matches the GPS properties of XMP packets, written as attributes or
+// as elements
+var xmpGPS = regexp.MustCompile(`(?s)\s[\w.-]+:GPS\w*\s*=\s*(?:"[^"]*"|'[^']*')|<[\w.-]+:GPS\w*[^>]*/>|<[\w.-]+:GPS\w*[^>]*>.*?</[\w.-]+:GPS\w*\s*>`)
+
+// containerOf returns the container of an image from the start of its file
+func containerOf(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return containerJPEG
+	case bytes.HasPrefix(data, pngSignature):
+		return containerPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return containerWebP
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && slices.Contains(heifBrands, string(data[8:12])):
+		return containerHEIF
+	}
+	return ""
+}
+
+// StripNeedsWholeFile returns whether StripGPS must be given the whole file of
+// an image rather than its start. PNG, WebP and HEIF images may store their
+// metadata after their image data, while JPEG photos store them first.
+func StripNeedsWholeFile(header []byte) bool {
+	switch containerOf(header) {
+	case containerPNG, containerWebP, containerHEIF:
+		return true
+	}
+	return false
+}
+
+// StripGPS erases the GPS position in the EXIF and XMP metadata of a JPEG, PNG,
+// WebP or HEIF photo, so uploads do not reveal where photos were taken. JPEG
+// photos can be given the start of their file only, see StripNeedsWholeFile.
+// The data are changed in place and keep their size. It returns false when
+// there is no position.
+func StripGPS(data []byte) bool {
+	switch containerOf(data) {
+	case containerJPEG:
+		return stripJPEGGPS(data)
+	case containerPNG:
+		return stripPNGGPS(data)
+	case containerWebP:
+		return stripWebPGPS(data)
+	case containerHEIF:
+		return stripHEIFGPS(data)
+	}
+	return false
+}
+
+// stripTIFFGPS erases the GPS position of EXIF TIFF data, when it is valid
+func stripTIFFGPS(data []byte) bool {
+	t := newTIFF(data)
+	return t != nil && t.stripGPS()
+}
+
+// stripXMPGPS blanks the GPS properties of an XMP packet in place, keeping its size
+func stripXMPGPS(packet []byte) bool {
+	matches := xmpGPS.FindAllIndex(packet, -1)
+	for _, match := range matches {
+		for i := match[0]; i < match[1]; i++ {
+			packet[i] = ' '
+		}
+	}
+	return len(matches) > 0
+}
+
+// stripJPEGGPS erases the GPS position of the EXIF and XMP segments of a JPEG photo
+func stripJPEGGPS(data []byte) bool {
+	exif := stripTIFFGPS(jpegExif(data))
+	xmp := stripXMPGPS(jpegSegment(data, xmpNamespace))
+	return exif || xmp
+}
+
+// stripPNGGPS erases the GPS position of the eXIf and XMP chunks of a PNG
+// image, and updates their checksums. Compressed XMP packets are not supported.
+func stripPNGGPS(data []byte) bool {
+	stripped := false
+	for i := len(pngSignature); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if length > len(data)-i-12 {
+			break
+		}
+		typ := string(data[i+4 : i+8])
+		chunk := data[i+8 : i+8+length]
+
+		changed := false
+		switch typ {
+		case "eXIf":
+			changed = stripTIFFGPS(chunk)
+		case "iTXt":
+			changed = stripXMPGPS(pngXMP(chunk))
+		}
+		if changed {
+			binary.BigEndian.PutUint32(data[i+8+length:], crc32.ChecksumIEEE(data[i+4:i+8+length]))
+			stripped = true
+		}
+
+		if typ == "IEND" {
+			break
+		}
+		i += 12 + length
+	}
+	return stripped
+}
+
+// pngXMP returns the XMP packet of an iTXt chunk, or nil for other texts and
+// compressed packets
+func pngXMP(chunk []byte) []byte {
+	keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+	if !ok || string(keyword) != "XML:com.adobe.xmp" || len(rest) < 2 || rest[0] != 0 {
+		return nil
+	}
+	rest = rest[2:]
+	// The language tag and translated keyword precede the text
+	for range 2 {
+		if _, rest, ok = bytes.Cut(rest, []byte{0}); !ok {
+			return nil
+		}
+	}
+	return rest
+}
+
+// stripWebPGPS erases the GPS position of the EXIF and XMP chunks of a WebP image
+func stripWebPGPS(data []byte) bool {
+	stripped := false
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if size > len(data)-i-8 {
+			break
+		}
+		chunk := data[i+8 : i+8+size]
+
+		switch string(data[i : i+4]) {
+		case "EXIF":
+			// Some writers keep the prefix of the JPEG segment
+			if stripTIFFGPS(bytes.TrimPrefix(chunk, []byte("Exif\x00\x00"))) {
+				stripped = true
+			}
+		case "XMP ":
+			if stripXMPGPS(chunk) {
+				stripped = true
+			}
+		}
+		// Chunks are padded to an even size
+		i += 8 + size + size%2
+	}
+	return stripped
+}
+
+// heifLocation is where the data of an item of a HEIF file are stored
+type heifLocation struct {
+	id     uint32
+	method uint64 // 0 for an offset of the file, 1 for an offset of the idat box
+	offset uint64
+	length uint64 // 0 up to the end of the data
+}
+
+// stripHEIFGPS erases the GPS position of the EXIF and XMP items of a HEIF image
+func stripHEIFGPS(data []byte) bool {
+	meta := isoBox(data, "meta")
+	if len(meta) < 4 {
+		return false
+	}
+	// The version and flags of the box precede its children
+	meta = meta[4:]
+	items := heifMetadataItems(isoBox(meta, "iinf"))
+	if len(items) == 0 {
+		return false
+	}
+
+	stripped := false
+	for _, location := range heifLocations(isoBox(meta, "iloc")) {
+		typ, ok := items[location.id]
+		if !ok {
+			continue
+		}
+		source := data
+		if location.method == 1 {
+			source = isoBox(meta, "idat")
+		}
+		if location.offset > uint64(len(source)) {
+			continue
+		}
+		item := source[location.offset:]
+		if location.length > 0 {
+			if location.length > uint64(len(item)) {
+				continue
+			}
+			item = item[:location.length]
+		}
+
+		switch typ {
+		case "Exif":
+			// The TIFF data follow the offset of their header
+			if len(item) < 4 {
+				continue
+			}
+			start := uint64(binary.BigEndian.Uint32(item)) + 4
+			if start <= uint64(len(item)) && stripTIFFGPS(item[start:]) {
+				stripped = true
+			}
+		case "mime":
+			if stripXMPGPS(item) {
+				stripped = true
+			}
+		}
+	}
+	return stripped
+}
+
+// heifMetadataItems returns the type of the EXIF and XMP items listed in an iinf
+// box, by item ID
+func heifMetadataItems(iinf []byte) map[uint32]string {
+	r := &isoReader{data: iinf}
+	version := r.uint(1)
+	r.uint(3)
+	if version == 0 {
+		r.uint(2)
+	} else {
+		r.uint(4)
+	}
+	if r.failed {
+		return nil
+	}
+
+	items := make(map[uint32]string)
+	isoBoxes(r.data, func(typ string, infe []byte) {
+		if typ != "infe" {
+			return
+		}
+		r := &isoReader{data: infe}
+		version := r.uint(1)
+		r.uint(3)
+		// Earlier versions have no item type
+		if version < 2 {
+			return
+		}
+		id := r.uint(2)
+		if version > 2 {
+			id = id<<16 | r.uint(2)
+		}
+		r.uint(2)
+		itemType := string(r.bytes(4))
+		if r.failed {
+			return
+		}
+
+		switch itemType {
+		case "Exif":
+			items[uint32(id)] = itemType
+		case "mime":
+			// The name of the item precedes its content type
+			_, rest, _ := bytes.Cut(r.data, []byte{0})
+			contentType, _, _ := bytes.Cut(rest, []byte{0})
+			if string(contentType) == "application/rdf+xml" {
+				items[uint32(id)] = itemType
+			}
+		}
+	})
+	return items
+}
+
+// heifLocations returns the locations of the items of an iloc box stored in a
+// single extent of the file
+func heifLocations(iloc []byte) []heifLocation {
+	r := &isoReader{data: iloc}
+	version := r.uint(1)
+	r.uint(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+	count := r.uint(2)
+	if version == 2 {
+		count = count<<16 | r.uint(2)
+	}
+
+	var locations []heifLocation
+	for i := uint64(0); i < count && !r.failed; i++ {
+		location := heifLocation{}
+		if version < 2 {
+			location.id = uint32(r.uint(2))
+		} else {
+			location.id = uint32(r.uint(4))
+		}
+		if version > 0 {
+			location.method = r.uint(2) & 0x0F
+		}
+		reference := r.uint(2)
+		base := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+		for j := uint64(0); j < extents && !r.failed; j++ {
+			r.uint(indexSize)
+			location.offset = base + r.uint(offsetSize)
+			location.length = r.uint(lengthSize)
+		}
+		// Items in other files or split in several extents are not supported
+		if !r.failed && reference == 0 && extents == 1 && location.method <= 1 {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+// isoBoxes calls fn with the type and content of each box of ISO base media
+// data, as used by HEIF files
+func isoBoxes(data []byte, fn func(typ string, content []byte)) {
+	for i := 0; i+8 <= len(data); {
+		size, header := uint64(binary.BigEndian.Uint32(data[i:])), 8
+		switch size {
+		case 0:
+			// The last box extends to the end of the data
+			size = uint64(len(data) - i)
+		case 1:
+			if i+16 > len(data) {
+				return
+			}
+			size, header = binary.BigEndian.Uint64(data[i+8:]), 16
+		}
+		if size < uint64(header) || size > uint64(len(data)-i) {
+			return
+		}
+		fn(string(data[i+4:i+8]), data[i+header:i+int(size)])
+		i += int(size)
+	}
+}
+
+// isoBox returns the content of the first box of a type, or nil when there is none
+func isoBox(data []byte, typ string) []byte {
+	var content []byte
+	isoBoxes(data, func(t string, c []byte) {
+		if t == typ && content == nil {
+			content = c
+		}
+	})
+	return content
+}
+
+// isoReader reads the big endian fields of a box, and records when they
+// overflow its content
+type isoReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *isoReader) bytes(n int) []byte {
+	if r.failed || n > len(r.data) {
+		r.failed = true
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// uint reads an unsigned integer of n bytes, 0 to 8
+func (r *isoReader) uint(n int) uint64 {
+	var v uint64
+	for _, b := range r.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
@@ -0,0 +1,163 @@
+// Package imaging resizes and converts the images of the media library, to
+// serve variants sized for the screens displaying them.
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fit is how an image is resized to a box of both a width and a height
+type Fit string
+
+const (
+	// FitContain scales the image to fit in the box, keeping its aspect ratio
+	FitContain Fit = "contain"
+	// FitCover scales the image to cover the box and crops it to the box
+	FitCover Fit = "cover"
+	// FitFill stretches the image to the box
+	FitFill Fit = "fill"
+)
+
+// Formats the images can be converted to
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+var mimeTypes = map[string]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatWebP: "image/webp",
+}
+
+// ErrInvalidOptions is returned for variants that are not allowed
+var ErrInvalidOptions = errors.New("invalid image options")
+
+// Options describes a variant of an image. Zero values are resolved by
+// Limits.Parse: no width or height keeps the aspect ratio, and no format
+// keeps the format of the original.
+type Options struct {
+	Width   int
+	Height  int
+	Fit     Fit
+	Quality int
+	Format  string
+}
+
+// Key returns the name of the variant, unique for each image it produces
+func (o Options) Key() string {
+	return fmt.Sprintf("%dx%d-%s-q%d.%s", o.Width, o.Height, o.Fit, o.Quality, o.Format)
+}
+
+// MimeType returns the MIME type of the variant
+func (o Options) MimeType() string {
+	return mimeTypes[o.Format]
+}
+
+// Limits are the variants clients can request, so they cannot make the server
+// generate and store an unbounded number of them
+type Limits struct {
+	Sizes     []int // widths and heights images can be resized to
+	Qualities []int // qualities that can be requested, besides the default
+	Quality   int   // default quality
+}
+
+// Parse returns the options of the w, h, fit, q and fmt query parameters of a
+// request for an image of a MIME type. It returns false when no parameter is
+// set, to serve the original.
+func (l Limits) Parse(query func(key string) string, mimeType string) (Options, bool, error) {
+	w, h, fit, q, format := query("w"), query("h"), query("fit"), query("q"), query("fmt")
+	if w == "" && h == "" && fit == "" && q == "" && format == "" {
+		return Options{}, false, nil
+	}
+
+	opts := Options{Fit: FitContain, Quality: l.Quality, Format: FormatOf(mimeType)}
+	var err error
+	if opts.Width, err = l.size(w); err != nil {
+		return opts, true, err
+	}
+	if opts.Height, err = l.size(h); err != nil {
+		return opts, true, err
+	}
+
+	switch Fit(fit) {
+	case "":
+	case FitContain, FitCover, FitFill:
+		opts.Fit = Fit(fit)
+	default:
+		return opts, true, fmt.Errorf("%w: fit must be contain, cover or fill", ErrInvalidOptions)
+	}
+	// The fit only matters for a box
+	if opts.Width == 0 || opts.Height == 0 {
+		opts.Fit = FitContain
+	}
+
+	if q != "" {
+		quality, err := strconv.Atoi(q)
+		if err != nil || (quality != l.Quality && !slices.Contains(l.Qualities, quality)) {
+			return opts, true, fmt.Errorf("%w: q must be one of %s", ErrInvalidOptions, joinInts(append([]int{l.Quality}, l.Qualities...)))
+		}
+		opts.Quality = quality
+	}
+
+	switch strings.ToLower(format) {
+	case "":
+	case "jpg", FormatJPEG:
+		opts.Format = FormatJPEG
+	case FormatPNG, FormatWebP:
+		opts.Format = strings.ToLower(format)
+	default:
+		return opts, true, fmt.Errorf("%w: fmt must be jpeg, png or webp", ErrInvalidOptions)
+	}
+	if opts.Format == "" {
+		return opts, true, fmt.Errorf("%w: %s images cannot be converted", ErrInvalidOptions, mimeType)
+	}
+
+	return opts, true, nil
+}
+
+// Width returns the options of the variant of an image of a MIME type scaled to
+// a width, as requested by a srcset
+func (l Limits) Width(width int, mimeType string) Options {
+	return Options{Width: width, Fit: FitContain, Quality: l.Quality, Format: FormatOf(mimeType)}
+}
+
+func (l Limits) size(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains(l.Sizes, size) {
+		return 0, fmt.Errorf("%w: w and h must be one of %s", ErrInvalidOptions, joinInts(l.Sizes))
+	}
+	return size, nil
+}
+
+// FormatOf returns the format of the images of a MIME type, or an empty string
+// when they cannot be resized. Animated GIFs and SVGs are served as they are.
+func FormatOf(mimeType string) string {
+	for format, t := range mimeTypes {
+		if t == mimeType {
+			return format
+		}
+	}
+	return ""
+}
+
+// Resizable returns whether the images of a MIME type can be resized
+func Resizable(mimeType string) bool {
+	return FormatOf(mimeType) != ""
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,404 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimits = Limits{Sizes: []int{100, 200, 400}, Qualities: []int{50, 90}, Quality: 80}
+
+func parse(t *testing.T, query, mimeType string) (Options, bool, error) {
+	values, err := url.ParseQuery(query)
+	require.NoError(t, err)
+	return testLimits.Parse(values.Get, mimeType)
+}
+
+func TestParse(t *testing.T) {
+	_, ok, err := parse(t, "", "image/jpeg")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	opts, ok, err := parse(t, "w=200", "image/jpeg")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Options{Width: 200, Fit: FitContain, Quality: 80, Format: FormatJPEG}, opts)
+	assert.Equal(t, testLimits.Width(200, "image/jpeg"), opts)
+	assert.Equal(t, "200x0-contain-q80.jpeg", opts.Key())
+
+	opts, _, err = parse(t, "w=200&h=100&fit=cover&q=50&fmt=webp", "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, Options{Width: 200, Height: 100, Fit: FitCover, Quality: 50, Format: FormatWebP}, opts)
+	assert.Equal(t, "image/webp", opts.MimeType())
+
+	// The fit only applies to boxes
+	opts, _, err = parse(t, "h=100&fit=fill&fmt=jpg", "image/webp")
+	require.NoError(t, err)
+	assert.Equal(t, Options{Height: 100, Fit: FitContain, Quality: 80, Format: FormatJPEG}, opts)
+
+	for _, query := range []string{"w=150", "w=abc", "h=1000", "q=70", "fit=stretch", "fmt=gif"} {
+		_, ok, err := parse(t, query, "image/jpeg")
+		assert.True(t, ok, query)
+		assert.ErrorIs(t, err, ErrInvalidOptions, query)
+	}
+
+	_, _, err = parse(t, "w=100", "image/svg+xml")
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+}
+
+// testImage returns a PNG image whose left half is red and right half blue
+func testImage(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestTransform(t *testing.T) {
+	data := testImage(t, 400, 200)
+
+	for _, tc := range []struct {
+		opts          Options
+		width, height int
+	}{
+		{Options{Width: 200}, 200, 100},
+		{Options{Height: 100}, 200, 100},
+		{Options{Width: 200, Height: 200, Fit: FitContain}, 200, 100},
+		{Options{Width: 200, Height: 200, Fit: FitCover}, 200, 200},
+		{Options{Width: 200, Height: 200, Fit: FitFill}, 200, 200},
+		// Never enlarged
+		{Options{Width: 800}, 400, 200},
+		{Options{Width: 800, Height: 800, Fit: FitCover}, 200, 200},
+	} {
+		for _, format := range []string{FormatJPEG, FormatPNG, FormatWebP} {
+			tc.opts.Format, tc.opts.Quality = format, 80
+			if tc.opts.Fit == "" {
+				tc.opts.Fit = FitContain
+			}
+
+			img, err := Transform(data, tc.opts)
+			require.NoError(t, err, tc.opts.Key())
+			assert.Equal(t, tc.width, img.Width, tc.opts.Key())
+			assert.Equal(t, tc.height, img.Height, tc.opts.Key())
+
+			cfg, decoded, err := image.DecodeConfig(bytes.NewReader(img.Data))
+			require.NoError(t, err, tc.opts.Key())
+			assert.Equal(t, format, decoded)
+			assert.Equal(t, tc.width, cfg.Width, tc.opts.Key())
+			assert.Equal(t, tc.height, cfg.Height, tc.opts.Key())
+		}
+	}
+
+	_, err := Transform([]byte("not an image"), Options{Width: 100, Format: FormatJPEG})
+	assert.Error(t, err)
+}
+
+// withExif inserts an EXIF segment of TIFF data in a JPEG image
+func withExif(data, tiff []byte) []byte {
+	return withSegment(data, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// withSegment inserts an APP1 segment in a JPEG image
+func withSegment(data, segment []byte) []byte {
+	header := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(segment)+2))
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, header...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// ifdEntry returns a big endian IFD field
+func ifdEntry(tag, typ uint16, count, value uint32) []byte {
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry, tag)
+	binary.BigEndian.PutUint16(entry[2:], typ)
+	binary.BigEndian.PutUint32(entry[4:], count)
+	if typ == 3 && count == 1 {
+		binary.BigEndian.PutUint16(entry[8:], uint16(value))
+	} else {
+		binary.BigEndian.PutUint32(entry[8:], value)
+	}
+	return entry
+}
+
+// withOrientation inserts an EXIF segment with an orientation in a JPEG image
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = append(append(tiff, ifdEntry(exifOrientationTag, 3, 1, uint32(orientation))...), 0, 0, 0, 0)
+	return withExif(data, tiff)
+}
+
+// gpsLatitude is the latitude of the photos of the tests, 48°51'24"
+var gpsLatitude = []byte{0, 0, 0, 48, 0, 0, 0, 1, 0, 0, 0, 51, 0, 0, 0, 1, 0, 0, 0, 24, 0, 0, 0, 1}
+
+// withPhotoExif inserts the EXIF segment of a photo in a JPEG image, with
+// its capture date and GPS position
+func withPhotoExif(data []byte) []byte {
+	return withExif(data, photoTIFF())
+}
+
+// photoTIFF returns the EXIF TIFF data of a photo, with its capture date and
+// GPS position
+func photoTIFF() []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+
+	// IFD0 at 8, pointing to the EXIF IFD at 50 and the GPS IFD at 108
+	tiff = append(tiff, 0, 3)
+	tiff = append(tiff, ifdEntry(exifOrientationTag, 3, 1, 1)...)
+	tiff = append(tiff, ifdEntry(exifIFDTag, 4, 1, 50)...)
+	tiff = append(tiff, ifdEntry(exifGPSTag, 4, 1, 108)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	// EXIF IFD at 50, with its values at 80 and 100
+	tiff = append(tiff, 0, 2)
+	tiff = append(tiff, ifdEntry(exifDateTimeOriginalTag, 2, 20, 80)...)
+	tiff = append(tiff, ifdEntry(exifOffsetTimeOrigTag, 2, 7, 100)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "2024:05:01 10:20:30\x00"...)
+	tiff = append(tiff, "+02:00\x00\x00"...)
+
+	// GPS IFD at 108, with the latitude at 138
+	tiff = append(tiff, 0, 2)
+	tiff = append(tiff, ifdEntry(1, 2, 2, 0x4E000000)...)
+	tiff = append(tiff, ifdEntry(2, 5, 3, 138)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, gpsLatitude...)
+
+	return tiff
+}
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	img, _, err := image.Decode(bytes.NewReader(testImage(t, width, height)))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	return buf.Bytes()
+}
+
+func TestReadMetadata(t *testing.T) {
+	photo := withPhotoExif(testJPEG(t, 40, 20))
+
+	metadata, err := ReadMetadata(photo)
+	require.NoError(t, err)
+	assert.Equal(t, 40, metadata.Width)
+	assert.Equal(t, 20, metadata.Height)
+	require.NotNil(t, metadata.TakenAt)
+	assert.Equal(t, time.Date(2024, 5, 1, 8, 20, 30, 0, time.UTC), metadata.TakenAt.UTC())
+
+	metadata, err = ReadMetadata(testImage(t, 40, 20))
+	require.NoError(t, err)
+	assert.Nil(t, metadata.TakenAt)
+
+	_, err = ReadMetadata([]byte("not an image"))
+	assert.Error(t, err)
+}
+
+func TestStripGPS(t *testing.T) {
+	photo := withPhotoExif(testJPEG(t, 40, 20))
+	size := len(photo)
+	require.True(t, bytes.Contains(photo, gpsLatitude))
+
+	assert.True(t, StripGPS(photo))
+	assert.Len(t, photo, size)
+	assert.False(t, bytes.Contains(photo, gpsLatitude))
+	assert.False(t, StripGPS(photo))
+
+	// The rest of the metadata and the image are left untouched
+	metadata, err := ReadMetadata(photo)
+	require.NoError(t, err)
+	assert.NotNil(t, metadata.TakenAt)
+	_, err = jpeg.Decode(bytes.NewReader(photo))
+	assert.NoError(t, err)
+
+	assert.False(t, StripGPS(testJPEG(t, 40, 20)))
+	assert.False(t, StripGPS(testImage(t, 40, 20)))
+}
+
+// photoXMP is the XMP packet of a photo, with its GPS position written both
+// as attributes and as elements
+const photoXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLatitude="48,51.4N" exif:DateTimeOriginal="2024-05-01T10:20:30">` +
+	`<exif:GPSLongitude>2,21.1E</exif:GPSLongitude><exif:GPSAltitude rdf:resource="35/1"/></rdf:Description></rdf:RDF></x:xmpmeta>`
+
+// assertStripped checks that the GPS position of the EXIF and XMP metadata of
+// a photo were erased, and the rest kept
+func assertStripped(t *testing.T, data []byte) {
+	size := len(data)
+	require.True(t, bytes.Contains(data, gpsLatitude))
+	require.Contains(t, string(data), "48,51.4N")
+
+	assert.True(t, StripGPS(data))
+	assert.Len(t, data, size)
+	assert.False(t, bytes.Contains(data, gpsLatitude))
+	for _, position := range []string{"48,51.4N", "2,21.1E", "35/1"} {
+		assert.NotContains(t, string(data), position)
+	}
+	assert.Contains(t, string(data), "2024:05:01 10:20:30")
+	assert.Contains(t, string(data), `exif:DateTimeOriginal="2024-05-01T10:20:30"`)
+	assert.False(t, StripGPS(data))
+
+	// The XMP packet is still well-formed
+	start := bytes.Index(data, []byte("<x:xmpmeta"))
+	end := bytes.Index(data, []byte("</x:xmpmeta>")) + len("</x:xmpmeta>")
+	decoder := xml.NewDecoder(bytes.NewReader(data[start:end]))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestStripGPS_JPEGXMP(t *testing.T) {
+	photo := withSegment(withPhotoExif(testJPEG(t, 40, 20)), []byte(xmpNamespace+photoXMP))
+	assert.False(t, StripNeedsWholeFile(photo))
+	assertStripped(t, photo)
+
+	_, err := jpeg.Decode(bytes.NewReader(photo))
+	assert.NoError(t, err)
+}
+
+// pngChunk returns a PNG chunk with its checksum
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, typ...), data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestStripGPS_PNG(t *testing.T) {
+	plain := testImage(t, 40, 20)
+	// The metadata follow the IHDR chunk
+	end := len(pngSignature) + 25
+	photo := append([]byte{}, plain[:end]...)
+	photo = append(photo, pngChunk("eXIf", photoTIFF())...)
+	photo = append(photo, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+photoXMP))...)
+	photo = append(photo, plain[end:]...)
+
+	assert.True(t, StripNeedsWholeFile(photo))
+	assertStripped(t, photo)
+
+	// The checksums of the chunks were updated
+	_, err := png.Decode(bytes.NewReader(photo))
+	assert.NoError(t, err)
+}
+
+// webpChunk returns a RIFF chunk of a WebP image, padded to an even size
+func webpChunk(typ string, data []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(typ), uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestStripGPS_WebP(t *testing.T) {
+	plain, err := Transform(testImage(t, 40, 20), Options{Fit: FitContain, Quality: 80, Format: FormatWebP})
+	require.NoError(t, err)
+
+	// The metadata follow the image data
+	photo := append([]byte{}, plain.Data...)
+	photo = append(photo, webpChunk("EXIF", photoTIFF())...)
+	photo = append(photo, webpChunk("XMP ", []byte(photoXMP))...)
+	binary.LittleEndian.PutUint32(photo[4:], uint32(len(photo)-8))
+
+	assert.True(t, StripNeedsWholeFile(photo))
+	assertStripped(t, photo)
+}
+
+// isoBoxOf returns a box of ISO base media data
+func isoBoxOf(typ string, content ...[]byte) []byte {
+	data := bytes.Join(content, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(data)+8)), append([]byte(typ), data...)...)
+}
+
+// heifPhoto returns a HEIC photo with EXIF and XMP items stored at the end of
+// the file, without image
+func heifPhoto() []byte {
+	exif := append([]byte("\x00\x00\x00\x06Exif\x00\x00"), photoTIFF()...)
+	xmp := []byte(photoXMP)
+
+	ftyp := isoBoxOf("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	iinf := isoBoxOf("iinf", []byte{0, 0, 0, 0, 0, 2},
+		isoBoxOf("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif\x00")),
+		isoBoxOf("infe", []byte{2, 0, 0, 0, 0, 2, 0, 0}, []byte("mimeXMP\x00application/rdf+xml\x00")))
+
+	// Version 0, 4 bytes offsets and lengths, no base offset
+	iloc := func(exifOffset, xmpOffset uint32) []byte {
+		content := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 2}
+		for _, item := range []struct {
+			id             uint16
+			offset, length uint32
+		}{{1, exifOffset, uint32(len(exif))}, {2, xmpOffset, uint32(len(xmp))}} {
+			content = binary.BigEndian.AppendUint16(content, item.id)
+			content = append(content, 0, 0, 0, 1)
+			content = binary.BigEndian.AppendUint32(content, item.offset)
+			content = binary.BigEndian.AppendUint32(content, item.length)
+		}
+		return isoBoxOf("iloc", content)
+	}
+
+	meta := isoBoxOf("meta", []byte{0, 0, 0, 0}, iinf, iloc(0, 0))
+	start := uint32(len(ftyp) + len(meta) + 8)
+	meta = isoBoxOf("meta", []byte{0, 0, 0, 0}, iinf, iloc(start, start+uint32(len(exif))))
+	return bytes.Join([][]byte{ftyp, meta, isoBoxOf("mdat", exif, xmp)}, nil)
+}
+
+func TestStripGPS_HEIF(t *testing.T) {
+	photo := heifPhoto()
+	assert.True(t, StripNeedsWholeFile(photo))
+	assertStripped(t, photo)
+
+	// Truncated files are left untouched
+	photo = heifPhoto()
+	assert.False(t, StripGPS(photo[:len(photo)-len(photoXMP)-10]))
+}
+
+func TestOrientation(t *testing.T) {
+	data := testJPEG(t, 40, 20)
+
+	assert.Equal(t, 0, jpegOrientation(data))
+	rotated := withOrientation(data, 6)
+	assert.Equal(t, 6, jpegOrientation(rotated))
+
+	width, height, err := Dimensions(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, 20, width)
+	assert.Equal(t, 40, height)
+
+	// Turned clockwise, the red half is on top
+	out, err := Transform(rotated, Options{Fit: FitContain, Quality: 95, Format: FormatPNG})
+	require.NoError(t, err)
+	assert.Equal(t, 20, out.Width)
+	assert.Equal(t, 40, out.Height)
+	decoded, err := png.Decode(bytes.NewReader(out.Data))
+	require.NoError(t, err)
+	r, _, b, _ := decoded.At(10, 5).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = decoded.At(10, 35).RGBA()
+	assert.Greater(t, b, r)
+}
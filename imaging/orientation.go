@@ -0,0 +1,53 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// orient turns an image upright from its EXIF orientation
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok {
+		src = image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counterclockwise
+				dx, dy = y, w-1-x
+			}
+			s := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+			d := dst.PixOffset(dx, dy)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
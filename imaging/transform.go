@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/gen2brain/webp"
+	"github.com/nfnt/resize"
+)
+
+// MaxPixels is the size of the largest image that is decoded, which bounds the
+// memory used to resize an image
+const MaxPixels = 50_000_000
+
+// ErrTooLarge is returned for images larger than MaxPixels
+var ErrTooLarge = errors.New("image too large to resize")
+
+// Image is an encoded variant of an image
+type Image struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Transform resizes and converts an encoded image as described by opts. The
+// image is turned upright as its EXIF orientation says, and never enlarged.
+// The metadata of the original, like the GPS position, are not carried over.
+func Transform(data []byte, opts Options) (*Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img = resizeImage(orient(img, jpegOrientation(data)), opts)
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: opts.Quality})
+	case FormatPNG:
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	case FormatWebP:
+		err = webp.Encode(&buf, img, webp.Options{Quality: opts.Quality, Method: webp.DefaultMethod})
+	default:
+		err = fmt.Errorf("%w: unknown format %q", ErrInvalidOptions, opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &Image{Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// Dimensions returns the size of an image as it is displayed, from the start of
+// its file. The header of most images fits in their first 64KB.
+func Dimensions(header []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return 0, 0, err
+	}
+	// Orientations 5 to 8 swap the width and height
+	if jpegOrientation(header) >= 5 {
+		return cfg.Height, cfg.Width, nil
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// resizeImage resizes an image to the box of opts, keeping the aspect ratio of
+// the box when the image is smaller than it
+func resizeImage(img image.Image, opts Options) image.Image {
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	tw, th := float64(opts.Width), float64(opts.Height)
+
+	switch {
+	case tw == 0 && th == 0:
+		return img
+	case th == 0:
+		th = h * tw / w
+	case tw == 0:
+		tw = w * th / h
+	case opts.Fit == FitContain:
+		if tw/w < th/h {
+			th = h * tw / w
+		} else {
+			tw = w * th / h
+		}
+	}
+
+	// The scaled size of the image, larger than the box when it is cropped
+	sw, sh := tw, th
+	if opts.Fit == FitCover && opts.Width > 0 && opts.Height > 0 {
+		scale := math.Max(tw/w, th/h)
+		sw, sh = w*scale, h*scale
+	}
+
+	// Never enlarge, but keep the aspect ratio of the box
+	if shrink := math.Max(sw/w, sh/h); shrink > 1 {
+		tw, th, sw, sh = tw/shrink, th/shrink, sw/shrink, sh/shrink
+	}
+
+	width, height := max(int(math.Round(tw)), 1), max(int(math.Round(th)), 1)
+	scaledWidth, scaledHeight := max(int(math.Round(sw)), 1), max(int(math.Round(sh)), 1)
+	resized := img
+	if scaledWidth != bounds.Dx() || scaledHeight != bounds.Dy() {
+		resized = resize.Resize(uint(scaledWidth), uint(scaledHeight), img, resize.Lanczos3)
+	}
+	if width >= scaledWidth && height >= scaledHeight {
+		return resized
+	}
+	return crop(resized, width, height)
+}
+
+// crop returns the center of an image
+func crop(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	x := bounds.Min.X + (bounds.Dx()-width)/2
+	y := bounds.Min.Y + (bounds.Dy()-height)/2
+	rect := image.Rect(x, y, x+width, y+height)
+
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// flatten draws an image with transparency on white, as JPEG has no alpha channel
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,209 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ghostURL is the placeholder Ghost exports use for the URL of the site
+const ghostURL = "__GHOST_URL__"
+
+// ghostExport is a Ghost JSON export. Older versions wrap it in a db array.
+type ghostExport struct {
+	DB   []ghostExport `json:"db"`
+	Data *ghostData    `json:"data"`
+}
+
+type ghostData struct {
+	Posts []struct {
+		ID            string  `json:"id"`
+		Title         string  `json:"title"`
+		Slug          string  `json:"slug"`
+		HTML          *string `json:"html"`
+		FeatureImage  *string `json:"feature_image"`
+		CustomExcerpt *string `json:"custom_excerpt"`
+		Type          string  `json:"type"`
+		Status        string  `json:"status"`
+		AuthorID      string  `json:"author_id"`
+		CreatedAt     *string `json:"created_at"`
+		UpdatedAt     *string `json:"updated_at"`
+		PublishedAt   *string `json:"published_at"`
+	} `json:"posts"`
+	Tags []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"tags"`
+	PostsTags []struct {
+		PostID    string `json:"post_id"`
+		TagID     string `json:"tag_id"`
+		SortOrder int    `json:"sort_order"`
+	} `json:"posts_tags"`
+	Users []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"users"`
+	PostsAuthors []struct {
+		PostID    string `json:"post_id"`
+		AuthorID  string `json:"author_id"`
+		SortOrder int    `json:"sort_order"`
+	} `json:"posts_authors"`
+	Settings []struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	} `json:"settings"`
+}
+
+// ParseGhost parses a Ghost JSON export, as made by Settings > Labs > Export in
+// the Ghost admin. siteURL is the URL of the Ghost site, to download the media
+// files from. The publish dates are kept in the timezone of the site.
+func ParseGhost(r io.Reader, siteURL string) (*Blog, error) {
+	var export ghostExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid Ghost export: %w", err)
+	}
+	for export.Data == nil && len(export.DB) > 0 {
+		export = export.DB[0]
+	}
+	if export.Data == nil {
+		return nil, fmt.Errorf("invalid Ghost export: no data")
+	}
+	data := export.Data
+
+	siteURL = strings.TrimSuffix(siteURL, "/")
+	blog := &Blog{
+		uploadPath: func(url string) (string, bool) {
+			rel, ok := uploadPathAfter(url, "/content/")
+			if !ok || !(strings.HasPrefix(rel, "images/") || strings.HasPrefix(rel, "media/") || strings.HasPrefix(rel, "files/")) {
+				return "", false
+			}
+			return rel, true
+		},
+		mediaURL: func(url string) string {
+			if strings.HasPrefix(url, "/") {
+				return siteURL + url
+			}
+			return url
+		},
+	}
+
+	timezone := "UTC"
+	for _, setting := range data.Settings {
+		var value string
+		if setting.Key == "timezone" && json.Unmarshal(setting.Value, &value) == nil && value != "" {
+			timezone = value
+		}
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		timezone, location = "UTC", time.UTC
+	}
+
+	for _, user := range data.Users {
+		firstName, lastName, _ := strings.Cut(strings.TrimSpace(user.Name), " ")
+		blog.Authors = append(blog.Authors, Author{
+			Key:       user.ID,
+			Email:     strings.TrimSpace(user.Email),
+			FirstName: firstName,
+			LastName:  lastName,
+		})
+	}
+
+	tagNames := make(map[string]string)
+	for _, tag := range data.Tags {
+		// Internal tags, named with a leading #, are not shown to readers
+		if !strings.HasPrefix(tag.Name, "#") {
+			tagNames[tag.ID] = tag.Name
+		}
+	}
+	sort.SliceStable(data.PostsTags, func(i, j int) bool {
+		return data.PostsTags[i].SortOrder < data.PostsTags[j].SortOrder
+	})
+	postTags := make(map[string][]string)
+	for _, pt := range data.PostsTags {
+		if name, ok := tagNames[pt.TagID]; ok {
+			postTags[pt.PostID] = append(postTags[pt.PostID], name)
+		}
+	}
+
+	// The primary author has the lowest sort order
+	postAuthors := make(map[string]string)
+	primary := make(map[string]int)
+	for _, pa := range data.PostsAuthors {
+		if order, ok := primary[pa.PostID]; !ok || pa.SortOrder < order {
+			postAuthors[pa.PostID] = pa.AuthorID
+			primary[pa.PostID] = pa.SortOrder
+		}
+	}
+
+	for _, p := range data.Posts {
+		var visible bool
+		switch p.Status {
+		case "published", "scheduled":
+			visible = true
+		case "draft":
+			visible = false
+		default:
+			// Sent newsletters
+			continue
+		}
+
+		content := stringValue(p.HTML)
+		if image := stringValue(p.FeatureImage); image != "" {
+			content = fmt.Sprintf(`<figure><img src="%s" alt=""></figure>`, strings.ReplaceAll(image, `"`, "&quot;")) + content
+		}
+		// Without the URL of the site, its links are left relative
+		content = strings.ReplaceAll(content, ghostURL, siteURL)
+
+		author := postAuthors[p.ID]
+		if author == "" {
+			author = p.AuthorID
+		}
+
+		post := Post{
+			Title:     p.Title,
+			Slug:      p.Slug,
+			HTML:      content,
+			Excerpt:   stringValue(p.CustomExcerpt),
+			Visible:   visible,
+			Timezone:  timezone,
+			Author:    author,
+			CreatedAt: ghostDate(p.CreatedAt, time.Now()).UTC(),
+			UpdatedAt: ghostDate(p.UpdatedAt, time.Now()).UTC(),
+			Tags:      postTags[p.ID],
+		}
+		post.PublishedAt = ghostDate(p.PublishedAt, post.CreatedAt).In(location)
+
+		if p.Type == "page" {
+			blog.Pages = append(blog.Pages, post)
+		} else {
+			blog.Posts = append(blog.Posts, post)
+		}
+	}
+
+	return blog, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// ghostDate parses a date of a Ghost export, or returns fallback when it has none
+func ghostDate(value *string, fallback time.Time) time.Time {
+	if value == nil {
+		return fallback
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, *value); err == nil {
+			return t
+		}
+	}
+	return fallback
+}
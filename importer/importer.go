@@ -0,0 +1,560 @@
+// Package importer imports blogs exported from other platforms, WordPress and
+// Ghost, into Captain. The exports are parsed into a Blog, whose authors, posts,
+// pages and referenced media files are then written to the site.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/archive"
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+	"github.com/captain-corp/captain/utils"
+
+	"golang.org/x/net/html"
+	"gorm.io/gorm"
+)
+
+// Blog is a blog parsed from an export
+type Blog struct {
+	Authors []Author
+	Posts   []Post
+	Pages   []Post
+
+	// uploadPath returns the path of a media URL relative to the uploads
+	// directory of the blog, or false for URLs not hosted by the blog
+	uploadPath func(url string) (string, bool)
+	// mediaURL returns the absolute URL to download an uploaded file from
+	mediaURL func(url string) string
+	// preserveNewlines is set for the WordPress content, whose paragraphs are separated by blank lines
+	preserveNewlines bool
+}
+
+// Author is an author of the blog, identified by Key in the posts
+type Author struct {
+	Key       string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// Post is a post or page of the blog, with its HTML content
+type Post struct {
+	Title       string
+	Slug        string
+	HTML        string
+	Excerpt     string
+	Visible     bool
+	PublishedAt time.Time // in the timezone of the blog
+	Timezone    string    // IANA name of the timezone of PublishedAt
+	Tags        []string
+	Author      string // Author.Key
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Options controls how a blog is imported
+type Options struct {
+	Strategy archive.Strategy
+	// UploadsDir is a local copy of the uploads directory of the blog, read
+	// instead of downloading the media files
+	UploadsDir string
+	// KeepHTML stores the content as HTML rather than converting it to Markdown
+	KeepHTML bool
+	// DefaultAuthor is the author of the posts whose author has no email.
+	// Defaults to the first admin.
+	DefaultAuthor *models.User
+	// HTTPClient downloads the media files, defaults to a client with a timeout
+	HTTPClient *http.Client
+}
+
+// Report summarizes what an import changed
+type Report struct {
+	Posts archive.ImportCounts
+	Pages archive.ImportCounts
+	Users archive.ImportCounts
+	Media int // media files imported
+
+	KeptHTML             []string // posts and pages stored as HTML, which could not be converted to Markdown
+	FailedMedia          []string // media URLs that could not be imported, left unchanged
+	UsersWithoutPassword []string
+}
+
+func (r *Report) String() string {
+	lines := []string{
+		"Posts: " + r.Posts.String(),
+		"Pages: " + r.Pages.String(),
+		"Users: " + r.Users.String(),
+		fmt.Sprintf("Media: %d imported", r.Media),
+	}
+	if len(r.KeptHTML) > 0 {
+		lines = append(lines, "Kept as HTML: "+strings.Join(r.KeptHTML, ", "))
+	}
+	if len(r.FailedMedia) > 0 {
+		lines = append(lines, "Media not imported: "+strings.Join(r.FailedMedia, ", "))
+	}
+	if len(r.UsersWithoutPassword) > 0 {
+		lines = append(lines, "Users without password: "+strings.Join(r.UsersWithoutPassword, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// importer holds the state of an import
+type importer struct {
+	blog   *Blog
+	repos  *repository.Repositories
+	store  storage.Provider
+	opts   Options
+	report *Report
+
+	authorIDs map[string]uint   // Author.Key to user ID
+	media     map[string]string // media URL to imported media path
+}
+
+// Import writes the blog to the site. Posts and pages whose slug already exists
+// are resolved with the strategy of the options, so importing a blog again
+// with the skip strategy only adds what is missing.
+func Import(blog *Blog, repos *repository.Repositories, store storage.Provider, opts Options) (*Report, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = archive.StrategySkip
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	imp := &importer{
+		blog:      blog,
+		repos:     repos,
+		store:     store,
+		opts:      opts,
+		report:    &Report{},
+		authorIDs: make(map[string]uint),
+		media:     make(map[string]string),
+	}
+
+	if err := imp.importAuthors(); err != nil {
+		return imp.report, err
+	}
+	for _, post := range blog.Posts {
+		if err := imp.importPost(post); err != nil {
+			return imp.report, err
+		}
+	}
+	for _, page := range blog.Pages {
+		if err := imp.importPage(page); err != nil {
+			return imp.report, err
+		}
+	}
+
+	return imp.report, nil
+}
+
+func (imp *importer) importAuthors() error {
+	for _, author := range imp.blog.Authors {
+		if author.Email == "" {
+			continue
+		}
+
+		user, err := imp.repos.Users.FindByEmail(author.Email)
+		if err == nil {
+			imp.authorIDs[author.Key] = user.ID
+			imp.report.Users.Skipped++
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		user = &models.User{
+			Email:     author.Email,
+			FirstName: author.FirstName,
+			LastName:  author.LastName,
+			Role:      models.RoleAuthor,
+		}
+		if err := imp.repos.Users.Create(user); err != nil {
+			return fmt.Errorf("failed to import author %s: %w", author.Email, err)
+		}
+		imp.authorIDs[author.Key] = user.ID
+		imp.report.Users.Created++
+		imp.report.UsersWithoutPassword = append(imp.report.UsersWithoutPassword, author.Email)
+	}
+
+	return nil
+}
+
+// authorID returns the user ID of the author of a post
+func (imp *importer) authorID(key string) (uint, error) {
+	if id, ok := imp.authorIDs[key]; ok {
+		return id, nil
+	}
+
+	if imp.opts.DefaultAuthor == nil {
+		users, err := imp.repos.Users.FindAll()
+		if err != nil {
+			return 0, err
+		}
+		for _, user := range users {
+			if user.Role == models.RoleAdmin {
+				imp.opts.DefaultAuthor = user
+				break
+			}
+		}
+		if imp.opts.DefaultAuthor == nil {
+			return 0, errors.New("no admin to attribute the posts of unknown authors to, create a user first")
+		}
+	}
+
+	return imp.opts.DefaultAuthor.ID, nil
+}
+
+// resolveSlug applies the conflict strategy to the slug of an imported post or
+// page. It returns the slug to import it under and the existing record to
+// overwrite, or an empty slug to skip it.
+func (imp *importer) resolveSlug(slug string, find func(string) error) (string, bool, error) {
+	err := find(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return slug, false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	switch imp.opts.Strategy {
+	case archive.StrategyOverwrite:
+		return slug, true, nil
+	case archive.StrategyRename:
+		for i := 2; ; i++ {
+			candidate := fmt.Sprintf("%s-%d", slug, i)
+			if err := find(candidate); errors.Is(err, gorm.ErrRecordNotFound) {
+				return candidate, false, nil
+			} else if err != nil {
+				return "", false, err
+			}
+		}
+	default:
+		return "", false, nil
+	}
+}
+
+func (imp *importer) importPost(p Post) error {
+	slug := postSlug(p)
+
+	var existing *models.Post
+	newSlug, overwrite, err := imp.resolveSlug(slug, func(s string) error {
+		post, err := imp.repos.Posts.FindBySlug(s)
+		existing = post
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if newSlug == "" {
+		imp.report.Posts.Skipped++
+		return nil
+	}
+
+	content, isHTML, err := imp.convert(p.HTML)
+	if err != nil {
+		return err
+	}
+	if isHTML {
+		imp.report.KeptHTML = append(imp.report.KeptHTML, slug)
+	}
+
+	authorID, err := imp.authorID(p.Author)
+	if err != nil {
+		return err
+	}
+
+	post := &models.Post{}
+	if overwrite {
+		post = existing
+		post.Author = nil
+		post.Tags = nil
+	}
+
+	post.Title = p.Title
+	post.Slug = newSlug
+	post.Content = content
+	post.Visible = p.Visible
+	post.AuthorID = authorID
+	post.PublishedAt = p.PublishedAt
+	post.PublishedAtUTC = p.PublishedAt.UTC()
+	post.PublishedAtTimezone = p.Timezone
+	_, offset := p.PublishedAt.Zone()
+	post.PublishedAtTimeZoneOffset = offset / 60
+	post.CreatedAt = p.CreatedAt
+	post.UpdatedAt = p.UpdatedAt
+	post.Excerpt = nil
+	if excerpt := strings.TrimSpace(p.Excerpt); excerpt != "" {
+		post.Excerpt = &excerpt
+	}
+
+	if overwrite {
+		err = imp.repos.Posts.Update(post)
+		imp.report.Posts.Updated++
+	} else {
+		err = imp.repos.Posts.Create(post)
+		if newSlug != slug {
+			imp.report.Posts.Renamed++
+		} else {
+			imp.report.Posts.Created++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import post %s: %w", slug, err)
+	}
+
+	if err := imp.repos.Posts.AssociateTags(post, p.Tags); err != nil {
+		return fmt.Errorf("failed to tag post %s: %w", slug, err)
+	}
+
+	return nil
+}
+
+func (imp *importer) importPage(p Post) error {
+	slug := postSlug(p)
+
+	var existing *models.Page
+	newSlug, overwrite, err := imp.resolveSlug(slug, func(s string) error {
+		page, err := imp.repos.Pages.FindBySlug(s)
+		existing = page
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if newSlug == "" {
+		imp.report.Pages.Skipped++
+		return nil
+	}
+
+	content, isHTML, err := imp.convert(p.HTML)
+	if err != nil {
+		return err
+	}
+
+	page := &models.Page{}
+	if overwrite {
+		page = existing
+	}
+
+	page.Title = p.Title
+	page.Slug = newSlug
+	page.Content = content
+	page.ContentType = "markdown"
+	if isHTML {
+		page.ContentType = "html"
+	}
+	page.Visible = p.Visible
+	page.CreatedAt = p.CreatedAt
+	page.UpdatedAt = p.UpdatedAt
+
+	if overwrite {
+		err = imp.repos.Pages.Update(page)
+		imp.report.Pages.Updated++
+	} else {
+		err = imp.repos.Pages.Create(page)
+		if newSlug != slug {
+			imp.report.Pages.Renamed++
+		} else {
+			imp.report.Pages.Created++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import page %s: %w", slug, err)
+	}
+
+	return nil
+}
+
+// postSlug returns the slug of a post, made from its title when the export has none
+func postSlug(p Post) string {
+	if slug := utils.Slugify(p.Slug); slug != "" {
+		return slug
+	}
+	return utils.Slugify(p.Title)
+}
+
+// convert imports the media files referenced by the HTML content, and returns
+// the content as Markdown, or as HTML when it cannot be converted
+func (imp *importer) convert(content string) (string, bool, error) {
+	nodes, err := parseHTML(content)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse content: %w", err)
+	}
+
+	imp.rewriteMedia(nodes)
+
+	if !imp.opts.KeepHTML {
+		if markdown, ok := toMarkdown(nodes, imp.blog.preserveNewlines); ok {
+			return markdown, false, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		if err := html.Render(&buf, node); err != nil {
+			return "", false, fmt.Errorf("failed to render content: %w", err)
+		}
+	}
+
+	return buf.String(), true, nil
+}
+
+// rewriteMedia imports the media files linked from the nodes, and points the
+// links to the imported files
+func (imp *importer) rewriteMedia(nodes []*html.Node) {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				if (attr.Key == "src" && n.Data == "img") || (attr.Key == "href" && n.Data == "a") {
+					if mediaPath, ok := imp.importMedia(attr.Val); ok {
+						n.Attr[i].Val = "/media/" + mediaPath
+					}
+				}
+			}
+			if n.Data == "img" {
+				// The resized variants of the srcset are not imported
+				n.Attr = removeAttrs(n.Attr, "srcset", "sizes")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	for _, node := range nodes {
+		walk(node)
+	}
+}
+
+func removeAttrs(attrs []html.Attribute, keys ...string) []html.Attribute {
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		remove := false
+		for _, key := range keys {
+			if attr.Key == key {
+				remove = true
+			}
+		}
+		if !remove {
+			kept = append(kept, attr)
+		}
+	}
+	return kept
+}
+
+// importMedia imports the media file at url when it is hosted by the blog,
+// and returns its path in the storage
+func (imp *importer) importMedia(url string) (string, bool) {
+	rel, ok := imp.blog.uploadPath(url)
+	if !ok {
+		return "", false
+	}
+	if mediaPath, ok := imp.media[url]; ok {
+		return mediaPath, true
+	}
+
+	mediaPath, err := imp.saveMedia(url, rel)
+	if err != nil {
+		imp.report.FailedMedia = append(imp.report.FailedMedia, url)
+		imp.media[url] = ""
+		return "", false
+	}
+
+	imp.media[url] = mediaPath
+	return mediaPath, mediaPath != ""
+}
+
+func (imp *importer) saveMedia(url, rel string) (string, error) {
+	reader, err := imp.openMedia(url, rel)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// Files imported before, or uploaded with the same content, are reused.
+	// Downloads are spooled to disk rather than held in memory.
+	stored, err := mediastore.Save(imp.repos, imp.store, rel, reader)
+	if err != nil {
+		return "", err
+	}
+	if stored.Shared {
+		return stored.Path, nil
+	}
+
+	media := &models.Media{
+		Name: path.Base(rel),
+		Path: stored.Path,
+		Size: stored.Size,
+		Hash: stored.Hash,
+	}
+	if err := mediastore.Create(imp.repos, imp.store, media, stored); err != nil {
+		return "", err
+	}
+	imp.report.Media++
+
+	return stored.Path, nil
+}
+
+// openMedia opens a media file of the uploads directory, or downloads it
+func (imp *importer) openMedia(url, rel string) (io.ReadCloser, error) {
+	if imp.opts.UploadsDir != "" {
+		return os.Open(filepath.Join(imp.opts.UploadsDir, filepath.FromSlash(rel)))
+	}
+
+	url = imp.blog.mediaURL(url)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("cannot download %s without the URL of the blog", url)
+	}
+
+	resp, err := imp.opts.HTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// uploadPathAfter returns the path following marker in url, without query
+// string, when it is a valid relative path
+func uploadPathAfter(url, marker string) (string, bool) {
+	i := strings.Index(url, marker)
+	if i < 0 {
+		return "", false
+	}
+
+	rel := url[i+len(marker):]
+	if j := strings.IndexAny(rel, "?#"); j >= 0 {
+		rel = rel[:j]
+	}
+	if rel == "" || path.Clean(rel) != rel || strings.HasPrefix(rel, "../") || rel == ".." {
+		return "", false
+	}
+
+	return rel, true
+}
+
+// timezoneName returns a timezone name for a fixed UTC offset, in minutes
+func timezoneName(offset int) string {
+	if offset == 0 || offset%60 != 0 || offset < -12*60 || offset > 14*60 {
+		return "UTC"
+	}
+	// The sign of the Etc zones is inverted: Etc/GMT-2 is UTC+2
+	return fmt.Sprintf("Etc/GMT%+d", -offset/60)
+}
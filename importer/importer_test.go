@@ -0,0 +1,284 @@
+package importer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/archive"
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name             string
+		html             string
+		preserveNewlines bool
+		markdown         string
+	}{
+		{"paragraphs", "<p>Hello <strong>world</strong></p><p>Second <em>one</em></p>", false, "Hello **world**\n\nSecond *one*"},
+		{"headings", "<h2>Title</h2><p>Text</p>", false, "## Title\n\nText"},
+		{"links and images", `<p><a href="https://example.com/a b">link</a> <img src="/x.png" alt="X"></p>`, false, "[link](https://example.com/a%20b) ![X](/x.png)"},
+		{"lists", "<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>", false, "- one\n- two\n\n  1. nested"},
+		{"blockquote", "<blockquote><p>quoted</p></blockquote>", false, "> quoted"},
+		{"code", `<pre><code class="language-go">func main() {}</code></pre><p>Run <code>go</code></p>`, false, "```go\nfunc main() {}\n```\n\nRun `go`"},
+		{"escaping", "<p>a_b *c* [d]</p>", false, `a\_b \*c\* \[d\]`},
+		{"figure", `<figure><img src="/a.jpg" alt=""><figcaption>Caption</figcaption></figure>`, false, "![](/a.jpg)\n\n*Caption*"},
+		{"wordpress newlines", "<!-- wp:paragraph -->First line\nsecond line\n\nNext paragraph", true, "First line\\\nsecond line\n\nNext paragraph"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parseHTML(tt.html)
+			require.NoError(t, err)
+			markdown, ok := toMarkdown(nodes, tt.preserveNewlines)
+			assert.True(t, ok)
+			assert.Equal(t, tt.markdown, markdown)
+		})
+	}
+
+	for _, unsupported := range []string{
+		"<table><tr><td>cell</td></tr></table>",
+		`<p><iframe src="https://www.youtube.com/embed/x"></iframe></p>`,
+		"<p>H<sub>2</sub>O</p>",
+	} {
+		nodes, err := parseHTML(unsupported)
+		require.NoError(t, err)
+		_, ok := toMarkdown(nodes, false)
+		assert.False(t, ok, unsupported)
+	}
+}
+
+func newTestSite(t *testing.T) (*repository.Repositories, storage.Provider) {
+	store, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	repos := repository.NewRepositories(db.SetupTestDB())
+	require.NoError(t, repos.Users.Create(&models.User{Email: "admin@example.com", Role: models.RoleAdmin}))
+	return repos, store
+}
+
+// mediaServer serves a fake image for any path
+func mediaServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "missing") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("image " + r.URL.Path))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const wordpressExport = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>My Blog</title>
+	<link>SITE_URL</link>
+	<wp:base_site_url>SITE_URL</wp:base_site_url>
+	<wp:author>
+		<wp:author_login><![CDATA[jane]]></wp:author_login>
+		<wp:author_email><![CDATA[jane@example.com]]></wp:author_email>
+		<wp:author_first_name><![CDATA[Jane]]></wp:author_first_name>
+		<wp:author_last_name><![CDATA[Doe]]></wp:author_last_name>
+	</wp:author>
+	<item>
+		<title>Hello World</title>
+		<dc:creator><![CDATA[jane]]></dc:creator>
+		<content:encoded><![CDATA[Welcome to <b>my</b> blog.
+
+[caption id="attachment_2" width="300"]<img src="SITE_URL/wp-content/uploads/2020/05/photo.jpg" srcset="SITE_URL/wp-content/uploads/2020/05/photo-300x200.jpg 300w" alt="Photo" /> A photo[/caption]
+
+<img src="SITE_URL/wp-content/uploads/missing.jpg" alt="" />]]></content:encoded>
+		<excerpt:encoded><![CDATA[The first post]]></excerpt:encoded>
+		<wp:post_date><![CDATA[2020-05-01 12:30:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2020-05-01 10:30:00]]></wp:post_date_gmt>
+		<wp:post_modified><![CDATA[2020-05-02 08:00:00]]></wp:post_modified>
+		<wp:post_modified_gmt><![CDATA[2020-05-02 06:00:00]]></wp:post_modified_gmt>
+		<wp:post_name><![CDATA[hello-world]]></wp:post_name>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+		<category domain="category" nicename="uncategorized"><![CDATA[Uncategorized]]></category>
+		<category domain="category" nicename="news"><![CDATA[News]]></category>
+		<category domain="post_tag" nicename="go"><![CDATA[Go]]></category>
+	</item>
+	<item>
+		<title>Contact</title>
+		<dc:creator><![CDATA[jane]]></dc:creator>
+		<content:encoded><![CDATA[<table><tr><td>Mail</td></tr></table>]]></content:encoded>
+		<excerpt:encoded><![CDATA[]]></excerpt:encoded>
+		<wp:post_date><![CDATA[2020-05-01 12:30:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2020-05-01 10:30:00]]></wp:post_date_gmt>
+		<wp:post_name><![CDATA[contact]]></wp:post_name>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[page]]></wp:post_type>
+	</item>
+	<item>
+		<title>Draft</title>
+		<dc:creator><![CDATA[ghost]]></dc:creator>
+		<content:encoded><![CDATA[Not yet]]></content:encoded>
+		<wp:post_date><![CDATA[2020-06-01 09:00:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[0000-00-00 00:00:00]]></wp:post_date_gmt>
+		<wp:post_name><![CDATA[]]></wp:post_name>
+		<wp:status><![CDATA[draft]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>photo</title>
+		<wp:post_type><![CDATA[attachment]]></wp:post_type>
+		<wp:status><![CDATA[inherit]]></wp:status>
+	</item>
+</channel>
+</rss>`
+
+func TestImportWordPress(t *testing.T) {
+	server := mediaServer(t)
+	repos, store := newTestSite(t)
+
+	blog, err := ParseWordPress(strings.NewReader(strings.ReplaceAll(wordpressExport, "SITE_URL", server.URL)), "")
+	require.NoError(t, err)
+	require.Len(t, blog.Posts, 2)
+	require.Len(t, blog.Pages, 1)
+
+	report, err := Import(blog, repos, store, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Posts.Created)
+	assert.Equal(t, 1, report.Pages.Created)
+	assert.Equal(t, 1, report.Users.Created)
+	assert.Equal(t, 1, report.Media)
+	assert.Equal(t, []string{server.URL + "/wp-content/uploads/missing.jpg"}, report.FailedMedia)
+	assert.Equal(t, []string{"jane@example.com"}, report.UsersWithoutPassword)
+
+	post, err := repos.Posts.FindBySlug("hello-world")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome to **my** blog.\n\n![Photo](/media/2020/05/photo.jpg)\n\n*A photo*\n\n![]("+server.URL+"/wp-content/uploads/missing.jpg)", post.Content)
+	assert.Equal(t, "jane@example.com", post.Author.Email)
+	require.NotNil(t, post.Excerpt)
+	assert.Equal(t, "The first post", *post.Excerpt)
+	assert.ElementsMatch(t, []string{"News", "Go"}, []string{post.Tags[0].Name, post.Tags[1].Name})
+	assert.True(t, post.PublishedAtUTC.Equal(time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "Etc/GMT-2", post.PublishedAtTimezone)
+	assert.Equal(t, 120, post.PublishedAtTimeZoneOffset)
+
+	media, err := repos.Media.FindByPath("2020/05/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", media.MimeType)
+	file, err := store.Get(media.Path)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "image /wp-content/uploads/2020/05/photo.jpg", string(data))
+
+	page, err := repos.Pages.FindBySlug("contact")
+	require.NoError(t, err)
+	assert.Equal(t, "html", page.ContentType)
+	assert.Contains(t, page.Content, "<table>")
+
+	draft, err := repos.Posts.FindBySlug("draft")
+	require.NoError(t, err)
+	assert.False(t, draft.Visible)
+	assert.Equal(t, "admin@example.com", draft.Author.Email, "posts of unknown authors go to the first admin")
+
+	// Importing again renames the existing posts, and reuses the media files
+	report, err = Import(blog, repos, store, Options{Strategy: archive.StrategyRename})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Posts.Renamed)
+	assert.Equal(t, 1, report.Users.Skipped)
+	assert.Equal(t, 0, report.Media)
+	_, err = repos.Posts.FindBySlug("hello-world-2")
+	assert.NoError(t, err)
+}
+
+func TestParseWordPressTimezone(t *testing.T) {
+	blog, err := ParseWordPress(strings.NewReader(wordpressExport), "Europe/Paris")
+	require.NoError(t, err)
+
+	post := blog.Posts[0]
+	assert.Equal(t, "Europe/Paris", post.Timezone)
+	assert.True(t, post.PublishedAt.Equal(time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)))
+
+	_, err = ParseWordPress(strings.NewReader(wordpressExport), "Mars/Olympus")
+	assert.Error(t, err)
+}
+
+const ghostJSON = `{
+	"db": [{
+		"meta": {"version": "5.0.0"},
+		"data": {
+			"posts": [
+				{"id": "p1", "title": "Ghost Post", "slug": "ghost-post", "type": "post", "status": "published",
+				 "html": "<p>Hi from <a href=\"__GHOST_URL__/about/\">Ghost</a></p><figure class=\"kg-card kg-image-card\"><img src=\"__GHOST_URL__/content/images/2023/01/cat.png\" class=\"kg-image\" alt=\"Cat\"></figure>",
+				 "feature_image": "__GHOST_URL__/content/images/2023/01/cover.png",
+				 "custom_excerpt": null,
+				 "created_at": "2023-01-02T09:00:00.000Z", "updated_at": "2023-01-03T09:00:00.000Z", "published_at": "2023-01-02T10:00:00.000Z"},
+				{"id": "p2", "title": "About", "slug": "about", "type": "page", "status": "draft", "html": "<p>About me</p>",
+				 "created_at": "2023-01-02T09:00:00.000Z", "updated_at": "2023-01-02T09:00:00.000Z", "published_at": null}
+			],
+			"tags": [{"id": "t1", "name": "Cats"}, {"id": "t2", "name": "#internal"}],
+			"posts_tags": [{"post_id": "p1", "tag_id": "t2", "sort_order": 0}, {"post_id": "p1", "tag_id": "t1", "sort_order": 1}],
+			"users": [{"id": "u1", "name": "John Smith", "email": "john@example.com"}],
+			"posts_authors": [{"post_id": "p1", "author_id": "u1", "sort_order": 0}],
+			"settings": [{"key": "timezone", "value": "America/New_York"}]
+		}
+	}]
+}`
+
+func TestImportGhost(t *testing.T) {
+	server := mediaServer(t)
+	repos, store := newTestSite(t)
+
+	blog, err := ParseGhost(strings.NewReader(ghostJSON), server.URL+"/")
+	require.NoError(t, err)
+
+	report, err := Import(blog, repos, store, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Posts.Created)
+	assert.Equal(t, 1, report.Pages.Created)
+	assert.Equal(t, 2, report.Media)
+	assert.Empty(t, report.FailedMedia)
+
+	post, err := repos.Posts.FindBySlug("ghost-post")
+	require.NoError(t, err)
+	assert.Equal(t, "![](/media/images/2023/01/cover.png)\n\nHi from [Ghost]("+server.URL+"/about/)\n\n![Cat](/media/images/2023/01/cat.png)", post.Content)
+	assert.Equal(t, "john@example.com", post.Author.Email)
+	require.Len(t, post.Tags, 1)
+	assert.Equal(t, "Cats", post.Tags[0].Name)
+	assert.Equal(t, "America/New_York", post.PublishedAtTimezone)
+	assert.Equal(t, -300, post.PublishedAtTimeZoneOffset)
+	assert.True(t, post.PublishedAtUTC.Equal(time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC)))
+
+	page, err := repos.Pages.FindBySlug("about")
+	require.NoError(t, err)
+	assert.Equal(t, "markdown", page.ContentType)
+	assert.Equal(t, "About me", page.Content)
+	assert.False(t, page.Visible)
+}
+
+func TestUploadPath(t *testing.T) {
+	rel, ok := uploadPathAfter("https://blog.example.com/wp-content/uploads/2020/05/a.jpg?resize=300", "/wp-content/uploads/")
+	assert.True(t, ok)
+	assert.Equal(t, "2020/05/a.jpg", rel)
+
+	_, ok = uploadPathAfter("https://blog.example.com/wp-content/uploads/../../wp-config.php", "/wp-content/uploads/")
+	assert.False(t, ok)
+	_, ok = uploadPathAfter("https://other.example.com/image.jpg", "/wp-content/uploads/")
+	assert.False(t, ok)
+
+	assert.Equal(t, "UTC", timezoneName(0))
+	assert.Equal(t, "Etc/GMT-2", timezoneName(120))
+	assert.Equal(t, "Etc/GMT+5", timezoneName(-300))
+	assert.Equal(t, "UTC", timezoneName(330))
+}
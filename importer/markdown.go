@@ -0,0 +1,334 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// parseHTML parses an HTML fragment, as found in the body of a post
+func parseHTML(content string) ([]*html.Node, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	return html.ParseFragment(strings.NewReader(content), body)
+}
+
+// blockElements are converted to Markdown blocks
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true, "main": true,
+	"figure": true, "figcaption": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "pre": true, "hr": true,
+}
+
+// inlineElements have no Markdown equivalent, their content is kept without them
+var inlineElements = map[string]bool{
+	"span": true, "font": true, "abbr": true, "small": true, "cite": true, "time": true, "u": true, "mark": true,
+}
+
+var (
+	blankLines    = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	spaces        = regexp.MustCompile(`\s+`)
+	markdownChars = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", "&lt;")
+	blockStart    = regexp.MustCompile(`^(#|>|[-+] |\d+\. )`)
+)
+
+// markdownConverter converts HTML to Markdown. Elements without Markdown
+// equivalent, such as tables or embeds, make the conversion fail, so the
+// content can be kept as HTML rather than lose them.
+type markdownConverter struct {
+	// preserveNewlines converts the blank lines of the text to paragraphs and
+	// its newlines to line breaks, as WordPress does when displaying content
+	preserveNewlines bool
+	unsupported      bool
+}
+
+// toMarkdown converts HTML nodes to Markdown, or returns false when they use
+// elements Markdown cannot express
+func toMarkdown(nodes []*html.Node, preserveNewlines bool) (string, bool) {
+	c := &markdownConverter{preserveNewlines: preserveNewlines}
+	markdown := c.blocks(nodes)
+	if c.unsupported {
+		return "", false
+	}
+	return markdown, true
+}
+
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, child)
+	}
+	return nodes
+}
+
+// blocks converts a sequence of nodes to Markdown blocks separated by blank lines
+func (c *markdownConverter) blocks(nodes []*html.Node) string {
+	var out []string
+	var inline strings.Builder
+
+	flush := func() {
+		for _, paragraph := range strings.Split(inline.String(), "\n\n") {
+			paragraph = trimBreaks(paragraph)
+			if paragraph == "" {
+				continue
+			}
+			if blockStart.MatchString(paragraph) {
+				paragraph = `\` + paragraph
+			}
+			out = append(out, paragraph)
+		}
+		inline.Reset()
+	}
+
+	for _, n := range nodes {
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			flush()
+			if block := c.block(n); block != "" {
+				out = append(out, block)
+			}
+			continue
+		}
+		inline.WriteString(c.inline(n))
+	}
+	flush()
+
+	return strings.Join(out, "\n\n")
+}
+
+func (c *markdownConverter) block(n *html.Node) string {
+	switch n.Data {
+	case "p":
+		return c.blocks(children(n))
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(spaces.ReplaceAllString(c.inlines(children(n)), " "))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "figcaption":
+		text := strings.TrimSpace(spaces.ReplaceAllString(c.inlines(children(n)), " "))
+		if text == "" {
+			return ""
+		}
+		return "*" + text + "*"
+	case "ul", "ol":
+		return c.list(n)
+	case "blockquote":
+		content := c.blocks(children(n))
+		if content == "" {
+			return ""
+		}
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case "pre":
+		return codeBlock(n)
+	case "hr":
+		return "---"
+	default:
+		return c.blocks(children(n))
+	}
+}
+
+func (c *markdownConverter) list(n *html.Node) string {
+	var items []string
+	number := 1
+	for _, child := range children(n) {
+		if child.Type == html.TextNode && strings.TrimSpace(child.Data) == "" {
+			continue
+		}
+		if child.Type != html.ElementNode || child.Data != "li" {
+			c.unsupported = true
+			continue
+		}
+
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		content := c.blocks(children(child))
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(content, "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// codeBlock converts a pre element to a fenced code block
+func codeBlock(n *html.Node) string {
+	language := ""
+	if code := n.FirstChild; code != nil && code.NextSibling == nil && code.Type == html.ElementNode && code.Data == "code" {
+		for _, class := range strings.Fields(attr(code, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = lang
+			}
+		}
+	}
+
+	code := strings.TrimRight(textContent(n), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func (c *markdownConverter) inlines(nodes []*html.Node) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(c.inline(n))
+	}
+	return sb.String()
+}
+
+func (c *markdownConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return c.text(n.Data)
+	case html.CommentNode:
+		// WordPress block editor delimiters
+		return ""
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "strong", "b":
+		return emphasis("**", c.inlines(children(n)))
+	case "em", "i":
+		return emphasis("*", c.inlines(children(n)))
+	case "del", "s", "strike":
+		return emphasis("~~", c.inlines(children(n)))
+	case "code", "kbd", "tt":
+		return inlineCode(textContent(n))
+	case "br":
+		return "\\\n"
+	case "a":
+		text := c.inlines(children(n))
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		if strings.TrimSpace(text) == "" {
+			text = markdownChars.Replace(href)
+		}
+		return "[" + text + "](" + escapeURL(href) + ")"
+	case "img":
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		alt := markdownChars.Replace(spaces.ReplaceAllString(attr(n, "alt"), " "))
+		return "![" + alt + "](" + escapeURL(src) + ")"
+	default:
+		if blockElements[n.Data] {
+			// A block inside an inline element, such as a paragraph in a link
+			return "\n\n" + c.block(n) + "\n\n"
+		}
+		if inlineElements[n.Data] {
+			return c.inlines(children(n))
+		}
+		c.unsupported = true
+		return ""
+	}
+}
+
+// text escapes text for Markdown and normalizes its whitespace
+func (c *markdownConverter) text(text string) string {
+	if !c.preserveNewlines {
+		return markdownChars.Replace(spaces.ReplaceAllString(text, " "))
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	paragraphs := blankLines.Split(text, -1)
+	for i, paragraph := range paragraphs {
+		lines := strings.Split(paragraph, "\n")
+		for j, line := range lines {
+			lines[j] = markdownChars.Replace(spaces.ReplaceAllString(line, " "))
+		}
+		paragraphs[i] = strings.Join(lines, "\\\n")
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// trimBreaks removes the whitespace and line breaks around a paragraph
+func trimBreaks(s string) string {
+	for {
+		trimmed := strings.TrimLeft(strings.TrimPrefix(s, "\\\n"), " \n")
+		trimmed = strings.TrimRight(trimmed, " ")
+		if strings.HasSuffix(trimmed, "\\\n") {
+			trimmed = strings.TrimSuffix(trimmed, "\\\n")
+		} else {
+			trimmed = strings.TrimSuffix(trimmed, "\n")
+		}
+		if trimmed != s {
+			s = trimmed
+			continue
+		}
+		return s
+	}
+}
+
+// emphasis wraps text in a Markdown emphasis marker, keeping the surrounding
+// whitespace outside of it as Markdown requires
+func emphasis(marker, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := text[:strings.Index(text, trimmed)]
+	end := text[len(start)+len(trimmed):]
+	return start + marker + trimmed + marker + end
+}
+
+func inlineCode(code string) string {
+	fence := "`"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		return fence + " " + code + " " + fence
+	}
+	return fence + code + fence
+}
+
+func escapeURL(url string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(url)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "br" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wxrDateFormat is the format of the dates of a WordPress export
+const wxrDateFormat = "2006-01-02 15:04:05"
+
+// wxr is a WordPress eXtended RSS export. Its elements are matched by local
+// name, as the namespace of the WordPress elements changes with the version
+// of the export.
+type wxr struct {
+	Channel struct {
+		BaseSiteURL string      `xml:"base_site_url"`
+		Link        string      `xml:"link"`
+		Authors     []wxrAuthor `xml:"author"`
+		Items       []wxrItem   `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrAuthor struct {
+	Login     string `xml:"author_login"`
+	Email     string `xml:"author_email"`
+	FirstName string `xml:"author_first_name"`
+	LastName  string `xml:"author_last_name"`
+}
+
+type wxrItem struct {
+	Title   string `xml:"title"`
+	Creator string `xml:"creator"`
+	// content:encoded and excerpt:encoded, told apart by namespace
+	Encoded []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:"encoded"`
+	PostName        string `xml:"post_name"`
+	PostType        string `xml:"post_type"`
+	Status          string `xml:"status"`
+	PostDate        string `xml:"post_date"`
+	PostDateGMT     string `xml:"post_date_gmt"`
+	PostModified    string `xml:"post_modified"`
+	PostModifiedGMT string `xml:"post_modified_gmt"`
+	Categories      []struct {
+		Domain   string `xml:"domain,attr"`
+		Nicename string `xml:"nicename,attr"`
+		Name     string `xml:",chardata"`
+	} `xml:"category"`
+}
+
+// content returns the content:encoded or excerpt:encoded element of the item
+func (item *wxrItem) content(excerpt bool) string {
+	for _, encoded := range item.Encoded {
+		if strings.Contains(encoded.XMLName.Space, "/excerpt/") == excerpt {
+			return encoded.Value
+		}
+	}
+	return ""
+}
+
+// captionShortcode matches the [caption] shortcode WordPress wraps images with a caption in
+var captionShortcode = regexp.MustCompile(`(?s)\[caption[^\]]*\]\s*((?:<a[^>]*>)?\s*<img[^>]*>\s*(?:</a>)?)(.*?)\[/caption\]`)
+
+// ParseWordPress parses a WordPress eXtended RSS (WXR) export, as made by
+// Tools > Export in the WordPress admin. The publish dates are kept in
+// timezone, an IANA timezone name, or when empty in the UTC offset of each
+// post when it is a whole number of hours.
+func ParseWordPress(r io.Reader, timezone string) (*Blog, error) {
+	var location *time.Location
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+		location = loc
+	}
+
+	var export wxr
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid WordPress export: %w", err)
+	}
+
+	siteURL := strings.TrimSuffix(export.Channel.BaseSiteURL, "/")
+	if siteURL == "" {
+		siteURL = strings.TrimSuffix(export.Channel.Link, "/")
+	}
+
+	blog := &Blog{
+		uploadPath: func(url string) (string, bool) {
+			return uploadPathAfter(url, "/wp-content/uploads/")
+		},
+		mediaURL: func(url string) string {
+			if strings.HasPrefix(url, "/") {
+				return siteURL + url
+			}
+			return url
+		},
+		preserveNewlines: true,
+	}
+
+	for _, author := range export.Channel.Authors {
+		blog.Authors = append(blog.Authors, Author{
+			Key:       author.Login,
+			Email:     strings.TrimSpace(author.Email),
+			FirstName: author.FirstName,
+			LastName:  author.LastName,
+		})
+	}
+
+	for _, item := range export.Channel.Items {
+		if item.PostType != "post" && item.PostType != "page" {
+			// Attachments, menu items, revisions...
+			continue
+		}
+
+		var visible bool
+		switch item.Status {
+		case "publish", "future":
+			visible = true
+		case "draft", "pending", "private":
+			visible = false
+		default:
+			// Trashed and automatic drafts
+			continue
+		}
+
+		slug, err := url.PathUnescape(item.PostName)
+		if err != nil {
+			slug = item.PostName
+		}
+
+		post := Post{
+			Title:   item.Title,
+			Slug:    slug,
+			HTML:    captionShortcode.ReplaceAllString(item.content(false), "<figure>$1<figcaption>$2</figcaption></figure>"),
+			Excerpt: item.content(true),
+			Visible: visible,
+			Author:  item.Creator,
+		}
+		post.PublishedAt, post.Timezone = wxrDate(item.PostDate, item.PostDateGMT, location)
+		post.CreatedAt = post.PublishedAt.UTC()
+		post.UpdatedAt, _ = wxrDate(item.PostModified, item.PostModifiedGMT, location)
+		post.UpdatedAt = post.UpdatedAt.UTC()
+
+		if item.PostType == "page" {
+			blog.Pages = append(blog.Pages, post)
+			continue
+		}
+
+		for _, category := range item.Categories {
+			if category.Domain == "category" && category.Nicename == "uncategorized" {
+				continue
+			}
+			if category.Domain == "category" || category.Domain == "post_tag" {
+				post.Tags = append(post.Tags, strings.TrimSpace(category.Name))
+			}
+		}
+		blog.Posts = append(blog.Posts, post)
+	}
+
+	return blog, nil
+}
+
+// wxrDate returns the time of a post from its local and GMT dates, in location
+// when set or else in the timezone of its UTC offset, with the timezone name
+func wxrDate(local, gmt string, location *time.Location) (time.Time, string) {
+	localTime, localErr := time.Parse(wxrDateFormat, local)
+	gmtTime, gmtErr := time.Parse(wxrDateFormat, gmt)
+
+	switch {
+	case localErr == nil && location != nil:
+		t := time.Date(localTime.Year(), localTime.Month(), localTime.Day(),
+			localTime.Hour(), localTime.Minute(), localTime.Second(), 0, location)
+		return t, location.String()
+	case localErr == nil && gmtErr == nil:
+		name := timezoneName(int(localTime.Sub(gmtTime).Minutes()))
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return gmtTime, "UTC"
+		}
+		return gmtTime.In(loc), name
+	case localErr == nil:
+		// Drafts have no GMT date
+		return localTime, "UTC"
+	default:
+		return time.Now().UTC(), "UTC"
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
+	"time"
 
+	"github.com/captain-corp/captain/archive"
 	"github.com/captain-corp/captain/cmd"
 	"github.com/captain-corp/captain/config"
+	"github.com/captain-corp/captain/contentsync"
 	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
 	"github.com/captain-corp/captain/server"
+	"github.com/captain-corp/captain/staticsite"
 	"github.com/captain-corp/captain/system"
 
 	"github.com/gofiber/fiber/v2/log"
@@ -23,6 +30,7 @@ import (
 //go:embed embedded/public/static/css/*
 //go:embed embedded/public/static/js/*
 //go:embed embedded/public/static/img/*
+//go:embed embedded/api/*
 var embeddedFS embed.FS
 
 var (
@@ -32,6 +40,10 @@ var (
 	serverPort int
 )
 
+// contentWriteBackInterval is how often the watched content directory is
+// updated with the changes made in the admin
+const contentWriteBackInterval = 5 * time.Second
+
 func main() {
 	var rootCmd = &cobra.Command{Use: "captain"}
 
@@ -68,14 +80,149 @@ func main() {
 		Run:   cmd.CreateUser,
 	}
 
+	userCreateCmd.Flags().StringP("role", "r", models.RoleAdmin, "Role of the new user (admin, editor, author, contributor)")
+
 	var userUpdatePasswordCmd = &cobra.Command{
 		Use:   "update-password",
 		Short: "Update user password",
 		Run:   cmd.UpdateUserPassword,
 	}
 
-	userCmd.AddCommand(userCreateCmd, userUpdatePasswordCmd)
-	rootCmd.AddCommand(runCmd, userCmd, versionCmd)
+	var userResetTwoFactorCmd = &cobra.Command{
+		Use:   "reset-2fa",
+		Short: "Disable two-factor authentication of a user who lost their device",
+		Run:   cmd.ResetUserTwoFactor,
+	}
+
+	userCmd.AddCommand(userCreateCmd, userUpdatePasswordCmd, userResetTwoFactorCmd)
+
+	var dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Database schema commands",
+	}
+
+	var dbMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply the pending schema migrations",
+		Run:   cmd.MigrateDB,
+	}
+
+	dbMigrateCmd.Flags().Bool("dry-run", false, "Print the SQL of the pending migrations without running it")
+
+	var dbStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "List the schema migrations and whether they are applied",
+		Run:   cmd.ShowDBStatus,
+	}
+
+	var dbRollbackCmd = &cobra.Command{
+		Use:   "rollback",
+		Short: "Revert the last applied schema migrations",
+		Run:   cmd.RollbackDB,
+	}
+
+	dbRollbackCmd.Flags().Int("steps", 1, "Number of migrations to revert")
+	dbRollbackCmd.Flags().Bool("dry-run", false, "Print the SQL of the rollback without running it")
+
+	dbCmd.AddCommand(dbMigrateCmd, dbStatusCmd, dbRollbackCmd)
+
+	var exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export the site and its media to a zip archive",
+		Run:   cmd.ExportSite,
+	}
+
+	exportCmd.Flags().StringP("output", "o", "", "Archive file (default captain-export-<date>.zip)")
+	exportCmd.Flags().Bool("with-passwords", false, "Include the password hashes and two-factor secrets of the users")
+
+	var importCmd = &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Import a site archive created by export",
+		Args:  cobra.ExactArgs(1),
+		Run:   cmd.ImportSite,
+	}
+
+	importCmd.Flags().String("on-conflict", string(archive.StrategySkip), "What to do with posts, pages and media that already exist (skip, overwrite, rename)")
+
+	var importWordPressCmd = &cobra.Command{
+		Use:   "wordpress <export.xml>",
+		Short: "Import a WordPress export (WXR)",
+		Args:  cobra.ExactArgs(1),
+		Run:   cmd.ImportWordPress,
+	}
+
+	importWordPressCmd.Flags().String("timezone", "", "Timezone of the blog, e.g. Europe/Paris (default from the UTC offset of each post)")
+
+	var importGhostCmd = &cobra.Command{
+		Use:   "ghost <export.json>",
+		Short: "Import a Ghost JSON export",
+		Args:  cobra.ExactArgs(1),
+		Run:   cmd.ImportGhost,
+	}
+
+	importGhostCmd.Flags().String("url", "", "URL of the Ghost site, to download the images from")
+
+	for _, c := range []*cobra.Command{importWordPressCmd, importGhostCmd} {
+		c.Flags().String("on-conflict", string(archive.StrategySkip), "What to do with posts and pages that already exist (skip, overwrite, rename)")
+		c.Flags().String("uploads", "", "Local copy of the uploads directory (wp-content/uploads or Ghost content), instead of downloading the media")
+		c.Flags().Bool("keep-html", false, "Store the content as HTML instead of converting it to Markdown")
+	}
+
+	importCmd.AddCommand(importWordPressCmd, importGhostCmd)
+
+	var syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Create or update the posts and pages of the content directory files",
+		Run:   cmd.SyncContent,
+	}
+
+	syncCmd.Flags().String("dir", "", "Content directory (overrides config)")
+	syncCmd.Flags().Bool("export", false, "Write the posts and pages to the content directory instead")
+
+	var buildCmd = &cobra.Command{
+		Use:   "build",
+		Short: "Write the public site as static files, to host it on a CDN",
+		Run:   cmd.BuildSite(embeddedFS),
+	}
+
+	buildCmd.Flags().String("out", "./public", "Output directory")
+	buildCmd.Flags().String("base-url", "", "Public URL of the static site (default from site.domain)")
+	buildCmd.Flags().String("links", string(staticsite.LinksRoot), "How links are written (root, absolute, relative)")
+
+	var mediaCmd = &cobra.Command{
+		Use:   "media",
+		Short: "Media library commands",
+	}
+
+	var mediaFsckCmd = &cobra.Command{
+		Use:   "fsck",
+		Short: "Compare the media with the files of the storage, and repair the differences",
+		Run:   cmd.CheckMedia,
+	}
+
+	mediaFsckCmd.Flags().StringSlice("fix", nil, "Repairs to apply (sizes, types, import or purge the orphaned files, hashes)")
+
+	mediaCmd.AddCommand(mediaFsckCmd)
+
+	var storageCmd = &cobra.Command{
+		Use:   "storage",
+		Short: "Manage the storage of the media files",
+	}
+
+	var storageMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy the media files to another storage provider",
+		Run:   cmd.MigrateStorage,
+	}
+
+	storageMigrateCmd.Flags().String("from", "", "Storage provider of the files (local, s3, azure, gcs or webdav), storage.provider by default")
+	storageMigrateCmd.Flags().String("to", "", "Storage provider to copy the files to (local, s3, azure, gcs or webdav)")
+	storageMigrateCmd.Flags().Bool("dry-run", false, "List the files to copy without copying them")
+	storageMigrateCmd.MarkFlagRequired("to")
+
+	storageCmd.AddCommand(storageMigrateCmd)
+
+	rootCmd.AddCommand(runCmd, userCmd, dbCmd, exportCmd, importCmd, syncCmd, buildCmd, mediaCmd, storageCmd, versionCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("Error: %v", err)
@@ -112,9 +259,9 @@ func runServer(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// Validate S3 configuration if S3 provider is selected
-	if err := cfg.ValidateS3Config(); err != nil {
-		log.Fatalf("S3 configuration error: %v", err)
+	// Validate the configuration of the selected storage provider
+	if err := cfg.ValidateStorageConfig(); err != nil {
+		log.Fatalf("Storage configuration error: %v", err)
 	}
 
 	// Create and start server
@@ -122,6 +269,22 @@ func runServer(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
 
+	// Sync the content directory while the server runs
+	if cfg.Content.Dir != "" && cfg.Content.Watch {
+		syncer := contentsync.New(cfg.Content.Dir, repository.NewRepositories(database))
+		report, err := syncer.Import()
+		if err != nil {
+			log.Fatalf("Failed to sync content directory: %v", err)
+		}
+		log.Infof("Synced content directory %s: %s", cfg.Content.Dir, report)
+
+		go func() {
+			if err := syncer.Watch(context.Background(), cfg.Content.WriteBack, contentWriteBackInterval); err != nil {
+				log.Errorf("Content directory watcher stopped: %v", err)
+			}
+		}()
+	}
+
 	// Run the server
 	if err := srv.Run(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
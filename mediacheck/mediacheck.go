@@ -0,0 +1,429 @@
+// Package mediacheck reconciles the media of the database with the files of the
+// storage provider, and repairs the differences on request.
+package mediacheck
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/imaging"
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
+)
+
+// Fix is a repair of the differences found by a check
+type Fix string
+
+const (
+	// FixSizes sets the size of the media to the size of their file
+	FixSizes Fix = "sizes"
+	// FixTypes sets the MIME type of the media to the type of their file
+	FixTypes Fix = "types"
+	// FixImport creates the media of the orphaned files
+	FixImport Fix = "import"
+	// FixPurge deletes the orphaned files
+	FixPurge Fix = "purge"
+	// FixHashes hashes the content of the media stored before hashing
+	FixHashes Fix = "hashes"
+)
+
+// Fixes lists the valid fixes
+var Fixes = []Fix{FixSizes, FixTypes, FixImport, FixPurge, FixHashes}
+
+// ParseFixes returns the fixes of their names
+func ParseFixes(names []string) ([]Fix, error) {
+	var fixes []Fix
+	for _, name := range names {
+		if !slices.Contains(Fixes, Fix(name)) {
+			return nil, fmt.Errorf("invalid fix %q, expected sizes, types, import, purge or hashes", name)
+		}
+		fixes = append(fixes, Fix(name))
+	}
+	if err := validateFixes(fixes); err != nil {
+		return nil, err
+	}
+	return fixes, nil
+}
+
+func validateFixes(fixes []Fix) error {
+	if slices.Contains(fixes, FixImport) && slices.Contains(fixes, FixPurge) {
+		return fmt.Errorf("orphaned files are either imported or purged, not both")
+	}
+	return nil
+}
+
+// orphanGracePeriod is how long orphaned files are left alone, as they may
+// belong to uploads being completed. The files of direct uploads in progress
+// are never orphaned.
+const orphanGracePeriod = time.Hour
+
+// headerSize is how much of an imported image is read to find its metadata
+const headerSize = 128 * 1024
+
+// siteFiles are the media generated from the logo, served at the root of the
+// site instead of being linked from the content
+var siteFiles = []string{system.FaviconFilename, system.FaviconPngFilename, system.AppleTouchIconFilename}
+
+// compatibleTypes are the MIME types of media accepted for files of another
+// type. The generated favicon.ico is a PNG image, which browsers accept.
+var compatibleTypes = map[string]string{
+	"image/x-icon": "image/png",
+}
+
+// Options configures a check
+type Options struct {
+	Fixes []Fix     // repairs applied after the check
+	Now   time.Time // to tell recent files, the current time by default
+}
+
+// Mismatch is a media whose size or type differs from its file
+type Mismatch struct {
+	Media    *models.Media
+	Size     int64  // of the file
+	MimeType string // of the file, empty when its content does not tell
+}
+
+// SizeDiffers returns whether the size of the media differs from its file
+func (m Mismatch) SizeDiffers() bool {
+	return m.Size != m.Media.Size
+}
+
+// TypeDiffers returns whether the MIME type of the media differs from its file
+func (m Mismatch) TypeDiffers() bool {
+	return m.MimeType != "" && m.MimeType != utils.BaseMimeType(m.Media.MimeType)
+}
+
+// Report lists the differences between the media and the files
+type Report struct {
+	Files        int              // files of the storage provider
+	Media        int              // media of the database
+	Orphans      []storage.Object // files without media nor variant, left after the fixes
+	Missing      []*models.Media  // media without file
+	Mismatches   []Mismatch       // media whose size or type differs from their file, left after the fixes
+	Unreferenced []*models.Media  // media linked from no post or page, nor used as logo
+	Unhashed     []*models.Media  // media stored before hashing, left after the fixes
+
+	Fixed    int      // media whose size or type was repaired
+	Hashed   int      // media whose content was hashed
+	Imported int      // orphaned files turned into media
+	Purged   int      // orphaned files deleted
+	Skipped  int      // orphaned files left alone as they are recent
+	Failed   []string // repairs that failed, with the reason
+}
+
+// OK returns whether the media and the files match. Unreferenced media are not
+// a problem, they may be linked from elsewhere.
+func (r *Report) OK() bool {
+	return len(r.Orphans) == 0 && len(r.Missing) == 0 && len(r.Mismatches) == 0
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d files, %d media", r.Files, r.Media)
+
+	if len(r.Orphans) > 0 {
+		fmt.Fprintf(&b, "\nOrphaned files, without media (%d):", len(r.Orphans))
+		for _, object := range r.Orphans {
+			fmt.Fprintf(&b, "\n  %s (%d bytes)", object.Path, object.Size)
+		}
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&b, "\nMissing files (%d):", len(r.Missing))
+		for _, media := range r.Missing {
+			fmt.Fprintf(&b, "\n  %s (media %d)", media.Path, media.ID)
+		}
+	}
+	if len(r.Mismatches) > 0 {
+		fmt.Fprintf(&b, "\nMismatched media (%d):", len(r.Mismatches))
+		for _, m := range r.Mismatches {
+			var differences []string
+			if m.SizeDiffers() {
+				differences = append(differences, fmt.Sprintf("size %d, file of %d bytes", m.Media.Size, m.Size))
+			}
+			if m.TypeDiffers() {
+				differences = append(differences, fmt.Sprintf("type %s, file of type %s", m.Media.MimeType, m.MimeType))
+			}
+			fmt.Fprintf(&b, "\n  %s (media %d): %s", m.Media.Path, m.Media.ID, strings.Join(differences, "; "))
+		}
+	}
+	if len(r.Unreferenced) > 0 {
+		fmt.Fprintf(&b, "\nUnreferenced media (%d):", len(r.Unreferenced))
+		for _, media := range r.Unreferenced {
+			fmt.Fprintf(&b, "\n  %s (media %d)", media.Path, media.ID)
+		}
+	}
+
+	if len(r.Unhashed) > 0 {
+		fmt.Fprintf(&b, "\n%d media stored before hashing, shared with no other media nor served with a content ETag", len(r.Unhashed))
+	}
+
+	if r.Fixed > 0 || r.Imported > 0 || r.Purged > 0 || r.Skipped > 0 {
+		fmt.Fprintf(&b, "\n%d media repaired, %d orphaned files imported, %d purged, %d left as they are recent", r.Fixed, r.Imported, r.Purged, r.Skipped)
+	}
+	if r.Hashed > 0 {
+		fmt.Fprintf(&b, "\n%d media hashed", r.Hashed)
+	}
+	for _, failure := range r.Failed {
+		b.WriteString("\n  " + failure)
+	}
+	return b.String()
+}
+
+// Check compares the media of the database with the files of the storage
+// provider, then applies the fixes of the options
+func Check(repos *repository.Repositories, store storage.Provider, opts Options) (*Report, error) {
+	if err := validateFixes(opts.Fixes); err != nil {
+		return nil, err
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	objects, err := store.List("")
+	if err != nil {
+		return nil, err
+	}
+	medias, err := repos.Media.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	variants, err := repos.MediaVariants.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image variants: %w", err)
+	}
+	// Sent for up to media.upload_expiry, their media are created once complete
+	uploads, err := repos.MediaUploads.FindDirect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	report := &Report{Files: len(objects), Media: len(medias)}
+
+	files := make(map[string]storage.Object, len(objects))
+	for _, object := range objects {
+		files[object.Path] = object
+	}
+	known := make(map[string]bool, len(medias)+len(variants)+len(uploads))
+	for _, variant := range variants {
+		known[variant.Path] = true
+	}
+	for _, upload := range uploads {
+		known[upload.Path] = true
+	}
+
+	// Shared files are read once for all their media
+	types := make(map[string]string)
+	var linkable []*models.Media
+	for _, media := range medias {
+		known[media.Path] = true
+
+		object, ok := files[media.Path]
+		if !ok {
+			report.Missing = append(report.Missing, media)
+			continue
+		}
+		if media.Hash == "" {
+			report.Unhashed = append(report.Unhashed, media)
+		}
+		mismatch := Mismatch{Media: media, Size: object.Size}
+		if object.Size > 0 {
+			mimeType, sniffed := types[object.Path]
+			if !sniffed {
+				if mimeType, err = sniffType(store, object); err != nil {
+					report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", media.Path, err))
+				}
+				types[object.Path] = mimeType
+			}
+			if compatibleTypes[utils.BaseMimeType(media.MimeType)] != mimeType {
+				mismatch.MimeType = mimeType
+			}
+		}
+		if mismatch.SizeDiffers() || mismatch.TypeDiffers() {
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+
+		if !slices.Contains(siteFiles, media.Name) {
+			linkable = append(linkable, media)
+		}
+	}
+
+	if report.Unreferenced, err = repos.Media.FindUnused(linkable); err != nil {
+		return nil, fmt.Errorf("failed to find the unused media: %w", err)
+	}
+
+	for _, object := range objects {
+		if !known[object.Path] {
+			report.Orphans = append(report.Orphans, object)
+		}
+	}
+
+	if slices.Contains(opts.Fixes, FixSizes) || slices.Contains(opts.Fixes, FixTypes) {
+		fixMismatches(repos, report, opts.Fixes)
+	}
+	if slices.Contains(opts.Fixes, FixImport) {
+		importOrphans(repos, store, report, opts.Now)
+	}
+	if slices.Contains(opts.Fixes, FixPurge) {
+		purgeOrphans(store, report, opts.Now)
+	}
+	if slices.Contains(opts.Fixes, FixHashes) {
+		hashMedia(repos, store, report)
+	}
+
+	return report, nil
+}
+
+// fixMismatches sets the sizes or types of the mismatched media to the ones of
+// their files. The repaired media are removed from the report.
+func fixMismatches(repos *repository.Repositories, report *Report, fixes []Fix) {
+	var remaining []Mismatch
+	for _, m := range report.Mismatches {
+		changed := false
+		if m.SizeDiffers() && slices.Contains(fixes, FixSizes) {
+			m.Media.Size = m.Size
+			changed = true
+		}
+		if m.TypeDiffers() && slices.Contains(fixes, FixTypes) {
+			m.Media.MimeType = m.MimeType
+			changed = true
+		}
+		if changed {
+			if err := repos.Media.Update(m.Media); err != nil {
+				report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", m.Media.Path, err))
+			} else {
+				report.Fixed++
+			}
+		}
+		if m.SizeDiffers() || m.TypeDiffers() {
+			remaining = append(remaining, m)
+		}
+	}
+	report.Mismatches = remaining
+}
+
+// importOrphans creates the media of the orphaned files. Orphaned variants are
+// left out, they are generated again when requested.
+func importOrphans(repos *repository.Repositories, store storage.Provider, report *Report, now time.Time) {
+	var remaining []storage.Object
+	for _, object := range report.Orphans {
+		if strings.HasPrefix(object.Path, models.MediaVariantsDir+"/") {
+			remaining = append(remaining, object)
+			continue
+		}
+		if now.Sub(object.ModTime) < orphanGracePeriod {
+			report.Skipped++
+			remaining = append(remaining, object)
+			continue
+		}
+
+		hash, err := hashFile(store, object.Path)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", object.Path, err))
+			remaining = append(remaining, object)
+			continue
+		}
+		media := &models.Media{
+			Name: path.Base(object.Path),
+			Path: object.Path,
+			Size: object.Size,
+			Hash: hash,
+		}
+		if metadata, err := readMetadata(store, object); err == nil {
+			media.Width, media.Height, media.TakenAt = metadata.Width, metadata.Height, metadata.TakenAt
+		}
+		if err := repos.Media.Create(media); err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", object.Path, err))
+			remaining = append(remaining, object)
+			continue
+		}
+		report.Imported++
+	}
+	report.Orphans = remaining
+}
+
+// purgeOrphans deletes the orphaned files
+func purgeOrphans(store storage.Provider, report *Report, now time.Time) {
+	var remaining []storage.Object
+	for _, object := range report.Orphans {
+		if now.Sub(object.ModTime) < orphanGracePeriod {
+			report.Skipped++
+			remaining = append(remaining, object)
+			continue
+		}
+		if err := store.Delete(object.Path); err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", object.Path, err))
+			remaining = append(remaining, object)
+			continue
+		}
+		report.Purged++
+	}
+	report.Orphans = remaining
+}
+
+// hashMedia hashes the content of the media stored before hashing. The hashed
+// media are removed from the report.
+func hashMedia(repos *repository.Repositories, store storage.Provider, report *Report) {
+	var remaining []*models.Media
+	for _, media := range report.Unhashed {
+		hash, err := hashFile(store, media.Path)
+		if err == nil {
+			media.Hash = hash
+			err = repos.Media.UpdateHash(media)
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", media.Path, err))
+			remaining = append(remaining, media)
+			continue
+		}
+		report.Hashed++
+	}
+	report.Unhashed = remaining
+}
+
+// hashFile returns the hash of the content of a file
+func hashFile(store storage.Provider, filePath string) (string, error) {
+	file, err := store.Get(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash, _, err := mediastore.Hash(file)
+	return hash, err
+}
+
+// readPrefix reads the first bytes of a file
+func readPrefix(store storage.Provider, object storage.Object, size int64) ([]byte, error) {
+	file, err := store.GetRange(object.Path, 0, min(size, object.Size))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// sniffType returns the MIME type of a file from its content, or an empty
+// string when the content does not tell
+func sniffType(store storage.Provider, object storage.Object) (string, error) {
+	data, err := readPrefix(store, object, utils.SniffSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", object.Path, err)
+	}
+	return utils.SniffMimeType(data), nil
+}
+
+// readMetadata returns the metadata of an image file
+func readMetadata(store storage.Provider, object storage.Object) (imaging.Metadata, error) {
+	header, err := readPrefix(store, object, headerSize)
+	if err != nil {
+		return imaging.Metadata{}, err
+	}
+	return imaging.ReadMetadata(header)
+}
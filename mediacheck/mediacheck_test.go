@@ -0,0 +1,212 @@
+package mediacheck
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngImage(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// newTestSite creates media and files covering each difference found by a check
+func newTestSite(t *testing.T) (*repository.Repositories, storage.Provider) {
+	store, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	repos := repository.NewRepositories(db.SetupTestDB())
+
+	data := pngImage(t, 20, 10)
+	save := func(path string) {
+		_, err := store.Save(path, bytes.NewReader(data))
+		require.NoError(t, err)
+	}
+	size := int64(len(data))
+
+	save("photos/used.png")
+	used := &models.Media{Name: "used.png", Path: "photos/used.png", Size: size}
+	require.NoError(t, repos.Media.Create(used))
+	require.NoError(t, repos.Posts.Create(&models.Post{Title: "Hello", Slug: "hello", Content: "![](/media/photos/used.png)"}))
+
+	save("_variants/photos/used-10x0-contain-q80.png")
+	require.NoError(t, repos.MediaVariants.Create(&models.MediaVariant{MediaID: used.ID, Key: "10x0-contain-q80.png", Path: "_variants/photos/used-10x0-contain-q80.png", MimeType: "image/png", Size: size}))
+
+	save("favicon.ico")
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "favicon.ico", Path: "favicon.ico", Size: size, MimeType: "image/x-icon"}))
+
+	save("wrong.png")
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "wrong.png", Path: "wrong.png", Size: 0, MimeType: "image/jpeg"}))
+
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "gone.jpg", Path: "gone.jpg", Size: 10}))
+
+	save("orphan.png")
+	save("_variants/stale-320x0-contain-q80.png")
+
+	return repos, store
+}
+
+func paths(objects []storage.Object) []string {
+	var paths []string
+	for _, object := range objects {
+		paths = append(paths, object.Path)
+	}
+	return paths
+}
+
+func mediaPaths(medias []*models.Media) []string {
+	var paths []string
+	for _, media := range medias {
+		paths = append(paths, media.Path)
+	}
+	return paths
+}
+
+func TestCheck(t *testing.T) {
+	repos, store := newTestSite(t)
+
+	report, err := Check(repos, store, Options{})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 6, report.Files)
+	assert.Equal(t, 4, report.Media)
+	assert.ElementsMatch(t, []string{"orphan.png", "_variants/stale-320x0-contain-q80.png"}, paths(report.Orphans))
+	assert.Equal(t, []string{"gone.jpg"}, mediaPaths(report.Missing))
+	assert.Equal(t, []string{"wrong.png"}, mediaPaths(report.Unreferenced))
+
+	require.Len(t, report.Mismatches, 1)
+	mismatch := report.Mismatches[0]
+	assert.Equal(t, "wrong.png", mismatch.Media.Path)
+	assert.True(t, mismatch.SizeDiffers())
+	assert.True(t, mismatch.TypeDiffers())
+	assert.Equal(t, "image/png", mismatch.MimeType)
+	assert.Contains(t, report.String(), "wrong.png (media 3): size 0")
+	assert.Len(t, report.Unhashed, 3)
+}
+
+func TestCheck_Fix(t *testing.T) {
+	repos, store := newTestSite(t)
+	later := time.Now().Add(2 * orphanGracePeriod)
+
+	// Recent orphaned files may belong to uploads in progress
+	report, err := Check(repos, store, Options{Fixes: []Fix{FixPurge}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Zero(t, report.Purged)
+
+	report, err = Check(repos, store, Options{Fixes: []Fix{FixSizes, FixTypes, FixImport}, Now: later})
+	require.NoError(t, err)
+	assert.Empty(t, report.Failed)
+	assert.Equal(t, 1, report.Fixed)
+	assert.Equal(t, 1, report.Imported)
+	assert.Empty(t, report.Mismatches)
+	assert.Equal(t, []string{"_variants/stale-320x0-contain-q80.png"}, paths(report.Orphans))
+
+	wrong, err := repos.Media.FindByPath("wrong.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", wrong.MimeType)
+	assert.NotZero(t, wrong.Size)
+
+	orphan, err := repos.Media.FindByPath("orphan.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", orphan.MimeType)
+	assert.Equal(t, 20, orphan.Width)
+	assert.Equal(t, 10, orphan.Height)
+
+	// Orphaned variants are not imported
+	_, err = repos.Media.FindByPath("_variants/stale-320x0-contain-q80.png")
+	assert.Error(t, err)
+
+	report, err = Check(repos, store, Options{Fixes: []Fix{FixHashes}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Hashed)
+	assert.Empty(t, report.Unhashed)
+	used, err := repos.Media.FindByPath("photos/used.png")
+	require.NoError(t, err)
+	assert.Equal(t, orphan.Hash, used.Hash, "imported orphans are hashed")
+	assert.Len(t, used.Hash, 64)
+
+	report, err = Check(repos, store, Options{Fixes: []Fix{FixPurge}, Now: later})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Purged)
+	assert.Empty(t, report.Orphans)
+
+	// Media without file are left for the admins to delete
+	report, err = Check(repos, store, Options{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+	assert.Empty(t, report.Mismatches)
+	assert.Equal(t, []string{"gone.jpg"}, mediaPaths(report.Missing))
+
+	_, err = Check(repos, store, Options{Fixes: []Fix{FixImport, FixPurge}})
+	assert.Error(t, err)
+}
+
+func TestCheck_Uploads(t *testing.T) {
+	repos, store := newTestSite(t)
+	_, err := store.Save("1700000000-clip.mp4", bytes.NewReader([]byte("video")))
+	require.NoError(t, err)
+	require.NoError(t, repos.MediaUploads.Create(&models.MediaUpload{
+		UserID: 1, Filename: "clip.mp4", MimeType: "video/mp4", Size: 5,
+		Direct: true, Path: "1700000000-clip.mp4", ExpiresAt: time.Now().Add(24 * time.Hour),
+	}))
+	later := time.Now().Add(2 * orphanGracePeriod)
+
+	// Uploads can be completed until they expire
+	report, err := Check(repos, store, Options{Fixes: []Fix{FixImport}, Now: later})
+	require.NoError(t, err)
+	assert.NotContains(t, paths(report.Orphans), "1700000000-clip.mp4")
+	_, err = repos.Media.FindByPath("1700000000-clip.mp4")
+	assert.Error(t, err, "the files of uploads are not imported")
+
+	report, err = Check(repos, store, Options{Fixes: []Fix{FixPurge}, Now: later})
+	require.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+	_, err = store.Stat("1700000000-clip.mp4")
+	assert.NoError(t, err, "the files of uploads are not purged")
+}
+
+// countingProvider counts the reads of the start of files
+type countingProvider struct {
+	storage.Provider
+	ranges int
+}
+
+func (p *countingProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	p.ranges++
+	return p.Provider.GetRange(path, offset, length)
+}
+
+func TestCheck_SharedFiles(t *testing.T) {
+	repos, store := newTestSite(t)
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "copy.png", Path: "photos/used.png", Size: int64(len(pngImage(t, 20, 10))), MimeType: "image/png"}))
+	counting := &countingProvider{Provider: store}
+
+	report, err := Check(repos, counting, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, counting.ranges, "shared files are read once")
+	assert.NotContains(t, mediaPaths(report.Unreferenced), "photos/used.png")
+}
+
+func TestParseFixes(t *testing.T) {
+	fixes, err := ParseFixes([]string{"sizes", "purge"})
+	require.NoError(t, err)
+	assert.Equal(t, []Fix{FixSizes, FixPurge}, fixes)
+
+	_, err = ParseFixes([]string{"everything"})
+	assert.Error(t, err)
+	_, err = ParseFixes([]string{"import", "purge"})
+	assert.Error(t, err)
+}
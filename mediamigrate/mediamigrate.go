@@ -0,0 +1,273 @@
+// Package mediamigrate copies the files of the media from a storage provider to
+// another, so sites can switch providers while they keep running.
+package mediamigrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+)
+
+// Status is the outcome of the migration of a file
+type Status string
+
+const (
+	// StatusCopied is a file copied and verified
+	StatusCopied Status = "copied"
+	// StatusPending is a file to copy, on dry runs
+	StatusPending Status = "pending"
+	// StatusSkipped is a file already in the target, copied by an earlier run
+	StatusSkipped Status = "skipped"
+	// StatusMissing is a file in neither provider
+	StatusMissing Status = "missing"
+	// StatusFailed is a file whose copy failed
+	StatusFailed Status = "failed"
+)
+
+// Options configures a migration
+type Options struct {
+	DryRun bool // list the files to copy without copying them
+
+	// Progress is called after each file, with the count of files handled
+	Progress func(done, total int, file *File)
+}
+
+// File is a file of the media or their variants
+type File struct {
+	Path   string // of the media or variant, in both providers
+	Size   int64
+	Status Status
+	Err    error // why the copy failed
+
+	hash string // of the media, checked against the content copied
+}
+
+// Report sums up a migration
+type Report struct {
+	Files   int   // referenced by the media and their variants
+	Copied  int   // files copied, or to copy on dry runs
+	Bytes   int64 // copied, or to copy on dry runs
+	Skipped int   // files already in the target
+	Missing []*File
+	Failed  []*File
+	DryRun  bool // nothing was copied
+}
+
+// OK returns whether all the files are in the target provider, or can be
+// copied to it on dry runs
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Failed) == 0
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	action := "copied"
+	if r.DryRun {
+		action = "to copy"
+	}
+	fmt.Fprintf(&b, "%d files: %d %s (%d bytes), %d already in the target", r.Files, r.Copied, action, r.Bytes, r.Skipped)
+
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&b, "\nMissing files, in neither provider (%d):", len(r.Missing))
+		for _, file := range r.Missing {
+			fmt.Fprintf(&b, "\n  %s", file.Path)
+		}
+	}
+	if len(r.Failed) > 0 {
+		fmt.Fprintf(&b, "\nFailed copies (%d):", len(r.Failed))
+		for _, file := range r.Failed {
+			fmt.Fprintf(&b, "\n  %s: %v", file.Path, file.Err)
+		}
+	}
+	return b.String()
+}
+
+// Migrate copies the files referenced by the media and their variants from a
+// storage provider to another, verifying their size and content. Files keep
+// their path, so links in posts and pages remain valid. Files already in the
+// target are skipped, so an interrupted migration resumes where it stopped.
+func Migrate(repos *repository.Repositories, from, to storage.Provider, opts Options) (*Report, error) {
+	saver, ok := storage.AsPathSaver(to)
+	if !ok {
+		return nil, fmt.Errorf("the target provider cannot store files at a given path")
+	}
+
+	files, err := listFiles(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Files: len(files), DryRun: opts.DryRun}
+	for i, file := range files {
+		if err := migrateFile(from, to, saver, file, opts.DryRun); err != nil {
+			file.Status = StatusFailed
+			file.Err = err
+		}
+
+		switch file.Status {
+		case StatusCopied, StatusPending:
+			report.Copied++
+			report.Bytes += file.Size
+		case StatusSkipped:
+			report.Skipped++
+		case StatusMissing:
+			report.Missing = append(report.Missing, file)
+		case StatusFailed:
+			report.Failed = append(report.Failed, file)
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(files), file)
+		}
+	}
+	return report, nil
+}
+
+// listFiles returns the files of the media and of their variants, shared files
+// once
+func listFiles(repos *repository.Repositories) ([]*File, error) {
+	medias, err := repos.Media.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	variants, err := repos.MediaVariants.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image variants: %w", err)
+	}
+
+	var files []*File
+	byPath := make(map[string]*File)
+	add := func(path string) *File {
+		file, ok := byPath[path]
+		if !ok {
+			file = &File{Path: path}
+			byPath[path] = file
+			files = append(files, file)
+		}
+		return file
+	}
+
+	for _, media := range medias {
+		file := add(media.Path)
+		if media.Hash != "" {
+			file.hash = media.Hash
+		}
+	}
+	for _, variant := range variants {
+		add(variant.Path)
+	}
+	return files, nil
+}
+
+// migrateFile copies a file unless the target already has it
+func migrateFile(from, to storage.Provider, saver storage.PathSaver, file *File, dryRun bool) error {
+	source, sourceErr := from.Stat(file.Path)
+
+	// Copied by an earlier run, or uploaded to the target since
+	if target, err := to.Stat(file.Path); err == nil && (sourceErr != nil || target.Size == source.Size) {
+		file.Size = target.Size
+		file.Status = StatusSkipped
+		return nil
+	}
+
+	if sourceErr != nil {
+		file.Status = StatusMissing
+		return nil
+	}
+	file.Size = source.Size
+	if dryRun {
+		file.Status = StatusPending
+		return nil
+	}
+
+	spooled, err := spool(from, file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spooled.Close()
+		os.Remove(spooled.Name())
+	}()
+
+	if err := saver.SaveAt(file.Path, spooled); err != nil {
+		return err
+	}
+
+	if err := verify(to, file); err != nil {
+		// The next run copies it again
+		_ = to.Delete(file.Path)
+		return err
+	}
+	file.Status = StatusCopied
+	return nil
+}
+
+// spool copies a file of the source provider to a temporary file, as most
+// providers need the size of the files they receive, and checks its content
+func spool(from storage.Provider, file *File) (*os.File, error) {
+	reader, err := from.Get(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	spooled, err := os.CreateTemp("", "captain-migrate-*")
+	if err != nil {
+		return nil, err
+	}
+	hash, size, err := mediastore.Hash(io.TeeReader(reader, spooled))
+	if err == nil {
+		err = checkContent(file, hash, size)
+	}
+	if err == nil {
+		file.hash = hash
+		_, err = spooled.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		os.Remove(spooled.Name())
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// checkContent compares the size and hash of a file read with the ones expected
+func checkContent(file *File, hash string, size int64) error {
+	if size != file.Size {
+		return fmt.Errorf("read %d bytes instead of %d", size, file.Size)
+	}
+	if file.hash != "" && hash != file.hash {
+		return fmt.Errorf("content does not match the hash of its media, %s", hash)
+	}
+	return nil
+}
+
+// verify reads a file back from the target and checks its content
+func verify(to storage.Provider, file *File) error {
+	object, err := to.Stat(file.Path)
+	if err != nil {
+		return fmt.Errorf("copy not found: %w", err)
+	}
+	if object.Size != file.Size {
+		return fmt.Errorf("copy has %d bytes instead of %d", object.Size, file.Size)
+	}
+
+	reader, err := to.Get(file.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	hash, _, err := mediastore.Hash(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read the copy: %w", err)
+	}
+	if hash != file.hash {
+		return fmt.Errorf("copy does not match the original content")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package mediamigrate
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/mediastore"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plainProvider hides the SaveAt of a provider
+type plainProvider struct {
+	storage.Provider
+}
+
+func newProvider(t *testing.T) storage.Provider {
+	provider, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	return provider
+}
+
+func hash(t *testing.T, content string) string {
+	hash, _, err := mediastore.Hash(strings.NewReader(content))
+	require.NoError(t, err)
+	return hash
+}
+
+func read(t *testing.T, provider storage.Provider, path string) string {
+	reader, err := provider.Get(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func filePaths(files []*File) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+// newTestSite creates media covering each outcome of a migration
+func newTestSite(t *testing.T) (*repository.Repositories, storage.Provider) {
+	repos := repository.NewRepositories(db.SetupTestDB())
+	from := newProvider(t)
+	save := func(path, content string) {
+		_, err := from.Save(path, strings.NewReader(content))
+		require.NoError(t, err)
+	}
+
+	save("photos/photo.jpg", "photo")
+	photo := &models.Media{Name: "photo.jpg", Path: "photos/photo.jpg", Size: 5, Hash: hash(t, "photo")}
+	require.NoError(t, repos.Media.Create(photo))
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "copy.jpg", Path: "photos/photo.jpg", Size: 5, Hash: hash(t, "photo")}))
+
+	save("_variants/photos/photo-320x0.jpg", "variant")
+	require.NoError(t, repos.MediaVariants.Create(&models.MediaVariant{MediaID: photo.ID, Key: "320x0", Path: "_variants/photos/photo-320x0.jpg"}))
+
+	save("old.pdf", "unhashed")
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "old.pdf", Path: "old.pdf", Size: 8}))
+
+	save("altered.png", "altered")
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "altered.png", Path: "altered.png", Size: 7, Hash: hash(t, "original")}))
+
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "gone.jpg", Path: "gone.jpg", Size: 4}))
+
+	return repos, from
+}
+
+func TestMigrate(t *testing.T) {
+	repos, from := newTestSite(t)
+	to := newProvider(t)
+
+	report, err := Migrate(repos, from, to, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Files)
+	assert.Equal(t, 4, report.Copied)
+	assert.Equal(t, int64(5+7+8+7), report.Bytes)
+	assert.Equal(t, []string{"gone.jpg"}, filePaths(report.Missing))
+	objects, err := to.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects, "dry runs copy nothing")
+
+	var progress []string
+	report, err = Migrate(repos, from, to, Options{Progress: func(done, total int, file *File) {
+		progress = append(progress, file.Path)
+		assert.Equal(t, 5, total)
+	}})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 3, report.Copied)
+	assert.Len(t, progress, 5)
+	assert.Equal(t, []string{"gone.jpg"}, filePaths(report.Missing))
+	require.Len(t, report.Failed, 1)
+	assert.Equal(t, "altered.png", report.Failed[0].Path)
+
+	assert.Equal(t, "photo", read(t, to, "photos/photo.jpg"))
+	assert.Equal(t, "variant", read(t, to, "_variants/photos/photo-320x0.jpg"))
+	assert.Equal(t, "unhashed", read(t, to, "old.pdf"))
+	_, err = to.Stat("altered.png")
+	assert.Error(t, err, "files not matching their hash are not copied")
+
+	// Files copied are skipped, as are the ones uploaded to the target since
+	_, err = to.Save("altered.png", strings.NewReader("altered"))
+	require.NoError(t, err)
+	report, err = Migrate(repos, from, to, Options{})
+	require.NoError(t, err)
+	assert.Zero(t, report.Copied)
+	assert.Equal(t, 4, report.Skipped)
+	assert.Empty(t, report.Failed)
+	assert.Contains(t, report.String(), "5 files: 0 copied (0 bytes), 4 already in the target")
+}
+
+func TestMigrate_PathSaver(t *testing.T) {
+	repos, from := newTestSite(t)
+	to := plainProvider{newProvider(t)}
+
+	_, err := Migrate(repos, from, to, Options{})
+	assert.Error(t, err, "files must keep their path")
+	objects, err := to.List("")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
@@ -0,0 +1,219 @@
+// Package mediastore stores the files of media by their content: media with the
+// same content share a single file, deleted with the last of them.
+package mediastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"gorm.io/gorm"
+)
+
+// ErrReleased is returned when creating the media of a shared file deleted
+// meanwhile with the last media using it
+var ErrReleased = errors.New("the file was deleted with the media sharing it, upload it again")
+
+// mu serializes the creation of the media sharing a file with the deletion of
+// the media using it, so a file is not deleted while a media is created for it
+var mu sync.Mutex
+
+// File is the stored file of a media
+type File struct {
+	Path   string
+	Hash   string // hex SHA-256 of the content
+	Size   int64
+	Shared bool // the file of another media with the same content
+}
+
+// Hash returns the hex SHA-256 and the size of a content
+func Hash(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Save stores the content of a file uploaded as filename. When a media already
+// has the same content, its file is returned instead of storing another copy.
+func Save(repos *repository.Repositories, store storage.Provider, filename string, r io.Reader) (*File, error) {
+	// The content is hashed before it is stored, so it is read twice
+	body, ok := r.(io.ReadSeeker)
+	if !ok {
+		spooled, err := spool(r)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			spooled.Close()
+			os.Remove(spooled.Name())
+		}()
+		body = spooled
+	}
+
+	hash, size, err := Hash(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if file, err := findShared(repos, hash, size); file != nil || err != nil {
+		return file, err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Files of other content are not overwritten
+	if _, err := store.Stat(filename); err == nil {
+		filename = hashedFilename(filename, hash)
+	}
+	savedPath, err := store.Save(filename, body)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: savedPath, Hash: hash, Size: size}, nil
+}
+
+// Adopt hashes a file already stored, like the ones uploaded straight to the
+// storage provider. When a media already has the same content, the file is
+// deleted and the one of the media is returned.
+func Adopt(repos *repository.Repositories, store storage.Provider, filePath string) (*File, error) {
+	reader, err := store.Get(filePath)
+	if err != nil {
+		return nil, err
+	}
+	hash, size, err := Hash(reader)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	file, err := findShared(repos, hash, size)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return &File{Path: filePath, Hash: hash, Size: size}, nil
+	}
+	// A media may already use this very file, like after imports of fsck
+	if file.Path != filePath {
+		if err := store.Delete(filePath); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Create creates the media of a stored file, and discards the file when the
+// media cannot be created. It fails with ErrReleased when the shared file was
+// deleted since it was stored.
+func Create(repos *repository.Repositories, store storage.Provider, media *models.Media, file *File) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if file.Shared {
+		count, err := repos.Media.CountByPath(file.Path)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrReleased
+		}
+	}
+	if err := repos.Media.Create(media); err != nil {
+		_ = Discard(store, file)
+		return err
+	}
+	return nil
+}
+
+// Delete deletes a media, and its file unless other media share it
+func Delete(repos *repository.Repositories, store storage.Provider, media *models.Media) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := repos.Media.Delete(media); err != nil {
+		return err
+	}
+	count, err := repos.Media.CountByPath(media.Path)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return store.Delete(media.Path)
+}
+
+// Discard deletes a file stored for a media that could not be created, unless
+// other media share it
+func Discard(store storage.Provider, file *File) error {
+	if file.Shared {
+		return nil
+	}
+	return store.Delete(file.Path)
+}
+
+// Release deletes the file of a media about to be given another file, unless
+// other media share it
+func Release(repos *repository.Repositories, store storage.Provider, media *models.Media) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	count, err := repos.Media.CountByPath(media.Path)
+	if err != nil {
+		return err
+	}
+	if count > 1 {
+		return nil
+	}
+	return store.Delete(media.Path)
+}
+
+// findShared returns the file of the media with a content, nil when there is none
+func findShared(repos *repository.Repositories, hash string, size int64) (*File, error) {
+	media, err := repos.Media.FindByHash(hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: media.Path, Hash: hash, Size: size, Shared: true}, nil
+}
+
+// spool copies a content to a temporary file
+func spool(r io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp("", "captain-media-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(file, r)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// hashedFilename returns a filename made unique by the start of the hash of
+// its content
+func hashedFilename(filename, hash string) string {
+	ext := path.Ext(filename)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(filename, ext), hash[:12], ext)
+}
@@ -0,0 +1,136 @@
+package mediastore
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) (*repository.Repositories, storage.Provider) {
+	store, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	return repository.NewRepositories(db.SetupTestDB()), store
+}
+
+// create saves a file and creates its media
+func create(t *testing.T, repos *repository.Repositories, store storage.Provider, filename string, content string) (*models.Media, *File) {
+	// Readers that cannot seek are spooled
+	file, err := Save(repos, store, filename, io.MultiReader(strings.NewReader(content)))
+	require.NoError(t, err)
+	media := &models.Media{Name: filename, Path: file.Path, Size: file.Size, Hash: file.Hash}
+	require.NoError(t, Create(repos, store, media, file))
+	return media, file
+}
+
+func TestSave(t *testing.T) {
+	repos, store := newTestStore(t)
+
+	first, file := create(t, repos, store, "photo.jpg", "first")
+	assert.Equal(t, "photo.jpg", file.Path)
+	assert.Equal(t, int64(5), file.Size)
+	assert.Equal(t, "a7937b64b8caa58f03721bb6bacf5c78cb235febe0e70b1b84cd99541461a08e", file.Hash)
+	assert.False(t, file.Shared)
+
+	// The same content shares the file
+	_, file = create(t, repos, store, "copy.jpg", "first")
+	assert.Equal(t, "photo.jpg", file.Path)
+	assert.True(t, file.Shared)
+
+	// Another content does not overwrite the file of the same name
+	_, file = create(t, repos, store, "photo.jpg", "second")
+	assert.Equal(t, "photo-"+file.Hash[:12]+".jpg", file.Path)
+	assert.False(t, file.Shared)
+
+	reader, err := store.Get(first.Path)
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+}
+
+func TestAdopt(t *testing.T) {
+	repos, store := newTestStore(t)
+	create(t, repos, store, "photo.jpg", "content")
+
+	_, err := store.Save("direct.jpg", bytes.NewReader([]byte("content")))
+	require.NoError(t, err)
+	file, err := Adopt(repos, store, "direct.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "photo.jpg", file.Path)
+	assert.True(t, file.Shared)
+	_, err = store.Stat("direct.jpg")
+	assert.Error(t, err, "the duplicate is deleted")
+
+	_, err = store.Save("other.jpg", bytes.NewReader([]byte("other")))
+	require.NoError(t, err)
+	file, err = Adopt(repos, store, "other.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "other.jpg", file.Path)
+	assert.False(t, file.Shared)
+
+	// Imported by fsck, or completed twice
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "other.jpg", Path: "other.jpg", Hash: file.Hash}))
+	file, err = Adopt(repos, store, "other.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "other.jpg", file.Path)
+	assert.True(t, file.Shared)
+	_, err = store.Stat("other.jpg")
+	assert.NoError(t, err, "the file of the media is kept")
+}
+
+func TestDelete(t *testing.T) {
+	repos, store := newTestStore(t)
+	first, _ := create(t, repos, store, "photo.jpg", "content")
+	second, file := create(t, repos, store, "copy.jpg", "content")
+
+	// Discarding a shared file keeps it
+	require.NoError(t, Discard(store, file))
+	_, err := store.Stat("photo.jpg")
+	require.NoError(t, err)
+
+	require.NoError(t, Delete(repos, store, first))
+	_, err = store.Stat("photo.jpg")
+	require.NoError(t, err, "the file is still used by the second media")
+
+	// Stored for a media sharing the file, which is deleted before the media
+	shared, err := Save(repos, store, "again.jpg", strings.NewReader("content"))
+	require.NoError(t, err)
+	require.True(t, shared.Shared)
+	require.NoError(t, Delete(repos, store, second))
+	_, err = store.Stat("photo.jpg")
+	assert.Error(t, err)
+	media := &models.Media{Name: "again.jpg", Path: shared.Path, Hash: shared.Hash}
+	assert.ErrorIs(t, Create(repos, store, media, shared), ErrReleased)
+	_, err = repos.Media.FindByPath("photo.jpg")
+	assert.Error(t, err, "no media points to the deleted file")
+
+	// Uploading the content again stores it again
+	_, file = create(t, repos, store, "photo.jpg", "content")
+	assert.Equal(t, "photo.jpg", file.Path)
+	assert.False(t, file.Shared)
+}
+
+func TestRelease(t *testing.T) {
+	repos, store := newTestStore(t)
+	first, _ := create(t, repos, store, "photo.jpg", "content")
+	create(t, repos, store, "copy.jpg", "content")
+
+	require.NoError(t, Release(repos, store, first))
+	_, err := store.Stat("photo.jpg")
+	require.NoError(t, err, "the file is still used by the second media")
+
+	other, _ := create(t, repos, store, "other.jpg", "other")
+	require.NoError(t, Release(repos, store, other))
+	_, err = store.Stat("other.jpg")
+	assert.Error(t, err)
+}
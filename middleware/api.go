@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIError sends an error using the REST API error envelope:
+// {"error": {"status": 404, "code": "not_found", "message": "..."}}
+func APIError(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error": fiber.Map{
+			"status":  status,
+			"code":    strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+			"message": message,
+		},
+	})
+}
+
+// APIAuth authenticates REST API requests with a bearer API token
+func APIAuth(repos *repository.Repositories) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		value, found := strings.CutPrefix(header, "Bearer ")
+		if !found || strings.TrimSpace(value) == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+			return APIError(c, http.StatusUnauthorized, "Missing bearer token")
+		}
+
+		token, err := repos.APITokens.FindByHash(utils.HashAPIToken(strings.TrimSpace(value)))
+		if err != nil || token.User == nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api", error="invalid_token"`)
+			return APIError(c, http.StatusUnauthorized, "Invalid or revoked token")
+		}
+
+		// Avoid writing to the database on every request
+		now := time.Now().UTC()
+		if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+			if err := repos.APITokens.TouchLastUsed(token, now); err != nil {
+				return APIError(c, http.StatusInternalServerError, "Failed to update token")
+			}
+		}
+
+		c.Locals("user", token.User)
+		c.Locals("apiToken", token)
+		return c.Next()
+	}
+}
+
+// RequireScope ensures that the API token used for the request was granted the scope
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token, ok := c.Locals("apiToken").(*models.APIToken); ok && token.HasScope(scope) {
+			return c.Next()
+		}
+		return APIError(c, http.StatusForbidden, "Token is missing the "+scope+" scope")
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestToken creates an API token of a user granted scopes, and returns its value
+func createTestToken(t *testing.T, repos *repository.Repositories, user *models.User, scopes string) string {
+	plain, err := utils.GenerateAPIToken()
+	require.NoError(t, err)
+	require.NoError(t, repos.APITokens.Create(&models.APIToken{
+		UserID:    user.ID,
+		Name:      "Test",
+		Prefix:    plain[:10],
+		TokenHash: utils.HashAPIToken(plain),
+		Scopes:    scopes,
+	}))
+	return plain
+}
+
+func TestAPIAuth(t *testing.T) {
+	app, repos := newTestApp(t)
+	api := app.Group("/api/v1", APIAuth(repos))
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	api.Get("/posts", RequireScope(models.ScopePostsRead), RequirePermission(models.PermissionEditOwnPosts), ok)
+	api.Get("/pages", RequireScope(models.ScopePagesRead), RequirePermission(models.PermissionManagePages), ok)
+
+	author := createTestUser(t, repos, models.RoleAuthor)
+	token := createTestToken(t, repos, author, models.ScopePostsRead+","+models.ScopePagesRead)
+	bearer := func(token string) map[string]string {
+		return map[string]string{fiber.HeaderAuthorization: "Bearer " + token}
+	}
+
+	// Missing token
+	resp := testRequest(t, app, http.MethodGet, "/api/v1/posts", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer realm="api"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	assert.Contains(t, readBody(t, resp), `"code":"unauthorized"`)
+	resp = testRequest(t, app, http.MethodGet, "/api/v1/posts", "", bearer(""))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Invalid token
+	resp = testRequest(t, app, http.MethodGet, "/api/v1/posts", "", bearer("invalid"))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer realm="api", error="invalid_token"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	resp = testRequest(t, app, http.MethodGet, "/api/v1/posts", "", map[string]string{fiber.HeaderAuthorization: token})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "tokens are sent as bearer tokens")
+
+	resp = testRequest(t, app, http.MethodGet, "/api/v1/posts", "", bearer(token))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	tokens, err := repos.APITokens.FindByUser(author.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	// Tokens act with the permissions of their user
+	resp = testRequest(t, app, http.MethodGet, "/api/v1/pages", "", bearer(token))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `"code":"forbidden"`)
+}
+
+func TestRequireScope(t *testing.T) {
+	app, repos := newTestApp(t)
+	app.Get("/api/v1/posts", APIAuth(repos), RequireScope(models.ScopePostsRead), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	admin := createTestUser(t, repos, models.RoleAdmin)
+
+	for scopes, status := range map[string]int{
+		models.ScopePostsRead:                                http.StatusOK,
+		models.ScopePostsWrite + "," + models.ScopePostsRead: http.StatusOK,
+		models.ScopePostsWrite:                               http.StatusForbidden,
+		"":                                                   http.StatusForbidden,
+	} {
+		token := createTestToken(t, repos, admin, scopes)
+		resp := testRequest(t, app, http.MethodGet, "/api/v1/posts", "", map[string]string{fiber.HeaderAuthorization: "Bearer " + token})
+		assert.Equal(t, status, resp.StatusCode, scopes)
+	}
+}
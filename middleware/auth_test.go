@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestApp returns an app using the middleware like the server does, with
+// routes of each permission. The /test routes set up the session of a user.
+func newTestApp(t *testing.T) (*fiber.App, *repository.Repositories) {
+	repos := repository.NewRepositories(db.SetupTestDB())
+	store := session.New()
+
+	app := fiber.New(fiber.Config{Views: testViews{}})
+	app.Use(LoadUserData(repos, store))
+	app.Use("/admin", AuthRequired(repos, store))
+	csrf := CSRF(store)
+	app.Use("/admin", csrf)
+	app.Use("/login/2fa", csrf)
+
+	app.Get("/test/login/:id", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return err
+		}
+		sess.Set("loggedIn", true)
+		sess.Set("userID", uint(id))
+		return sess.Save()
+	})
+	// Only through the password step of users with two-factor authentication
+	app.Get("/test/password/:id", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return err
+		}
+		sess.Set("twoFactorUserID", uint(id))
+		return sess.Save()
+	})
+	app.Get("/admin/csrf", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		token, _ := sess.Get(CSRFSessionKey).(string)
+		return c.SendString(token)
+	})
+
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/admin/posts", RequirePermission(models.PermissionEditOwnPosts), ok)
+	app.Post("/admin/posts", RequirePermission(models.PermissionEditOwnPosts), ok)
+	app.Get("/admin/pages", RequirePermission(models.PermissionManagePages), ok)
+	app.Delete("/admin/users/:id", RequirePermission(models.PermissionManageUsers), ok)
+	app.Get("/admin/settings", RequirePermission(models.PermissionManageSettings), ok)
+	app.Post("/login/2fa", ok)
+
+	return app, repos
+}
+
+// testViews renders the name of the templates
+type testViews struct{}
+
+func (testViews) Load() error { return nil }
+
+func (testViews) Render(w io.Writer, name string, _ interface{}, _ ...string) error {
+	_, err := io.WriteString(w, name)
+	return err
+}
+
+// createTestUser creates a user of a role
+func createTestUser(t *testing.T, repos *repository.Repositories, role string) *models.User {
+	user := &models.User{Email: role + "@example.com", Password: "secret", Role: role}
+	require.NoError(t, repos.Users.Create(user))
+	return user
+}
+
+// testRequest sends a request with the session cookie, when there is one
+func testRequest(t *testing.T, app *fiber.App, method, target, cookie string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest(method, target, nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: cookie})
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// login returns the session cookie of a logged in user
+func login(t *testing.T, app *fiber.App, user *models.User) string {
+	return sessionCookie(t, app, fmt.Sprintf("/test/login/%d", user.ID))
+}
+
+// sessionCookie returns the session cookie set by a /test route
+func sessionCookie(t *testing.T, app *fiber.App, target string) string {
+	resp := testRequest(t, app, http.MethodGet, target, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			return cookie.Value
+		}
+	}
+	t.Fatal("no session cookie")
+	return ""
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestAuthRequired(t *testing.T) {
+	app, repos := newTestApp(t)
+	user := createTestUser(t, repos, models.RoleAdmin)
+
+	resp := testRequest(t, app, http.MethodGet, "/admin/posts", "", nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/login?next=/admin/posts", resp.Header.Get(fiber.HeaderLocation))
+
+	resp = testRequest(t, app, http.MethodGet, "/admin/posts", login(t, app, user), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", readBody(t, resp))
+}
+
+func TestAuthRequired_TwoFactor(t *testing.T) {
+	app, repos := newTestApp(t)
+	user := createTestUser(t, repos, models.RoleAdmin)
+	user.TOTPEnabled = true
+	require.NoError(t, repos.Users.Update(user))
+
+	// The password is not enough to use the admin before the code is entered
+	cookie := sessionCookie(t, app, fmt.Sprintf("/test/password/%d", user.ID))
+	resp := testRequest(t, app, http.MethodGet, "/admin/posts", cookie, nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/login?next=/admin/posts", resp.Header.Get(fiber.HeaderLocation))
+
+	// The form of the code is protected against cross-site requests
+	resp = testRequest(t, app, http.MethodPost, "/login/2fa", cookie, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "csrf_error", readBody(t, resp))
+
+	resp = testRequest(t, app, http.MethodGet, "/admin/posts", login(t, app, user), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -81,7 +81,7 @@ func LoadUserData(repos *repository.Repositories, sessionStore *session.Store) f
 
 		if err == nil {
 			c.Locals("user", user)
-			err = c.Bind(fiber.Map{"user": user})
+			err = c.Bind(fiber.Map{"user": user, "currentUser": user})
 
 			if err != nil {
 				fmt.Printf("Error binding user into context: %v\n", err)
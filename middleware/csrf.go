@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/session"
+)
+
+const (
+	// CSRFSessionKey is the session key holding the CSRF token
+	CSRFSessionKey = "csrfToken"
+	// CSRFFormField is the name of the hidden form field holding the CSRF token
+	CSRFFormField = "_csrf"
+	// CSRFHeader is the header holding the CSRF token in requests made by the admin scripts
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRF protects the routes changing data against cross-site request forgery.
+// Each session gets a random token, bound to the templates as "csrfToken", which
+// must be sent back in the _csrf form field or the X-CSRF-Token header of every
+// request that is not a GET, HEAD or OPTIONS.
+func CSRF(sessionStore *session.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := sessionStore.Get(c)
+		if err != nil {
+			log.Errorf("Failed to load session: %v", err)
+			return CSRFFailed(c)
+		}
+
+		token, _ := sess.Get(CSRFSessionKey).(string)
+		if token == "" {
+			if token, err = utils.GenerateCSRFToken(); err != nil {
+				log.Errorf("Failed to generate CSRF token: %v", err)
+				return CSRFFailed(c)
+			}
+			sess.Set(CSRFSessionKey, token)
+			if err := sess.Save(); err != nil {
+				log.Errorf("Failed to save session: %v", err)
+				return CSRFFailed(c)
+			}
+		}
+
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		default:
+			sent := c.Get(CSRFHeader)
+			if sent == "" {
+				sent = c.FormValue(CSRFFormField)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return CSRFFailed(c)
+			}
+		}
+
+		if err := c.Bind(fiber.Map{"csrfToken": token}); err != nil {
+			log.Errorf("Error binding CSRF token into context: %v", err)
+		}
+
+		return c.Next()
+	}
+}
+
+// CSRFFailed answers with a 403 when the CSRF token is missing or invalid,
+// as JSON for the requests made by the admin scripts
+func CSRFFailed(c *fiber.Ctx) error {
+	message := "Invalid or expired form token, please reload the page and try again"
+
+	if strings.HasPrefix(c.Path(), "/admin/api") ||
+		c.Method() == fiber.MethodDelete ||
+		c.Get(CSRFHeader) != "" ||
+		strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": message})
+	}
+
+	return c.Status(http.StatusForbidden).Render("csrf_error", fiber.Map{
+		"error": message,
+		"back":  safeReferer(c),
+	})
+}
+
+// safeReferer returns the path of the referring page when it belongs to this site
+func safeReferer(c *fiber.Ctx) string {
+	referer := c.Get(fiber.HeaderReferer)
+	prefix := c.BaseURL() + "/"
+	if !strings.HasPrefix(referer, prefix) {
+		return "/admin"
+	}
+	return "/" + strings.TrimPrefix(referer, prefix)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRF(t *testing.T) {
+	app, repos := newTestApp(t)
+	cookie := login(t, app, createTestUser(t, repos, models.RoleAdmin))
+
+	resp := testRequest(t, app, http.MethodGet, "/admin/csrf", cookie, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	token := readBody(t, resp)
+	require.NotEmpty(t, token)
+
+	// Missing token
+	resp = testRequest(t, app, http.MethodPost, "/admin/posts", cookie, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "csrf_error", readBody(t, resp))
+
+	// Invalid token, answered as JSON to the admin scripts
+	resp = testRequest(t, app, http.MethodPost, "/admin/posts", cookie, map[string]string{CSRFHeader: "invalid"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `"error":"Invalid or expired form token`)
+	resp = testRequest(t, app, http.MethodDelete, "/admin/users/1", cookie, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+
+	// The token of another session
+	other := login(t, app, createTestUser(t, repos, models.RoleEditor))
+	resp = testRequest(t, app, http.MethodPost, "/admin/posts", other, map[string]string{CSRFHeader: token})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = testRequest(t, app, http.MethodPost, "/admin/posts", cookie, map[string]string{CSRFHeader: token})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = testRequest(t, app, http.MethodDelete, "/admin/users/1", cookie, map[string]string{CSRFHeader: token})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Sent by forms
+	req := httptest.NewRequest(http.MethodPost, "/admin/posts", strings.NewReader(CSRFFormField+"="+token))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: cookie})
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+func generateFeedHTML(settings *models.Settings, tagSlug string) string {
+	title := html.EscapeString(settings.Title)
+	links := fmt.Sprintf(`<link rel="alternate" type="application/rss+xml" title="%s RSS" href="/feed.xml"><link rel="alternate" type="application/atom+xml" title="%s Atom" href="/atom.xml">`,
+		title,
+		title,
+	)
+
+	if tagSlug != "" {
+		slug := html.EscapeString(tagSlug)
+		links += fmt.Sprintf(`<link rel="alternate" type="application/rss+xml" title="%s #%s RSS" href="/tags/%s/feed.xml">`,
+			title,
+			slug,
+			slug,
+		)
+	}
+
+	return links
+}
+
+// InjectFeedLinks middleware injects feed autodiscovery links into templates
+func InjectFeedLinks() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsAdminPath(c) || c.Accepts("text/html", "application/xhtml+xml") == "" {
+			return c.Next()
+		}
+
+		settings, ok := c.Locals("settings").(*models.Settings)
+		if !ok {
+			return c.Next()
+		}
+
+		var tagSlug string
+		if rest, found := strings.CutPrefix(c.Path(), "/tags/"); found && !strings.Contains(rest, "/") {
+			tagSlug = rest
+		}
+
+		err := c.Bind(fiber.Map{
+			"feedHTML": generateFeedHTML(settings, tagSlug),
+		})
+
+		if err != nil {
+			log.Warnf("Error binding feed HTML into context: %v", err)
+		}
+		return c.Next()
+	}
+}
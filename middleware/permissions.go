@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission ensures that the logged in user's role grants the permission
+func RequirePermission(permission models.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if user, ok := c.Locals("user").(*models.User); ok && user.Can(permission) {
+			return c.Next()
+		}
+		return Forbidden(c)
+	}
+}
+
+// Forbidden answers with a 403, as JSON for the REST API and the requests made by the admin scripts
+func Forbidden(c *fiber.Ctx) error {
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return APIError(c, http.StatusForbidden, "You do not have permission to perform this action")
+	}
+	if strings.HasPrefix(c.Path(), "/admin/api") || c.Method() == fiber.MethodDelete {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "You do not have permission to perform this action",
+		})
+	}
+	return c.Status(http.StatusForbidden).Render("admin_403", fiber.Map{})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	app, repos := newTestApp(t)
+
+	routes := []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/admin/posts"},
+		{http.MethodGet, "/admin/pages"},
+		{http.MethodDelete, "/admin/users/1"},
+		{http.MethodGet, "/admin/settings"},
+	}
+	allowed := map[string][]bool{
+		models.RoleAdmin:       {true, true, true, true},
+		models.RoleEditor:      {true, true, false, false},
+		models.RoleAuthor:      {true, false, false, false},
+		models.RoleContributor: {true, false, false, false},
+	}
+
+	for role, allowed := range allowed {
+		t.Run(role, func(t *testing.T) {
+			cookie := login(t, app, createTestUser(t, repos, role))
+			token := readBody(t, testRequest(t, app, http.MethodGet, "/admin/csrf", cookie, nil))
+
+			for i, route := range routes {
+				resp := testRequest(t, app, route.method, route.target, cookie, map[string]string{CSRFHeader: token})
+				if allowed[i] {
+					assert.Equal(t, http.StatusOK, resp.StatusCode, route.target)
+					continue
+				}
+				assert.Equal(t, http.StatusForbidden, resp.StatusCode, route.target)
+				// Pages show the error, the admin scripts get it as JSON
+				if route.method == http.MethodDelete {
+					assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType), route.target)
+				} else {
+					assert.Equal(t, "admin_403", readBody(t, resp), route.target)
+				}
+			}
+		})
+	}
+}
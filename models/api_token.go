@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API token scopes, granting read or write access to a resource of the REST API
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopePagesRead  = "pages:read"
+	ScopePagesWrite = "pages:write"
+	ScopeTagsRead   = "tags:read"
+	ScopeTagsWrite  = "tags:write"
+	ScopeMediaRead  = "media:read"
+	ScopeMediaWrite = "media:write"
+	ScopeMenusRead  = "menus:read"
+	ScopeMenusWrite = "menus:write"
+)
+
+// APIScopes lists the available API token scopes
+var APIScopes = []string{
+	ScopePostsRead, ScopePostsWrite,
+	ScopePagesRead, ScopePagesWrite,
+	ScopeTagsRead, ScopeTagsWrite,
+	ScopeMediaRead, ScopeMediaWrite,
+	ScopeMenusRead, ScopeMenusWrite,
+}
+
+// APIToken is a personal access token used to authenticate against the REST API.
+// Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;not null"`
+	User       *User  `gorm:"foreignKey:UserID"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"` // first characters of the token, to help users identify it
+	TokenHash  string `gorm:"size:255;uniqueIndex;not null"`
+	Scopes     string `gorm:"type:text"` // comma separated scopes
+	LastUsedAt *time.Time
+}
+
+// HasScope returns true if the token was granted the scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeList returns the scopes granted to the token
+func (t *APIToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// IsValidAPIScope returns true if the scope exists
+func IsValidAPIScope(scope string) bool {
+	for _, s := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
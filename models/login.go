@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Login attempt results recorded in the audit log
+const (
+	LoginResultSuccess            = "success"
+	LoginResultPasswordAccepted   = "password_accepted" // waiting for the two-factor code
+	LoginResultInvalidCredentials = "invalid_credentials"
+	LoginResultInvalidCode        = "invalid_code"
+	LoginResultThrottled          = "throttled"
+)
+
+// LoginAttempt is an entry of the login audit log
+type LoginAttempt struct {
+	gorm.Model
+	Email     string `gorm:"index"`
+	UserID    *uint  `gorm:"index"`
+	User      *User  `gorm:"foreignKey:UserID"`
+	IP        string `gorm:"index"`
+	UserAgent string
+	Success   bool   `gorm:"not null;default:false"`
+	Result    string `gorm:"not null"`
+}
+
+// LoginPolicy configures how failed logins are throttled
+type LoginPolicy struct {
+	MaxFailures int           // failures before the key is locked out
+	Window      time.Duration // failures older than this are forgotten
+	BackoffBase time.Duration // delay after the first failure, doubled after each failure
+	Lockout     time.Duration // duration of the lockout, also the maximum backoff delay
+}
+
+// LoginThrottle counts the recent failed logins of an IP address or an account.
+// Keys are "ip:<address>" or "account:<email>".
+type LoginThrottle struct {
+	gorm.Model
+	Key           string `gorm:"column:throttle_key;size:255;uniqueIndex;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	BlockedUntil  time.Time `gorm:"index"`
+}
+
+// RetryAfter returns how long the key remains blocked
+func (t *LoginThrottle) RetryAfter(now time.Time) time.Duration {
+	if t.BlockedUntil.After(now) {
+		return t.BlockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// IsLockedOut returns true if the key reached the maximum number of failures
+// and is still blocked
+func (t *LoginThrottle) IsLockedOut(now time.Time, policy LoginPolicy) bool {
+	return t.Failures >= policy.MaxFailures && t.RetryAfter(now) > 0
+}
+
+// RecordFailure counts a failed login and blocks the key with an exponential
+// backoff, or for the lockout duration once the maximum number of failures is reached
+func (t *LoginThrottle) RecordFailure(now time.Time, policy LoginPolicy) {
+	if now.Sub(t.LastFailureAt) > policy.Window && !t.BlockedUntil.After(now) {
+		t.Failures = 0
+	}
+
+	t.Failures++
+	t.LastFailureAt = now
+
+	if t.Failures >= policy.MaxFailures {
+		t.BlockedUntil = now.Add(policy.Lockout)
+		return
+	}
+
+	delay := policy.BackoffBase
+	for i := 1; i < t.Failures && delay < policy.Lockout; i++ {
+		delay *= 2
+	}
+	t.BlockedUntil = now.Add(min(delay, policy.Lockout))
+}
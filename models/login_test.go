@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottle_RecordFailure(t *testing.T) {
+	policy := LoginPolicy{
+		MaxFailures: 4,
+		Window:      15 * time.Minute,
+		BackoffBase: time.Second,
+		Lockout:     10 * time.Minute,
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	throttle := &LoginThrottle{Key: "account:jane@example.com"}
+
+	assert.Zero(t, throttle.RetryAfter(now))
+
+	// Exponential backoff
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		throttle.RecordFailure(now, policy)
+		assert.Equal(t, i+1, throttle.Failures)
+		assert.Equal(t, want, throttle.RetryAfter(now))
+		assert.False(t, throttle.IsLockedOut(now, policy))
+		now = now.Add(want)
+	}
+
+	// Lockout once the maximum number of failures is reached
+	throttle.RecordFailure(now, policy)
+	assert.Equal(t, 10*time.Minute, throttle.RetryAfter(now))
+	assert.True(t, throttle.IsLockedOut(now, policy))
+
+	// The lockout expires
+	now = now.Add(10 * time.Minute)
+	assert.Zero(t, throttle.RetryAfter(now))
+	assert.False(t, throttle.IsLockedOut(now, policy))
+
+	// Failures outside of the window are forgotten
+	now = now.Add(policy.Window + time.Second)
+	throttle.RecordFailure(now, policy)
+	assert.Equal(t, 1, throttle.Failures)
+	assert.Equal(t, time.Second, throttle.RetryAfter(now))
+}
+
+func TestLoginThrottle_BackoffIsCapped(t *testing.T) {
+	policy := LoginPolicy{MaxFailures: 100, Window: time.Hour, BackoffBase: time.Second, Lockout: time.Minute}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	throttle := &LoginThrottle{Key: "ip:127.0.0.1"}
+
+	for i := 0; i < 50; i++ {
+		throttle.RecordFailure(now, policy)
+	}
+
+	assert.Equal(t, time.Minute, throttle.RetryAfter(now))
+}
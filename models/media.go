@@ -3,11 +3,15 @@ package models
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"io"
 	"mime"
+	"net/url"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/captain-corp/captain/imaging"
 	"github.com/captain-corp/captain/storage"
 
 	"gorm.io/gorm"
@@ -16,12 +20,94 @@ import (
 // Media represents a media file in the system
 type Media struct {
 	gorm.Model
-	Name        string    `gorm:"not null" form:"name"`
-	Path        string    `gorm:"not null;unique" form:"path"`
-	MimeType    string    `gorm:"not null" form:"mimeType"`
-	Size        int64     `gorm:"not null" form:"size"`
-	Description string    `gorm:"type:text" form:"description"`
-	File        io.Reader `gorm:"-" form:"-"`
+	Name        string     `gorm:"not null" form:"name"`
+	Path        string     `gorm:"size:255;not null;index" form:"path"` // shared by the media with the same content
+	MimeType    string     `gorm:"not null" form:"mimeType"`
+	Size        int64      `gorm:"not null" form:"size"`
+	Description string     `gorm:"type:text" form:"description"`
+	AltText     string     `gorm:"type:text" form:"altText"`
+	Caption     string     `gorm:"type:text" form:"caption"`
+	Folder      string     `gorm:"size:255;not null;default:'';index" form:"folder"` // slash-separated, empty for the root
+	Width       int        `gorm:"not null;default:0" form:"-"`                      // of images, 0 when unknown
+	Height      int        `gorm:"not null;default:0" form:"-"`
+	TakenAt     *time.Time `form:"-"`                                          // capture date of photos, from their EXIF metadata
+	Hash        string     `gorm:"size:64;not null;default:'';index" form:"-"` // hex SHA-256 of the content, empty for media stored before hashing
+	File        io.Reader  `gorm:"-" form:"-"`
+}
+
+// Media types the media library can be filtered by
+const (
+	MediaTypeImage    = "image"
+	MediaTypeVideo    = "video"
+	MediaTypeAudio    = "audio"
+	MediaTypeDocument = "document" // any other file
+)
+
+// MediaFilter narrows down the media library
+type MediaFilter struct {
+	Query  string // in the name, description, alt text or caption
+	Folder string // the folder and its subfolders
+	Type   string // one of the media types
+}
+
+// MediaUsage lists the content referencing a media
+type MediaUsage struct {
+	Posts []*Post
+	Pages []*Page
+	Logo  bool // the media is the site logo
+}
+
+// InUse returns whether the media is referenced, and unsafe to delete
+func (u *MediaUsage) InUse() bool {
+	return len(u.Posts) > 0 || len(u.Pages) > 0 || u.Logo
+}
+
+// MediaUpload is an upload in progress, sent straight to the storage provider or
+// in chunks through Captain, which becomes a media once complete
+type MediaUpload struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	Filename  string    `gorm:"size:255;not null"`
+	MimeType  string    `gorm:"size:255;not null"`
+	Size      int64     `gorm:"not null"`
+	Received  int64     `gorm:"not null;default:0"`           // bytes of the chunks received
+	Direct    bool      `gorm:"not null;default:false"`       // sent straight to the storage provider
+	Path      string    `gorm:"size:255;not null;default:''"` // of the file of direct uploads
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// MediaVariantsDir is the directory of the storage provider holding the variants
+const MediaVariantsDir = "_variants"
+
+// MediaVariant is a resized or converted copy of an image, generated on demand
+// and kept in the storage provider
+type MediaVariant struct {
+	gorm.Model
+	MediaID  uint   `gorm:"not null;uniqueIndex:idx_media_variants_key"`
+	Key      string `gorm:"column:variant_key;size:64;not null;uniqueIndex:idx_media_variants_key"`
+	Path     string `gorm:"size:255;not null"`
+	MimeType string `gorm:"not null"`
+	Size     int64  `gorm:"not null"`
+	Width    int    `gorm:"not null"`
+	Height   int    `gorm:"not null"`
+}
+
+// BeforeSave hook to normalize the folder
+func (m *Media) BeforeSave(_ *gorm.DB) error {
+	m.Folder = NormalizeFolder(m.Folder)
+	return nil
+}
+
+// NormalizeFolder returns a folder name without leading, trailing or repeated
+// slashes, nor relative parts
+func NormalizeFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = strings.TrimSpace(part); part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
 }
 
 // BeforeCreate hook to ensure media has a mime type
@@ -37,26 +123,102 @@ func (m *Media) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
-// GetHTMLTag returns the HTML tag for the media
-func (m *Media) GetHTMLTag() string {
+// Alt returns the alternative text of an image, its name when it has none
+func (m *Media) Alt() string {
+	if m.AltText != "" {
+		return m.AltText
+	}
+	return m.Name
+}
+
+// GetHTMLTag returns the HTML tag for the media. Images list their variants of
+// the given widths in a srcset, and are shown in a figure with their caption.
+func (m *Media) GetHTMLTag(widths []int) string {
 	// If it's an image, return img tag
 	if isImage := strings.HasPrefix(m.MimeType, "image/"); isImage {
-		return fmt.Sprintf(`<img src="/media/%s" alt="%s">`, m.Path, m.Name)
+		tag := fmt.Sprintf(`<img src="/media/%s" alt="%s">`, m.Path, html.EscapeString(m.Alt()))
+		if srcset := m.SrcSet(widths); srcset != "" {
+			tag = fmt.Sprintf(`<img src="/media/%s" srcset="%s" sizes="%s" width="%d" height="%d" alt="%s">`,
+				m.Path, srcset, m.Sizes(), m.Width, m.Height, html.EscapeString(m.Alt()))
+		}
+		if m.Caption != "" {
+			tag = fmt.Sprintf(`<figure>%s<figcaption>%s</figcaption></figure>`, tag, html.EscapeString(m.Caption))
+		}
+		return tag
 	}
 	// Otherwise return an anchor tag
-	return fmt.Sprintf(`<a href="/media/%s">%s</a>`, m.Path, m.Name)
+	return fmt.Sprintf(`<a href="/media/%s">%s</a>`, m.Path, html.EscapeString(m.Name))
 }
 
 // GetMarkdownTag returns the markdown tag for the media
 func (m *Media) GetMarkdownTag() string {
 	// If it's an image, return image markdown
 	if isImage := strings.HasPrefix(m.MimeType, "image/"); isImage {
-		return fmt.Sprintf("![%s](/media/%s)", m.Name, m.Path)
+		return fmt.Sprintf("![%s](/media/%s)", m.Alt(), m.Path)
 	}
 	// Otherwise return a link
 	return fmt.Sprintf("[%s](/media/%s)", m.Name, m.Path)
 }
 
+// SrcSet returns the srcset of the variants of an image narrower than the
+// original, or an empty string for media that cannot be resized or whose width
+// is unknown
+func (m *Media) SrcSet(widths []int) string {
+	if !imaging.Resizable(m.MimeType) || m.Width == 0 {
+		return ""
+	}
+
+	var candidates []string
+	for _, width := range widths {
+		if width < m.Width {
+			candidates = append(candidates, fmt.Sprintf("/media/%s?w=%d %dw", m.Path, width, width))
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	candidates = append(candidates, fmt.Sprintf("/media/%s %dw", m.Path, m.Width))
+	return strings.Join(candidates, ", ")
+}
+
+// Sizes returns the sizes attribute going with SrcSet, for an image displayed
+// at the width of the page up to its own width
+func (m *Media) Sizes() string {
+	return fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", m.Width, m.Width)
+}
+
+// References returns the URLs content links to the media with
+func (m *Media) References() []string {
+	references := []string{"/media/" + m.Path}
+	if escaped := (&url.URL{Path: m.Path}).EscapedPath(); escaped != m.Path {
+		references = append(references, "/media/"+escaped)
+	}
+	return references
+}
+
+// ReferencedIn returns whether content links to the media, ignoring the URLs
+// of other files starting with its path
+func (m *Media) ReferencedIn(content string) bool {
+	for _, reference := range m.References() {
+		for rest := content; ; {
+			i := strings.Index(rest, reference)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(reference):]
+			if rest == "" || !isPathChar(rest[0]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPathChar returns whether a character continues the path of a URL
+func isPathChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._~%/", c) >= 0
+}
+
 func (m *Media) FetchFile(storage storage.Provider) error {
 	file, err := storage.Get(m.Path)
 	if err != nil {
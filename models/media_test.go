@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedia_SrcSet(t *testing.T) {
+	widths := []int{320, 640, 1280}
+
+	tests := []struct {
+		name  string
+		media Media
+		want  string
+	}{
+		{"unknown width", Media{Path: "a.jpg", MimeType: "image/jpeg"}, ""},
+		{"svg", Media{Path: "a.svg", MimeType: "image/svg+xml", Width: 800}, ""},
+		{"smaller than all widths", Media{Path: "a.png", MimeType: "image/png", Width: 320}, ""},
+		{
+			"jpeg",
+			Media{Path: "a.jpg", MimeType: "image/jpeg", Width: 1000},
+			"/media/a.jpg?w=320 320w, /media/a.jpg?w=640 640w, /media/a.jpg 1000w",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.media.SrcSet(widths))
+		})
+	}
+}
+
+func TestMedia_ReferencedIn(t *testing.T) {
+	media := Media{Path: "photos/my photo.jpg"}
+
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"![Photo](/media/photos/my photo.jpg)", true},
+		{"![Photo](/media/photos/my%20photo.jpg)", true},
+		{`<img src="/media/photos/my%20photo.jpg?w=320">`, true},
+		{"/media/photos/my photo.jpg", true},
+		{"![Photo](/media/photos/my photo.jpg.bak)", false},
+		{"![Photo](/media/photos/my photo.jpg2) /media/photos/my photo.jpg", true},
+		{"![Photo](/media/photos/other.jpg)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			assert.Equal(t, tt.want, media.ReferencedIn(tt.content))
+		})
+	}
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	assert.Equal(t, "", NormalizeFolder(""))
+	assert.Equal(t, "", NormalizeFolder(" / "))
+	assert.Equal(t, "travel/2024", NormalizeFolder("/travel//2024/"))
+	assert.Equal(t, "travel/2024", NormalizeFolder("travel/../2024/./"))
+	assert.Equal(t, "travel/new york", NormalizeFolder(" travel / new york "))
+}
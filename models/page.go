@@ -9,12 +9,22 @@ import (
 type Page struct {
 	gorm.Model
 	Title       string `gorm:"not null" form:"title"`
-	Slug        string `gorm:"uniqueIndex;not null" form:"slug"`
+	Slug        string `gorm:"size:255;uniqueIndex;not null" form:"slug"`
 	Content     string `gorm:"not null" form:"content"`
 	ContentType string `gorm:"not null;default:'markdown' " form:"contentType"` // 'markdown' or 'html'
 	Visible     bool   `gorm:"not null" form:"visible"`
 }
 
+// AfterSave hook to keep the full-text search index in sync
+func (p *Page) AfterSave(tx *gorm.DB) error {
+	return indexSearchDocument(tx, SearchKindPage, p.ID, p.Title, p.Content)
+}
+
+// AfterDelete hook to remove the page from the full-text search index
+func (p *Page) AfterDelete(tx *gorm.DB) error {
+	return removeSearchDocument(tx, SearchKindPage, p.ID)
+}
+
 func (p *Page) ToJSON() string {
 	buff, err := json.Marshal(map[string]interface{}{
 		"id":          p.ID,
@@ -10,7 +10,7 @@ import (
 type Post struct {
 	gorm.Model
 	Title                     string    `gorm:"not null"`
-	Slug                      string    `gorm:"uniqueIndex;not null"`
+	Slug                      string    `gorm:"size:255;uniqueIndex;not null"`
 	Content                   string    `gorm:"not null"`
 	PublishedAt               time.Time `gorm:"not null"`
 	PublishedAtUTC            time.Time `gorm:"not null"`
@@ -23,12 +23,31 @@ type Post struct {
 	Author                    *User     `gorm:"foreignKey:AuthorID" form:"author"`
 }
 
+// AfterSave hook to keep the full-text search index in sync
+func (p *Post) AfterSave(tx *gorm.DB) error {
+	content := p.Content
+	if p.Excerpt != nil && *p.Excerpt != "" {
+		content = *p.Excerpt + "\n\n" + content
+	}
+	return indexSearchDocument(tx, SearchKindPost, p.ID, p.Title, content)
+}
+
+// AfterDelete hook to remove the post from the full-text search index
+func (p *Post) AfterDelete(tx *gorm.DB) error {
+	return removeSearchDocument(tx, SearchKindPost, p.ID)
+}
+
 // IsScheduled returns true if the post is scheduled for future publication
 func (p *Post) IsScheduled() bool {
 	now := time.Now().UTC()
 	return p.Visible && p.PublishedAtUTC.After(now)
 }
 
+// IsPublished returns true if the post is visible and its publication date has passed
+func (p *Post) IsPublished() bool {
+	return p.Visible && !p.IsScheduled()
+}
+
 func (p *Post) ToJSON() string {
 	tags := make([]string, 0, len(p.Tags))
 	for _, tag := range p.Tags {
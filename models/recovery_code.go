@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time code letting a user log in without their
+// authenticator app. Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"not null"`
+	UsedAt   *time.Time
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // PostRepository defines the interface for post operations
 type PostRepository interface {
 	Create(post *Post) error
@@ -14,6 +16,8 @@ type PostRepository interface {
 	FindVisibleByTag(tagID uint, page, perPage int) ([]Post, int64, error)
 	FindAll() ([]*Post, error)
 	FindRecent(limit int) ([]*Post, error)
+	FindUpdatedSince(since time.Time) ([]*Post, error)
+	FindDeletedSince(since time.Time) ([]*Post, error)
 	AssociateTags(post *Post, tags []string) error
 	CountByAuthor(user *User) (int64, error)
 	CountByTag(tagID uint) (int64, error)
@@ -28,6 +32,7 @@ type TagRepository interface {
 	FindBySlug(slug string) (*Tag, error)
 	FindByName(name string) (*Tag, error)
 	FindAll() ([]*Tag, error)
+	FindVisible() ([]*Tag, error)
 	FindPostsAndCount() ([]struct {
 		Tag
 		PostCount int64
@@ -44,6 +49,7 @@ type UserRepository interface {
 	FindAll() ([]*User, error)
 	CountByEmail(email string) (int64, error)
 	CountAll() (int64, error)
+	CountByRole(role string) (int64, error)
 }
 
 // PageRepository defines the interface for page operations
@@ -54,9 +60,28 @@ type PageRepository interface {
 	FindByID(id uint) (*Page, error)
 	FindBySlug(slug string) (*Page, error)
 	FindAll() ([]*Page, error)
+	FindAllVisible() ([]*Page, error)
+	FindUpdatedSince(since time.Time) ([]*Page, error)
+	FindDeletedSince(since time.Time) ([]*Page, error)
 	CountRelatedMenuItems(id uint, count *int64) error
 }
 
+// PostRevisionRepository defines the interface for post revision operations
+type PostRevisionRepository interface {
+	Create(revision *PostRevision) error
+	FindByID(id uint) (*PostRevision, error)
+	FindByPost(postID uint) ([]*PostRevision, error)
+	CountByPost(postID uint) (int64, error)
+}
+
+// PageRevisionRepository defines the interface for page revision operations
+type PageRevisionRepository interface {
+	Create(revision *PageRevision) error
+	FindByID(id uint) (*PageRevision, error)
+	FindByPage(pageID uint) ([]*PageRevision, error)
+	CountByPage(pageID uint) (int64, error)
+}
+
 // MenuItemRepository defines the interface for menu item operations
 type MenuItemRepository interface {
 	Create(item *MenuItem) error
@@ -78,14 +103,74 @@ type SettingsRepository interface {
 	Create(settings Settings) error
 }
 
+// SearchRepository defines the interface for full-text search operations
+type SearchRepository interface {
+	Search(query string, includeHidden bool, page, perPage int) ([]SearchResult, int64, error)
+}
+
+// APITokenRepository defines the interface for API token operations
+type APITokenRepository interface {
+	Create(token *APIToken) error
+	Delete(token *APIToken) error
+	FindByID(id uint) (*APIToken, error)
+	FindByHash(hash string) (*APIToken, error)
+	FindByUser(userID uint) ([]*APIToken, error)
+	TouchLastUsed(token *APIToken, at time.Time) error
+}
+
+// RecoveryCodeRepository defines the interface for two-factor recovery code operations
+type RecoveryCodeRepository interface {
+	ReplaceForUser(userID uint, hashes []string) error
+	DeleteByUser(userID uint) error
+	Use(userID uint, hash string) (bool, error)
+	CountUnused(userID uint) (int64, error)
+}
+
+// LoginRepository defines the interface for login audit and throttling operations
+type LoginRepository interface {
+	RecordAttempt(attempt *LoginAttempt) error
+	FindAttemptsPaginated(page, perPage int) ([]*LoginAttempt, int64, error)
+	FindThrottle(key string) (*LoginThrottle, error)
+	SaveThrottle(throttle *LoginThrottle) error
+	DeleteThrottle(key string) error
+	FindBlockedThrottles(now time.Time) ([]*LoginThrottle, error)
+}
+
 // MediaRepository defines the interface for media operations
 type MediaRepository interface {
 	Create(media *Media) error
 	Update(media *Media) error
 	Delete(media *Media) error
 	FindByPath(path string) (*Media, error)
+	FindByHash(hash string) (*Media, error)
+	CountByPath(path string) (int64, error)
 	FindByID(id uint) (*Media, error)
 	FindByFilename(filename string) (*Media, error)
 	FindAll() ([]*Media, error)
+	Search(filter MediaFilter) ([]*Media, error)
+	FindFolders() ([]string, error)
+	FindUsage(media *Media) (*MediaUsage, error)
+	FindUnused(media []*Media) ([]*Media, error)
 	SaveAll(media []*Media) error
+	UpdateDimensions(media *Media) error
+	UpdateHash(media *Media) error
+}
+
+// MediaUploadRepository defines the interface for the uploads in progress
+type MediaUploadRepository interface {
+	Create(upload *MediaUpload) error
+	Update(upload *MediaUpload) error
+	Delete(upload *MediaUpload) error
+	FindByID(id uint) (*MediaUpload, error)
+	FindExpired(now time.Time) ([]*MediaUpload, error)
+	FindDirect() ([]*MediaUpload, error)
+}
+
+// MediaVariantRepository defines the interface for media variant operations
+type MediaVariantRepository interface {
+	Create(variant *MediaVariant) error
+	Delete(variant *MediaVariant) error
+	FindByKey(mediaID uint, key string) (*MediaVariant, error)
+	FindByMedia(mediaID uint) ([]*MediaVariant, error)
+	FindAll() ([]*MediaVariant, error)
 }
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PostRevision is a snapshot of a post taken each time it is saved. The author
+// of the post is not part of the snapshot and is kept when a revision is restored.
+type PostRevision struct {
+	gorm.Model
+	PostID   uint   `gorm:"index;not null"`
+	Title    string `gorm:"not null"`
+	Content  string `gorm:"not null"`
+	Excerpt  string `gorm:"type:text"`
+	Tags     string `gorm:"type:text"` // comma separated tag names
+	AuthorID uint   // user who saved the revision
+	Author   *User  `gorm:"foreignKey:AuthorID"`
+}
+
+// NewPostRevision creates a revision from the current state of a post
+func NewPostRevision(post *Post, tags []string, authorID uint) *PostRevision {
+	revision := &PostRevision{
+		PostID:   post.ID,
+		Title:    post.Title,
+		Content:  post.Content,
+		Tags:     joinTagNames(tags),
+		AuthorID: authorID,
+	}
+	if post.Excerpt != nil {
+		revision.Excerpt = *post.Excerpt
+	}
+	return revision
+}
+
+// TagNames returns the tag names captured in the revision
+func (r *PostRevision) TagNames() []string {
+	if r.Tags == "" {
+		return []string{}
+	}
+	return strings.Split(r.Tags, ",")
+}
+
+// PageRevision is a snapshot of a page taken each time it is saved
+type PageRevision struct {
+	gorm.Model
+	PageID      uint   `gorm:"index;not null"`
+	Title       string `gorm:"not null"`
+	Content     string `gorm:"not null"`
+	ContentType string `gorm:"not null;default:'markdown'"`
+	AuthorID    uint   // user who saved the revision
+	Author      *User  `gorm:"foreignKey:AuthorID"`
+}
+
+// NewPageRevision creates a revision from the current state of a page
+func NewPageRevision(page *Page, authorID uint) *PageRevision {
+	return &PageRevision{
+		PageID:      page.ID,
+		Title:       page.Title,
+		Content:     page.Content,
+		ContentType: page.ContentType,
+		AuthorID:    authorID,
+	}
+}
+
+// PostTagNames returns the names of the tags associated with a post
+func PostTagNames(post *Post) []string {
+	names := make([]string, 0, len(post.Tags))
+	for _, tag := range post.Tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func joinTagNames(tags []string) string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", " "))
+		if tag != "" {
+			names = append(names, tag)
+		}
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,63 @@
+package models
+
+// User roles, from the most to the least privileged
+const (
+	RoleAdmin       = "admin"
+	RoleEditor      = "editor"
+	RoleAuthor      = "author"
+	RoleContributor = "contributor"
+)
+
+// Roles lists the available roles, from the most to the least privileged
+var Roles = []string{RoleAdmin, RoleEditor, RoleAuthor, RoleContributor}
+
+// Permission is an action a user may be allowed to perform in the admin
+type Permission string
+
+const (
+	PermissionEditOwnPosts    Permission = "posts.edit_own"
+	PermissionPublishPosts    Permission = "posts.publish"
+	PermissionEditOthersPosts Permission = "posts.edit_others"
+	PermissionManagePages     Permission = "pages.manage"
+	PermissionManageTags      Permission = "tags.manage"
+	PermissionUploadMedia     Permission = "media.upload"
+	PermissionManageMedia     Permission = "media.manage"
+	PermissionManageMenus     Permission = "menus.manage"
+	PermissionManageUsers     Permission = "users.manage"
+	PermissionManageSettings  Permission = "settings.manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermissionEditOwnPosts, PermissionPublishPosts, PermissionEditOthersPosts,
+		PermissionManagePages, PermissionManageTags, PermissionUploadMedia, PermissionManageMedia,
+		PermissionManageMenus, PermissionManageUsers, PermissionManageSettings,
+	},
+	RoleEditor: {
+		PermissionEditOwnPosts, PermissionPublishPosts, PermissionEditOthersPosts,
+		PermissionManagePages, PermissionManageTags, PermissionUploadMedia, PermissionManageMedia,
+		PermissionManageMenus,
+	},
+	RoleAuthor: {
+		PermissionEditOwnPosts, PermissionPublishPosts, PermissionUploadMedia,
+	},
+	RoleContributor: {
+		PermissionEditOwnPosts,
+	},
+}
+
+// IsValidRole returns true if the role exists
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission returns true if the role grants the permission
+func RoleHasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchKindPost = "post"
+	SearchKindPage = "page"
+)
+
+// SearchResult represents a post or page matching a full-text search query
+type SearchResult struct {
+	Kind         string
+	RefID        uint
+	Title        string
+	Slug         string
+	TitleSnippet string
+	Snippet      string
+	Visible      bool
+	Scheduled    bool
+	Rank         float64 `gorm:"column:search_rank"` // "rank" is reserved in MySQL
+}
+
+// URL returns the public URL of the search result
+func (r *SearchResult) URL() string {
+	if r.Kind == SearchKindPage {
+		return "/pages/" + r.Slug
+	}
+	return "/posts/" + r.Slug
+}
+
+// EditURL returns the admin URL used to edit the search result
+func (r *SearchResult) EditURL() string {
+	if r.Kind == SearchKindPage {
+		return fmt.Sprintf("/admin/pages/%d/edit", r.RefID)
+	}
+	return fmt.Sprintf("/admin/posts/%d/edit", r.RefID)
+}
+
+// indexSearchDocument inserts or replaces a document in the full-text search index
+func indexSearchDocument(tx *gorm.DB, kind string, id uint, title, content string) error {
+	if err := removeSearchDocument(tx, kind, id); err != nil {
+		return err
+	}
+
+	return tx.Exec(
+		"INSERT INTO search_index (title, content, kind, ref_id) VALUES (?, ?, ?, ?)",
+		title, content, kind, id,
+	).Error
+}
+
+// removeSearchDocument removes a document from the full-text search index
+func removeSearchDocument(tx *gorm.DB, kind string, id uint) error {
+	return tx.Exec("DELETE FROM search_index WHERE kind = ? AND ref_id = ?", kind, id).Error
+}
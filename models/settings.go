@@ -14,4 +14,7 @@ type Settings struct {
 	PostsPerPage int    `gorm:"not null" form:"posts_per_page"`
 	LogoID       *uint  `gorm:"" form:"logo_id"`
 	UseFavicon   bool   `gorm:"not null;default:false" form:"use_favicon"`
+	RobotsTxt    string `gorm:"type:text" form:"robots_txt"`
+	// PreviewSecret signs the preview links of unpublished posts and pages
+	PreviewSecret string `gorm:"" form:"-" json:"-"`
 }
@@ -9,6 +9,37 @@ type User struct {
 	gorm.Model
 	FirstName string
 	LastName  string
-	Email     string `gorm:"uniqueIndex"`
+	Email     string `gorm:"size:255;uniqueIndex"`
 	Password  string
+	Role      string `gorm:"not null;default:'admin'"`
+
+	// Two-factor authentication
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"` // last accepted time step, to reject replayed codes
+}
+
+// ResetTwoFactor disables two-factor authentication for the user.
+// Recovery codes must be deleted separately.
+func (u *User) ResetTwoFactor() {
+	u.TOTPSecret = ""
+	u.TOTPEnabled = false
+	u.TOTPLastStep = 0
+}
+
+// Can returns true if the user's role grants the permission
+func (u *User) Can(permission Permission) bool {
+	return RoleHasPermission(u.Role, permission)
+}
+
+// CanEditPost returns true if the user may edit or delete the post.
+// Users without the publish permission may only edit their own drafts.
+func (u *User) CanEditPost(post *Post) bool {
+	if u.Can(PermissionEditOthersPosts) {
+		return true
+	}
+	if post.AuthorID != u.ID || !u.Can(PermissionEditOwnPosts) {
+		return false
+	}
+	return !post.Visible || u.Can(PermissionPublishPosts)
 }
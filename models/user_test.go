@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUser_Can(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleAdmin, PermissionManageUsers, true},
+		{RoleAdmin, PermissionManageSettings, true},
+		{RoleEditor, PermissionManageUsers, false},
+		{RoleEditor, PermissionManageMenus, true},
+		{RoleEditor, PermissionEditOthersPosts, true},
+		{RoleAuthor, PermissionPublishPosts, true},
+		{RoleAuthor, PermissionEditOthersPosts, false},
+		{RoleAuthor, PermissionManagePages, false},
+		{RoleContributor, PermissionEditOwnPosts, true},
+		{RoleContributor, PermissionPublishPosts, false},
+		{RoleContributor, PermissionUploadMedia, false},
+		{"", PermissionEditOwnPosts, false},
+		{"unknown", PermissionEditOwnPosts, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+"/"+string(tt.permission), func(t *testing.T) {
+			user := &User{Role: tt.role}
+			assert.Equal(t, tt.want, user.Can(tt.permission))
+		})
+	}
+}
+
+func TestUser_CanEditPost(t *testing.T) {
+	const ownerID, otherID = 1, 2
+
+	tests := []struct {
+		name    string
+		role    string
+		userID  uint
+		visible bool
+		want    bool
+	}{
+		{"Editor edits others' posts", RoleEditor, otherID, true, true},
+		{"Author edits own published post", RoleAuthor, ownerID, true, true},
+		{"Author cannot edit others' posts", RoleAuthor, otherID, false, false},
+		{"Contributor edits own draft", RoleContributor, ownerID, false, true},
+		{"Contributor cannot edit own published post", RoleContributor, ownerID, true, false},
+		{"Contributor cannot edit others' drafts", RoleContributor, otherID, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Role: tt.role}
+			user.ID = tt.userID
+			post := &Post{AuthorID: ownerID, Visible: tt.visible}
+			assert.Equal(t, tt.want, user.CanEditPost(post))
+		})
+	}
+}
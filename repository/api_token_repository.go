@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type apiTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewAPITokenRepository creates a new API token repository
+func NewAPITokenRepository(db *gorm.DB) models.APITokenRepository {
+	return &apiTokenRepository{db: db}
+}
+
+func (r *apiTokenRepository) Create(token *models.APIToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *apiTokenRepository) Delete(token *models.APIToken) error {
+	return r.db.Delete(token).Error
+}
+
+func (r *apiTokenRepository) FindByID(id uint) (*models.APIToken, error) {
+	var token models.APIToken
+	err := r.db.First(&token, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindByHash finds a token by the hash of its value, along with its user
+func (r *apiTokenRepository) FindByHash(hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := r.db.Preload("User").Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *apiTokenRepository) FindByUser(userID uint) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// TouchLastUsed records the time the token was last used
+func (r *apiTokenRepository) TouchLastUsed(token *models.APIToken, at time.Time) error {
+	token.LastUsedAt = &at
+	return r.db.Model(token).UpdateColumn("last_used_at", at).Error
+}
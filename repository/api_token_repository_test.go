@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenRepository_FindByHash(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserRepository(db)
+	repo := NewAPITokenRepository(db)
+
+	user := &models.User{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Password: "secret", Role: models.RoleEditor}
+	require.NoError(t, users.Create(user))
+
+	plain, err := utils.GenerateAPIToken()
+	require.NoError(t, err)
+
+	token := &models.APIToken{
+		UserID:    user.ID,
+		Name:      "CI",
+		Prefix:    plain[:10],
+		TokenHash: utils.HashAPIToken(plain),
+		Scopes:    models.ScopePostsRead + "," + models.ScopePostsWrite,
+	}
+	require.NoError(t, repo.Create(token))
+
+	found, err := repo.FindByHash(utils.HashAPIToken(plain))
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	require.NotNil(t, found.User)
+	assert.Equal(t, "jane@example.com", found.User.Email)
+	assert.True(t, found.HasScope(models.ScopePostsWrite))
+	assert.False(t, found.HasScope(models.ScopePagesRead))
+
+	_, err = repo.FindByHash(utils.HashAPIToken(plain + "x"))
+	assert.Error(t, err)
+
+	// Revoked tokens can no longer be found
+	require.NoError(t, repo.Delete(found))
+	_, err = repo.FindByHash(utils.HashAPIToken(plain))
+	assert.Error(t, err)
+}
+
+func TestAPITokenRepository_TouchLastUsed(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewAPITokenRepository(db)
+
+	token := &models.APIToken{UserID: 1, Name: "CLI", Prefix: "cpt_abcdef", TokenHash: "hash"}
+	require.NoError(t, repo.Create(token))
+	require.NoError(t, repo.Create(&models.APIToken{UserID: 2, Name: "Other", Prefix: "cpt_123456", TokenHash: "other"}))
+
+	usedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.TouchLastUsed(token, usedAt))
+
+	tokens, err := repo.FindByUser(1)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	require.NotNil(t, tokens[0].LastUsedAt)
+	assert.True(t, usedAt.Equal(*tokens[0].LastUsedAt))
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type loginRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginRepository creates a new login audit and throttling repository
+func NewLoginRepository(db *gorm.DB) models.LoginRepository {
+	return &loginRepository{db: db}
+}
+
+func (r *loginRepository) RecordAttempt(attempt *models.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// FindAttemptsPaginated finds the login attempts, most recent first
+func (r *loginRepository) FindAttemptsPaginated(page, perPage int) ([]*models.LoginAttempt, int64, error) {
+	var attempts []*models.LoginAttempt
+	var total int64
+
+	if err := r.db.Model(&models.LoginAttempt{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Preload("User").
+		Order("created_at desc, id desc").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&attempts).Error
+
+	return attempts, total, err
+}
+
+// FindThrottle finds the throttle of a key, or returns a new unsaved one
+func (r *loginRepository) FindThrottle(key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Where("throttle_key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.LoginThrottle{Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *loginRepository) SaveThrottle(throttle *models.LoginThrottle) error {
+	return r.db.Save(throttle).Error
+}
+
+func (r *loginRepository) DeleteThrottle(key string) error {
+	return r.db.Unscoped().Where("throttle_key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// FindBlockedThrottles finds the keys blocked at the given time
+func (r *loginRepository) FindBlockedThrottles(now time.Time) ([]*models.LoginThrottle, error) {
+	var throttles []*models.LoginThrottle
+	err := r.db.Where("blocked_until > ?", now).Order("blocked_until desc").Find(&throttles).Error
+	return throttles, err
+}
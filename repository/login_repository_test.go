@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginRepository_Throttle(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewLoginRepository(db)
+	now := time.Now()
+
+	// Unknown keys return a new unsaved throttle
+	throttle, err := repo.FindThrottle("ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, throttle.ID)
+	assert.Equal(t, "ip:127.0.0.1", throttle.Key)
+
+	throttle.Failures = 3
+	throttle.BlockedUntil = now.Add(time.Minute)
+	require.NoError(t, repo.SaveThrottle(throttle))
+	require.NoError(t, repo.SaveThrottle(&models.LoginThrottle{Key: "account:old@example.com", BlockedUntil: now.Add(-time.Minute)}))
+
+	found, err := repo.FindThrottle("ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, throttle.ID, found.ID)
+	assert.Equal(t, 3, found.Failures)
+
+	blocked, err := repo.FindBlockedThrottles(now)
+	require.NoError(t, err)
+	require.Len(t, blocked, 1)
+	assert.Equal(t, "ip:127.0.0.1", blocked[0].Key)
+
+	// Deleted keys can be saved again
+	require.NoError(t, repo.DeleteThrottle("ip:127.0.0.1"))
+	found, err = repo.FindThrottle("ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, found.ID)
+	require.NoError(t, repo.SaveThrottle(found))
+}
+
+func TestLoginRepository_FindAttemptsPaginated(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewLoginRepository(db)
+
+	user := &models.User{Email: "user@example.com", FirstName: "Test", LastName: "User", Password: "hash"}
+	require.NoError(t, NewUserRepository(db).Create(user))
+
+	require.NoError(t, repo.RecordAttempt(&models.LoginAttempt{Email: "user@example.com", IP: "127.0.0.1", Result: models.LoginResultInvalidCredentials}))
+	require.NoError(t, repo.RecordAttempt(&models.LoginAttempt{Email: "user@example.com", UserID: &user.ID, IP: "127.0.0.1", Success: true, Result: models.LoginResultSuccess}))
+	require.NoError(t, repo.RecordAttempt(&models.LoginAttempt{Email: "other@example.com", IP: "10.0.0.1", Result: models.LoginResultInvalidCredentials}))
+
+	attempts, total, err := repo.FindAttemptsPaginated(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, attempts, 2)
+	assert.Equal(t, "other@example.com", attempts[0].Email)
+	assert.Nil(t, attempts[0].User)
+	require.NotNil(t, attempts[1].User)
+	assert.Equal(t, user.ID, attempts[1].User.ID)
+
+	attempts, _, err = repo.FindAttemptsPaginated(2, 2)
+	require.NoError(t, err)
+	require.Len(t, attempts, 1)
+	assert.False(t, attempts[0].Success)
+}
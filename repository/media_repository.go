@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"slices"
+	"strings"
+
 	"github.com/captain-corp/captain/models"
 
 	"gorm.io/gorm"
@@ -45,6 +48,24 @@ func (r *mediaRepository) FindByPath(path string) (*models.Media, error) {
 	return &media, nil
 }
 
+// FindByHash returns the first media of a content, whose file is shared by
+// the media uploaded since with the same content
+func (r *mediaRepository) FindByHash(hash string) (*models.Media, error) {
+	var media models.Media
+	err := r.db.Where("hash = ?", hash).First(&media).Error
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// CountByPath returns the number of media sharing a file
+func (r *mediaRepository) CountByPath(path string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Media{}).Where("path = ?", path).Count(&count).Error
+	return count, err
+}
+
 func (r *mediaRepository) FindByFilename(filename string) (*models.Media, error) {
 	var media models.Media
 	err := r.db.Where("name = ?", filename).First(&media).Error
@@ -60,6 +81,144 @@ func (r *mediaRepository) FindAll() ([]*models.Media, error) {
 	return media, err
 }
 
+// mediaTypePrefixes are the MIME type prefixes of the media types
+var mediaTypePrefixes = map[string]string{
+	models.MediaTypeImage: "image/%",
+	models.MediaTypeVideo: "video/%",
+	models.MediaTypeAudio: "audio/%",
+}
+
+// Search returns the media matching a filter, newest first
+func (r *mediaRepository) Search(filter models.MediaFilter) ([]*models.Media, error) {
+	query := r.db.Order("created_at desc")
+
+	for _, term := range strings.Fields(strings.ToLower(filter.Query)) {
+		like := "%" + term + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(alt_text) LIKE ? OR LOWER(caption) LIKE ?", like, like, like, like)
+	}
+
+	if folder := models.NormalizeFolder(filter.Folder); folder != "" {
+		query = query.Where("folder = ? OR folder LIKE ?", folder, folder+"/%")
+	}
+
+	if prefix, ok := mediaTypePrefixes[filter.Type]; ok {
+		query = query.Where("mime_type LIKE ?", prefix)
+	} else if filter.Type == models.MediaTypeDocument {
+		for _, prefix := range mediaTypePrefixes {
+			query = query.Where("mime_type NOT LIKE ?", prefix)
+		}
+	}
+
+	var media []*models.Media
+	err := query.Find(&media).Error
+	return media, err
+}
+
+// FindFolders returns the folders holding media, sorted
+func (r *mediaRepository) FindFolders() ([]string, error) {
+	var folders []string
+	err := r.db.Model(&models.Media{}).Where("folder <> ''").Distinct().Order("folder").Pluck("folder", &folders).Error
+	return folders, err
+}
+
+// referenceCondition returns the condition of the rows of which a column
+// contains a reference to a media
+func referenceCondition(media *models.Media, columns ...string) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	for _, column := range columns {
+		for _, reference := range media.References() {
+			where = append(where, column+" LIKE ?")
+			args = append(args, "%"+reference+"%")
+		}
+	}
+	return strings.Join(where, " OR "), args
+}
+
+// FindUsage returns the posts and pages whose content links to a media, and
+// whether it is the site logo
+func (r *mediaRepository) FindUsage(media *models.Media) (*models.MediaUsage, error) {
+	usage := &models.MediaUsage{}
+
+	// LIKE finds the candidates, whose links are then checked
+	var posts []*models.Post
+	where, args := referenceCondition(media, "content", "excerpt")
+	if err := r.db.Where(where, args...).Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if media.ReferencedIn(post.Content) || (post.Excerpt != nil && media.ReferencedIn(*post.Excerpt)) {
+			usage.Posts = append(usage.Posts, post)
+		}
+	}
+
+	var pages []*models.Page
+	where, args = referenceCondition(media, "content")
+	if err := r.db.Where(where, args...).Order("id").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if media.ReferencedIn(page.Content) {
+			usage.Pages = append(usage.Pages, page)
+		}
+	}
+
+	var logos int64
+	if err := r.db.Model(&models.Settings{}).Where("logo_id = ?", media.ID).Count(&logos).Error; err != nil {
+		return nil, err
+	}
+	usage.Logo = logos > 0
+
+	return usage, nil
+}
+
+// FindUnused returns the media of a list linked from no post or page, nor used
+// as logo. The content is read once for all the media.
+func (r *mediaRepository) FindUnused(media []*models.Media) ([]*models.Media, error) {
+	var contents []string
+	var posts []*models.Post
+	if err := r.db.Select("content", "excerpt").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		contents = append(contents, post.Content)
+		if post.Excerpt != nil {
+			contents = append(contents, *post.Excerpt)
+		}
+	}
+	var pages []string
+	if err := r.db.Model(&models.Page{}).Pluck("content", &pages).Error; err != nil {
+		return nil, err
+	}
+	contents = append(contents, pages...)
+
+	var logos []uint
+	if err := r.db.Model(&models.Settings{}).Where("logo_id IS NOT NULL").Pluck("logo_id", &logos).Error; err != nil {
+		return nil, err
+	}
+
+	var unused []*models.Media
+	for _, m := range media {
+		if slices.Contains(logos, m.ID) || slices.ContainsFunc(contents, m.ReferencedIn) {
+			continue
+		}
+		unused = append(unused, m)
+	}
+	return unused, nil
+}
+
+// UpdateDimensions saves the width and height of an image, leaving its
+// update time untouched as the file did not change
+func (r *mediaRepository) UpdateDimensions(media *models.Media) error {
+	return r.db.Model(media).UpdateColumns(map[string]interface{}{"width": media.Width, "height": media.Height}).Error
+}
+
+// UpdateHash saves the hash of the content of a media, leaving its update time
+// untouched as the file did not change
+func (r *mediaRepository) UpdateHash(media *models.Media) error {
+	return r.db.Model(media).UpdateColumn("hash", media.Hash).Error
+}
+
 func (r *mediaRepository) SaveAll(media []*models.Media) error {
 	return r.db.Save(media).Error
 }
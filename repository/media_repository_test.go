@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func mediaNames(media []*models.Media) []string {
+	names := make([]string, len(media))
+	for i, m := range media {
+		names[i] = m.Name
+	}
+	return names
+}
+
+func TestMediaRepository_Search(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMediaRepository(db)
+
+	for _, m := range []*models.Media{
+		{Name: "beach.jpg", Path: "beach.jpg", MimeType: "image/jpeg", Folder: "/travel//2024/", AltText: "Sunset on the beach"},
+		{Name: "city.png", Path: "city.png", MimeType: "image/png", Folder: "travel"},
+		{Name: "talk.mp4", Path: "talk.mp4", MimeType: "video/mp4", Description: "Conference talk"},
+		{Name: "slides.pdf", Path: "slides.pdf", MimeType: "application/pdf", Folder: "travelling"},
+	} {
+		require.NoError(t, repo.Create(m))
+	}
+
+	found, err := repo.Search(models.MediaFilter{})
+	require.NoError(t, err)
+	assert.Len(t, found, 4)
+
+	found, err = repo.Search(models.MediaFilter{Query: "SUNSET"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beach.jpg"}, mediaNames(found))
+	assert.Equal(t, "travel/2024", found[0].Folder)
+
+	found, err = repo.Search(models.MediaFilter{Folder: "travel"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"beach.jpg", "city.png"}, mediaNames(found))
+
+	found, err = repo.Search(models.MediaFilter{Type: models.MediaTypeVideo})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"talk.mp4"}, mediaNames(found))
+
+	found, err = repo.Search(models.MediaFilter{Type: models.MediaTypeDocument})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"slides.pdf"}, mediaNames(found))
+
+	found, err = repo.Search(models.MediaFilter{Query: "talk conference", Type: models.MediaTypeVideo})
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	folders, err := repo.FindFolders()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"travel", "travel/2024", "travelling"}, folders)
+}
+
+func TestMediaRepository_FindUsage(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMediaRepository(db)
+
+	media := &models.Media{Name: "my photo.jpg", Path: "my photo.jpg", MimeType: "image/jpeg"}
+	require.NoError(t, repo.Create(media))
+
+	excerpt := "![](/media/my photo.jpg)"
+	posts := NewPostRepository(db)
+	for _, p := range []*models.Post{
+		{Title: "Linked", Slug: "linked", Content: "![Photo](/media/my%20photo.jpg)", PublishedAt: time.Now()},
+		{Title: "Excerpt", Slug: "excerpt", Content: "Text", Excerpt: &excerpt, PublishedAt: time.Now()},
+		{Title: "Other", Slug: "other", Content: "![Photo](/media/my%20photo.jpg.bak)", PublishedAt: time.Now()},
+	} {
+		require.NoError(t, posts.Create(p))
+	}
+	require.NoError(t, NewPageRepository(db).Create(&models.Page{Title: "About", Slug: "about", Content: `<img src="/media/my photo.jpg">`, ContentType: "html"}))
+
+	usage, err := repo.FindUsage(media)
+	require.NoError(t, err)
+	require.Len(t, usage.Posts, 2)
+	assert.Equal(t, "linked", usage.Posts[0].Slug)
+	assert.Equal(t, "excerpt", usage.Posts[1].Slug)
+	require.Len(t, usage.Pages, 1)
+	assert.False(t, usage.Logo)
+	assert.True(t, usage.InUse())
+
+	unused := &models.Media{Name: "unused.jpg", Path: "unused.jpg", MimeType: "image/jpeg"}
+	require.NoError(t, repo.Create(unused))
+	usage, err = repo.FindUsage(unused)
+	require.NoError(t, err)
+	assert.False(t, usage.InUse())
+}
+
+func TestMediaRepository_FindUnused(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMediaRepository(db)
+
+	var medias []*models.Media
+	for _, name := range []string{"linked.jpg", "excerpt.jpg", "page.jpg", "logo.png", "unused.jpg"} {
+		media := &models.Media{Name: name, Path: name, MimeType: "image/jpeg"}
+		require.NoError(t, repo.Create(media))
+		medias = append(medias, media)
+	}
+
+	excerpt := "![](/media/excerpt.jpg)"
+	require.NoError(t, NewPostRepository(db).Create(&models.Post{Title: "Linked", Slug: "linked", Content: "![](/media/linked.jpg)", Excerpt: &excerpt, PublishedAt: time.Now()}))
+	require.NoError(t, NewPageRepository(db).Create(&models.Page{Title: "About", Slug: "about", Content: `<img src="/media/page.jpg">`, ContentType: "html"}))
+	require.NoError(t, db.Create(&models.Settings{Title: "Site", LogoID: &medias[3].ID}).Error)
+
+	unused, err := repo.FindUnused(medias)
+	require.NoError(t, err)
+	require.Len(t, unused, 1)
+	assert.Equal(t, "unused.jpg", unused[0].Name)
+}
+
+func TestMediaRepository_FindByHash(t *testing.T) {
+	repo := NewMediaRepository(setupTestDB(t))
+
+	first := &models.Media{Name: "photo.jpg", Path: "photo.jpg", Hash: "abc"}
+	require.NoError(t, repo.Create(first))
+	require.NoError(t, repo.Create(&models.Media{Name: "copy.jpg", Path: "photo.jpg", Hash: "abc"}))
+	require.NoError(t, repo.Create(&models.Media{Name: "old.jpg", Path: "old.jpg"}))
+
+	media, err := repo.FindByHash("abc")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, media.ID)
+	_, err = repo.FindByHash("def")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	count, err := repo.CountByPath("photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	old, err := repo.FindByPath("old.jpg")
+	require.NoError(t, err)
+	old.Hash = "def"
+	require.NoError(t, repo.UpdateHash(old))
+	media, err = repo.FindByHash("def")
+	require.NoError(t, err)
+	assert.Equal(t, old.ID, media.ID)
+}
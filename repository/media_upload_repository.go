@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type mediaUploadRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaUploadRepository creates a new repository of the uploads in progress
+func NewMediaUploadRepository(db *gorm.DB) models.MediaUploadRepository {
+	return &mediaUploadRepository{db: db}
+}
+
+func (r *mediaUploadRepository) Create(upload *models.MediaUpload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *mediaUploadRepository) Update(upload *models.MediaUpload) error {
+	return r.db.Save(upload).Error
+}
+
+// Delete removes an upload for good, once complete or abandoned
+func (r *mediaUploadRepository) Delete(upload *models.MediaUpload) error {
+	return r.db.Unscoped().Delete(&models.MediaUpload{}, upload.ID).Error
+}
+
+func (r *mediaUploadRepository) FindByID(id uint) (*models.MediaUpload, error) {
+	var upload models.MediaUpload
+	err := r.db.First(&upload, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// FindDirect returns the uploads sent straight to the storage provider, whose
+// file is stored before their media
+func (r *mediaUploadRepository) FindDirect() ([]*models.MediaUpload, error) {
+	var uploads []*models.MediaUpload
+	err := r.db.Where("direct = ?", true).Order("id").Find(&uploads).Error
+	return uploads, err
+}
+
+func (r *mediaUploadRepository) FindExpired(now time.Time) ([]*models.MediaUpload, error) {
+	var uploads []*models.MediaUpload
+	err := r.db.Where("expires_at < ?", now).Order("id").Find(&uploads).Error
+	return uploads, err
+}
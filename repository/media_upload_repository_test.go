@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMediaUploadRepository(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMediaUploadRepository(db)
+	now := time.Now()
+
+	expired := &models.MediaUpload{UserID: 1, Filename: "old.mp4", MimeType: "video/mp4", Size: 100, ExpiresAt: now.Add(-time.Minute)}
+	active := &models.MediaUpload{UserID: 1, Filename: "new.mp4", MimeType: "video/mp4", Size: 100, ExpiresAt: now.Add(time.Hour)}
+	direct := &models.MediaUpload{UserID: 1, Filename: "clip.mp4", MimeType: "video/mp4", Size: 100, Direct: true, Path: "1700000000-clip.mp4", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, repo.Create(expired))
+	require.NoError(t, repo.Create(active))
+	require.NoError(t, repo.Create(direct))
+
+	uploads, err := repo.FindDirect()
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "1700000000-clip.mp4", uploads[0].Path)
+
+	active.Received = 50
+	require.NoError(t, repo.Update(active))
+	found, err := repo.FindByID(active.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), found.Received)
+
+	uploads, err = repo.FindExpired(now)
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "old.mp4", uploads[0].Filename)
+
+	require.NoError(t, repo.Delete(expired))
+	_, err = repo.FindByID(expired.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	uploads, err = repo.FindExpired(now)
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
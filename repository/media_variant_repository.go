@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type mediaVariantRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaVariantRepository creates a new media variant repository
+func NewMediaVariantRepository(db *gorm.DB) models.MediaVariantRepository {
+	return &mediaVariantRepository{db: db}
+}
+
+func (r *mediaVariantRepository) Create(variant *models.MediaVariant) error {
+	return r.db.Create(variant).Error
+}
+
+// Delete removes a variant for good, so that it can be generated again
+func (r *mediaVariantRepository) Delete(variant *models.MediaVariant) error {
+	return r.db.Unscoped().Delete(&models.MediaVariant{}, variant.ID).Error
+}
+
+func (r *mediaVariantRepository) FindByKey(mediaID uint, key string) (*models.MediaVariant, error) {
+	var variant models.MediaVariant
+	err := r.db.Where("media_id = ? AND variant_key = ?", mediaID, key).First(&variant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+func (r *mediaVariantRepository) FindByMedia(mediaID uint) ([]*models.MediaVariant, error) {
+	var variants []*models.MediaVariant
+	err := r.db.Where("media_id = ?", mediaID).Order("id").Find(&variants).Error
+	return variants, err
+}
+
+func (r *mediaVariantRepository) FindAll() ([]*models.MediaVariant, error) {
+	var variants []*models.MediaVariant
+	err := r.db.Order("id").Find(&variants).Error
+	return variants, err
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/captain-corp/captain/models"
 
 	"gorm.io/gorm"
@@ -24,7 +26,7 @@ func (r *pageRepository) Update(page *models.Page) error {
 }
 
 func (r *pageRepository) Delete(page *models.Page) error {
-	return r.db.Delete(&models.Page{}, page).Error
+	return r.db.Delete(page).Error
 }
 
 func (r *pageRepository) FindByID(id uint) (*models.Page, error) {
@@ -42,6 +44,26 @@ func (r *pageRepository) FindAll() ([]*models.Page, error) {
 	return pages, err
 }
 
+func (r *pageRepository) FindAllVisible() ([]*models.Page, error) {
+	var pages []*models.Page
+	err := r.db.Where("visible = ?", true).Order("updated_at desc").Find(&pages).Error
+	return pages, err
+}
+
+// FindUpdatedSince finds the pages created or updated after since
+func (r *pageRepository) FindUpdatedSince(since time.Time) ([]*models.Page, error) {
+	var pages []*models.Page
+	err := r.db.Where("updated_at > ?", since).Order("updated_at").Find(&pages).Error
+	return pages, err
+}
+
+// FindDeletedSince finds the pages deleted after since
+func (r *pageRepository) FindDeletedSince(since time.Time) ([]*models.Page, error) {
+	var pages []*models.Page
+	err := r.db.Unscoped().Where("deleted_at > ?", since).Order("deleted_at").Find(&pages).Error
+	return pages, err
+}
+
 func (r *pageRepository) FindBySlug(slug string) (*models.Page, error) {
 	var page models.Page
 	err := r.db.Where("slug = ?", slug).First(&page).Error
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageRepository_FindAllVisible(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewPageRepository(db)
+
+	pages := []*models.Page{
+		{Title: "About", Slug: "about", Content: "About", ContentType: "markdown", Visible: true},
+		{Title: "Draft", Slug: "draft", Content: "Draft", ContentType: "markdown", Visible: false},
+		{Title: "Contact", Slug: "contact", Content: "Contact", ContentType: "html", Visible: true},
+	}
+
+	for _, p := range pages {
+		require.NoError(t, repo.Create(p))
+	}
+
+	found, err := repo.FindAllVisible()
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	for _, p := range found {
+		assert.True(t, p.Visible)
+		assert.NotEqual(t, "draft", p.Slug)
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type pageRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewPageRevisionRepository creates a new page revision repository
+func NewPageRevisionRepository(db *gorm.DB) models.PageRevisionRepository {
+	return &pageRevisionRepository{db: db}
+}
+
+func (r *pageRevisionRepository) Create(revision *models.PageRevision) error {
+	return r.db.Create(revision).Error
+}
+
+func (r *pageRevisionRepository) FindByID(id uint) (*models.PageRevision, error) {
+	var revision models.PageRevision
+	err := r.db.Preload("Author").First(&revision, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// FindByPage returns the revisions of a page, newest first
+func (r *pageRevisionRepository) FindByPage(pageID uint) ([]*models.PageRevision, error) {
+	var revisions []*models.PageRevision
+	err := r.db.Preload("Author").Where("page_id = ?", pageID).Order("id desc").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *pageRevisionRepository) CountByPage(pageID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PageRevision{}).Where("page_id = ?", pageID).Count(&count).Error
+	return count, err
+}
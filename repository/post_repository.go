@@ -154,6 +154,23 @@ func (r *PostRepository) FindRecent(limit int) ([]*models.Post, error) {
 	return posts, err
 }
 
+// FindUpdatedSince finds the posts created or updated after since
+func (r *PostRepository) FindUpdatedSince(since time.Time) ([]*models.Post, error) {
+	var posts []*models.Post
+	err := r.db.Preload("Tags").Joins("Author").
+		Where("posts.updated_at > ?", since).
+		Order("posts.updated_at").
+		Find(&posts).Error
+	return posts, err
+}
+
+// FindDeletedSince finds the posts deleted after since
+func (r *PostRepository) FindDeletedSince(since time.Time) ([]*models.Post, error) {
+	var posts []*models.Post
+	err := r.db.Unscoped().Where("deleted_at > ?", since).Order("deleted_at").Find(&posts).Error
+	return posts, err
+}
+
 // FindAllPaginated finds all posts with pagination
 func (r *PostRepository) FindAllPaginated(page, perPage int) ([]models.Post, int64, error) {
 	var posts []models.Post
@@ -7,21 +7,16 @@ import (
 	"github.com/captain-corp/captain/db"
 	"github.com/captain-corp/captain/models"
 
-	"github.com/glebarez/sqlite"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
+// setupTestDB returns an empty migrated database, in-memory SQLite unless
+// CAPTAIN_TEST_DB_DRIVER and CAPTAIN_TEST_DB_DSN are set
 func setupTestDB(t *testing.T) *gorm.DB {
-	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
-
-	// Migrate the schema
-	err = db.ExecuteMigrations(database)
-	require.NoError(t, err)
-
-	return database
+	t.Helper()
+	return db.SetupTestDB()
 }
 
 func TestPostRepository_Create(t *testing.T) {
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type postRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewPostRevisionRepository creates a new post revision repository
+func NewPostRevisionRepository(db *gorm.DB) models.PostRevisionRepository {
+	return &postRevisionRepository{db: db}
+}
+
+func (r *postRevisionRepository) Create(revision *models.PostRevision) error {
+	return r.db.Create(revision).Error
+}
+
+func (r *postRevisionRepository) FindByID(id uint) (*models.PostRevision, error) {
+	var revision models.PostRevision
+	err := r.db.Preload("Author").First(&revision, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// FindByPost returns the revisions of a post, newest first
+func (r *postRevisionRepository) FindByPost(postID uint) ([]*models.PostRevision, error) {
+	var revisions []*models.PostRevision
+	err := r.db.Preload("Author").Where("post_id = ?", postID).Order("id desc").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *postRevisionRepository) CountByPost(postID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PostRevision{}).Where("post_id = ?", postID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostRevisionRepository_FindByPost(t *testing.T) {
+	db := setupTestDB(t)
+	posts := NewPostRepository(db)
+	repo := NewPostRevisionRepository(db)
+
+	excerpt := "Excerpt"
+	post := &models.Post{Title: "First", Slug: "first", Content: "One", Excerpt: &excerpt}
+	other := &models.Post{Title: "Other", Slug: "other", Content: "Other"}
+	require.NoError(t, posts.Create(post))
+	require.NoError(t, posts.Create(other))
+
+	require.NoError(t, repo.Create(models.NewPostRevision(post, []string{"go", " web ", ""}, 1)))
+	post.Title = "Second"
+	require.NoError(t, repo.Create(models.NewPostRevision(post, nil, 1)))
+	require.NoError(t, repo.Create(models.NewPostRevision(other, nil, 1)))
+
+	revisions, err := repo.FindByPost(post.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+
+	// Newest first
+	assert.Equal(t, "Second", revisions[0].Title)
+	assert.Equal(t, "First", revisions[1].Title)
+	assert.Equal(t, "Excerpt", revisions[1].Excerpt)
+	assert.Equal(t, []string{"go", "web"}, revisions[1].TagNames())
+	assert.Empty(t, revisions[0].TagNames())
+
+	count, err := repo.CountByPost(post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	found, err := repo.FindByID(revisions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, found.PostID)
+}
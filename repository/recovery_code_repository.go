@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository creates a new recovery code repository
+func NewRecoveryCodeRepository(db *gorm.DB) models.RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser deletes the existing codes of the user and stores the new hashes
+func (r *recoveryCodeRepository) ReplaceForUser(userID uint, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(hashes))
+		for _, hash := range hashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepository) DeleteByUser(userID uint) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// Use marks an unused code as used, and returns false if there is no such code
+func (r *recoveryCodeRepository) Use(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryCodeRepository_Use(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRecoveryCodeRepository(db)
+
+	require.NoError(t, repo.ReplaceForUser(1, []string{"a", "b"}))
+	require.NoError(t, repo.ReplaceForUser(2, []string{"a"}))
+
+	used, err := repo.Use(1, "a")
+	require.NoError(t, err)
+	assert.True(t, used)
+
+	// Codes are single use
+	used, err = repo.Use(1, "a")
+	require.NoError(t, err)
+	assert.False(t, used)
+
+	used, err = repo.Use(1, "unknown")
+	require.NoError(t, err)
+	assert.False(t, used)
+
+	count, err := repo.CountUnused(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Other users' codes are untouched
+	count, err = repo.CountUnused(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRecoveryCodeRepository_ReplaceForUser(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRecoveryCodeRepository(db)
+
+	require.NoError(t, repo.ReplaceForUser(1, []string{"old"}))
+	require.NoError(t, repo.ReplaceForUser(1, []string{"new1", "new2"}))
+
+	used, err := repo.Use(1, "old")
+	require.NoError(t, err)
+	assert.False(t, used, "replaced codes must no longer work")
+
+	count, err := repo.CountUnused(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, repo.DeleteByUser(1))
+	count, err = repo.CountUnused(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
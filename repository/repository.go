@@ -8,24 +8,40 @@ import (
 
 // Repositories holds all repository implementations
 type Repositories struct {
-	Posts     models.PostRepository
-	Tags      models.TagRepository
-	Users     models.UserRepository
-	Pages     models.PageRepository
-	MenuItems models.MenuItemRepository
-	Settings  models.SettingsRepository
-	Media     models.MediaRepository
+	Posts         models.PostRepository
+	Tags          models.TagRepository
+	Users         models.UserRepository
+	Pages         models.PageRepository
+	MenuItems     models.MenuItemRepository
+	Settings      models.SettingsRepository
+	Media         models.MediaRepository
+	MediaVariants models.MediaVariantRepository
+	MediaUploads  models.MediaUploadRepository
+	Search        models.SearchRepository
+	PostRevisions models.PostRevisionRepository
+	PageRevisions models.PageRevisionRepository
+	APITokens     models.APITokenRepository
+	RecoveryCodes models.RecoveryCodeRepository
+	Logins        models.LoginRepository
 }
 
 // NewRepositories creates a new Repositories instance
 func NewRepositories(db *gorm.DB) *Repositories {
 	return &Repositories{
-		Posts:     NewPostRepository(db),
-		Tags:      NewTagRepository(db),
-		Users:     NewUserRepository(db),
-		Pages:     NewPageRepository(db),
-		MenuItems: NewMenuItemRepository(db),
-		Settings:  NewSettingsRepository(db),
-		Media:     NewMediaRepository(db),
+		Posts:         NewPostRepository(db),
+		Tags:          NewTagRepository(db),
+		Users:         NewUserRepository(db),
+		Pages:         NewPageRepository(db),
+		MenuItems:     NewMenuItemRepository(db),
+		Settings:      NewSettingsRepository(db),
+		Media:         NewMediaRepository(db),
+		MediaVariants: NewMediaVariantRepository(db),
+		MediaUploads:  NewMediaUploadRepository(db),
+		Search:        NewSearchRepository(db),
+		PostRevisions: NewPostRevisionRepository(db),
+		PageRevisions: NewPageRevisionRepository(db),
+		APITokens:     NewAPITokenRepository(db),
+		RecoveryCodes: NewRecoveryCodeRepository(db),
+		Logins:        NewLoginRepository(db),
 	}
 }
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/captain-corp/captain/models"
+
+	"gorm.io/gorm"
+)
+
+type searchRepository struct {
+	db *gorm.DB
+}
+
+// NewSearchRepository creates a new search repository
+func NewSearchRepository(db *gorm.DB) models.SearchRepository {
+	return &searchRepository{db: db}
+}
+
+const searchFrom = `FROM search_index
+	LEFT JOIN posts ON search_index.kind = 'post' AND posts.id = search_index.ref_id AND posts.deleted_at IS NULL
+	LEFT JOIN pages ON search_index.kind = 'page' AND pages.id = search_index.ref_id AND pages.deleted_at IS NULL
+	WHERE (posts.id IS NOT NULL OR pages.id IS NOT NULL)`
+
+const searchVisibleOnly = `
+	AND (posts.id IS NULL OR (posts.visible = ? AND posts.published_at_utc <= ?))
+	AND (pages.id IS NULL OR pages.visible = ?)`
+
+const searchColumns = `SELECT
+		search_index.kind AS kind,
+		search_index.ref_id AS ref_id,
+		COALESCE(posts.title, pages.title) AS title,
+		COALESCE(posts.slug, pages.slug) AS slug,
+		COALESCE(posts.visible, pages.visible) AS visible,
+		posts.id IS NOT NULL AND posts.visible AND posts.published_at_utc > ? AS scheduled,`
+
+// searchSnippetWords is the number of words of the content included in snippets
+const searchSnippetWords = 32
+
+// Search finds posts and pages matching the query, ranked with bm25 on SQLite.
+// PostgreSQL and MySQL match the words anywhere in the title or content, and rank
+// title matches first.
+// Matching terms in the snippets are wrapped with the \x02 and \x03 control characters.
+func (r *searchRepository) Search(query string, includeHidden bool, page, perPage int) ([]models.SearchResult, int64, error) {
+	var results []models.SearchResult
+	var total int64
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return results, 0, nil
+	}
+
+	fullText := r.db.Dialector.Name() == "sqlite"
+
+	now := time.Now().UTC()
+	where := searchFrom
+	var args []interface{}
+	if fullText {
+		where += " AND search_index MATCH ?"
+		args = append(args, buildMatchQuery(terms))
+	} else {
+		for _, term := range terms {
+			where += " AND (LOWER(search_index.title) LIKE ? OR LOWER(search_index.content) LIKE ?)"
+			args = append(args, "%"+term+"%", "%"+term+"%")
+		}
+	}
+	if !includeHidden {
+		where += searchVisibleOnly
+		args = append(args, true, now, true)
+	}
+
+	if err := r.db.Raw("SELECT count(*) "+where, args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * perPage
+	selectArgs := []interface{}{now}
+
+	var columns string
+	if fullText {
+		columns = `
+		highlight(search_index, 0, char(2), char(3)) AS title_snippet,
+		snippet(search_index, 1, char(2), char(3), '…', 32) AS snippet,
+		bm25(search_index, 10.0, 1.0) AS search_rank`
+	} else {
+		// Lower ranks first, as with bm25
+		rank := make([]string, 0, len(terms))
+		for _, term := range terms {
+			rank = append(rank, "CASE WHEN LOWER(search_index.title) LIKE ? THEN -1 ELSE 0 END")
+			selectArgs = append(selectArgs, "%"+term+"%")
+		}
+		columns = `
+		search_index.title AS title_snippet,
+		search_index.content AS snippet,
+		` + strings.Join(rank, " + ") + ` AS search_rank`
+	}
+
+	selectArgs = append(selectArgs, args...)
+	selectArgs = append(selectArgs, perPage, offset)
+
+	err := r.db.Raw(searchColumns+columns+`
+		`+where+`
+		ORDER BY search_rank, search_index.ref_id DESC
+		LIMIT ? OFFSET ?`, selectArgs...).Scan(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !fullText {
+		for i := range results {
+			results[i].TitleSnippet = highlightTerms(results[i].TitleSnippet, terms, 0)
+			results[i].Snippet = highlightTerms(results[i].Snippet, terms, searchSnippetWords)
+		}
+	}
+
+	return results, total, nil
+}
+
+// searchTerms splits free text into lower case words
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// buildMatchQuery turns search terms into a safe FTS5 query where every
+// word must match, allowing prefix matches on each word
+func buildMatchQuery(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"*`)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// highlightTerms wraps the words containing one of the terms with the \x02 and \x03
+// control characters, like the FTS5 highlight function. When maxWords is positive,
+// the text is cut around the first match, like the FTS5 snippet function.
+func highlightTerms(text string, terms []string, maxWords int) string {
+	type word struct{ start, end int }
+
+	var words []word
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWordRune && start < 0 {
+			start = i
+		} else if !isWordRune && start >= 0 {
+			words = append(words, word{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, word{start, len(text)})
+	}
+
+	matches := func(w word) bool {
+		lower := strings.ToLower(text[w.start:w.end])
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first, last := 0, len(words)
+	if maxWords > 0 && len(words) > maxWords {
+		for i, w := range words {
+			if matches(w) {
+				first = max(0, min(i-maxWords/4, len(words)-maxWords))
+				break
+			}
+		}
+		last = first + maxWords
+	}
+
+	var b strings.Builder
+	textStart, textEnd := 0, len(text)
+	if first > 0 {
+		b.WriteString("…")
+		textStart = words[first].start
+	}
+	if last < len(words) {
+		textEnd = words[last-1].end
+	}
+
+	pos := textStart
+	for _, w := range words[first:last] {
+		if !matches(w) {
+			continue
+		}
+		b.WriteString(text[pos:w.start])
+		b.WriteString("\x02")
+		b.WriteString(text[w.start:w.end])
+		b.WriteString("\x03")
+		pos = w.end
+	}
+	b.WriteString(text[pos:textEnd])
+
+	if textEnd < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchRepository_Search(t *testing.T) {
+	db := setupTestDB(t)
+	posts := NewPostRepository(db)
+	pages := NewPageRepository(db)
+	repo := NewSearchRepository(db)
+
+	now := time.Now().UTC()
+	published := &models.Post{Title: "Sailing basics", Slug: "sailing-basics", Content: "How to rig a sailboat", Visible: true, PublishedAt: now.Add(-time.Hour), PublishedAtUTC: now.Add(-time.Hour)}
+	draft := &models.Post{Title: "Sailing draft", Slug: "sailing-draft", Content: "Unfinished notes about sailing", Visible: false, PublishedAt: now, PublishedAtUTC: now}
+	scheduled := &models.Post{Title: "Sailing schedule", Slug: "sailing-schedule", Content: "Sailing next week", Visible: true, PublishedAt: now.Add(24 * time.Hour), PublishedAtUTC: now.Add(24 * time.Hour)}
+	page := &models.Page{Title: "About", Slug: "about", Content: "We love sailing", ContentType: "markdown", Visible: true}
+
+	for _, p := range []*models.Post{published, draft, scheduled} {
+		require.NoError(t, posts.Create(p))
+	}
+	require.NoError(t, pages.Create(page))
+
+	t.Run("anonymous users only find visible content", func(t *testing.T) {
+		results, total, err := repo.Search("sailing", false, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		require.Len(t, results, 2)
+
+		slugs := []string{results[0].Slug, results[1].Slug}
+		assert.ElementsMatch(t, []string{"sailing-basics", "about"}, slugs)
+	})
+
+	t.Run("hidden content is included when requested", func(t *testing.T) {
+		results, total, err := repo.Search("sailing", true, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+
+		for _, r := range results {
+			if r.Slug == "sailing-schedule" {
+				assert.True(t, r.Scheduled)
+			}
+		}
+	})
+
+	t.Run("title matches rank first and are highlighted", func(t *testing.T) {
+		results, _, err := repo.Search("sail", false, 1, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, results)
+		assert.Equal(t, "sailing-basics", results[0].Slug)
+		assert.Contains(t, results[0].TitleSnippet, "\x02Sailing\x03")
+	})
+
+	t.Run("results are paginated", func(t *testing.T) {
+		results, total, err := repo.Search("sailing", true, 2, 3)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+		assert.Len(t, results, 1)
+	})
+
+	t.Run("index follows updates and deletes", func(t *testing.T) {
+		published.Content = "How to rig a catamaran"
+		require.NoError(t, posts.Update(published))
+
+		results, _, err := repo.Search("catamaran", false, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, published.ID, results[0].RefID)
+
+		require.NoError(t, posts.Delete(published))
+
+		results, total, err := repo.Search("catamaran", false, 1, 10)
+		require.NoError(t, err)
+		assert.Zero(t, total)
+		assert.Empty(t, results)
+	})
+
+	t.Run("special characters do not break the query", func(t *testing.T) {
+		_, _, err := repo.Search(`"sailing" AND (NEAR*`, true, 1, 10)
+		assert.NoError(t, err)
+
+		results, total, err := repo.Search("   ", true, 1, 10)
+		assert.NoError(t, err)
+		assert.Zero(t, total)
+		assert.Empty(t, results)
+	})
+}
+
+func TestHighlightTerms(t *testing.T) {
+	assert.Equal(t, "How to \x02sail\x03 a \x02Sailboat\x03.", highlightTerms("How to sail a Sailboat.", []string{"sail"}, 0))
+	assert.Equal(t, "No match", highlightTerms("No match", []string{"sail"}, 0))
+
+	// Long texts are cut around the first match
+	text := "one two three four five six seven eight nine ten sailing eleven twelve thirteen fourteen"
+	assert.Equal(t, "…ten \x02sailing\x03 eleven twelve thirteen…", highlightTerms(text, []string{"sail"}, 5))
+	assert.Equal(t, "one two…", highlightTerms(text, []string{"none"}, 2))
+}
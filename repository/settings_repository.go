@@ -5,6 +5,7 @@ import (
 
 	"github.com/captain-corp/captain/models"
 	"github.com/captain-corp/captain/system"
+	"github.com/captain-corp/captain/utils"
 
 	"gorm.io/gorm"
 )
@@ -23,14 +24,29 @@ func (r *settingsRepository) Get() (*models.Settings, error) {
 	err := r.db.First(&settings).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
+		secret, err := utils.GeneratePreviewSecret()
+		if err != nil {
+			return nil, err
+		}
+
 		settings = models.Settings{
-			Title:        system.DefaultTitle,
-			Subtitle:     system.DefaultSubtitle,
-			ChromaStyle:  system.DefaultChromaStyle,
-			Theme:        system.DefaultTheme,
-			PostsPerPage: system.DefaultPostsPerPage,
+			Title:         system.DefaultTitle,
+			Subtitle:      system.DefaultSubtitle,
+			ChromaStyle:   system.DefaultChromaStyle,
+			Theme:         system.DefaultTheme,
+			PostsPerPage:  system.DefaultPostsPerPage,
+			PreviewSecret: secret,
+		}
+		// Created in place, so the returned settings have their ID
+		if err := r.db.Create(&settings).Error; err != nil {
+			return nil, err
+		}
+	} else if err == nil && settings.PreviewSecret == "" {
+		// Settings created before preview links existed
+		if settings.PreviewSecret, err = utils.GeneratePreviewSecret(); err != nil {
+			return nil, err
 		}
-		if err := r.Create(settings); err != nil {
+		if err := r.db.Model(&settings).Update("preview_secret", settings.PreviewSecret).Error; err != nil {
 			return nil, err
 		}
 	}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/captain-corp/captain/models"
 
 	"gorm.io/gorm"
@@ -73,6 +75,18 @@ func (r *tagRepository) FindAll() ([]*models.Tag, error) {
 	return tags, err
 }
 
+// FindVisible returns the tags of at least one visible and published post,
+// whose archive lists posts
+func (r *tagRepository) FindVisible() ([]*models.Tag, error) {
+	var tags []*models.Tag
+	err := r.db.Where(`EXISTS (SELECT 1 FROM post_tags JOIN posts ON posts.id = post_tags.post_id
+		WHERE post_tags.tag_id = tags.id AND posts.visible = ? AND posts.published_at_utc <= ? AND posts.deleted_at IS NULL)`,
+		true, time.Now().UTC()).
+		Order("id").
+		Find(&tags).Error
+	return tags, err
+}
+
 func (r *tagRepository) FindByName(name string) (*models.Tag, error) {
 	var tag models.Tag
 	err := r.db.Where("name = ?", name).First(&tag).Error
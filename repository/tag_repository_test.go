@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/captain-corp/captain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagRepository_FindVisible(t *testing.T) {
+	db := setupTestDB(t)
+	tags := NewTagRepository(db)
+	posts := NewPostRepository(db)
+
+	published := &models.Tag{Name: "Published", Slug: "published"}
+	draft := &models.Tag{Name: "Draft", Slug: "draft"}
+	scheduled := &models.Tag{Name: "Scheduled", Slug: "scheduled"}
+	empty := &models.Tag{Name: "Empty", Slug: "empty"}
+	for _, tag := range []*models.Tag{published, draft, scheduled, empty} {
+		require.NoError(t, tags.Create(tag))
+	}
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, posts.Create(&models.Post{Title: "Published", Slug: "published", Visible: true, PublishedAt: past, PublishedAtUTC: past, Tags: []models.Tag{*published, *draft}}))
+	require.NoError(t, posts.Create(&models.Post{Title: "Draft", Slug: "draft", PublishedAt: past, PublishedAtUTC: past, Tags: []models.Tag{*draft}}))
+	require.NoError(t, posts.Create(&models.Post{Title: "Scheduled", Slug: "scheduled", Visible: true, PublishedAt: time.Now().Add(time.Hour), PublishedAtUTC: time.Now().Add(time.Hour), Tags: []models.Tag{*scheduled}}))
+
+	found, err := tags.FindVisible()
+	require.NoError(t, err)
+	var slugs []string
+	for _, tag := range found {
+		slugs = append(slugs, tag.Slug)
+	}
+	assert.Equal(t, []string{"published", "draft"}, slugs)
+}
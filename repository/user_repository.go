@@ -67,3 +67,9 @@ func (r *userRepository) CountAll() (int64, error) {
 	err := r.db.Model(&models.User{}).Count(&count).Error
 	return count, err
 }
+
+func (r *userRepository) CountByRole(role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
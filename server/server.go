@@ -28,9 +28,11 @@ import (
 
 // Server represents the HTTP server and its dependencies
 type Server struct {
-	app    *fiber.App
-	db     *gorm.DB
-	config *config.Config
+	app      *fiber.App
+	db       *gorm.DB
+	config   *config.Config
+	staticFS fs.FS
+	storage  storage.Provider
 }
 
 // New creates a new server instance
@@ -40,7 +42,10 @@ func New(db *gorm.DB, cfg *config.Config, embeddedFS embed.FS) (*Server, error)
 	var staticFS fs.FS
 	themeName := cfg.Site.Theme
 	repositories := repository.NewRepositories(db)
-	sessionStorage := sqlite3.New(sqlite3.Config{Database: cfg.DB.Path})
+	sessionStorage, err := newSessionStorage(db, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize session storage: %w", err)
+	}
 	sessionStore := session.New(session.Config{
 		Storage:        sessionStorage,
 		CookieDomain:   cfg.Site.Domain,
@@ -69,7 +74,9 @@ func New(db *gorm.DB, cfg *config.Config, embeddedFS embed.FS) (*Server, error)
 
 	// Create Fiber app with template engine
 	app := fiber.New(fiber.Config{
-		Views: viewEngine,
+		Views:       viewEngine,
+		ProxyHeader: cfg.Server.ProxyHeader,
+		BodyLimit:   cfg.Server.BodyLimit * 1024 * 1024,
 	})
 
 	app.Use("/admin/static", filesystem.New(filesystem.Config{
@@ -96,26 +103,53 @@ func New(db *gorm.DB, cfg *config.Config, embeddedFS embed.FS) (*Server, error)
 	app.Use(middleware.LoadUserData(repositories, sessionStore))
 	app.Use(middleware.ServeFavicon(repositories, storageProvider))
 	app.Use(middleware.InjectFavicon(repositories))
+	app.Use(middleware.InjectFeedLinks())
 	app.Use("/admin", middleware.AuthRequired(repositories, sessionStore))
 
+	csrf := middleware.CSRF(sessionStore)
+	app.Use("/admin", csrf)
+	app.Use("/setup", csrf)
+	app.Use("/login/2fa", csrf)
+
+	imageVariants := handlers.NewImageVariants(repositories, storageProvider, cfg)
+	mediaUploads := handlers.NewMediaUploads(repositories, storageProvider, imageVariants, cfg)
+	mediaRedirects := handlers.NewMediaRedirects(storageProvider, cfg)
+
 	publicApp := handlers.RegisterPublicRoutes(repositories, cfg)
-	dynamicApp := handlers.RegisterDynamicRoutes(repositories, storageProvider)
+	dynamicApp := handlers.RegisterDynamicRoutes(repositories, storageProvider, imageVariants, mediaRedirects)
 	authApp := handlers.RegisterAuthRoutes(repositories, cfg, sessionStore)
-	adminApp := handlers.RegisterAdminRoutes(repositories, storageProvider, sessionStore)
+	adminApp := handlers.RegisterAdminRoutes(repositories, cfg, storageProvider, imageVariants, mediaUploads, sessionStore)
+	apiApp, err := handlers.RegisterAPIRoutes(repositories, storageProvider, imageVariants, embeddedFS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register API routes: %w", err)
+	}
 
 	app.Mount("/media", dynamicApp)
+	app.Mount("/", apiApp)
 	app.Mount("/", adminApp)
 	app.Mount("/", authApp)
 	app.Mount("/", publicApp)
 
 	return &Server{
-		config: cfg,
-		db:     db,
-		app:    app,
+		config:   cfg,
+		db:       db,
+		app:      app,
+		staticFS: staticFS,
+		storage:  storageProvider,
 	}, nil
 
 }
 
+// newSessionStorage returns the session storage matching the database driver.
+// SQLite keeps sessions in its own connection to the database file, while other
+// drivers share the main connection so all replicas see the same sessions.
+func newSessionStorage(database *gorm.DB, cfg *config.Config) (fiber.Storage, error) {
+	if database.Dialector.Name() == db.DriverSQLite {
+		return sqlite3.New(sqlite3.Config{Database: cfg.DB.Path}), nil
+	}
+	return db.NewSessionStorage(database)
+}
+
 // setupStatics sets up static files
 func setupStatics(themeName string, embeddedFS embed.FS) (fs.FS, fs.FS, error) {
 	var userStaticFS fs.FS
@@ -178,6 +212,21 @@ func setupTemplates(themeName string, embeddedFS embed.FS) (*html.Engine, error)
 	return engine, nil
 }
 
+// App returns the Fiber app serving the site, to render its pages without listening
+func (s *Server) App() *fiber.App {
+	return s.app
+}
+
+// StaticFS returns the static files of the theme, served under /static
+func (s *Server) StaticFS() fs.FS {
+	return s.staticFS
+}
+
+// Storage returns the storage provider of the media files
+func (s *Server) Storage() storage.Provider {
+	return s.storage
+}
+
 // Run starts the HTTP server
 func (s *Server) Run() error {
 	// Load theme based on config
@@ -0,0 +1,499 @@
+// Package staticsite renders the public site to a tree of static files, to
+// host it on a CDN or any static file server. Pages are rendered through the
+// Fiber app of the server, so they use the theme templates, and the links
+// between them are rewritten to point to the written files.
+package staticsite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// origin is the URL pages are requested from. The feeds, sitemaps and
+// canonical links built from it are rewritten to the base URL.
+const origin = "http://captain.build"
+
+// notFoundFile is the page served by static hosts for missing files
+const notFoundFile = "404.html"
+
+// excluded are the prefixes of the URLs that need a server, or are copied
+// instead of rendered
+var excluded = []string{"/admin", "/api", "/login", "/logout", "/setup", "/search", "/static", "/media"}
+
+// rendered are the URLs under the excluded prefixes served by the app
+var rendered = map[target]bool{"/media/chroma.css": true}
+
+// originURL matches the absolute URLs of the origin
+var originURL = regexp.MustCompile(regexp.QuoteMeta(origin) + `[^"'<>\s]*`)
+
+// Options configures a build
+type Options struct {
+	OutDir  string   // directory the site is written to
+	BaseURL string   // public URL of the site, for the feeds, sitemaps and absolute links
+	Links   LinkMode // how links between files are written, root by default
+}
+
+// Report summarizes a build
+type Report struct {
+	Pages  int      // pages, feeds and sitemaps rendered
+	Files  int      // static and media files copied
+	Broken []string // linked URLs that could not be rendered, with their status
+}
+
+func (r *Report) String() string {
+	summary := fmt.Sprintf("%d pages rendered, %d files copied", r.Pages, r.Files)
+	for _, broken := range r.Broken {
+		summary += "\n  broken link " + broken
+	}
+	return summary
+}
+
+// file is a file of the built site
+type file struct {
+	name        string // path in the output directory
+	contentType string
+	body        []byte // content to write, with its links rewritten
+	copied      bool   // whether the file was already copied to the output directory
+}
+
+type builder struct {
+	app     *fiber.App
+	repos   *repository.Repositories
+	store   storage.Provider
+	opts    Options
+	baseURL *url.URL
+	report  *Report
+
+	files  map[target]*file
+	queued map[target]bool
+	queue  []target
+	media  map[target]bool // media linked from the site
+}
+
+// Build renders the visible posts, pages, tag archives, feeds and sitemaps of
+// the site served by app to opts.OutDir, and copies the theme statics and the
+// media files they link to next to them. The app must not have a site domain configured,
+// so its absolute URLs use the request host.
+func Build(app *fiber.App, staticFS fs.FS, repos *repository.Repositories, store storage.Provider, opts Options) (*Report, error) {
+	if opts.OutDir == "" {
+		return nil, errors.New("no output directory")
+	}
+	if opts.Links == "" {
+		opts.Links = LinksRoot
+	}
+	if _, err := ParseLinkMode(string(opts.Links)); err != nil {
+		return nil, err
+	}
+	baseURL, err := url.Parse(opts.BaseURL)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q, expected an http or https URL", opts.BaseURL)
+	}
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/")
+	baseURL.RawQuery, baseURL.Fragment = "", ""
+
+	b := &builder{
+		app:     app,
+		repos:   repos,
+		store:   store,
+		opts:    opts,
+		baseURL: baseURL,
+		report:  &Report{},
+		files:   make(map[target]*file),
+		queued:  make(map[target]bool),
+		media:   make(map[target]bool),
+	}
+
+	if err := b.copyStatics(staticFS); err != nil {
+		return b.report, fmt.Errorf("failed to copy static files: %w", err)
+	}
+	if err := b.render(); err != nil {
+		return b.report, err
+	}
+	if err := b.copyMedia(); err != nil {
+		return b.report, fmt.Errorf("failed to copy media: %w", err)
+	}
+	if err := b.write(); err != nil {
+		return b.report, err
+	}
+
+	sort.Strings(b.report.Broken)
+	return b.report, nil
+}
+
+// seeds returns the URLs rendered even when no page links to them
+func (b *builder) seeds() ([]target, error) {
+	seeds := []target{"/", "/feed.xml", "/atom.xml", "/robots.txt", "/sitemap.xml", "/media/chroma.css", "/favicon.ico", "/favicon.png"}
+
+	posts, err := b.repos.Posts.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]bool)
+	for _, post := range posts {
+		if !post.IsPublished() {
+			continue
+		}
+		seeds = append(seeds, newTarget("/posts/"+post.Slug, 1))
+		for _, tag := range post.Tags {
+			tags[tag.Slug] = true
+		}
+	}
+	for slug := range tags {
+		seeds = append(seeds, newTarget("/tags/"+slug, 1), newTarget("/tags/"+slug+"/feed.xml", 1))
+	}
+
+	pages, err := b.repos.Pages.FindAllVisible()
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		seeds = append(seeds, newTarget("/pages/"+page.Slug, 1))
+	}
+
+	return seeds, nil
+}
+
+// render renders the seeds and every page of the site they link to
+func (b *builder) render() error {
+	seeds, err := b.seeds()
+	if err != nil {
+		return err
+	}
+	optional := make(map[target]bool)
+	for _, seed := range seeds {
+		optional[seed] = true
+		b.enqueue(seed)
+	}
+
+	for len(b.queue) > 0 {
+		t := b.queue[0]
+		b.queue = b.queue[1:]
+
+		status, f, err := b.fetch(t)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", t, err)
+		}
+		if status != fiber.StatusOK {
+			// Seeds like the favicons only exist when configured
+			if !optional[t] || status >= 500 {
+				b.report.Broken = append(b.report.Broken, fmt.Sprintf("%s (%d)", t, status))
+			}
+			continue
+		}
+
+		f.name = outputFile(t, isHTML(f.contentType))
+		if !validFile(f.name) {
+			continue
+		}
+		b.files[t] = f
+		b.report.Pages++
+
+		for _, link := range b.links(t, f) {
+			b.enqueue(link)
+		}
+	}
+
+	// Static hosts serve 404.html for missing files
+	status, f, err := b.fetch(newTarget("/posts/"+notFoundFile, 1))
+	if err != nil {
+		return fmt.Errorf("failed to render the not found page: %w", err)
+	}
+	if status == fiber.StatusNotFound && isHTML(f.contentType) {
+		f.name = notFoundFile
+		b.files[target("/"+notFoundFile)] = f
+	}
+
+	return nil
+}
+
+func (b *builder) enqueue(t target) {
+	if b.queued[t] || b.files[t] != nil {
+		return
+	}
+	if !rendered[t] {
+		p := t.path()
+		// Media are copied once every page linking to them is rendered
+		if strings.HasPrefix(p, "/media/") {
+			b.media[t] = true
+			return
+		}
+		for _, prefix := range excluded {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return
+			}
+		}
+	}
+	b.queued[t] = true
+	b.queue = append(b.queue, t)
+}
+
+// fetch renders a URL through the app
+func (b *builder) fetch(t target) (int, *file, error) {
+	req := httptest.NewRequest(fiber.MethodGet, origin+escapePath(t.path())+strings.TrimPrefix(string(t), t.path()), nil)
+	req.Header.Set(fiber.HeaderAccept, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := b.app.Test(req, -1)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if location := resp.Header.Get(fiber.HeaderLocation); strings.HasPrefix(location, "/setup") {
+		return 0, nil, errors.New("the site is not set up yet")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, &file{contentType: resp.Header.Get(fiber.HeaderContentType), body: body}, nil
+}
+
+// links returns the URLs of the site linked from a rendered file
+func (b *builder) links(from target, f *file) []target {
+	var values []string
+	if isText(f.contentType) {
+		values = append(values, originURL.FindAllString(string(f.body), -1)...)
+	}
+	switch {
+	case isHTML(f.contentType):
+		for _, match := range linkAttr.FindAllSubmatch(f.body, -1) {
+			values = append(values, string(match[2]))
+		}
+		for _, match := range srcsetAttr.FindAllSubmatch(f.body, -1) {
+			values = append(values, srcsetURLs(string(match[1]))...)
+		}
+	case strings.HasPrefix(f.contentType, "text/css"):
+		for _, match := range cssURL.FindAllSubmatch(f.body, -1) {
+			values = append(values, string(match[2]))
+		}
+	}
+
+	var links []target
+	for _, value := range values {
+		if t, _, ok := resolve(from, origin, value); ok {
+			links = append(links, t)
+		}
+	}
+	return links
+}
+
+// copyStatics registers the theme static files, copied under /static
+func (b *builder) copyStatics(staticFS fs.FS) error {
+	return fs.WalkDir(staticFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f := &file{name: "static/" + name, contentType: mime.TypeByExtension(path.Ext(name))}
+		if isText(f.contentType) {
+			if f.body, err = fs.ReadFile(staticFS, name); err != nil {
+				return err
+			}
+		} else if err := b.copyFile(f.name, func() (io.ReadCloser, error) { return staticFS.Open(name) }); err != nil {
+			return err
+		} else {
+			f.copied = true
+		}
+		t := newTarget("/"+f.name, 1)
+		b.files[t] = f
+		b.report.Files++
+
+		// Stylesheets may link to media
+		for _, link := range b.links(t, f) {
+			b.enqueue(link)
+		}
+		return nil
+	})
+}
+
+// copyMedia copies the media files linked from the site from the storage
+// provider under /media. The resized images of srcsets are rendered through
+// the app.
+func (b *builder) copyMedia() error {
+	for t := range b.media {
+		name := mediaFile(t)
+		if !validFile(name) {
+			continue
+		}
+
+		if t.path() != string(t) {
+			status, f, err := b.fetch(t)
+			if err != nil {
+				return fmt.Errorf("failed to render %s: %w", t, err)
+			}
+			if status != fiber.StatusOK {
+				b.report.Broken = append(b.report.Broken, fmt.Sprintf("%s (%d)", t, status))
+				continue
+			}
+			f.name = name
+			b.files[t] = f
+			b.report.Files++
+			continue
+		}
+
+		m, err := b.repos.Media.FindByPath(strings.TrimPrefix(t.path(), "/media/"))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			b.report.Broken = append(b.report.Broken, fmt.Sprintf("%s (%d)", t, fiber.StatusNotFound))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := b.copyFile(name, func() (io.ReadCloser, error) { return b.store.Get(m.Path) }); err != nil {
+			return fmt.Errorf("%s: %w", m.Path, err)
+		}
+		b.files[t] = &file{name: name, contentType: m.MimeType, copied: true}
+		b.report.Files++
+	}
+	return nil
+}
+
+func (b *builder) copyFile(name string, open func() (io.ReadCloser, error)) error {
+	src, err := open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst := filepath.Join(b.opts.OutDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// write writes the rendered files with their links rewritten
+func (b *builder) write() error {
+	for t, f := range b.files {
+		if f.copied {
+			continue
+		}
+
+		body := f.body
+		if isText(f.contentType) {
+			body = b.rewrite(t, f)
+		}
+
+		dst := filepath.Join(b.opts.OutDir, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, body, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite points the links of a file to the built files. Absolute URLs, in the
+// feeds, sitemaps and canonical links, always use the base URL.
+func (b *builder) rewrite(from target, f *file) []byte {
+	body := originURL.ReplaceAllFunc(f.body, func(match []byte) []byte {
+		t, fragment, ok := resolve(from, origin, string(match))
+		if !ok {
+			return []byte(b.baseURL.String() + strings.TrimPrefix(string(match), origin))
+		}
+		if built := b.files[t]; built != nil {
+			return []byte(b.baseURL.String() + publicPath(built.name) + fragment)
+		}
+		return []byte(b.baseURL.String() + string(t) + fragment)
+	})
+
+	switch {
+	case isHTML(f.contentType):
+		body = linkAttr.ReplaceAllFunc(body, func(match []byte) []byte {
+			groups := linkAttr.FindSubmatch(match)
+			if link, ok := b.link(from, f, string(groups[2])); ok {
+				return []byte(fmt.Sprintf(`%s="%s"`, groups[1], link))
+			}
+			return match
+		})
+		body = srcsetAttr.ReplaceAllFunc(body, func(match []byte) []byte {
+			srcset := rewriteSrcset(string(srcsetAttr.FindSubmatch(match)[1]), func(value string) (string, bool) {
+				return b.link(from, f, value)
+			})
+			return []byte(`srcset="` + srcset + `"`)
+		})
+	case strings.HasPrefix(f.contentType, "text/css"):
+		body = cssURL.ReplaceAllFunc(body, func(match []byte) []byte {
+			groups := cssURL.FindSubmatch(match)
+			if link, ok := b.link(from, f, string(groups[2])); ok {
+				return []byte("url(" + string(groups[1]) + link)
+			}
+			return match
+		})
+	}
+
+	return body
+}
+
+// link returns a root-relative link of a file rewritten to the built file it
+// points to, in the link mode of the build
+func (b *builder) link(from target, f *file, value string) (string, bool) {
+	if !strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "?") {
+		return "", false
+	}
+	t, fragment, ok := resolve(from, origin, value)
+	if !ok {
+		return "", false
+	}
+	built := b.files[t]
+	if built == nil {
+		return "", false
+	}
+
+	switch b.opts.Links {
+	case LinksAbsolute:
+		return b.baseURL.String() + publicPath(built.name) + fragment, true
+	case LinksRelative:
+		// The not found page is served for any missing path, so its links
+		// cannot be relative
+		if f.name == notFoundFile {
+			return b.baseURL.EscapedPath() + publicPath(built.name) + fragment, true
+		}
+		return relativePath(f.name, built.name) + fragment, true
+	default:
+		return b.baseURL.EscapedPath() + publicPath(built.name) + fragment, true
+	}
+}
+
+func isHTML(contentType string) bool {
+	return strings.HasPrefix(contentType, fiber.MIMETextHTML)
+}
+
+// isText returns whether a file can contain links to rewrite
+func isText(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/html", "text/css", "text/plain", "text/xml", "application/xml",
+		"application/rss+xml", "application/atom+xml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package staticsite
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LinkMode is how the links between the files of the static site are written
+type LinkMode string
+
+const (
+	// LinksRoot writes links from the root of the site, /posts/hello/, under
+	// the path of the base URL
+	LinksRoot LinkMode = "root"
+	// LinksAbsolute writes links with the base URL, https://example.com/posts/hello/
+	LinksAbsolute LinkMode = "absolute"
+	// LinksRelative writes links relative to each file, ../posts/hello/index.html,
+	// so the site can be served from any path or browsed from disk
+	LinksRelative LinkMode = "relative"
+)
+
+// LinkModes lists the valid link modes
+var LinkModes = []LinkMode{LinksRoot, LinksAbsolute, LinksRelative}
+
+// ParseLinkMode returns the link mode of a name
+func ParseLinkMode(name string) (LinkMode, error) {
+	for _, mode := range LinkModes {
+		if string(mode) == name {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid link mode %q, expected root, absolute or relative", name)
+}
+
+var (
+	// linkAttr matches the link attributes of HTML
+	linkAttr = regexp.MustCompile(`\b(href|src)="([^"]*)"`)
+	// srcsetAttr matches the srcset attributes of HTML, listing image candidates
+	srcsetAttr = regexp.MustCompile(`\bsrcset="([^"]*)"`)
+	// cssURL matches the root-relative URLs of CSS
+	cssURL = regexp.MustCompile(`url\((['"]?)(/[^'")]*)`)
+)
+
+// target is a URL of the site, in the form pages are stored under: a path
+// without trailing slash, followed by the page number of paginated lists or
+// the width of resized images
+type target string
+
+func newTarget(urlPath string, page int) target {
+	if urlPath != "/" {
+		urlPath = strings.TrimSuffix(urlPath, "/")
+	}
+	if page > 1 {
+		return target(urlPath + "?page=" + strconv.Itoa(page))
+	}
+	return target(urlPath)
+}
+
+// newVariantTarget returns the URL of an image of the media library resized to a width
+func newVariantTarget(urlPath string, width int) target {
+	return target(urlPath + "?w=" + strconv.Itoa(width))
+}
+
+func (t target) path() string {
+	p, _, _ := strings.Cut(string(t), "?")
+	return p
+}
+
+// resolve returns the site URL a link points to, from the page at base. Links
+// to other sites or with a query string other than the page number, or the
+// width of a media image, are not part of the static site.
+func resolve(base target, origin, link string) (target, string, bool) {
+	link = strings.ReplaceAll(link, "&amp;", "&")
+	if rest, ok := strings.CutPrefix(link, origin); ok {
+		link = rest
+		if link == "" {
+			link = "/"
+		}
+	}
+	if strings.HasPrefix(link, "?") {
+		link = base.path() + link
+	}
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return "", "", false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Path == "" {
+		return "", "", false
+	}
+
+	page, width := 1, 0
+	for key, values := range u.Query() {
+		if len(values) != 1 {
+			return "", "", false
+		}
+		switch {
+		case key == "page":
+			if page, err = strconv.Atoi(values[0]); err != nil || page < 1 {
+				return "", "", false
+			}
+		case key == "w" && strings.HasPrefix(u.Path, "/media/"):
+			if width, err = strconv.Atoi(values[0]); err != nil || width < 1 {
+				return "", "", false
+			}
+		default:
+			return "", "", false
+		}
+	}
+
+	fragment := ""
+	if u.Fragment != "" {
+		fragment = "#" + u.EscapedFragment()
+	}
+	if width > 0 {
+		return newVariantTarget(u.Path, width), fragment, true
+	}
+	return newTarget(u.Path, page), fragment, true
+}
+
+// srcsetURLs returns the URLs of the image candidates of a srcset
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// rewriteSrcset rewrites the URLs of the image candidates of a srcset
+func rewriteSrcset(srcset string, rewrite func(string) (string, bool)) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		if link, ok := rewrite(fields[0]); ok {
+			fields[0] = link
+			candidates[i] = strings.Join(fields, " ")
+		}
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// outputFile returns the file a URL is written to. HTML pages are written as
+// the index of a directory named after the URL, so they are served without the
+// .html extension.
+func outputFile(t target, isHTML bool) string {
+	p := strings.TrimPrefix(t.path(), "/")
+	if _, page, found := strings.Cut(string(t), "?page="); found {
+		p = strings.TrimPrefix(p+"/page/"+page, "/")
+	}
+	if !isHTML {
+		return p
+	}
+	if p == "" {
+		return "index.html"
+	}
+	return p + "/index.html"
+}
+
+// mediaFile returns the file a media URL is written to. Resized images are
+// written under _variants, with their width after their name.
+func mediaFile(t target) string {
+	p := strings.TrimPrefix(t.path(), "/")
+	if _, width, found := strings.Cut(string(t), "?w="); found {
+		ext := path.Ext(p)
+		return "media/_variants/" + strings.TrimSuffix(strings.TrimPrefix(p, "media/"), ext) + "-" + width + "w" + ext
+	}
+	return p
+}
+
+// validFile returns whether a file path stays inside the output directory
+func validFile(file string) bool {
+	return file != "" && !strings.HasPrefix(file, "/") && path.Clean(file) == file &&
+		file != ".." && !strings.HasPrefix(file, "../")
+}
+
+// publicPath returns the URL path of a file from the root of the site
+func publicPath(file string) string {
+	if file == "index.html" {
+		return "/"
+	}
+	if dir, found := strings.CutSuffix(file, "/index.html"); found {
+		return escapePath("/" + dir + "/")
+	}
+	return escapePath("/" + file)
+}
+
+// relativePath returns the URL of a file from the directory of another
+func relativePath(from, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(to))
+	if err != nil {
+		return publicPath(to)
+	}
+	return escapePath(filepath.ToSlash(rel))
+}
+
+func escapePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
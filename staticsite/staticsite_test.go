@@ -0,0 +1,213 @@
+package staticsite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/captain-corp/captain/db"
+	"github.com/captain-corp/captain/models"
+	"github.com/captain-corp/captain/repository"
+	"github.com/captain-corp/captain/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSite returns an app serving a small site in the shape of the public
+// routes of the server
+func newTestSite(t *testing.T) (*fiber.App, *repository.Repositories, storage.Provider) {
+	repos := repository.NewRepositories(db.SetupTestDB())
+	admin := &models.User{Email: "admin@example.com", Role: models.RoleAdmin}
+	require.NoError(t, repos.Users.Create(admin))
+
+	post := &models.Post{Title: "Hello", Slug: "hello", Content: "Hello", Visible: true, AuthorID: admin.ID}
+	require.NoError(t, repos.Posts.Create(post))
+	require.NoError(t, repos.Posts.AssociateTags(post, []string{"Go"}))
+	require.NoError(t, repos.Posts.Create(&models.Post{Title: "Draft", Slug: "draft", Content: "Draft", AuthorID: admin.ID}))
+	require.NoError(t, repos.Pages.Create(&models.Page{Title: "About", Slug: "about", Content: "About", ContentType: "markdown", Visible: true}))
+
+	store, err := storage.NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	path, err := store.Save("photo.jpg", strings.NewReader("jpeg"))
+	require.NoError(t, err)
+	require.NoError(t, repos.Media.Create(&models.Media{Name: "photo.jpg", Path: path, MimeType: "image/jpeg", Size: 4}))
+	for _, name := range []string{"pattern.png", "unused.png"} {
+		_, err := store.Save(name, strings.NewReader("png"))
+		require.NoError(t, err)
+		require.NoError(t, repos.Media.Create(&models.Media{Name: name, Path: name, MimeType: "image/png", Size: 3}))
+	}
+
+	layout := func(c *fiber.Ctx, body string) error {
+		c.Type("html")
+		return c.SendString(`<html><head><link rel="canonical" href="` + c.BaseURL() + c.Path() + `">` +
+			`<link rel="stylesheet" href="/static/css/main.css"></head><body>` +
+			`<a href="/">Home</a> <a href="/admin">Admin</a> <a href="https://example.org/">Elsewhere</a>` + body + `</body></html>`)
+	}
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		if c.QueryInt("page", 1) > 1 {
+			return layout(c, `<a href="?page=1">Newer</a>`)
+		}
+		return layout(c, `<a href="/posts/hello">Hello</a> <a href="/pages/about#team">About</a> <a href="?page=2">Older</a>`)
+	})
+	app.Get("/posts/:slug", func(c *fiber.Ctx) error {
+		if c.Params("slug") != "hello" {
+			c.Status(fiber.StatusNotFound)
+			return layout(c, "Not found")
+		}
+		return layout(c, `<img src="/media/`+path+`" srcset="/media/`+path+`?w=400 400w, /media/`+path+` 800w" sizes="100vw">`+
+			` <a href="/tags/go">Go</a> <a href="/posts/missing">Missing</a>`)
+	})
+	app.Get("/pages/:slug", func(c *fiber.Ctx) error {
+		return layout(c, "About")
+	})
+	app.Get("/tags/:slug", func(c *fiber.Ctx) error {
+		return layout(c, `<a href="/posts/hello/">Hello</a>`)
+	})
+	app.Get("/feed.xml", func(c *fiber.Ctx) error {
+		c.Type("xml")
+		return c.SendString(`<rss><channel><link>` + c.BaseURL() + `/</link><item><link>` + c.BaseURL() + `/posts/hello</link></item></channel></rss>`)
+	})
+	app.Get("/robots.txt", func(c *fiber.Ctx) error {
+		return c.SendString("Sitemap: " + c.BaseURL() + "/sitemap.xml")
+	})
+	app.Get("/media/chroma.css", func(c *fiber.Ctx) error {
+		c.Type("css")
+		return c.SendString(".chroma {}")
+	})
+	app.Get("/media/*", func(c *fiber.Ctx) error {
+		if c.Params("*") != path || c.Query("w") != "400" {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		c.Type("jpg")
+		return c.SendString("jpeg 400w")
+	})
+
+	return app, repos, store
+}
+
+var testStatics = fstest.MapFS{
+	"css/main.css": {Data: []byte(`body { background: url(/static/img/bg.png) } main { background: url("/media/pattern.png") }`)},
+	"img/bg.png":   {Data: []byte("png")},
+	"js/main.js":   {Data: []byte("console.log('/static/img/bg.png')")},
+}
+
+func readOutput(t *testing.T, dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBuild(t *testing.T) {
+	app, repos, store := newTestSite(t)
+	out := t.TempDir()
+
+	report, err := Build(app, testStatics, repos, store, Options{OutDir: out, BaseURL: "https://example.com/blog/"})
+	require.NoError(t, err)
+	assert.Equal(t, 3+3, report.Files)
+	assert.Equal(t, []string{"/posts/missing (404)"}, report.Broken)
+
+	for _, name := range []string{"index.html", "page/2/index.html", "posts/hello/index.html", "pages/about/index.html",
+		"tags/go/index.html", "feed.xml", "robots.txt", "media/chroma.css", "static/img/bg.png", "404.html"} {
+		assert.FileExists(t, filepath.Join(out, filepath.FromSlash(name)))
+	}
+	assert.NoFileExists(t, filepath.Join(out, "posts", "draft", "index.html"))
+	assert.Equal(t, "jpeg", readOutput(t, out, "media/photo.jpg"))
+	assert.Equal(t, "png", readOutput(t, out, "media/pattern.png"))
+	assert.Equal(t, "jpeg 400w", readOutput(t, out, "media/_variants/photo-400w.jpg"))
+	assert.NoFileExists(t, filepath.Join(out, "media", "unused.png"), "media not linked from the site are not copied")
+
+	home := readOutput(t, out, "index.html")
+	assert.Contains(t, home, `<link rel="canonical" href="https://example.com/blog/">`)
+	assert.Contains(t, home, `href="/blog/static/css/main.css"`)
+	assert.Contains(t, home, `href="/blog/posts/hello/"`)
+	assert.Contains(t, home, `href="/blog/pages/about/#team"`)
+	assert.Contains(t, home, `href="/blog/page/2/"`)
+	assert.Contains(t, home, `href="/admin"`)
+	assert.Contains(t, home, `href="https://example.org/"`)
+
+	assert.Contains(t, readOutput(t, out, "page/2/index.html"), `href="/blog/"`)
+	assert.Contains(t, readOutput(t, out, "posts/hello/index.html"), `src="/blog/media/photo.jpg"`)
+	assert.Contains(t, readOutput(t, out, "posts/hello/index.html"), `srcset="/blog/media/_variants/photo-400w.jpg 400w, /blog/media/photo.jpg 800w"`)
+	assert.Contains(t, readOutput(t, out, "posts/hello/index.html"), `href="/posts/missing"`)
+	assert.Contains(t, readOutput(t, out, "feed.xml"), `<link>https://example.com/blog/posts/hello/</link>`)
+	assert.Equal(t, "Sitemap: https://example.com/blog/sitemap.xml", readOutput(t, out, "robots.txt"))
+	assert.Contains(t, readOutput(t, out, "static/css/main.css"), `url(/blog/static/img/bg.png)`)
+	assert.Contains(t, readOutput(t, out, "static/css/main.css"), `url("/blog/media/pattern.png")`)
+	assert.Equal(t, "console.log('/static/img/bg.png')", readOutput(t, out, "static/js/main.js"))
+}
+
+func TestBuildLinkModes(t *testing.T) {
+	app, repos, store := newTestSite(t)
+
+	for mode, expected := range map[LinkMode][]string{
+		LinksAbsolute: {`href="https://example.com/"`, `href="https://example.com/tags/go/"`, `src="https://example.com/media/photo.jpg"`},
+		LinksRelative: {`href="../../index.html"`, `href="../../tags/go/index.html"`, `src="../../media/photo.jpg"`,
+			`srcset="../../media/_variants/photo-400w.jpg 400w, ../../media/photo.jpg 800w"`},
+	} {
+		t.Run(string(mode), func(t *testing.T) {
+			out := t.TempDir()
+			_, err := Build(app, testStatics, repos, store, Options{OutDir: out, BaseURL: "https://example.com", Links: mode})
+			require.NoError(t, err)
+
+			post := readOutput(t, out, "posts/hello/index.html")
+			for _, link := range expected {
+				assert.Contains(t, post, link)
+			}
+			assert.Contains(t, post, `<link rel="canonical" href="https://example.com/posts/hello/">`)
+		})
+	}
+
+	out := t.TempDir()
+	_, err := Build(app, testStatics, repos, store, Options{OutDir: out, BaseURL: "https://example.com", Links: LinksRelative})
+	require.NoError(t, err)
+	assert.Contains(t, readOutput(t, out, "index.html"), `href="page/2/index.html"`)
+	assert.Contains(t, readOutput(t, out, "static/css/main.css"), `url(../img/bg.png)`)
+	// Missing paths of any depth are served the not found page
+	assert.Contains(t, readOutput(t, out, "404.html"), `href="/static/css/main.css"`)
+}
+
+func TestBuildOptions(t *testing.T) {
+	app, repos, store := newTestSite(t)
+
+	for _, opts := range []Options{
+		{BaseURL: "https://example.com"},
+		{OutDir: t.TempDir(), BaseURL: "example.com"},
+		{OutDir: t.TempDir(), BaseURL: "https://example.com", Links: "other"},
+	} {
+		_, err := Build(app, testStatics, repos, store, opts)
+		assert.Error(t, err, fmt.Sprintf("%+v", opts))
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	for _, tc := range []struct {
+		target target
+		html   bool
+		file   string
+	}{
+		{"/", true, "index.html"},
+		{"/?page=3", true, "page/3/index.html"},
+		{"/posts/hello", true, "posts/hello/index.html"},
+		{"/tags/go?page=2", true, "tags/go/page/2/index.html"},
+		{"/tags/go/feed.xml", false, "tags/go/feed.xml"},
+	} {
+		assert.Equal(t, tc.file, outputFile(tc.target, tc.html), string(tc.target))
+	}
+
+	variant, _, ok := resolve("/", origin, "/media/photos/cat.jpg?w=400")
+	require.True(t, ok)
+	assert.Equal(t, "media/_variants/photos/cat-400w.jpg", mediaFile(variant))
+	assert.Equal(t, "media/photos/cat.jpg", mediaFile("/media/photos/cat.jpg"))
+
+	for _, link := range []string{"/search?q=go", "/posts/hello?w=400", "https://example.org/", "//cdn.example.org/x.js", "#top", "mailto:a@example.com"} {
+		_, _, ok := resolve("/", origin, link)
+		assert.False(t, ok, link)
+	}
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+)
+
+// AzureProvider implements Provider interface for Azure Blob Storage
+type AzureProvider struct {
+	client     *container.Client
+	credential *container.SharedKeyCredential
+	container  string
+}
+
+// NewAzureProvider creates a new AzureProvider for a container of a storage
+// account. The endpoint defaults to the Blob service of the account, and is
+// set to use an emulator like Azurite.
+func NewAzureProvider(accountName, accountKey, containerName, endpoint string) (*AzureProvider, error) {
+	credential, err := container.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Azure account key: %v", err)
+	}
+
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", accountName)
+	}
+	containerURL, err := url.JoinPath(endpoint, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Azure endpoint: %v", err)
+	}
+
+	client, err := container.NewClientWithSharedKeyCredential(containerURL, credential, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure client: %v", err)
+	}
+
+	return &AzureProvider{
+		client:     client,
+		credential: credential,
+		container:  containerName,
+	}, nil
+}
+
+// Save implements Provider.Save
+func (p *AzureProvider) Save(filename string, reader io.Reader) (string, error) {
+	filename = objectKey(filename)
+	if err := p.SaveAt(filename, reader); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// SaveAt implements PathSaver.SaveAt, uploading the file in blocks
+func (p *AzureProvider) SaveAt(path string, reader io.Reader) error {
+	_, err := p.client.NewBlockBlobClient(path).UploadStream(context.TODO(), reader, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upload file to Azure: %v", err)
+	}
+	return nil
+}
+
+// Delete implements Provider.Delete
+func (p *AzureProvider) Delete(path string) error {
+	_, err := p.client.NewBlobClient(path).Delete(context.TODO(), nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("failed to delete file from Azure: %v", err)
+	}
+	return nil
+}
+
+// Get implements Provider.Get
+func (p *AzureProvider) Get(path string) (io.ReadCloser, error) {
+	return p.download(path, blob.HTTPRange{})
+}
+
+// GetRange implements Provider.GetRange with a ranged download
+func (p *AzureProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return p.download(path, blob.HTTPRange{Offset: offset, Count: length})
+}
+
+// download returns a range of a blob, all of it for an empty range
+func (p *AzureProvider) download(path string, r blob.HTTPRange) (io.ReadCloser, error) {
+	result, err := p.client.NewBlobClient(path).DownloadStream(context.TODO(), &blob.DownloadStreamOptions{Range: r})
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, fmt.Errorf("file not found: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from Azure: %v", err)
+	}
+	return result.Body, nil
+}
+
+// List implements Provider.List with the pages of the blobs of the container
+func (p *AzureProvider) List(prefix string) ([]Object, error) {
+	var objects []Object
+	pager := p.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+	for pager.More() {
+		page, err := pager.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of Azure container %s: %v", p.container, err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			object := Object{Path: *item.Name}
+			if item.Properties != nil {
+				object.Size = deref(item.Properties.ContentLength)
+				object.ModTime = deref(item.Properties.LastModified)
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// Stat implements Provider.Stat with the properties of the blob
+func (p *AzureProvider) Stat(path string) (Object, error) {
+	result, err := p.client.NewBlobClient(path).GetProperties(context.TODO(), nil)
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat file in Azure: %v", err)
+	}
+	return Object{
+		Path:    path,
+		Size:    deref(result.ContentLength),
+		ModTime: deref(result.LastModified),
+	}, nil
+}
+
+// PresignUpload implements DirectUploader with a SAS allowing to create the
+// blob. Browsers send it in a single Put Blob request, limited to 5000 MiB.
+func (p *AzureProvider) PresignUpload(filename, mimeType string, size int64, expires time.Duration) (*DirectUpload, error) {
+	key, err := uploadKey(filename)
+	if err != nil {
+		return nil, err
+	}
+	sasURL, err := p.client.NewBlobClient(key).GetSASURL(sas.BlobPermissions{Create: true, Write: true}, time.Now().Add(expires), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload to Azure: %v", err)
+	}
+
+	return &DirectUpload{
+		Path: key,
+		URL:  sasURL,
+		Headers: map[string]string{
+			"Content-Type":   mimeType,
+			"X-Ms-Blob-Type": "BlockBlob",
+		},
+	}, nil
+}
+
+// PresignGet implements URLSigner with a SAS allowing to read the blob,
+// serving it with its type
+func (p *AzureProvider) PresignGet(path, mimeType string, expires time.Duration) (string, error) {
+	query, err := sas.BlobSignatureValues{
+		ExpiryTime:    time.Now().UTC().Add(expires),
+		Permissions:   (&sas.BlobPermissions{Read: true}).String(),
+		ContainerName: p.container,
+		BlobName:      path,
+		ContentType:   mimeType,
+	}.SignWithSharedKey(p.credential)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download from Azure: %v", err)
+	}
+	return p.client.NewBlobClient(path).URL() + "?" + query.Encode(), nil
+}
+
+// deref returns the value of an optional field of a response, its zero value
+// when it is missing
+func deref[T any](value *T) T {
+	if value == nil {
+		var zero T
+		return zero
+	}
+	return *value
+}
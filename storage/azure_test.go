@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// azuriteAccountKey is the key of the account of the Azurite emulator
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// newTestAzureProvider returns a provider of Azurite, started with
+// CAPTAIN_TEST_AZURE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+func newTestAzureProvider(t *testing.T) *AzureProvider {
+	endpoint := os.Getenv("CAPTAIN_TEST_AZURE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CAPTAIN_TEST_AZURE_ENDPOINT is not set")
+	}
+	provider, err := NewAzureProvider("devstoreaccount1", azuriteAccountKey, "captain-test", endpoint)
+	require.NoError(t, err)
+	_, err = provider.client.Create(context.TODO(), nil)
+	if !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		require.NoError(t, err)
+	}
+	return provider
+}
+
+func TestAzureProvider(t *testing.T) {
+	testProvider(t, newTestAzureProvider(t))
+}
+
+func TestAzureProvider_PresignUpload(t *testing.T) {
+	provider := newTestAzureProvider(t)
+
+	upload, err := provider.PresignUpload("clip.mp4", "video/mp4", 5, time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { provider.Delete(upload.Path) })
+
+	req, err := http.NewRequest(http.MethodPut, upload.URL, strings.NewReader("video"))
+	require.NoError(t, err)
+	for name, value := range upload.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	object, err := provider.Stat(upload.Path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), object.Size)
+}
+
+func TestAzureProvider_PresignGet(t *testing.T) {
+	provider := newTestAzureProvider(t)
+
+	path, err := provider.Save("clip.mp4", strings.NewReader("video"))
+	require.NoError(t, err)
+	t.Cleanup(func() { provider.Delete(path) })
+
+	signedURL, err := provider.PresignGet(path, "video/mp4", time.Hour)
+	require.NoError(t, err)
+	resp, err := http.Get(signedURL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "video", readAll(t, resp.Body))
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// CacheProvider keeps the files read from a remote provider on the local disk,
+// so they are not downloaded again on each request. The least recently used
+// files are evicted beyond the size of the cache. Files are cached by path, and
+// evicted when they are written again or deleted.
+type CacheProvider struct {
+	Provider
+	dir         string
+	maxSize     int64
+	maxFileSize int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of cacheEntry, by name
+	lru     *list.List               // most recently used first
+	size    int64
+	writes  uint64 // counts the files written or deleted, see cacheFiller
+}
+
+// cacheEntry is a file of the cache
+type cacheEntry struct {
+	name string
+	size int64
+}
+
+// NewCacheProvider creates a cache of maxSize bytes in dir, in front of a
+// provider. Files larger than maxFileSize bytes are not cached. The files
+// cached by earlier runs are kept, in the order of their last use.
+func NewCacheProvider(provider Provider, dir string, maxSize, maxFileSize int64) (*CacheProvider, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type cachedFile struct {
+		cacheEntry
+		used time.Time
+	}
+	var files []cachedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// Left by a download stopped with the server
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			_ = os.Remove(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{cacheEntry{entry.Name(), info.Size()}, info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].used.After(files[j].used) })
+
+	c := &CacheProvider{
+		Provider:    provider,
+		dir:         dir,
+		maxSize:     maxSize,
+		maxFileSize: min(maxFileSize, maxSize),
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	for _, file := range files {
+		c.entries[file.name] = c.lru.PushBack(&file.cacheEntry)
+		c.size += file.size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Get implements Provider.Get, reading the file from the cache, or caching it
+// while it is read from the provider
+func (c *CacheProvider) Get(path string) (io.ReadCloser, error) {
+	name := cacheName(path)
+	if file := c.open(name); file != nil {
+		return file, nil
+	}
+
+	reader, err := c.Provider.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		log.Warnf("Failed to cache %s: %v", path, err)
+		return reader, nil
+	}
+	c.mu.Lock()
+	writes := c.writes
+	c.mu.Unlock()
+	return &cacheFiller{cache: c, name: name, reader: reader, temp: temp, writes: writes}, nil
+}
+
+// GetRange implements Provider.GetRange, reading the range from the cache when
+// it holds the file. Ranges do not fill the cache.
+func (c *CacheProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	file := c.open(cacheName(path))
+	if file == nil {
+		return c.Provider.GetRange(path, offset, length)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Delete implements Provider.Delete, deleting the cached file too
+func (c *CacheProvider) Delete(path string) error {
+	err := c.Provider.Delete(path)
+	c.invalidate(path)
+	return err
+}
+
+// Save implements Provider.Save, evicting the cached file of a path written again
+func (c *CacheProvider) Save(filename string, reader io.Reader) (string, error) {
+	path, err := c.Provider.Save(filename, reader)
+	if err == nil {
+		c.invalidate(path)
+	}
+	return path, err
+}
+
+// SaveAt implements PathSaver.SaveAt, evicting the cached file of the path
+func (c *CacheProvider) SaveAt(path string, reader io.Reader) error {
+	saver, ok := AsPathSaver(c.Provider)
+	if !ok {
+		return fmt.Errorf("the storage provider cannot store files at a given path")
+	}
+	err := saver.SaveAt(path, reader)
+	c.invalidate(path)
+	return err
+}
+
+// invalidate evicts the cached file of a path, and keeps the files being read
+// from the provider out of the cache, as they may be older
+func (c *CacheProvider) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[cacheName(path)]; ok {
+		c.remove(element)
+	}
+	c.writes++
+}
+
+// Size returns the size of the files in the cache
+func (c *CacheProvider) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// open returns a cached file, marked as the most recently used, or nil when it
+// is not cached
+func (c *CacheProvider) open(name string) *os.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	file, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		// Deleted from the disk
+		c.remove(element)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	// Keeps the order of use for the next runs
+	now := time.Now()
+	_ = os.Chtimes(file.Name(), now, now)
+	return file
+}
+
+// add moves a downloaded file to the cache, evicting the least recently used
+// files beyond its size. Files read before a write are dropped.
+func (c *CacheProvider) add(name, temp string, size int64, writes uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if writes != c.writes {
+		return os.Remove(temp)
+	}
+
+	if err := os.Rename(temp, filepath.Join(c.dir, name)); err != nil {
+		return err
+	}
+	// Downloaded by concurrent requests
+	if element, ok := c.entries[name]; ok {
+		entry := element.Value.(*cacheEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[name] = c.lru.PushFront(&cacheEntry{name, size})
+		c.size += size
+	}
+	c.evict()
+	return nil
+}
+
+// evict deletes the least recently used files beyond the size of the cache
+func (c *CacheProvider) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove deletes a file of the cache. Readers already holding it keep reading
+// it.
+func (c *CacheProvider) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	if err := os.Remove(filepath.Join(c.dir, entry.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Failed to delete cached file %s: %v", entry.name, err)
+	}
+	c.lru.Remove(element)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+}
+
+// cacheName returns the name of the cached file of a path, flat and safe
+func cacheName(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:]) + filepath.Ext(path)
+}
+
+// cacheFiller reads a file from the provider, writing it to a temporary file
+// added to the cache once it is read to its end. Files read partially or
+// larger than the files cached are dropped.
+type cacheFiller struct {
+	cache  *CacheProvider
+	name   string
+	reader io.ReadCloser
+	temp   *os.File
+	size   int64
+	writes uint64 // of the cache when the read started
+}
+
+func (f *cacheFiller) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	if f.temp == nil {
+		return n, err
+	}
+
+	if n > 0 {
+		f.size += int64(n)
+		if f.size > f.cache.maxFileSize {
+			f.drop()
+		} else if _, writeErr := f.temp.Write(p[:n]); writeErr != nil {
+			log.Warnf("Failed to cache %s: %v", f.name, writeErr)
+			f.drop()
+		}
+	}
+
+	if err == io.EOF && f.temp != nil {
+		temp := f.temp
+		f.temp = nil
+		if closeErr := temp.Close(); closeErr != nil {
+			log.Warnf("Failed to cache %s: %v", f.name, closeErr)
+			_ = os.Remove(temp.Name())
+		} else if addErr := f.cache.add(f.name, temp.Name(), f.size, f.writes); addErr != nil {
+			log.Warnf("Failed to cache %s: %v", f.name, addErr)
+			_ = os.Remove(temp.Name())
+		}
+	}
+	return n, err
+}
+
+func (f *cacheFiller) Close() error {
+	if f.temp != nil {
+		f.drop()
+	}
+	return f.reader.Close()
+}
+
+// drop deletes the temporary file of a file not cached
+func (f *cacheFiller) drop() {
+	f.temp.Close()
+	_ = os.Remove(f.temp.Name())
+	f.temp = nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider counts the files read from a provider
+type countingProvider struct {
+	Provider
+	gets int
+}
+
+func (p *countingProvider) Get(path string) (io.ReadCloser, error) {
+	p.gets++
+	return p.Provider.Get(path)
+}
+
+func (p *countingProvider) SaveAt(path string, reader io.Reader) error {
+	return p.Provider.(PathSaver).SaveAt(path, reader)
+}
+
+func newTestCache(t *testing.T, maxSize, maxFileSize int64) (*CacheProvider, *countingProvider) {
+	local, err := NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	provider := &countingProvider{Provider: local}
+	cache, err := NewCacheProvider(provider, t.TempDir(), maxSize, maxFileSize)
+	require.NoError(t, err)
+	return cache, provider
+}
+
+func TestCacheProvider(t *testing.T) {
+	cache, _ := newTestCache(t, 1024, 1024)
+	testProvider(t, cache)
+}
+
+func TestCacheProvider_Get(t *testing.T) {
+	cache, provider := newTestCache(t, 1024, 1024)
+	path, err := cache.Save("hello.txt", strings.NewReader("hello world"))
+	require.NoError(t, err)
+
+	reader, err := cache.Get(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", readAll(t, reader))
+	assert.Equal(t, int64(11), cache.Size())
+
+	reader, err = cache.Get(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", readAll(t, reader))
+	reader, err = cache.GetRange(path, 6, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "world", readAll(t, reader))
+	assert.Equal(t, 1, provider.gets, "cached files are read from the disk")
+
+	// The cache is kept across restarts
+	restarted, err := NewCacheProvider(provider, cache.dir, 1024, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), restarted.Size())
+
+	require.NoError(t, cache.Delete(path))
+	assert.Zero(t, cache.Size())
+	_, err = cache.Get(path)
+	assert.Error(t, err)
+}
+
+func TestCacheProvider_Partial(t *testing.T) {
+	cache, provider := newTestCache(t, 1024, 8)
+	long, err := cache.Save("long.txt", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	short, err := cache.Save("short.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+
+	// Larger than the files cached
+	reader, err := cache.Get(long)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", readAll(t, reader))
+
+	// Read partially
+	reader, err = cache.Get(short)
+	require.NoError(t, err)
+	_, err = reader.Read(make([]byte, 2))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	assert.Zero(t, cache.Size())
+	entries, err := os.ReadDir(cache.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "temporary files are deleted")
+
+	reader, err = cache.Get(short)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readAll(t, reader))
+	assert.Equal(t, int64(5), cache.Size())
+	assert.Equal(t, 3, provider.gets)
+}
+
+func TestCacheProvider_Evict(t *testing.T) {
+	cache, provider := newTestCache(t, 10, 10)
+	get := func(path string) {
+		reader, err := cache.Get(path)
+		require.NoError(t, err)
+		readAll(t, reader)
+	}
+
+	var paths []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		path, err := cache.Save(name, strings.NewReader("four"))
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+
+	get(paths[0])
+	get(paths[1])
+	get(paths[0])
+	get(paths[2]) // evicts b, the least recently used
+	assert.Equal(t, int64(8), cache.Size())
+	assert.Equal(t, 3, provider.gets)
+
+	get(paths[0])
+	get(paths[2])
+	assert.Equal(t, 3, provider.gets)
+	get(paths[1])
+	assert.Equal(t, 4, provider.gets)
+
+	// Files deleted from the disk are read again
+	require.NoError(t, os.Remove(filepath.Join(cache.dir, cacheName(paths[1]))))
+	get(paths[1])
+	assert.Equal(t, 5, provider.gets)
+}
+
+func TestCacheProvider_Write(t *testing.T) {
+	cache, provider := newTestCache(t, 1024, 1024)
+	get := func(path string) string {
+		reader, err := cache.Get(path)
+		require.NoError(t, err)
+		return readAll(t, reader)
+	}
+
+	path, err := cache.Save("favicon.ico", strings.NewReader("old"))
+	require.NoError(t, err)
+	assert.Equal(t, "old", get(path))
+
+	// Icons are saved again under their name
+	_, err = cache.Save("favicon.ico", strings.NewReader("new"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", get(path))
+
+	saver, ok := AsPathSaver(&Storage{Provider: cache})
+	require.True(t, ok)
+	assert.Same(t, cache, saver, "files are saved through the cache")
+	require.NoError(t, saver.SaveAt(path, strings.NewReader("moved")))
+
+	// Files read before a write are not cached
+	reader, err := cache.Get(path)
+	require.NoError(t, err)
+	require.NoError(t, saver.SaveAt(path, strings.NewReader("last")))
+	readAll(t, reader)
+	assert.Equal(t, "last", get(path))
+	assert.Equal(t, "last", get(path))
+	assert.Equal(t, 4, provider.gets)
+}
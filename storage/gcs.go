@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// gcsXMLEndpoint is the endpoint of the S3-compatible API of Google Cloud
+// Storage, used with HMAC keys
+const gcsXMLEndpoint = "https://storage.googleapis.com"
+
+// GCSProvider implements Provider interface for Google Cloud Storage, with its
+// JSON API
+type GCSProvider struct {
+	client *gcs.Client
+	bucket *gcs.BucketHandle
+	name   string
+
+	// anonymous is set for emulators, which cannot sign URLs
+	anonymous bool
+}
+
+// NewGCSProvider creates a new GCSProvider. Without credentials file, the
+// default credentials of the environment are used, or none when an endpoint is
+// set, as emulators like fake-gcs-server do not check them.
+func NewGCSProvider(bucket, credentialsFile, endpoint string) (*GCSProvider, error) {
+	var opts []option.ClientOption
+	anonymous := false
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	switch {
+	case credentialsFile != "":
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	case endpoint != "":
+		opts = append(opts, option.WithoutAuthentication())
+		anonymous = true
+	}
+
+	client, err := gcs.NewClient(context.TODO(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+
+	return &GCSProvider{
+		client:    client,
+		bucket:    client.Bucket(bucket),
+		name:      bucket,
+		anonymous: anonymous,
+	}, nil
+}
+
+// NewGCSXMLProvider creates a provider for the XML API of Google Cloud Storage,
+// compatible with S3 and authenticated with an HMAC key
+func NewGCSXMLProvider(bucket, endpoint, accessKey, secretKey string) (*S3Provider, error) {
+	if endpoint == "" {
+		endpoint = gcsXMLEndpoint
+	}
+	return NewS3Provider(bucket, "auto", endpoint, accessKey, secretKey)
+}
+
+// Save implements Provider.Save
+func (p *GCSProvider) Save(filename string, reader io.Reader) (string, error) {
+	filename = objectKey(filename)
+	if err := p.SaveAt(filename, reader); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// SaveAt implements PathSaver.SaveAt
+func (p *GCSProvider) SaveAt(path string, reader io.Reader) error {
+	writer := p.bucket.Object(path).NewWriter(context.TODO())
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to upload file to GCS: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload file to GCS: %v", err)
+	}
+	return nil
+}
+
+// Delete implements Provider.Delete
+func (p *GCSProvider) Delete(path string) error {
+	err := p.bucket.Object(path).Delete(context.TODO())
+	if err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete file from GCS: %v", err)
+	}
+	return nil
+}
+
+// Get implements Provider.Get
+func (p *GCSProvider) Get(path string) (io.ReadCloser, error) {
+	return p.GetRange(path, 0, -1)
+}
+
+// GetRange implements Provider.GetRange with a ranged read, to the end of the
+// file for a negative length
+func (p *GCSProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := p.bucket.Object(path).NewRangeReader(context.TODO(), offset, length)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil, fmt.Errorf("file not found: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from GCS: %v", err)
+	}
+	return reader, nil
+}
+
+// List implements Provider.List with the pages of the objects of the bucket
+func (p *GCSProvider) List(prefix string) ([]Object, error) {
+	var objects []Object
+	query := &gcs.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "Updated"}); err != nil {
+		return nil, err
+	}
+	it := p.bucket.Objects(context.TODO(), query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of GCS bucket %s: %v", p.name, err)
+		}
+		objects = append(objects, Object{Path: attrs.Name, Size: attrs.Size, ModTime: attrs.Updated})
+	}
+	return objects, nil
+}
+
+// Stat implements Provider.Stat with the attributes of the object
+func (p *GCSProvider) Stat(path string) (Object, error) {
+	attrs, err := p.bucket.Object(path).Attrs(context.TODO())
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat file in GCS: %v", err)
+	}
+	return Object{Path: path, Size: attrs.Size, ModTime: attrs.Updated}, nil
+}
+
+// PresignUpload implements DirectUploader with a V4 signed URL. Signing needs
+// a service account, user credentials and emulators send uploads in chunks.
+func (p *GCSProvider) PresignUpload(filename, mimeType string, size int64, expires time.Duration) (*DirectUpload, error) {
+	if p.anonymous {
+		return nil, ErrNoDirectUpload
+	}
+
+	key, err := uploadKey(filename)
+	if err != nil {
+		return nil, err
+	}
+	signedURL, err := p.bucket.SignedURL(key, &gcs.SignedURLOptions{
+		Method:      http.MethodPut,
+		ContentType: mimeType,
+		Expires:     time.Now().Add(expires),
+		Scheme:      gcs.SigningSchemeV4,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoDirectUpload, err)
+	}
+
+	return &DirectUpload{
+		Path:    key,
+		URL:     signedURL,
+		Headers: map[string]string{"Content-Type": mimeType},
+	}, nil
+}
+
+// PresignGet implements URLSigner with a V4 signed URL, serving the file with
+// its type. Like uploads, it needs credentials able to sign.
+func (p *GCSProvider) PresignGet(path, mimeType string, expires time.Duration) (string, error) {
+	if p.anonymous {
+		return "", ErrNoPresignedURL
+	}
+
+	signedURL, err := p.bucket.SignedURL(path, &gcs.SignedURLOptions{
+		Method:          http.MethodGet,
+		Expires:         time.Now().Add(expires),
+		Scheme:          gcs.SigningSchemeV4,
+		QueryParameters: url.Values{"response-content-type": {mimeType}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoPresignedURL, err)
+	}
+	return signedURL, nil
+}
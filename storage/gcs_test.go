@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGCSProvider returns a provider of fake-gcs-server, started with
+// CAPTAIN_TEST_GCS_ENDPOINT=http://127.0.0.1:4443/storage/v1/
+func newTestGCSProvider(t *testing.T) *GCSProvider {
+	endpoint := os.Getenv("CAPTAIN_TEST_GCS_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CAPTAIN_TEST_GCS_ENDPOINT is not set")
+	}
+	provider, err := NewGCSProvider("captain-test", "", endpoint)
+	require.NoError(t, err)
+	err = provider.bucket.Create(context.TODO(), "captain", &gcs.BucketAttrs{})
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 409 {
+		require.NoError(t, err)
+	}
+	return provider
+}
+
+func TestGCSProvider(t *testing.T) {
+	provider := newTestGCSProvider(t)
+	testProvider(t, provider)
+
+	// Emulators cannot sign URLs, uploads go through Captain
+	_, err := provider.PresignUpload("clip.mp4", "video/mp4", 5, time.Hour)
+	assert.ErrorIs(t, err, ErrNoDirectUpload)
+	_, err = provider.PresignGet("clip.mp4", "video/mp4", time.Hour)
+	assert.ErrorIs(t, err, ErrNoPresignedURL)
+}
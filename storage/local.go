@@ -3,8 +3,10 @@ package storage
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LocalProvider implements Provider interface for local filesystem storage
@@ -45,6 +47,12 @@ func (p *LocalProvider) Save(filename string, reader io.Reader) (string, error)
 	return filename, nil
 }
 
+// SaveAt implements PathSaver.SaveAt, files keeping their name
+func (p *LocalProvider) SaveAt(path string, reader io.Reader) error {
+	_, err := p.Save(path, reader)
+	return err
+}
+
 // Delete implements Provider.Delete
 func (p *LocalProvider) Delete(path string) error {
 	fullPath := filepath.Join(p.baseDir, path)
@@ -63,3 +71,56 @@ func (p *LocalProvider) Get(path string) (io.ReadCloser, error) {
 	}
 	return file, nil
 }
+
+// GetRange implements Provider.GetRange
+func (p *LocalProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	fullPath := filepath.Join(p.baseDir, path)
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+// List implements Provider.List by walking the storage directory
+func (p *LocalProvider) List(prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(p.baseDir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(p.baseDir, fullPath)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(rel)
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return objects, nil
+}
+
+// Stat implements Provider.Stat
+func (p *LocalProvider) Stat(path string) (Object, error) {
+	info, err := os.Stat(filepath.Join(p.baseDir, path))
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return Object{Path: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, reader io.ReadCloser) string {
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func objectPaths(objects []Object) []string {
+	var paths []string
+	for _, object := range objects {
+		paths = append(paths, object.Path)
+	}
+	return paths
+}
+
+// testProvider checks the behaviour every provider shares
+func testProvider(t *testing.T, provider Provider) {
+	path, err := provider.Save("photos/hello.txt", strings.NewReader("hello world"))
+	require.NoError(t, err)
+	other, err := provider.Save("other.txt", strings.NewReader("other"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		provider.Delete(path)
+		provider.Delete(other)
+	})
+
+	reader, err := provider.Get(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", readAll(t, reader))
+
+	reader, err = provider.GetRange(path, 6, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "world", readAll(t, reader))
+
+	object, err := provider.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, object.Path)
+	assert.Equal(t, int64(11), object.Size)
+	assert.WithinDuration(t, time.Now(), object.ModTime, time.Minute)
+
+	objects, err := provider.List("")
+	require.NoError(t, err)
+	assert.Subset(t, objectPaths(objects), []string{path, other})
+	objects, err = provider.List(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, objectPaths(objects))
+	assert.Equal(t, int64(11), objects[0].Size)
+	objects, err = provider.List("missing/")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	require.NoError(t, provider.Delete(path))
+	_, err = provider.Stat(path)
+	assert.Error(t, err)
+	_, err = provider.Get(path)
+	assert.Error(t, err)
+	// Deleting a missing file is not an error
+	assert.NoError(t, provider.Delete(path))
+}
+
+func TestLocalProvider(t *testing.T) {
+	provider, err := NewLocalProvider(t.TempDir())
+	require.NoError(t, err)
+	testProvider(t, provider)
+}
@@ -2,10 +2,14 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -57,31 +61,56 @@ func NewS3Provider(bucket, region, endpoint, access_key, secret_key string) (*S3
 	}, nil
 }
 
-// Save implements Provider.Save
-func (p *S3Provider) Save(filename string, reader io.Reader) (string, error) {
-	// Generate unique filename with slugified name
+// objectKey returns a unique key for a file, with its slugified name
+func objectKey(filename string) string {
 	ext := filepath.Ext(filename)
 	name := filename[:len(filename)-len(ext)]
-	filename = fmt.Sprintf("%d-%s%s", time.Now().Unix(), slugify(name), ext)
+	return fmt.Sprintf("%d-%s%s", time.Now().Unix(), slugify(name), ext)
+}
+
+// uploadKey returns a unique key for a file sent straight to the storage. The
+// key is chosen when the upload starts and written once the browser sends the
+// file, so a random part keeps apart the uploads of the same name started in
+// the same second.
+func uploadKey(filename string) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(filename)
+	name := filename[:len(filename)-len(ext)]
+	return fmt.Sprintf("%d-%s-%s%s", time.Now().Unix(), hex.EncodeToString(b), slugify(name), ext), nil
+}
+
+// Save implements Provider.Save
+func (p *S3Provider) Save(filename string, reader io.Reader) (string, error) {
+	filename = objectKey(filename)
+	if err := p.SaveAt(filename, reader); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
 
+// SaveAt implements PathSaver.SaveAt
+func (p *S3Provider) SaveAt(path string, reader io.Reader) error {
 	// Upload to S3
 	_, err := p.client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket: aws.String(p.bucket),
-		Key:    aws.String(filename),
+		Key:    aws.String(path),
 		Body:   reader,
 	})
 	if err != nil {
 		var ae smithy.APIError
 		if errors.As(err, &ae) {
-			fmt.Printf("Failed to upload file %s to S3: %s (%s)\n", filename, ae.ErrorMessage(), ae.ErrorCode())
+			fmt.Printf("Failed to upload file %s to S3: %s (%s)\n", path, ae.ErrorMessage(), ae.ErrorCode())
 		} else {
-			fmt.Printf("Failed to upload file %s to S3: %v\n", filename, err)
+			fmt.Printf("Failed to upload file %s to S3: %v\n", path, err)
 		}
-		return "", fmt.Errorf("failed to upload file to S3: %v", err)
+		return fmt.Errorf("failed to upload file to S3: %v", err)
 	}
 
-	fmt.Printf("Successfully uploaded file %s to S3 bucket %s\n", filename, p.bucket)
-	return filename, nil
+	fmt.Printf("Successfully uploaded file %s to S3 bucket %s\n", path, p.bucket)
+	return nil
 }
 
 // Delete implements Provider.Delete
@@ -125,3 +154,122 @@ func (p *S3Provider) Get(path string) (io.ReadCloser, error) {
 
 	return result.Body, nil
 }
+
+// GetRange implements Provider.GetRange with a ranged GetObject
+func (p *S3Provider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	result, err := p.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(path),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) {
+			fmt.Printf("Failed to get range of file %s from S3: %s (%s)\n", path, ae.ErrorMessage(), ae.ErrorCode())
+			if ae.ErrorCode() == "NoSuchKey" {
+				return nil, fmt.Errorf("file not found: %s", path)
+			}
+		} else {
+			fmt.Printf("Failed to get range of file %s from S3: %v\n", path, err)
+		}
+		return nil, fmt.Errorf("failed to get range of file from S3: %v", err)
+	}
+
+	return result.Body, nil
+}
+
+// List implements Provider.List with the pages of ListObjectsV2
+func (p *S3Provider) List(prefix string) ([]Object, error) {
+	var objects []Object
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			var ae smithy.APIError
+			if errors.As(err, &ae) {
+				fmt.Printf("Failed to list files of S3 bucket %s: %s (%s)\n", p.bucket, ae.ErrorMessage(), ae.ErrorCode())
+			} else {
+				fmt.Printf("Failed to list files of S3 bucket %s: %v\n", p.bucket, err)
+			}
+			return nil, fmt.Errorf("failed to list files of S3 bucket: %v", err)
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, Object{
+				Path:    aws.ToString(object.Key),
+				Size:    aws.ToInt64(object.Size),
+				ModTime: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// Stat implements Provider.Stat with HeadObject
+func (p *S3Provider) Stat(path string) (Object, error) {
+	result, err := p.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		var ae smithy.APIError
+		if errors.As(err, &ae) {
+			fmt.Printf("Failed to stat file %s in S3: %s (%s)\n", path, ae.ErrorMessage(), ae.ErrorCode())
+		} else {
+			fmt.Printf("Failed to stat file %s in S3: %v\n", path, err)
+		}
+		return Object{}, fmt.Errorf("failed to stat file in S3: %v", err)
+	}
+
+	return Object{
+		Path:    path,
+		Size:    aws.ToInt64(result.ContentLength),
+		ModTime: aws.ToTime(result.LastModified),
+	}, nil
+}
+
+// PresignUpload implements DirectUploader with a presigned PutObject, signed
+// for the size and type of the file
+func (p *S3Provider) PresignUpload(filename, mimeType string, size int64, expires time.Duration) (*DirectUpload, error) {
+	key, err := uploadKey(filename)
+	if err != nil {
+		return nil, err
+	}
+	request, err := s3.NewPresignClient(p.client).PresignPutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(p.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(mimeType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload to S3: %v", err)
+	}
+
+	// Browsers set the host and length of requests themselves
+	headers := make(map[string]string)
+	for name, values := range request.SignedHeader {
+		switch http.CanonicalHeaderKey(name) {
+		case "Host", "Content-Length":
+		default:
+			headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ",")
+		}
+	}
+
+	return &DirectUpload{Path: key, URL: request.URL, Headers: headers}, nil
+}
+
+// PresignGet implements URLSigner with a presigned GetObject, serving the file
+// with its type
+func (p *S3Provider) PresignGet(path, mimeType string, expires time.Duration) (string, error) {
+	request, err := s3.NewPresignClient(p.client).PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket:              aws.String(p.bucket),
+		Key:                 aws.String(path),
+		ResponseContentType: aws.String(mimeType),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign download from S3: %v", err)
+	}
+	return request.URL, nil
+}
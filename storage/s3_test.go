@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Provider_PresignGet(t *testing.T) {
+	// Signing does not send requests
+	provider, err := NewS3Provider("captain", "us-east-1", "https://s3.example.com", "access", "secret")
+	require.NoError(t, err)
+
+	signedURL, err := provider.PresignGet("photos/a b.png", "image/png", time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "captain.s3.example.com", u.Host)
+	assert.Equal(t, "/photos/a%20b.png", u.EscapedPath())
+	assert.Equal(t, "image/png", u.Query().Get("response-content-type"))
+	assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestS3Provider_PresignUpload(t *testing.T) {
+	provider, err := NewS3Provider("captain", "us-east-1", "https://s3.example.com", "access", "secret")
+	require.NoError(t, err)
+
+	first, err := provider.PresignUpload("My Clip.mp4", "video/mp4", 5, time.Hour)
+	require.NoError(t, err)
+	second, err := provider.PresignUpload("My Clip.mp4", "video/mp4", 5, time.Hour)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^\d+-[0-9a-f]{8}-my-clip\.mp4$`, first.Path)
+	assert.NotEqual(t, first.Path, second.Path, "uploads of the same name do not overwrite each other")
+	assert.Equal(t, "video/mp4", first.Headers["Content-Type"])
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/captain-corp/captain/config"
 )
@@ -17,6 +21,106 @@ type Provider interface {
 
 	// Get retrieves a file and returns a ReadCloser and any error
 	Get(path string) (io.ReadCloser, error)
+
+	// GetRange retrieves length bytes of a file from offset, without reading
+	// the rest of the file
+	GetRange(path string, offset, length int64) (io.ReadCloser, error)
+
+	// List returns the files whose path starts with prefix, all the files for
+	// an empty prefix
+	List(prefix string) ([]Object, error)
+
+	// Stat returns the size and modification time of a file
+	Stat(path string) (Object, error)
+}
+
+// DirectUpload is an upload sent by browsers straight to the storage provider
+type DirectUpload struct {
+	Path    string            // of the file once uploaded
+	URL     string            // the file is sent to with a PUT request
+	Headers map[string]string // to send with the request
+}
+
+// ErrNoDirectUpload is returned by direct uploaders whose credentials cannot
+// presign uploads, which are then sent through Captain
+var ErrNoDirectUpload = errors.New("direct uploads are not available")
+
+// DirectUploader is implemented by the providers receiving the uploads straight
+// from browsers, so large files do not go through Captain
+type DirectUploader interface {
+	// PresignUpload returns the URL a file of size bytes of mimeType is
+	// uploaded to, valid for expires
+	PresignUpload(filename, mimeType string, size int64, expires time.Duration) (*DirectUpload, error)
+}
+
+// AsDirectUploader returns the direct uploader of a provider, when it has one
+func AsDirectUploader(provider Provider) (DirectUploader, bool) {
+	uploader, ok := unwrap(provider).(DirectUploader)
+	return uploader, ok
+}
+
+// PathSaver is implemented by the providers able to store a file at a given
+// path, so files keep their path when they are moved from another provider
+type PathSaver interface {
+	// SaveAt stores a file from a reader at path, replacing the file there
+	SaveAt(path string, reader io.Reader) error
+}
+
+// AsPathSaver returns the path saver of a provider, when it has one. The cache
+// of the provider saves the files, evicting the ones written again.
+func AsPathSaver(provider Provider) (PathSaver, bool) {
+	if _, ok := unwrap(provider).(PathSaver); !ok {
+		return nil, false
+	}
+	for {
+		switch p := provider.(type) {
+		case *Storage:
+			provider = p.Provider
+		case *CacheProvider:
+			return p, true
+		default:
+			return p.(PathSaver), true
+		}
+	}
+}
+
+// ErrNoPresignedURL is returned by URL signers whose credentials cannot sign
+// URLs, the files are then served by Captain
+var ErrNoPresignedURL = errors.New("presigned URLs are not available")
+
+// URLSigner is implemented by the providers serving files at URLs of their
+// own, so browsers download media without going through Captain
+type URLSigner interface {
+	// PresignGet returns the URL a file is downloaded from, served as
+	// mimeType, valid for expires
+	PresignGet(path, mimeType string, expires time.Duration) (string, error)
+}
+
+// AsURLSigner returns the URL signer of a provider, when it has one
+func AsURLSigner(provider Provider) (URLSigner, bool) {
+	signer, ok := unwrap(provider).(URLSigner)
+	return signer, ok
+}
+
+// unwrap returns the provider storing the files, under its name and cache
+func unwrap(provider Provider) Provider {
+	for {
+		switch p := provider.(type) {
+		case *Storage:
+			provider = p.Provider
+		case *CacheProvider:
+			provider = p.Provider
+		default:
+			return provider
+		}
+	}
+}
+
+// Object describes a file of a storage provider
+type Object struct {
+	Path    string // as returned by Save, with forward slashes
+	Size    int64
+	ModTime time.Time
 }
 
 // Storage wraps a Provider with its name
@@ -25,14 +129,53 @@ type Storage struct {
 	Provider
 }
 
+// NewStorage creates the selected storage provider, behind a cache on the
+// local disk when one is configured for a remote provider
 func NewStorage(cfg *config.Config) (*Storage, error) {
+	name := cfg.Storage.Provider
+	provider, err := NewProvider(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := cfg.Storage.Cache
+	if cache.MaxSize > 0 && name != "" && name != "local" {
+		dir := cache.Dir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "captain-cache")
+		}
+		provider, err = NewCacheProvider(provider, dir, int64(cache.MaxSize)*1024*1024, int64(cache.MaxFileSize)*1024*1024)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize storage cache: %w", err)
+		}
+	}
+
+	return &Storage{
+		name:     name,
+		Provider: provider,
+	}, nil
+}
+
+// NewProvider creates a storage provider from its configuration, whether it
+// is the selected one or not, so files can be moved between providers
+func NewProvider(cfg *config.Config, name string) (Provider, error) {
 	var provider Provider
 	var err error
-	name := cfg.Storage.Provider
 
 	switch name {
 	case "s3":
 		provider, err = NewS3Provider(cfg.Storage.S3.Bucket, cfg.Storage.S3.Region, cfg.Storage.S3.Endpoint, cfg.Storage.S3.AccessKey, cfg.Storage.S3.SecretKey)
+	case "azure":
+		provider, err = NewAzureProvider(cfg.Storage.Azure.AccountName, cfg.Storage.Azure.AccountKey, cfg.Storage.Azure.Container, cfg.Storage.Azure.Endpoint)
+	case "gcs":
+		gcs := cfg.Storage.GCS
+		if gcs.API == "xml" {
+			provider, err = NewGCSXMLProvider(gcs.Bucket, gcs.Endpoint, gcs.AccessKey, gcs.SecretKey)
+		} else {
+			provider, err = NewGCSProvider(gcs.Bucket, gcs.CredentialsFile, gcs.Endpoint)
+		}
+	case "webdav":
+		provider, err = NewWebDAVProvider(cfg.Storage.WebDAV.URL, cfg.Storage.WebDAV.Username, cfg.Storage.WebDAV.Password)
 	default:
 		provider, err = NewLocalProvider(cfg.Storage.LocalPath)
 	}
@@ -40,9 +183,5 @@ func NewStorage(cfg *config.Config) (*Storage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage provider: %w", err)
 	}
-
-	return &Storage{
-		name:     name,
-		Provider: provider,
-	}, nil
+	return provider, nil
 }
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// propfindBody requests the properties of the files listed by PROPFIND
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// webdavTimeout bounds the connection to the WebDAV server and the wait for its
+// responses. The transfer of the files themselves is not bounded, as large
+// media take long to upload and download.
+const webdavTimeout = 60 * time.Second
+
+// WebDAVProvider implements Provider interface for a directory of a WebDAV
+// server. Files keep their name, as with local storage.
+type WebDAVProvider struct {
+	client   *http.Client
+	base     *url.URL
+	username string
+	password string
+}
+
+// davMultistatus is the response of PROPFIND requests
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davEntry is a file or directory listed by PROPFIND
+type davEntry struct {
+	Path string // relative to the base URL, with a trailing slash for directories
+	Object
+}
+
+// NewWebDAVProvider creates a new WebDAVProvider storing the files in the
+// directory of rawURL, with basic authentication when username is set
+func NewWebDAVProvider(rawURL, username, password string) (*WebDAVProvider, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebDAV URL: %v", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+
+	return &WebDAVProvider{
+		client:   &http.Client{Transport: newWebDAVTransport(webdavTimeout)},
+		base:     base,
+		username: username,
+		password: password,
+	}, nil
+}
+
+func newWebDAVTransport(timeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return transport
+}
+
+// Save implements Provider.Save, creating the directories of the file
+func (p *WebDAVProvider) Save(filename string, reader io.Reader) (string, error) {
+	filename = strings.TrimPrefix(path.Clean("/"+filename), "/")
+
+	if err := p.mkdirAll(path.Dir(filename)); err != nil {
+		return "", err
+	}
+
+	resp, err := p.do(http.MethodPut, filename, reader, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to WebDAV: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload file to WebDAV: %s", resp.Status)
+	}
+	return filename, nil
+}
+
+// SaveAt implements PathSaver.SaveAt, files keeping their name
+func (p *WebDAVProvider) SaveAt(path string, reader io.Reader) error {
+	_, err := p.Save(path, reader)
+	return err
+}
+
+// Delete implements Provider.Delete
+func (p *WebDAVProvider) Delete(path string) error {
+	resp, err := p.do(http.MethodDelete, path, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete file from WebDAV: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete file from WebDAV: %s", resp.Status)
+	}
+	return nil
+}
+
+// Get implements Provider.Get
+func (p *WebDAVProvider) Get(path string) (io.ReadCloser, error) {
+	resp, err := p.do(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from WebDAV: %v", err)
+	}
+	if err := checkGet(resp, path, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetRange implements Provider.GetRange with a Range request. The start of
+// the file is skipped when the server ignores the range.
+func (p *WebDAVProvider) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := p.do(http.MethodGet, path, nil, map[string]string{
+		"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get range of file from WebDAV: %v", err)
+	}
+	if err := checkGet(resp, path, http.StatusOK, http.StatusPartialContent); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to get range of file from WebDAV: %v", err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, length), resp.Body}, nil
+}
+
+// List implements Provider.List by walking the directories holding prefix
+func (p *WebDAVProvider) List(prefix string) ([]Object, error) {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i+1]
+	}
+
+	var objects []Object
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := p.propfind(dir, "1")
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch {
+			case entry.Path == dir:
+			case strings.HasSuffix(entry.Path, "/"):
+				if strings.HasPrefix(entry.Path, prefix) || strings.HasPrefix(prefix, entry.Path) {
+					if err := walk(entry.Path); err != nil {
+						return err
+					}
+				}
+			case strings.HasPrefix(entry.Path, prefix):
+				objects = append(objects, entry.Object)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(dir); err != nil {
+		if _, missing := err.(notFoundError); missing {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list files of WebDAV: %v", err)
+	}
+	return objects, nil
+}
+
+// Stat implements Provider.Stat with the properties of the file
+func (p *WebDAVProvider) Stat(path string) (Object, error) {
+	entries, err := p.propfind(path, "0")
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat file in WebDAV: %v", err)
+	}
+	if len(entries) != 1 || strings.HasSuffix(entries[0].Path, "/") {
+		return Object{}, fmt.Errorf("failed to stat file in WebDAV: not a file: %s", path)
+	}
+	return entries[0].Object, nil
+}
+
+// notFoundError is returned by propfind for missing files
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return "file not found: " + string(e)
+}
+
+// propfind lists a file, or a directory with its content when depth is 1
+func (p *WebDAVProvider) propfind(filePath, depth string) ([]davEntry, error) {
+	resp, err := p.do("PROPFIND", filePath, strings.NewReader(propfindBody), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, notFoundError(filePath)
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s: %s", filePath, resp.Status)
+	}
+
+	var multistatus davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("invalid PROPFIND response: %v", err)
+	}
+
+	var entries []davEntry
+	for _, response := range multistatus.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROPFIND response: %v", err)
+		}
+		hrefPath := href.Path
+		if hrefPath+"/" == p.base.Path {
+			hrefPath = p.base.Path
+		}
+		rel, ok := strings.CutPrefix(hrefPath, p.base.Path)
+		if !ok {
+			continue
+		}
+
+		for _, propstat := range response.Propstats {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			entry := davEntry{Path: rel}
+			if prop.ResourceType.Collection != nil && rel != "" && !strings.HasSuffix(rel, "/") {
+				entry.Path += "/"
+			}
+			entry.Object.Path = strings.TrimSuffix(entry.Path, "/")
+			entry.Size, _ = strconv.ParseInt(prop.ContentLength, 10, 64)
+			entry.ModTime, _ = http.ParseTime(prop.LastModified)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// mkdirAll creates a directory and its parents, the ones already there are
+// answered with 405 Method Not Allowed
+func (p *WebDAVProvider) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+	current := ""
+	for _, name := range strings.Split(dir, "/") {
+		current += name + "/"
+		resp, err := p.do("MKCOL", current, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create directory %s in WebDAV: %v", current, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create directory %s in WebDAV: %s", current, resp.Status)
+		}
+	}
+	return nil
+}
+
+// do sends a request for a file, relative to the base URL
+func (p *WebDAVProvider) do(method, filePath string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	target := p.base.JoinPath(filePath)
+	if strings.HasSuffix(filePath, "/") && !strings.HasSuffix(target.Path, "/") {
+		target.Path += "/"
+	}
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	return p.client.Do(req)
+}
+
+// checkGet closes the body of a GET response without one of the expected
+// statuses
+func checkGet(resp *http.Response, path string, statuses ...int) error {
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("file not found: %s", path)
+	}
+	return fmt.Errorf("failed to get file from WebDAV: %s", resp.Status)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer serves a temporary directory under /dav/, with basic
+// authentication
+func newWebDAVServer(t *testing.T) *httptest.Server {
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.Dir(t.TempDir()),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "captain" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebDAVProvider(t *testing.T) {
+	server := newWebDAVServer(t)
+	provider, err := NewWebDAVProvider(server.URL+"/dav", "captain", "secret")
+	require.NoError(t, err)
+	testProvider(t, provider)
+
+	// Files keep their name, in nested directories
+	path, err := provider.Save("_variants/photos/my photo-320x0.jpg", strings.NewReader("variant"))
+	require.NoError(t, err)
+	assert.Equal(t, "_variants/photos/my photo-320x0.jpg", path)
+	objects, err := provider.List("_variants/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, objectPaths(objects))
+	objects, err = provider.List("_variants/photos/my")
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, objectPaths(objects))
+
+	_, err = provider.Stat("_variants/photos")
+	assert.Error(t, err, "directories are not files")
+
+	provider, err = NewWebDAVProvider(server.URL+"/dav/", "captain", "wrong")
+	require.NoError(t, err)
+	_, err = provider.Save("hello.txt", strings.NewReader("hello"))
+	assert.Error(t, err)
+}
+
+func TestWebDAVProvider_Timeout(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	provider, err := NewWebDAVProvider(server.URL, "", "")
+	require.NoError(t, err)
+	provider.client.Transport = newWebDAVTransport(100 * time.Millisecond)
+	start := time.Now()
+	_, err = provider.Get("hello.txt")
+	assert.Error(t, err, "servers not answering are given up")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
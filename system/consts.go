@@ -1,5 +1,7 @@
 package system
 
+import "time"
+
 const (
 	// FaviconSize is the size of the favicon.ico file
 	FaviconSize = 32
@@ -15,3 +17,36 @@ const (
 	FaviconSvgFilename     = "favicon.svg"
 	FaviconPngFilename     = "favicon.png"
 )
+
+const (
+	// FeedItemsLimit is the maximum number of posts included in RSS and Atom feeds
+	FeedItemsLimit = 20
+	// SitemapMaxURLs is the maximum number of URLs allowed in a single sitemap file
+	SitemapMaxURLs = 50000
+)
+
+const (
+	// APIDefaultPerPage is the number of items returned by REST API list endpoints by default
+	APIDefaultPerPage = 20
+	// APIMaxPerPage is the maximum number of items REST API list endpoints may return
+	APIMaxPerPage = 100
+)
+
+const (
+	// PreviewLinkTTL is how long signed preview links of unpublished content remain valid
+	PreviewLinkTTL = 7 * 24 * time.Hour
+)
+
+const (
+	// RecoveryCodesCount is the number of recovery codes generated when enabling two-factor authentication
+	RecoveryCodesCount = 10
+	// TwoFactorLoginTTL is how long a user has to enter their code after the password step
+	TwoFactorLoginTTL = 5 * time.Minute
+	// TwoFactorMaxAttempts is the number of wrong codes accepted before the login starts over
+	TwoFactorMaxAttempts = 5
+)
+
+const (
+	// LoginActivityPerPage is the number of login attempts displayed per page in the admin
+	LoginActivityPerPage = 50
+)
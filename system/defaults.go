@@ -6,4 +6,5 @@ const (
 	DefaultChromaStyle  = "paraiso-dark"
 	DefaultPostsPerPage = 10
 	DefaultTheme        = "light"
+	DefaultRobotsTxt    = "User-agent: *\nDisallow: /admin\n"
 )
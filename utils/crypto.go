@@ -1,6 +1,14 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,3 +23,71 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// APITokenPrefix is prepended to generated API tokens to make them easy to recognize
+const APITokenPrefix = "cpt_"
+
+// GenerateAPIToken returns a new random API token
+func GenerateAPIToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + hex.EncodeToString(b), nil
+}
+
+// HashAPIToken returns the SHA-256 hash of an API token, as stored in the database
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateCSRFToken returns a new random token protecting a session against cross-site requests
+func GenerateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GeneratePreviewSecret returns a new random secret used to sign preview links
+func GeneratePreviewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignPreviewToken returns a token granting access to the subject until expiresAt.
+// The token has the form "<unix expiry>.<hex HMAC-SHA256>".
+func SignPreviewToken(secret, subject string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expiry + "." + previewSignature(secret, subject, expiry)
+}
+
+// VerifyPreviewToken returns true if the token was signed for the subject and has not expired
+func VerifyPreviewToken(secret, subject, token string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+
+	expiry, signature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(previewSignature(secret, subject, expiry)))
+}
+
+func previewSignature(secret, subject, expiry string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(subject + "|" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
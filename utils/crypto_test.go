@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -35,3 +37,77 @@ func TestCheckPasswordHash(t *testing.T) {
 		t.Error("CheckPasswordHash should return false for wrong password")
 	}
 }
+
+func TestGenerateCSRFToken(t *testing.T) {
+	token, err := GenerateCSRFToken()
+	if err != nil {
+		t.Fatalf("GenerateCSRFToken failed: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("GenerateCSRFToken should return 64 hex characters, got %q", token)
+	}
+
+	other, _ := GenerateCSRFToken()
+	if token == other {
+		t.Error("GenerateCSRFToken should not return the same token twice")
+	}
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	token, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		t.Errorf("GenerateAPIToken should start with %q, got %q", APITokenPrefix, token)
+	}
+
+	other, _ := GenerateAPIToken()
+	if token == other {
+		t.Error("GenerateAPIToken should not return the same token twice")
+	}
+}
+
+func TestHashAPIToken(t *testing.T) {
+	hash := HashAPIToken("cpt_token")
+	if hash == "cpt_token" || len(hash) != 64 {
+		t.Errorf("HashAPIToken should return a hex encoded SHA-256, got %q", hash)
+	}
+	if hash != HashAPIToken("cpt_token") {
+		t.Error("HashAPIToken should be deterministic")
+	}
+	if hash == HashAPIToken("cpt_other") {
+		t.Error("HashAPIToken should differ for different tokens")
+	}
+}
+
+func TestPreviewToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	token := SignPreviewToken("secret", "post:1", now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		secret  string
+		subject string
+		token   string
+		now     time.Time
+		want    bool
+	}{
+		{"valid", "secret", "post:1", token, now, true},
+		{"expired", "secret", "post:1", token, now.Add(2 * time.Hour), false},
+		{"other subject", "secret", "post:2", token, now, false},
+		{"other secret", "rotated", "post:1", token, now, false},
+		{"empty secret", "", "post:1", SignPreviewToken("", "post:1", now.Add(time.Hour)), now, false},
+		{"tampered expiry", "secret", "post:1", "9999999999" + token[strings.Index(token, "."):], now, false},
+		{"malformed", "secret", "post:1", "garbage", now, false},
+		{"empty", "secret", "post:1", "", now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPreviewToken(tt.secret, tt.subject, tt.token, tt.now); got != tt.want {
+				t.Errorf("VerifyPreviewToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strings"
+)
+
+// DiffOp is the kind of change a diff line represents
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffInsert
+	DiffDelete
+)
+
+// DiffLine is a single line of a line-level diff
+type DiffLine struct {
+	Op      DiffOp
+	Text    string
+	OldLine int // line number in the old text, 0 for inserted lines
+	NewLine int // line number in the new text, 0 for deleted lines
+}
+
+// IsInsert returns true if the line only exists in the new text
+func (l DiffLine) IsInsert() bool {
+	return l.Op == DiffInsert
+}
+
+// IsDelete returns true if the line only exists in the old text
+func (l DiffLine) IsDelete() bool {
+	return l.Op == DiffDelete
+}
+
+// DiffLines computes a line-level diff between two texts using the longest common subsequence
+func DiffLines(oldText, newText string) []DiffLine {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// Skip the common prefix and suffix to keep the LCS table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] holds the LCS length of midA[i:] and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]DiffLine, 0, len(a)+len(b))
+	oldLine, newLine := 0, 0
+	equal := func(text string) {
+		oldLine++
+		newLine++
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: text, OldLine: oldLine, NewLine: newLine})
+	}
+
+	for _, text := range a[:prefix] {
+		equal(text)
+	}
+
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			equal(midA[i])
+			i++
+			j++
+		case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+			newLine++
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: midB[j], NewLine: newLine})
+			j++
+		default:
+			oldLine++
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: midA[i], OldLine: oldLine})
+			i++
+		}
+	}
+
+	for _, text := range a[len(a)-suffix:] {
+		equal(text)
+	}
+
+	return lines
+}
+
+// DiffChanged returns true if the diff contains at least one inserted or deleted line
+func DiffChanged(lines []DiffLine) bool {
+	for _, line := range lines {
+		if line.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
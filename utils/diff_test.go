@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+)
+
+func diffString(lines []DiffLine) string {
+	var out string
+	for _, line := range lines {
+		switch line.Op {
+		case DiffInsert:
+			out += "+" + line.Text + "\n"
+		case DiffDelete:
+			out += "-" + line.Text + "\n"
+		default:
+			out += " " + line.Text + "\n"
+		}
+	}
+	return out
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+		changed  bool
+	}{
+		{"Identical", "a\nb\nc", "a\nb\nc", " a\n b\n c\n", false},
+		{"Both empty", "", "", "", false},
+		{"Added to empty", "", "a\nb", "+a\n+b\n", true},
+		{"Removed everything", "a\nb", "", "-a\n-b\n", true},
+		{"Line inserted", "a\nc", "a\nb\nc", " a\n+b\n c\n", true},
+		{"Line deleted", "a\nb\nc", "a\nc", " a\n-b\n c\n", true},
+		{"Line replaced", "a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n", true},
+		{"Windows line endings", "a\r\nb\r\n", "a\nb", " a\n b\n", false},
+		{"Paragraph lost", "intro\n\nbody\n\noutro", "intro\n\noutro", " intro\n \n-body\n-\n outro\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := DiffLines(tt.old, tt.new)
+			if got := diffString(lines); got != tt.expected {
+				t.Errorf("DiffLines() = %q, want %q", got, tt.expected)
+			}
+			if got := DiffChanged(lines); got != tt.changed {
+				t.Errorf("DiffChanged() = %v, want %v", got, tt.changed)
+			}
+		})
+	}
+}
+
+func TestDiffLinesNumbers(t *testing.T) {
+	lines := DiffLines("a\nb\nc", "a\nx\nc")
+
+	expected := []DiffLine{
+		{Op: DiffEqual, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: DiffDelete, Text: "b", OldLine: 2},
+		{Op: DiffInsert, Text: "x", NewLine: 2},
+		{Op: DiffEqual, Text: "c", OldLine: 3, NewLine: 3},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("DiffLines() returned %d lines, want %d", len(lines), len(expected))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], expected[i])
+		}
+	}
+}
@@ -1,12 +1,42 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
-const UNIQUE_CONSTRAINT = 2067
+const (
+	UNIQUE_CONSTRAINT      = 2067    // SQLite
+	PRIMARY_KEY_CONSTRAINT = 1555    // SQLite
+	PG_UNIQUE_VIOLATION    = "23505" // PostgreSQL
+	MYSQL_DUPLICATE_ENTRY  = 1062    // MySQL
+)
 
+// IsConstraintError returns true if the error is a unique constraint violation,
+// whatever the database driver
 func IsConstraintError(err error) bool {
-	castedErr := err.(*sqlite.Error)
-	return castedErr.Code() == UNIQUE_CONSTRAINT
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == UNIQUE_CONSTRAINT || sqliteErr.Code() == PRIMARY_KEY_CONSTRAINT
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == PG_UNIQUE_VIOLATION
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == MYSQL_DUPLICATE_ENTRY
+	}
+
+	return false
 }
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestIsConstraintError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"translated", gorm.ErrDuplicatedKey, true},
+		{"wrapped translated", fmt.Errorf("create: %w", gorm.ErrDuplicatedKey), true},
+		{"postgres unique violation", &pgconn.PgError{Code: PG_UNIQUE_VIOLATION}, true},
+		{"postgres other error", &pgconn.PgError{Code: "23503"}, false},
+		{"mysql duplicate entry", &mysql.MySQLError{Number: MYSQL_DUPLICATE_ENTRY}, true},
+		{"mysql other error", &mysql.MySQLError{Number: 1452}, false},
+		{"other error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConstraintError(tt.err); got != tt.want {
+				t.Errorf("IsConstraintError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
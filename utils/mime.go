@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SniffSize is how much of a file SniffMimeType considers
+const SniffSize = 512
+
+// SniffMimeType returns the MIME type of a file from its first bytes, or an
+// empty string when the content does not tell
+func SniffMimeType(data []byte) string {
+	switch mimeType := BaseMimeType(http.DetectContentType(data)); mimeType {
+	case "application/octet-stream", "application/zip", "text/plain", "text/xml", "text/html":
+		// Office documents, SVG images and text files are not told apart
+		return ""
+	default:
+		return mimeType
+	}
+}
+
+// BaseMimeType returns a MIME type without its parameters, in lower case
+func BaseMimeType(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
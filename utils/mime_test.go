@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestSniffMimeType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"JPEG", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg"},
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"Text", []byte("hello world"), ""},
+		{"SVG", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), ""},
+		{"Office document", []byte("PK\x03\x04\x14\x00\x06\x00"), ""},
+		{"Unknown", []byte{0x00, 0x01, 0x02, 0x03}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffMimeType(tt.data); got != tt.want {
+				t.Errorf("SniffMimeType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseMimeType(t *testing.T) {
+	if got := BaseMimeType(" Text/HTML; charset=utf-8"); got != "text/html" {
+		t.Errorf("BaseMimeType() = %q, want %q", got, "text/html")
+	}
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxRanges is the number of ranges served in a request, beyond which the
+// whole content is sent
+const maxRanges = 32
+
+var (
+	// ErrRangeMalformed is returned for Range headers that are ignored, the
+	// whole content being served instead
+	ErrRangeMalformed = errors.New("malformed range")
+	// ErrRangeUnsatisfiable is returned when no range overlaps the content
+	ErrRangeUnsatisfiable = errors.New("range not satisfiable")
+)
+
+// ByteRange is a range of bytes requested by a Range header
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange returns the Content-Range header of the range of a content of
+// size bytes
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseRange parses a Range header of a content of size bytes, as described
+// by RFC 9110. Ranges beyond the content are dropped and ranges ending past it
+// are shortened. It returns ErrRangeUnsatisfiable when no range is left, and
+// ErrRangeMalformed for headers to ignore, including the ones requesting more
+// bytes than the content holds.
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || strings.TrimSpace(unit) != "bytes" {
+		return nil, ErrRangeMalformed
+	}
+
+	specs := strings.Split(spec, ",")
+	if len(specs) > maxRanges {
+		return nil, ErrRangeMalformed
+	}
+
+	var ranges []ByteRange
+	var total int64
+	for _, s := range specs {
+		s = strings.TrimSpace(s)
+		first, last, ok := strings.Cut(s, "-")
+		if !ok {
+			return nil, ErrRangeMalformed
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var r ByteRange
+		if first == "" {
+			// The last bytes of the content
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrRangeMalformed
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			r = ByteRange{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrRangeMalformed
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, ErrRangeMalformed
+				}
+				end = min(end, size-1)
+			}
+			if start >= size {
+				continue
+			}
+			r = ByteRange{Start: start, Length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+		total += r.Length
+	}
+
+	if len(ranges) == 0 {
+		return nil, ErrRangeUnsatisfiable
+	}
+	// Overlapping ranges could make a small file send a large response
+	if total > size {
+		return nil, ErrRangeMalformed
+	}
+	return ranges, nil
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []ByteRange
+		err    error
+	}{
+		{"First bytes", "bytes=0-99", []ByteRange{{0, 100}}, nil},
+		{"Open end", "bytes=900-", []ByteRange{{900, 100}}, nil},
+		{"Suffix", "bytes=-10", []ByteRange{{990, 10}}, nil},
+		{"Suffix longer than content", "bytes=-5000", []ByteRange{{0, 1000}}, nil},
+		{"End past content", "bytes=500-5000", []ByteRange{{500, 500}}, nil},
+		{"Several", "bytes=0-9, 20-29,-5", []ByteRange{{0, 10}, {20, 10}, {995, 5}}, nil},
+		{"Unsatisfiable dropped", "bytes=0-9,2000-3000", []ByteRange{{0, 10}}, nil},
+		{"Unsatisfiable", "bytes=1000-", nil, ErrRangeUnsatisfiable},
+		{"Empty suffix", "bytes=-0", nil, ErrRangeUnsatisfiable},
+		{"Other unit", "items=0-9", nil, ErrRangeMalformed},
+		{"No equal sign", "bytes 0-9", nil, ErrRangeMalformed},
+		{"No dash", "bytes=10", nil, ErrRangeMalformed},
+		{"Reversed", "bytes=10-5", nil, ErrRangeMalformed},
+		{"Not a number", "bytes=a-5", nil, ErrRangeMalformed},
+		{"Negative", "bytes=--5", nil, ErrRangeMalformed},
+		{"Larger than content", "bytes=0-999,0-999", nil, ErrRangeMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRange(tt.header, 1000)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseRange(%q) error = %v, want %v", tt.header, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRange(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange_EmptyContent(t *testing.T) {
+	if _, err := ParseRange("bytes=0-", 0); !errors.Is(err, ErrRangeUnsatisfiable) {
+		t.Errorf("ParseRange of empty content error = %v, want %v", err, ErrRangeUnsatisfiable)
+	}
+}
+
+func TestByteRange_ContentRange(t *testing.T) {
+	if got := (ByteRange{Start: 10, Length: 20}).ContentRange(1000); got != "bytes 10-29/1000" {
+		t.Errorf("ContentRange() = %q", got)
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPDigits is the number of digits of a TOTP code
+	TOTPDigits = 6
+	// TOTPPeriod is the duration of a TOTP time step
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the number of time steps accepted before and after the current one,
+	// to tolerate clock drift between the server and the authenticator app
+	TOTPSkew = 1
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(b), nil
+}
+
+// TOTPStep returns the RFC 6238 time step containing t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode returns the code of the secret for the given time step (RFC 6238, HMAC-SHA1)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// ValidateTOTP checks a code against the time steps around now. Steps up to
+// lastStep were already used and are rejected to prevent replays.
+// It returns the matching step, to be stored as the new lastStep.
+func ValidateTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI scanned by authenticator apps
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenerateRecoveryCodes returns n random one-time recovery codes, formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the SHA-256 hash of a normalized recovery code, as stored in the database
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode(%d) failed: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode should fail on an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	current := TOTPStep(now)
+	code := func(step int64) string {
+		c, _ := TOTPCode(secret, step)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+	}{
+		{"current step", code(current), 0, true},
+		{"previous step within skew", code(current - 1), 0, true},
+		{"next step within skew", code(current + 1), 0, true},
+		{"outside skew", code(current - 2), 0, false},
+		{"replayed code", code(current), current, false},
+		{"spaces are ignored", code(current)[:3] + " " + code(current)[3:], 0, true},
+		{"wrong length", "12345", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(secret, tt.code, now, tt.lastStep)
+			if ok != tt.want {
+				t.Fatalf("ValidateTOTP() = %v, want %v", ok, tt.want)
+			}
+			if ok && step <= tt.lastStep {
+				t.Errorf("ValidateTOTP() returned step %d not after last step %d", step, tt.lastStep)
+			}
+		})
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("My Blog", "jane@example.com", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/My%20Blog:jane@example.com?") {
+		t.Errorf("unexpected URI label: %s", uri)
+	}
+	for _, param := range []string{"secret=ABC", "issuer=My+Blog", "digits=6", "period=30"} {
+		if !strings.Contains(uri, param) {
+			t.Errorf("URI %s should contain %s", uri, param)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes returned %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected recovery code format: %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	// Hashes ignore case, dashes and spaces
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" ") {
+		t.Error("HashRecoveryCode should normalize the code")
+	}
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Error("HashRecoveryCode should differ for different codes")
+	}
+}